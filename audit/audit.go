@@ -0,0 +1,339 @@
+// Package audit records the commands which change the state of the
+// rotators (set azimuth / elevation, stop, leases), together with the
+// frontend, the client and the heading of the rotator before the
+// command. The records are written to a rotating JSON lines file and
+// can be queried afterwards.
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/dh1tw/remoteRotator/rotator"
+)
+
+// Frontends through which commands are received
+const (
+	REST = "rest"
+	WS   = "ws"
+	TCP  = "tcp"
+	NATS = "nats"
+)
+
+// Record is an entry of the audit log.
+type Record struct {
+	Time            time.Time        `json:"time"`
+	Frontend        string           `json:"frontend"`
+	Client          string           `json:"client,omitempty"` // address of the client
+	User            string           `json:"user,omitempty"`   // user, token or operator name
+	Rotator         string           `json:"rotator"`
+	Command         string           `json:"command"`
+	Azimuth         *int             `json:"azimuth,omitempty"`          // requested azimuth
+	Elevation       *int             `json:"elevation,omitempty"`        // requested elevation
+	Duration        string           `json:"duration,omitempty"`         // requested lease
+	Previous        *rotator.Heading `json:"previous,omitempty"`         // heading before the command
+	Offsets         *Offsets         `json:"offsets,omitempty"`          // new calibration offsets
+	PreviousOffsets *Offsets         `json:"previous_offsets,omitempty"` // calibration offsets before the command
+	Error           string           `json:"error,omitempty"`            // reason why the command failed
+}
+
+// Offsets are the calibration offsets of a rotator's azimuth.
+type Offsets struct {
+	Offset   int `json:"offset"`
+	Mounting int `json:"mounting"`
+}
+
+// Logger writes the audit records to a JSON lines file, which is
+// rotated when it exceeds the maximum size. Without a file, the latest
+// records are only kept in memory.
+type Logger struct {
+	sync.Mutex
+	rotateMu   sync.RWMutex // held by queries while they read the files
+	path       string
+	maxSize    int64
+	maxBackups int
+	backlog    int
+	file       *os.File
+	size       int64
+	recent     []Record
+}
+
+// New returns an initialized Logger. Configuration settings can be set
+// through functional options.
+// Default settings are:
+// file: "" (records are only kept in memory),
+// maxSize: 10MB,
+// maxBackups: 5,
+// backlog: 1000 records (in memory).
+func New(opts ...func(*Logger)) (*Logger, error) {
+
+	l := &Logger{
+		maxSize:    10 << 20,
+		maxBackups: 5,
+		backlog:    1000,
+	}
+
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	if l.path == "" {
+		return l, nil
+	}
+
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// File is a functional option to set the path of the audit log file.
+func File(path string) func(*Logger) {
+	return func(l *Logger) {
+		l.path = path
+	}
+}
+
+// MaxSize is a functional option to set the size (in bytes) after which
+// the audit log file is rotated.
+func MaxSize(size int64) func(*Logger) {
+	return func(l *Logger) {
+		l.maxSize = size
+	}
+}
+
+// MaxBackups is a functional option to set the number of rotated files
+// which are kept (file.1 ... file.n).
+func MaxBackups(n int) func(*Logger) {
+	return func(l *Logger) {
+		l.maxBackups = n
+	}
+}
+
+// Backlog is a functional option to set the number of records kept in
+// memory if no file is configured.
+func Backlog(n int) func(*Logger) {
+	return func(l *Logger) {
+		l.backlog = n
+	}
+}
+
+func (l *Logger) open() error {
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return fmt.Errorf("unable to open audit log: %v", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	l.file = f
+	l.size = info.Size()
+	return nil
+}
+
+// Record writes a record to the audit log. If the time is not set, the
+// current time is used. Errors are logged.
+func (l *Logger) Record(rec Record) {
+	if l == nil {
+		return
+	}
+	if rec.Time.IsZero() {
+		rec.Time = time.Now()
+	}
+
+	l.Lock()
+	defer l.Unlock()
+
+	if l.path == "" {
+		l.recent = append(l.recent, rec)
+		if len(l.recent) > l.backlog {
+			l.recent = l.recent[len(l.recent)-l.backlog:]
+		}
+		return
+	}
+
+	data, err := json.Marshal(rec)
+	if err != nil {
+		log.Printf("unable to serialize audit record: %v\n", err)
+		return
+	}
+	data = append(data, '\n')
+
+	if l.size > 0 && l.size+int64(len(data)) > l.maxSize {
+		l.rotateMu.Lock()
+		if err := l.rotate(); err != nil {
+			log.Printf("unable to rotate audit log: %v\n", err)
+		}
+		l.rotateMu.Unlock()
+	}
+	if l.file == nil {
+		return
+	}
+
+	n, err := l.file.Write(data)
+	l.size += int64(n)
+	if err != nil {
+		log.Printf("unable to write audit log: %v\n", err)
+	}
+}
+
+// rotate renames the audit log file to file.1 (file.1 to file.2, ...)
+// and opens a new file. Must be called with the lock and the rotation
+// lock held.
+func (l *Logger) rotate() error {
+	l.file.Close()
+	l.file = nil
+
+	if l.maxBackups <= 0 {
+		if err := os.Remove(l.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return l.open()
+	}
+
+	os.Remove(l.backup(l.maxBackups))
+	for i := l.maxBackups - 1; i >= 1; i-- {
+		os.Rename(l.backup(i), l.backup(i+1))
+	}
+	if err := os.Rename(l.path, l.backup(1)); err != nil {
+		return err
+	}
+
+	return l.open()
+}
+
+func (l *Logger) backup(i int) string {
+	return fmt.Sprintf("%s.%d", l.path, i)
+}
+
+// Close closes the audit log file.
+func (l *Logger) Close() {
+	if l == nil {
+		return
+	}
+	l.Lock()
+	defer l.Unlock()
+	if l.file != nil {
+		l.file.Close()
+		l.file = nil
+	}
+}
+
+// Query selects records from the audit log. Empty fields match all
+// records.
+type Query struct {
+	Rotator  string
+	Frontend string
+	Client   string
+	User     string
+	Since    time.Time
+	Until    time.Time
+	Limit    int               // only return the latest n records; default: 100
+	Filter   func(Record) bool // optional, additional filter
+}
+
+func (q Query) match(rec Record) bool {
+	switch {
+	case q.Rotator != "" && q.Rotator != rec.Rotator,
+		q.Frontend != "" && q.Frontend != rec.Frontend,
+		q.Client != "" && q.Client != rec.Client,
+		q.User != "" && q.User != rec.User,
+		!q.Since.IsZero() && rec.Time.Before(q.Since),
+		!q.Until.IsZero() && rec.Time.After(q.Until),
+		q.Filter != nil && !q.Filter(rec):
+		return false
+	}
+	return true
+}
+
+// Query returns the latest records matching the query in chronological
+// order. The rotated files are searched as well. The files are read
+// without blocking the recording of new records; only a rotation waits
+// until the query is done.
+func (l *Logger) Query(q Query) ([]Record, error) {
+
+	if q.Limit <= 0 {
+		q.Limit = 100
+	}
+
+	// records written after this point are not returned
+	l.Lock()
+	size := l.size
+	recent := append([]Record{}, l.recent...)
+	l.Unlock()
+
+	records := []Record{}
+	add := func(rec Record) {
+		if !q.match(rec) {
+			return
+		}
+		records = append(records, rec)
+		if len(records) > q.Limit*2 {
+			records = append(records[:0], records[len(records)-q.Limit:]...)
+		}
+	}
+
+	if l.path == "" {
+		for _, rec := range recent {
+			add(rec)
+		}
+	} else {
+		l.rotateMu.RLock()
+		defer l.rotateMu.RUnlock()
+
+		// oldest first
+		for i := l.maxBackups; i >= 1; i-- {
+			if err := readFile(l.backup(i), -1, add); err != nil {
+				return nil, err
+			}
+		}
+		if err := readFile(l.path, size, add); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(records) > q.Limit {
+		records = records[len(records)-q.Limit:]
+	}
+
+	return records, nil
+}
+
+// readFile decodes the records of a JSON lines file up to the size
+// (< 0: the whole file). Missing files and invalid lines are skipped.
+func readFile(path string, size int64, f func(Record)) error {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var rd io.Reader = file
+	if size >= 0 {
+		rd = io.LimitReader(file, size)
+	}
+
+	sc := bufio.NewScanner(rd)
+	sc.Buffer(make([]byte, 64*1024), 1<<20)
+	for sc.Scan() {
+		rec := Record{}
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			continue
+		}
+		f(rec)
+	}
+
+	return sc.Err()
+}
@@ -0,0 +1,171 @@
+package audit
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dh1tw/remoteRotator/rotator/dummy"
+)
+
+func TestQuery(t *testing.T) {
+	l, err := New(Backlog(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	for i := 0; i < 8; i++ {
+		rotator := "rot1"
+		if i%2 == 1 {
+			rotator = "rot2"
+		}
+		l.Record(Record{
+			Time:     start.Add(time.Duration(i) * time.Second),
+			Frontend: WS,
+			Rotator:  rotator,
+			Command:  "stop",
+		})
+	}
+
+	tt := []struct {
+		name  string
+		query Query
+		count int
+	}{
+		{"backlog", Query{}, 5},
+		{"rotator", Query{Rotator: "rot1"}, 2},
+		{"limit", Query{Limit: 2}, 2},
+		{"since", Query{Since: start.Add(6 * time.Second)}, 2},
+		{"until", Query{Until: start.Add(4 * time.Second)}, 2},
+		{"frontend", Query{Frontend: TCP}, 0},
+		{"filter", Query{Filter: func(rec Record) bool { return rec.Rotator == "rot2" }}, 3},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			records, err := l.Query(tc.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(records) != tc.count {
+				t.Fatalf("expected %d records, got %d", tc.count, len(records))
+			}
+		})
+	}
+
+	// the latest records are returned in chronological order
+	records, _ := l.Query(Query{Limit: 2})
+	if !records[1].Time.Equal(start.Add(7*time.Second)) || !records[0].Time.Before(records[1].Time) {
+		t.Fatalf("unexpected records %v", records)
+	}
+}
+
+func TestRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	l, err := New(File(path), MaxSize(400), MaxBackups(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	for i := 0; i < 20; i++ {
+		l.Record(Record{Frontend: REST, Client: "10.0.0.1:1234", Rotator: "rot", Command: "stop"})
+	}
+
+	for _, p := range []string{path, path + ".1", path + ".2"} {
+		info, err := os.Stat(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() > 400 {
+			t.Fatalf("%s exceeds the maximum size (%d bytes)", p, info.Size())
+		}
+	}
+	if _, err := os.Stat(path + ".3"); err == nil {
+		t.Fatal("expected only 2 backups")
+	}
+
+	records, err := l.Query(Query{Limit: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) == 0 || len(records) >= 20 {
+		t.Fatalf("expected the records of the kept files, got %d", len(records))
+	}
+}
+
+func TestConcurrentQuery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	l, err := New(File(path), MaxSize(2000), MaxBackups(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 200; i++ {
+			l.Record(Record{Frontend: REST, Rotator: "rot", Command: "stop"})
+		}
+	}()
+
+	for i := 0; i < 20; i++ {
+		records, err := l.Query(Query{Limit: 1000})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, rec := range records {
+			if rec.Command != "stop" {
+				t.Fatalf("unexpected record %+v", rec)
+			}
+		}
+	}
+	<-done
+}
+
+type refusing struct {
+	*dummy.Dummy
+}
+
+func (r refusing) SetAzimuth(az int) error {
+	return errors.New("locked")
+}
+
+func TestBind(t *testing.T) {
+	d, _ := dummy.New(dummy.Name("rot"))
+	defer d.Close()
+
+	l, _ := New()
+
+	if r := Bind(d, nil, REST, "", ""); r != d {
+		t.Fatal("expected rotator to be returned unchanged without logger")
+	}
+
+	r := Bind(d, l, TCP, "10.0.0.1:4533", "dh1tw")
+	if err := r.SetElevation(10); err != nil {
+		t.Fatal(err)
+	}
+	if err := Bind(refusing{d}, l, REST, "10.0.0.2:80", "").SetAzimuth(90); err == nil {
+		t.Fatal("expected error")
+	}
+
+	records, _ := l.Query(Query{})
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(records))
+	}
+
+	rec := records[0]
+	if rec.Command != "set_elevation" || *rec.Elevation != 10 || rec.Frontend != TCP ||
+		rec.User != "dh1tw" || rec.Rotator != "rot" || rec.Previous == nil {
+		t.Fatalf("unexpected record %+v", rec)
+	}
+	if rec := records[1]; rec.Command != "set_azimuth" || *rec.Azimuth != 90 || rec.Error != "locked" {
+		t.Fatalf("unexpected record %+v", rec)
+	}
+}
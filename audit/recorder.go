@@ -0,0 +1,78 @@
+package audit
+
+import "github.com/dh1tw/remoteRotator/rotator"
+
+// Bind returns a view of the rotator which records all commands sent
+// through it on behalf of the client. Refused commands are recorded
+// with the reason. If the logger is nil, the rotator is returned
+// unchanged.
+func Bind(r rotator.Rotator, l *Logger, frontend, client, user string) rotator.Rotator {
+	if l == nil {
+		return r
+	}
+	return &recorder{
+		Rotator: r,
+		log:     l,
+		tmpl: Record{
+			Frontend: frontend,
+			Client:   client,
+			User:     user,
+		},
+	}
+}
+
+// recorder is a view of a rotator for a client.
+type recorder struct {
+	rotator.Rotator
+	log  *Logger
+	tmpl Record
+}
+
+// record executes the command and records it together with the
+// heading of the rotator before the command.
+func (r *recorder) record(cmd string, az, el *int, f func() error) error {
+	rec := r.tmpl
+	rec.Rotator = r.Name()
+	rec.Command = cmd
+	rec.Azimuth = az
+	rec.Elevation = el
+	previous := r.Serialize().Heading
+	rec.Previous = &previous
+
+	err := f()
+	if err != nil {
+		rec.Error = err.Error()
+	}
+	r.log.Record(rec)
+
+	return err
+}
+
+func (r *recorder) SetAzimuth(az int) error {
+	return r.record("set_azimuth", &az, nil, func() error {
+		return r.Rotator.SetAzimuth(az)
+	})
+}
+
+func (r *recorder) SetElevation(el int) error {
+	return r.record("set_elevation", nil, &el, func() error {
+		return r.Rotator.SetElevation(el)
+	})
+}
+
+func (r *recorder) StopAzimuth() error {
+	return r.record("stop_azimuth", nil, nil, r.Rotator.StopAzimuth)
+}
+
+func (r *recorder) StopElevation() error {
+	return r.record("stop_elevation", nil, nil, r.Rotator.StopElevation)
+}
+
+func (r *recorder) Stop() error {
+	return r.record("stop", nil, nil, r.Rotator.Stop)
+}
+
+// Unwrap returns the recorded rotator.
+func (r *recorder) Unwrap() rotator.Rotator {
+	return r.Rotator
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var hashPasswordCmd = &cobra.Command{
+	Use:   "hash-password",
+	Short: "generate the password hash of a user for the config file",
+	Long: `generate the password hash of a user for the config file
+
+The password is read from stdin and its bcrypt hash is printed. The hash can
+be copied into the [[auth.users]] section of the config file.
+
+$ echo -n "secret" | remoteRotator hash-password`,
+	Run: hashPassword,
+}
+
+func init() {
+	RootCmd.AddCommand(hashPasswordCmd)
+	hashPasswordCmd.Flags().IntP("cost", "c", bcrypt.DefaultCost, "bcrypt cost")
+}
+
+func hashPassword(cmd *cobra.Command, args []string) {
+
+	cost, _ := cmd.Flags().GetInt("cost")
+
+	fmt.Fprint(os.Stderr, "Password: ")
+	password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && password == "" {
+		fmt.Println("unable to read password:", err)
+		os.Exit(1)
+	}
+	password = strings.TrimRight(password, "\r\n")
+	if password == "" {
+		fmt.Println("password must not be empty")
+		os.Exit(1)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), cost)
+	if err != nil {
+		fmt.Println("unable to hash password:", err)
+		os.Exit(1)
+	}
+
+	fmt.Fprintln(os.Stderr)
+	fmt.Println(string(hash))
+}
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/dh1tw/remoteRotator/rotator"
+	"github.com/dh1tw/remoteRotator/rotator/antenna"
+	"github.com/spf13/viper"
+)
+
+type antennaConfig struct {
+	Name   string   `mapstructure:"name"`
+	Offset int      `mapstructure:"offset"`
+	Bands  []string `mapstructure:"bands"`
+}
+
+// initAntennas puts the antennas mounted on the mast of the rotator in
+// front of the rotator. The rotator is returned unchanged if no
+// antennas are configured.
+func initAntennas(r rotator.Rotator) (rotator.Rotator, error) {
+
+	var cfg []antennaConfig
+	if err := viper.UnmarshalKey("rotator.antennas", &cfg); err != nil {
+		return nil, fmt.Errorf("rotator.antennas: %v", err)
+	}
+
+	antennas := make([]rotator.Antenna, 0, len(cfg))
+	for _, a := range cfg {
+		antennas = append(antennas, rotator.Antenna{
+			Name:   a.Name,
+			Offset: a.Offset,
+			Bands:  a.Bands,
+		})
+	}
+
+	return antenna.New(r, antennas...)
+}
+
+// antennaMetadata returns the metadata through which a shackbus rotator
+// service announces the antennas mounted on its mast.
+func antennaMetadata(r rotator.Rotator) map[string]string {
+	md := map[string]string{}
+
+	ant, ok := rotator.As[*antenna.Rotator](r)
+	if !ok {
+		return md
+	}
+
+	data, err := json.Marshal(ant.Antennas())
+	if err != nil {
+		log.Println("unable to encode antennas:", err)
+		return md
+	}
+	md["antennas"] = string(data)
+
+	return md
+}
+
+// antennasFromMetadata returns the antennas announced in the metadata of
+// a shackbus rotator service.
+func antennasFromMetadata(md map[string]string) []rotator.Antenna {
+	data, ok := md["antennas"]
+	if !ok {
+		return nil
+	}
+
+	var antennas []rotator.Antenna
+	if err := json.Unmarshal([]byte(data), &antennas); err != nil {
+		log.Println("invalid antennas in service metadata:", err)
+		return nil
+	}
+
+	return antennas
+}
@@ -0,0 +1,29 @@
+package cmd
+
+import (
+	"github.com/dh1tw/remoteRotator/audit"
+	"github.com/spf13/viper"
+)
+
+// initAudit initializes the audit log of the control commands if it is
+// enabled in the config file. Without a file, the latest records are
+// kept in memory.
+func initAudit() (*audit.Logger, error) {
+
+	if !viper.GetBool("audit.enabled") {
+		return nil, nil
+	}
+
+	opts := []func(*audit.Logger){
+		audit.File(viper.GetString("audit.file")),
+	}
+
+	if size := viper.GetInt64("audit.max-size"); size > 0 {
+		opts = append(opts, audit.MaxSize(size<<20)) // MB
+	}
+	if viper.IsSet("audit.max-backups") {
+		opts = append(opts, audit.MaxBackups(viper.GetInt("audit.max-backups")))
+	}
+
+	return audit.New(opts...)
+}
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/dh1tw/remoteRotator/hub"
+	"github.com/spf13/viper"
+)
+
+// authAccount is a user or an API token in the [auth] section of the
+// config file. The roles map the rotator names (or "*" for all rotators)
+// to the roles "none", "read" or "control".
+type authAccount struct {
+	Name     string            `mapstructure:"name"`
+	Password string            `mapstructure:"password"` // bcrypt hash
+	Token    string            `mapstructure:"token"`
+	Roles    map[string]string `mapstructure:"roles"`
+}
+
+// initAuth enables the authentication of the hub's clients if it is
+// enabled in the config file.
+func initAuth(h *hub.Hub) error {
+
+	if !viper.GetBool("auth.enabled") {
+		return nil
+	}
+
+	anonymous, err := hub.ParseRole(viper.GetString("auth.anonymous"))
+	if err != nil {
+		return fmt.Errorf("auth.anonymous: %v", err)
+	}
+
+	var users, tokens []authAccount
+	if err := viper.UnmarshalKey("auth.users", &users); err != nil {
+		return fmt.Errorf("auth.users: %v", err)
+	}
+	if err := viper.UnmarshalKey("auth.tokens", &tokens); err != nil {
+		return fmt.Errorf("auth.tokens: %v", err)
+	}
+
+	if len(users) == 0 && len(tokens) == 0 && anonymous != hub.RoleControl {
+		return fmt.Errorf("authentication enabled, but no users or tokens configured")
+	}
+
+	accounts := hub.NewUsers()
+
+	for _, u := range users {
+		roles, err := parseRoles(u.Roles)
+		if err != nil {
+			return fmt.Errorf("user %s: %v", u.Name, err)
+		}
+		if err := accounts.AddUser(u.Name, u.Password, roles); err != nil {
+			return err
+		}
+	}
+
+	for _, t := range tokens {
+		roles, err := parseRoles(t.Roles)
+		if err != nil {
+			return fmt.Errorf("token %s: %v", t.Name, err)
+		}
+		if err := accounts.AddToken(t.Name, t.Token, roles); err != nil {
+			return err
+		}
+	}
+
+	h.SetAuth(accounts, anonymous)
+
+	return nil
+}
+
+// parseRoles converts the roles of an account. Accounts without roles
+// can control all rotators.
+func parseRoles(names map[string]string) (map[string]hub.Role, error) {
+
+	if len(names) == 0 {
+		return map[string]hub.Role{hub.AllRotators: hub.RoleControl}, nil
+	}
+
+	roles := make(map[string]hub.Role, len(names))
+	for rName, name := range names {
+		role, err := hub.ParseRole(name)
+		if err != nil {
+			return nil, err
+		}
+		roles[rName] = role
+	}
+
+	return roles, nil
+}
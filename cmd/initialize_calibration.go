@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/dh1tw/remoteRotator/rotator/calibration"
+	"github.com/dh1tw/remoteRotator/state"
+	"github.com/spf13/viper"
+)
+
+type calibrationPoint struct {
+	Indicated float64 `mapstructure:"indicated"`
+	Actual    float64 `mapstructure:"actual"`
+}
+
+// initCalibration initializes the conversion between the physical
+// azimuth of the rotator and the true azimuth of the antenna. Offsets
+// which have been changed at runtime are restored from the state store
+// and take precedence over the config file.
+func initCalibration(st *state.Store, saved state.Rotator) (*calibration.Calibrator, error) {
+
+	var table []calibrationPoint
+	if err := viper.UnmarshalKey("rotator.calibration.table", &table); err != nil {
+		return nil, fmt.Errorf("rotator.calibration.table: %v", err)
+	}
+
+	points := make([]calibration.Point, 0, len(table))
+	for _, p := range table {
+		points = append(points, calibration.Point{Indicated: p.Indicated, Actual: p.Actual})
+	}
+
+	opts := []func(*calibration.Calibrator){
+		calibration.Offset(viper.GetInt("rotator.calibration.offset")),
+		calibration.Mounting(viper.GetInt("rotator.calibration.mounting")),
+		calibration.Table(points...),
+	}
+
+	if saved.Offsets != nil {
+		log.Printf("restoring calibration offset %d°, mounting offset %d°\n",
+			saved.Offsets.Offset, saved.Offsets.Mounting)
+		opts = append(opts,
+			calibration.Offset(saved.Offsets.Offset),
+			calibration.Mounting(saved.Offsets.Mounting))
+	}
+
+	if st != nil {
+		rName := viper.GetString("rotator.name")
+		opts = append(opts, calibration.OnChange(func(offset, mounting int) {
+			st.Update(rName, func(rs *state.Rotator) {
+				rs.Offsets = &state.Offsets{Offset: offset, Mounting: mounting}
+			})
+		}))
+	}
+
+	return calibration.New(opts...)
+}
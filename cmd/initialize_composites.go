@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/dh1tw/remoteRotator/hub"
+	"github.com/dh1tw/remoteRotator/rotator"
+	"github.com/dh1tw/remoteRotator/rotator/composite"
+	"github.com/spf13/viper"
+)
+
+type compositeConfig struct {
+	Name      string `mapstructure:"name"`
+	Azimuth   string `mapstructure:"azimuth"`
+	Elevation string `mapstructure:"elevation"`
+}
+
+// composites are virtual az/el rotators, each composed of an azimuth and
+// an elevation rotator of the hub. A composite rotator is added to the
+// hub as soon as both of its rotators are available and removed when
+// one of them disappears.
+type composites struct {
+	sync.Mutex
+	hub     *hub.Hub
+	configs []compositeConfig
+	active  map[string]*composite.Rotator // key: name of the composite rotator
+}
+
+// initComposites reads the composite rotators from the config file
+// (key: <section>.composites).
+func initComposites(section string, h *hub.Hub) (*composites, error) {
+
+	var cfg []compositeConfig
+	if err := viper.UnmarshalKey(section+".composites", &cfg); err != nil {
+		return nil, fmt.Errorf("%s.composites: %v", section, err)
+	}
+
+	for _, c := range cfg {
+		if c.Name == "" || c.Azimuth == "" || c.Elevation == "" {
+			return nil, fmt.Errorf("%s.composites: name, azimuth and elevation are required", section)
+		}
+	}
+
+	return &composites{
+		hub:     h,
+		configs: cfg,
+		active:  make(map[string]*composite.Rotator),
+	}, nil
+}
+
+// update adds the composite rotators whose rotators have become available
+// to the hub and removes those which have lost one of their rotators.
+func (c *composites) update() {
+	c.Lock()
+	defer c.Unlock()
+
+	for _, cfg := range c.configs {
+		az, azOk := c.hub.Rotator(cfg.Azimuth)
+		el, elOk := c.hub.Rotator(cfg.Elevation)
+
+		if r, ok := c.active[cfg.Name]; ok {
+			rAz, rEl := r.Members()
+			if azOk && elOk && az == rAz && el == rEl {
+				continue
+			}
+			c.hub.RemoveRotator(r)
+			delete(c.active, cfg.Name)
+		}
+
+		if !azOk || !elOk {
+			continue
+		}
+
+		r, err := composite.New(az, el,
+			composite.Name(cfg.Name),
+			composite.EventHandler(c.broadcast))
+		if err != nil {
+			log.Printf("unable to create composite rotator %s: %v\n", cfg.Name, err)
+			continue
+		}
+		if err := c.hub.AddRotator(r); err != nil {
+			log.Println(err)
+			continue
+		}
+		c.active[cfg.Name] = r
+	}
+}
+
+// handle passes the event of a rotator to the composite rotators.
+func (c *composites) handle(r rotator.Rotator, heading rotator.Heading) {
+	c.Lock()
+	active := make([]*composite.Rotator, 0, len(c.active))
+	for _, cr := range c.active {
+		active = append(active, cr)
+	}
+	c.Unlock()
+
+	for _, cr := range active {
+		cr.Handle(r, heading)
+	}
+}
+
+// broadcast sends the heading of a composite rotator to the clients of
+// the hub.
+func (c *composites) broadcast(r rotator.Rotator, heading rotator.Heading) {
+	c.hub.Broadcast(hub.Event{
+		Name:        hub.UpdateHeading,
+		RotatorName: r.Name(),
+		Heading:     heading,
+	})
+}
@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/dh1tw/remoteRotator/hub"
+	"github.com/spf13/viper"
+)
+
+type groupConfig struct {
+	Name    string `mapstructure:"name"`
+	Members []struct {
+		Rotator string `mapstructure:"rotator"`
+		Offset  int    `mapstructure:"offset"`
+	} `mapstructure:"members"`
+}
+
+// initGroups reads the groups of rotators which are moved together from
+// the config file (key: <section>.groups).
+func initGroups(section string) ([]hub.Group, error) {
+
+	var cfg []groupConfig
+	if err := viper.UnmarshalKey(section+".groups", &cfg); err != nil {
+		return nil, fmt.Errorf("%s.groups: %v", section, err)
+	}
+
+	groups := make([]hub.Group, 0, len(cfg))
+	for _, g := range cfg {
+		group := hub.Group{Name: g.Name}
+		for _, m := range g.Members {
+			group.Members = append(group.Members, hub.GroupMember{
+				Rotator: m.Rotator,
+				Offset:  m.Offset,
+			})
+		}
+		groups = append(groups, group)
+	}
+
+	return groups, nil
+}
@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/dh1tw/remoteRotator/history"
+	"github.com/spf13/viper"
+)
+
+// initHistory opens the database of the heading history if it is
+// enabled in the config file.
+func initHistory() (*history.Store, error) {
+
+	if !viper.GetBool("history.enabled") {
+		return nil, nil
+	}
+
+	path := viper.GetString("history.file")
+	if path == "" {
+		dir, err := os.UserConfigDir()
+		if err != nil {
+			return nil, err
+		}
+		path = filepath.Join(dir, "remoteRotator", "history.db")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+
+	opts := []func(*history.Store){}
+	if viper.IsSet("history.retention") {
+		opts = append(opts, history.Retention(viper.GetDuration("history.retention")))
+	}
+
+	return history.Open(path, opts...)
+}
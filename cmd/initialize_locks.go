@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"github.com/dh1tw/remoteRotator/hub"
+	"github.com/dh1tw/remoteRotator/state"
+	"github.com/spf13/viper"
+)
+
+// initLocks initializes the leases which grant a client the exclusive
+// control of a rotator. The leases are restored from and kept in the
+// state store.
+func initLocks(st *state.Store) *hub.Locks {
+
+	opts := []func(*hub.Locks){
+		hub.AdminToken(viper.GetString("lock.admin-token")),
+		hub.AutoLease(viper.GetDuration("lock.auto-lease")),
+	}
+
+	if d := viper.GetDuration("lock.lease-time"); d > 0 {
+		opts = append(opts, hub.LeaseTime(d))
+	}
+	if d := viper.GetDuration("lock.max-lease"); d > 0 {
+		opts = append(opts, hub.MaxLease(d))
+	}
+	if viper.IsSet("lock.tcp-auto-lease") {
+		opts = append(opts, hub.TCPAutoLease(viper.GetDuration("lock.tcp-auto-lease")))
+	}
+
+	if st != nil {
+		opts = append(opts, hub.Restore(savedLeases(st)...), hub.Persist(persistLease(st)))
+	}
+
+	return hub.NewLocks(opts...)
+}
@@ -2,23 +2,202 @@ package cmd
 
 import (
 	"fmt"
+	"log"
 	"strings"
 
 	"github.com/dh1tw/remoteRotator/rotator"
 	"github.com/dh1tw/remoteRotator/rotator/dummy"
+	"github.com/dh1tw/remoteRotator/rotator/duty"
+	"github.com/dh1tw/remoteRotator/rotator/monitor"
+	"github.com/dh1tw/remoteRotator/rotator/scheduler"
 	"github.com/dh1tw/remoteRotator/rotator/yaesu"
+	"github.com/dh1tw/remoteRotator/state"
+	"github.com/dh1tw/remoteRotator/wind"
 	"github.com/spf13/viper"
 )
 
-// init rotator initializes a rotator
-func initRotator(rType string, eventHdlr rotator.EventHandler, errorCh chan struct{}) (rotator.Rotator, error) {
+// initRotator initializes a rotator and puts the configured layers
+// (e.g. the command scheduler) in front of it. Changes of the layers'
+// status (e.g. faults detected by the safety monitor) are reported
+// through the status handler. The presets and unacknowledged faults are
+// restored from and kept in the state store. The calibration is applied
+// directly in front of the driver.
+func initRotator(rType string, eventHdlr rotator.EventHandler,
+	statusHdlr rotator.StatusHandler, errorCh chan struct{},
+	st *state.Store) (rotator.Rotator, error) {
+
+	saved := st.Get(viper.GetString("rotator.name"))
+
+	cal, err := initCalibration(st, saved)
+	if err != nil {
+		return nil, err
+	}
+
+	// the presets are stored as true azimuths, the driver works with
+	// the physical azimuths
+	physical := saved
+	if saved.AzPreset != nil {
+		az := cal.ToPhysical(*saved.AzPreset, viper.GetInt("rotator.azimuth-max"))
+		physical.AzPreset = &az
+	}
+
+	r, err := initDriver(rType, cal.EventHandler(eventHdlr), errorCh, physical)
+	if err != nil {
+		return nil, err
+	}
+
+	// all other layers work with the true azimuth
+	r = cal.Bind(r)
+
+	if interval := viper.GetDuration("rotator.command-interval"); interval > 0 {
+		r, err = scheduler.New(r, scheduler.Interval(interval))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// the safety layers are put in front of the scheduler, so that
+	// moves are refused immediately while they are locked out
+	if viper.GetBool("rotator.monitor.enabled") {
+		r, err = initMonitor(r, statusHdlr, st, saved)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// the rotator is parked through the monitor, but bypasses the duty
+	// cycle limiter; a storm doesn't wait for the motor to cool down
+	if viper.GetBool("wind.enabled") {
+		r, err = initParker(r, statusHdlr)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// the duty cycle limiter stops the rotator through the monitor, so
+	// that the monitor doesn't mistake the stop for a stall
+	if viper.GetDuration("rotator.duty.budget") > 0 {
+		r, err = initDutyLimiter(r, statusHdlr)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// the presets are only stored if they have been accepted by all
+	// layers
+	r = state.Persist(r, st)
+
+	// the antennas report their bearings based on the heading seen by
+	// the clients
+	r, err = initAntennas(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if st != nil && viper.GetBool("state.resume") {
+		resumePresets(r, saved)
+	}
+
+	return r, nil
+}
+
+// initParker puts the wind parker in front of the rotator
+func initParker(r rotator.Rotator, statusHdlr rotator.StatusHandler) (rotator.Rotator, error) {
+
+	opts := []func(*wind.Parker){
+		wind.StatusHandler(statusHdlr),
+	}
+
+	if viper.IsSet("rotator.wind.park-azimuth") {
+		opts = append(opts, wind.ParkAzimuth(viper.GetInt("rotator.wind.park-azimuth")))
+	}
+	if viper.IsSet("rotator.wind.park-elevation") {
+		opts = append(opts, wind.ParkElevation(viper.GetInt("rotator.wind.park-elevation")))
+	}
+	if viper.IsSet("rotator.wind.offset") {
+		opts = append(opts, wind.Offset(viper.GetInt("rotator.wind.offset")))
+	}
+
+	return wind.NewParker(r, opts...)
+}
+
+// initDutyLimiter puts the motor duty cycle limiter in front of the rotator
+func initDutyLimiter(r rotator.Rotator, statusHdlr rotator.StatusHandler) (rotator.Rotator, error) {
+
+	opts := []func(*duty.Limiter){
+		duty.StatusHandler(statusHdlr),
+		duty.Budget(viper.GetDuration("rotator.duty.budget")),
+		duty.Queue(viper.GetBool("rotator.duty.queue")),
+	}
+
+	if d := viper.GetDuration("rotator.duty.cool-down"); d > 0 {
+		opts = append(opts, duty.CoolDown(d))
+	}
+	if viper.IsSet("rotator.duty.warn-level") {
+		opts = append(opts, duty.WarnLevel(viper.GetFloat64("rotator.duty.warn-level")))
+	}
+	if viper.IsSet("rotator.duty.resume-level") {
+		opts = append(opts, duty.ResumeLevel(viper.GetFloat64("rotator.duty.resume-level")))
+	}
+
+	return duty.New(r, opts...)
+}
+
+// initMonitor puts the safety monitor in front of the rotator. A fault
+// which hasn't been acknowledged before the restart is restored.
+func initMonitor(r rotator.Rotator, statusHdlr rotator.StatusHandler,
+	st *state.Store, saved state.Rotator) (rotator.Rotator, error) {
+
+	if st != nil {
+		statusHdlr = persistFault(st, statusHdlr)
+	}
+
+	opts := []func(*monitor.Monitor){
+		monitor.StatusHandler(statusHdlr),
+	}
+
+	if saved.Fault != nil {
+		opts = append(opts, monitor.InitialFault(*saved.Fault))
+	}
+
+	if d := viper.GetDuration("rotator.monitor.stall-timeout"); d > 0 {
+		opts = append(opts, monitor.StallTimeout(d))
+	}
+	if d := viper.GetDuration("rotator.monitor.settle-time"); d > 0 {
+		opts = append(opts, monitor.SettleTime(d))
+	}
+	if viper.IsSet("rotator.monitor.tolerance") {
+		opts = append(opts, monitor.Tolerance(viper.GetInt("rotator.monitor.tolerance")))
+	}
+	if viper.IsSet("rotator.monitor.runaway-tolerance") {
+		opts = append(opts, monitor.RunawayTolerance(viper.GetInt("rotator.monitor.runaway-tolerance")))
+	}
+
+	return monitor.New(r, opts...)
+}
+
+// initDriver initializes the driver of a rotator. The saved presets
+// are restored.
+func initDriver(rType string, eventHdlr rotator.EventHandler, errorCh chan struct{},
+	saved state.Rotator) (rotator.Rotator, error) {
 
 	switch strings.ToUpper(rType) {
 
 	case "YAESU":
+		if strings.ToLower(viper.GetString("rotator.portname")) == "auto" {
+			pn, baud, err := autoDetectPort(rType)
+			if err != nil {
+				return nil, err
+			}
+			log.Printf("found %s rotator on %s (%d baud)\n", rType, pn, baud)
+			viper.Set("rotator.portname", pn)
+			viper.Set("rotator.baudrate", baud)
+		}
+
 		evHandler := yaesu.EventHandler(eventHdlr)
 		name := yaesu.Name(viper.GetString("rotator.name"))
 		interval := yaesu.UpdateInterval(viper.GetDuration("rotator.pollingrate"))
+		fastInterval := yaesu.FastUpdateInterval(viper.GetDuration("rotator.pollingrate-fast"))
 		spPortName := yaesu.Portname(viper.GetString("rotator.portname"))
 		baudrate := yaesu.Baudrate(viper.GetInt("rotator.baudrate"))
 		hasAzimuth := yaesu.HasAzimuth(viper.GetBool("rotator.has-azimuth"))
@@ -30,9 +209,20 @@ func initRotator(rType string, eventHdlr rotator.EventHandler, errorCh chan stru
 		azStop := yaesu.AzimuthStop(viper.GetInt("rotator.azimuth-stop"))
 		errorCh := yaesu.ErrorCh(errorCh)
 
-		yaesu, err := yaesu.New(name, interval, evHandler,
+		opts := []func(*yaesu.Yaesu){name, interval, fastInterval, evHandler,
 			spPortName, baudrate, hasAzimuth, hasElevation, azMin, azMax, elMin,
-			elMax, azStop, errorCh)
+			elMax, azStop, errorCh}
+
+		// the controller continues to turn to its preset while the
+		// server restarts
+		if saved.AzPreset != nil {
+			opts = append(opts, yaesu.InitialAzPreset(*saved.AzPreset))
+		}
+		if saved.ElPreset != nil {
+			opts = append(opts, yaesu.InitialElPreset(*saved.ElPreset))
+		}
+
+		yaesu, err := yaesu.New(opts...)
 
 		if err != nil {
 			return nil, err
@@ -50,10 +240,40 @@ func initRotator(rType string, eventHdlr rotator.EventHandler, errorCh chan stru
 		elMax := dummy.ElevationMax(viper.GetInt("rotator.elevation-max"))
 		azStop := dummy.AzimuthStop(viper.GetInt("rotator.azimuth-stop"))
 
-		dummyRotator, err := dummy.New(name, evHandler, hasAzimuth, hasElevation, azMin, azMax, azStop, elMin, elMax)
+		accel := dummy.Acceleration(viper.GetFloat64("rotator.dummy.acceleration"))
+		startDelay := dummy.StartDelay(viper.GetDuration("rotator.dummy.start-delay"))
+		brakeRelease := dummy.BrakeRelease(viper.GetDuration("rotator.dummy.brake-release"))
+		failAfter := dummy.FailAfter(viper.GetDuration("rotator.dummy.fail-after"))
+		errorCh := dummy.ErrorCh(errorCh)
+
+		opts := []func(*dummy.Dummy){name, evHandler, hasAzimuth, hasElevation,
+			azMin, azMax, azStop, elMin, elMax, accel, startDelay, brakeRelease,
+			failAfter, errorCh}
+
+		// the simulated rotator has reached its preset during the restart
+		if saved.AzPreset != nil {
+			opts = append(opts, dummy.InitialAzimuth(*saved.AzPreset))
+		}
+		if saved.ElPreset != nil {
+			opts = append(opts, dummy.InitialElevation(*saved.ElPreset))
+		}
+
+		dummyRotator, err := dummy.New(opts...)
 		if err != nil {
 			return nil, err
 		}
+
+		// faults to be injected
+		if viper.IsSet("rotator.dummy.stall-at") {
+			dummyRotator.InjectStall(viper.GetInt("rotator.dummy.stall-at"))
+		}
+		if drift := viper.GetFloat64("rotator.dummy.drift"); drift != 0 {
+			dummyRotator.InjectDrift(drift)
+		}
+		if viper.GetBool("rotator.dummy.stuck-sensor") {
+			dummyRotator.InjectStuckSensor()
+		}
+
 		return dummyRotator, err
 
 	default:
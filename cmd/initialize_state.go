@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/dh1tw/remoteRotator/hub"
+	"github.com/dh1tw/remoteRotator/rotator"
+	"github.com/dh1tw/remoteRotator/rotator/monitor"
+	"github.com/dh1tw/remoteRotator/state"
+	"github.com/spf13/viper"
+)
+
+// initState opens the file in which the state of the rotators (presets,
+// leases, unacknowledged faults) is kept across restarts.
+func initState() (*state.Store, error) {
+
+	if !viper.GetBool("state.enabled") {
+		return nil, nil
+	}
+
+	path := viper.GetString("state.file")
+	if path == "" {
+		dir, err := os.UserConfigDir()
+		if err != nil {
+			return nil, err
+		}
+		path = filepath.Join(dir, "remoteRotator", "state.json")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+
+	return state.Open(path)
+}
+
+// persistFault returns a status handler which keeps the unacknowledged
+// fault of the safety monitor in the state store before it forwards the
+// status to the status handler.
+func persistFault(st *state.Store, statusHdlr rotator.StatusHandler) rotator.StatusHandler {
+	return func(r rotator.Rotator, s rotator.Status) {
+		switch {
+		case s.Level == rotator.StatusFault && s.Locked:
+			st.Update(r.Name(), func(rs *state.Rotator) {
+				rs.Fault = &s
+			})
+		case s.Code == monitor.Cleared:
+			st.Update(r.Name(), func(rs *state.Rotator) {
+				rs.Fault = nil
+			})
+		}
+		if statusHdlr != nil {
+			statusHdlr(r, s)
+		}
+	}
+}
+
+// persistLease stores the lease of a rotator in the state store.
+func persistLease(st *state.Store) func(string, *hub.Lease) {
+	return func(rName string, lease *hub.Lease) {
+		st.Update(rName, func(rs *state.Rotator) {
+			rs.Lease = nil
+			if lease != nil {
+				rs.Lease = &state.Lease{
+					Owner:    lease.Owner,
+					Operator: lease.Operator,
+					Expires:  lease.Expires,
+				}
+			}
+		})
+	}
+}
+
+// savedLeases returns the leases of the state store.
+func savedLeases(st *state.Store) []hub.Lease {
+	leases := []hub.Lease{}
+	for name, rs := range st.All() {
+		if rs.Lease != nil {
+			leases = append(leases, hub.Lease{
+				Rotator:  name,
+				Owner:    rs.Lease.Owner,
+				Operator: rs.Lease.Operator,
+				Expires:  rs.Lease.Expires,
+			})
+		}
+	}
+	return leases
+}
+
+// resumePresets commands the rotator to the presets which were pending
+// before the restart.
+func resumePresets(r rotator.Rotator, saved state.Rotator) {
+	if saved.AzPreset != nil && r.HasAzimuth() {
+		log.Printf("%s: resuming azimuth preset %d°\n", r.Name(), *saved.AzPreset)
+		if err := r.SetAzimuth(*saved.AzPreset); err != nil {
+			log.Printf("unable to resume azimuth preset of %s: %v\n", r.Name(), err)
+		}
+	}
+	if saved.ElPreset != nil && r.HasElevation() {
+		log.Printf("%s: resuming elevation preset %d°\n", r.Name(), *saved.ElPreset)
+		if err := r.SetElevation(*saved.ElPreset); err != nil {
+			log.Printf("unable to resume elevation preset of %s: %v\n", r.Name(), err)
+		}
+	}
+}
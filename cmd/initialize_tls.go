@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/dh1tw/remoteRotator/hub"
+	"github.com/spf13/viper"
+)
+
+// initTLS returns the TLS configuration for the HTTP server configured
+// in the section (e.g. "http" or "web") of the config file. If neither a
+// certificate nor the generation of a self-signed certificate is
+// configured, nil is returned.
+func initTLS(section string) (*tls.Config, error) {
+
+	certFile := viper.GetString(section + ".tls-cert")
+	keyFile := viper.GetString(section + ".tls-key")
+	selfSigned := viper.GetBool(section + ".tls-self-signed")
+
+	if certFile == "" && keyFile == "" && !selfSigned {
+		return nil, nil
+	}
+
+	if certFile == "" || keyFile == "" {
+		if !selfSigned {
+			return nil, fmt.Errorf("both, a certificate and a key file are required")
+		}
+		// keep the generated certificate, so that its fingerprint
+		// stays the same
+		dir, err := os.UserConfigDir()
+		if err != nil {
+			return nil, err
+		}
+		certFile = filepath.Join(dir, "remoteRotator", "cert.pem")
+		keyFile = filepath.Join(dir, "remoteRotator", "key.pem")
+	}
+
+	host := viper.GetString(section + ".host")
+	hosts := []string{"localhost", "127.0.0.1", "::1", getOutboundIP().String()}
+	if ip := net.ParseIP(host); ip == nil || !ip.IsUnspecified() {
+		hosts = append(hosts, host)
+	}
+	if hostname, err := os.Hostname(); err == nil {
+		hosts = append(hosts, hostname, hostname+".local")
+	}
+
+	cert, err := hub.LoadCertificate(certFile, keyFile, selfSigned, hosts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if leaf, err := x509.ParseCertificate(cert.Certificate[0]); err == nil {
+		log.Printf("TLS certificate %s (SHA-256 fingerprint %s)\n",
+			certFile, hub.Fingerprint(leaf.Raw))
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
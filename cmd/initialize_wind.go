@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/dh1tw/remoteRotator/rotator"
+	"github.com/dh1tw/remoteRotator/wind"
+	"github.com/spf13/viper"
+)
+
+// startWindGuard starts reading the configured weather station and parks
+// the rotators when the wind is too strong. It returns nil if the wind
+// parking is disabled.
+func startWindGuard(rotators ...rotator.Rotator) (*wind.Guard, error) {
+
+	if !viper.GetBool("wind.enabled") {
+		return nil, nil
+	}
+
+	address := viper.GetString("wind.address")
+	opts := []func(*wind.Feed){
+		wind.SpeedKey(viper.GetString("wind.speed-key")),
+		wind.DirectionKey(viper.GetString("wind.direction-key")),
+		wind.Username(viper.GetString("wind.username")),
+		wind.Password(viper.GetString("wind.password")),
+	}
+	if d := viper.GetDuration("wind.interval"); d > 0 {
+		opts = append(opts, wind.Interval(d))
+	}
+
+	var feed *wind.Feed
+
+	switch strings.ToLower(viper.GetString("wind.source")) {
+	case "file":
+		feed = wind.NewFileFeed(address, opts...)
+	case "http":
+		feed = wind.NewHTTPFeed(address, opts...)
+	case "mqtt":
+		feed = wind.NewMQTTFeed(address, viper.GetString("wind.topic"), opts...)
+	default:
+		return nil, fmt.Errorf("unknown wind source '%s' (supported: file, http, mqtt)",
+			viper.GetString("wind.source"))
+	}
+
+	gOpts := []func(*wind.Guard){}
+	if viper.IsSet("wind.release") {
+		gOpts = append(gOpts, wind.ReleaseSpeed(viper.GetFloat64("wind.release")))
+	}
+	if viper.IsSet("wind.hold-time") {
+		gOpts = append(gOpts, wind.HoldTime(viper.GetDuration("wind.hold-time")))
+	}
+
+	g, err := wind.NewGuard(feed, viper.GetFloat64("wind.threshold"), gOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, r := range rotators {
+		p, ok := rotator.As[*wind.Parker](r)
+		if !ok {
+			continue
+		}
+		g.Add(p)
+		log.Printf("wind parking of %s enabled (%s)\n", r.Name(), p)
+	}
+
+	if err := g.Start(); err != nil {
+		return nil, fmt.Errorf("unable to read wind speed: %v", err)
+	}
+
+	return g, nil
+}
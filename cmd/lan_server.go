@@ -58,19 +58,32 @@ func init() {
 	lanServerCmd.Flags().BoolP("http-enabled", "", true, "enable HTTP Server")
 	lanServerCmd.Flags().StringP("http-host", "w", "127.0.0.1", "Host (use '0.0.0.0' to listen on all network adapters)")
 	lanServerCmd.Flags().IntP("http-port", "k", 7070, "Port for the HTTP access to the rotator")
+	lanServerCmd.Flags().StringP("tls-cert", "", "", "TLS certificate (PEM) for HTTPS / wss")
+	lanServerCmd.Flags().StringP("tls-key", "", "", "TLS private key (PEM) for HTTPS / wss")
+	lanServerCmd.Flags().BoolP("tls-self-signed", "", false, "generate a self-signed TLS certificate if the certificate doesn't exist")
+	lanServerCmd.Flags().StringSliceP("allowed-origins", "", nil, "origins of third party web pages which may use the REST API and websocket ('*' for all)")
 	lanServerCmd.Flags().BoolP("discovery-enabled", "", true, "make rotator discoverable on the network")
-	lanServerCmd.Flags().StringP("portname", "P", "/dev/ttyACM0", "portname / path to the rotator (e.g. COM1)")
+	lanServerCmd.Flags().StringP("portname", "P", "/dev/ttyACM0", "portname / path or url to the rotator (e.g. COM1, serial:///dev/ttyUSB0?baud=4800, rfc2217://host:port) or 'auto' to detect the port")
 	lanServerCmd.Flags().IntP("baudrate", "b", 9600, "baudrate")
 	lanServerCmd.Flags().StringP("type", "t", "yaesu", "Rotator type (supported: yaesu, dummy")
 	lanServerCmd.Flags().StringP("name", "n", "myRotator", "Name tag for the rotator")
 	lanServerCmd.Flags().BoolP("has-azimuth", "", true, "rotator supports Azimuth")
 	lanServerCmd.Flags().BoolP("has-elevation", "", false, "rotator supports Elevation")
 	lanServerCmd.Flags().DurationP("pollingrate", "", time.Second*1, "rotator polling rate")
+	lanServerCmd.Flags().DurationP("pollingrate-fast", "", 0, "rotator polling rate while moving (0 = disabled)")
+	lanServerCmd.Flags().DurationP("command-interval", "", 0, "minimum interval between two set commands sent to the rotator (0 = disabled)")
 	lanServerCmd.Flags().IntP("azimuth-min", "", 0, "metadata: minimum azimuth (in deg)")
 	lanServerCmd.Flags().IntP("azimuth-max", "", 360, "metadata: maximum azimuth (in deg)")
 	lanServerCmd.Flags().IntP("azimuth-stop", "", 0, "metadata: mechanical azimuth stop (in deg)")
 	lanServerCmd.Flags().IntP("elevation-min", "", 0, "metadata: minimum elevation (in deg)")
 	lanServerCmd.Flags().IntP("elevation-max", "", 180, "metadata: maximum elevation (in deg)")
+	lanServerCmd.Flags().BoolP("state-enabled", "", false, "restore the presets, leases and unacknowledged faults after a restart")
+	lanServerCmd.Flags().IntP("azimuth-offset", "", 0, "calibration: correction of the azimuth indicator (true = indicated + offset)")
+	lanServerCmd.Flags().IntP("mounting-offset", "", 0, "calibration: direction of the antenna relative to the zero of the rotator")
+	lanServerCmd.Flags().BoolP("monitor-enabled", "", false, "stop and lock out the rotator on stalls, runaways and limit violations")
+	lanServerCmd.Flags().DurationP("stall-timeout", "", time.Second*10, "time after which a commanded rotator without heading change is considered stalled")
+	lanServerCmd.Flags().DurationP("duty-budget", "", 0, "maximum continuous motor-on time (0 = duty cycle limiter disabled)")
+	lanServerCmd.Flags().DurationP("duty-cool-down", "", time.Minute*15, "time the motor needs to recover the full duty cycle budget")
 }
 
 func lanServer(cmd *cobra.Command, args []string) {
@@ -95,7 +108,12 @@ func lanServer(cmd *cobra.Command, args []string) {
 	viper.BindPFlag("http.enabled", cmd.Flags().Lookup("http-enabled"))
 	viper.BindPFlag("http.host", cmd.Flags().Lookup("http-host"))
 	viper.BindPFlag("http.port", cmd.Flags().Lookup("http-port"))
+	viper.BindPFlag("http.tls-cert", cmd.Flags().Lookup("tls-cert"))
+	viper.BindPFlag("http.tls-key", cmd.Flags().Lookup("tls-key"))
+	viper.BindPFlag("http.tls-self-signed", cmd.Flags().Lookup("tls-self-signed"))
+	viper.BindPFlag("http.allowed-origins", cmd.Flags().Lookup("allowed-origins"))
 	viper.BindPFlag("discovery.enabled", cmd.Flags().Lookup("discovery-enabled"))
+	viper.BindPFlag("state.enabled", cmd.Flags().Lookup("state-enabled"))
 	viper.BindPFlag("rotator.portname", cmd.Flags().Lookup("portname"))
 	viper.BindPFlag("rotator.baudrate", cmd.Flags().Lookup("baudrate"))
 	viper.BindPFlag("rotator.type", cmd.Flags().Lookup("type"))
@@ -103,11 +121,19 @@ func lanServer(cmd *cobra.Command, args []string) {
 	viper.BindPFlag("rotator.has-azimuth", cmd.Flags().Lookup("has-azimuth"))
 	viper.BindPFlag("rotator.has-elevation", cmd.Flags().Lookup("has-elevation"))
 	viper.BindPFlag("rotator.pollingrate", cmd.Flags().Lookup("pollingrate"))
+	viper.BindPFlag("rotator.pollingrate-fast", cmd.Flags().Lookup("pollingrate-fast"))
+	viper.BindPFlag("rotator.command-interval", cmd.Flags().Lookup("command-interval"))
 	viper.BindPFlag("rotator.azimuth-min", cmd.Flags().Lookup("azimuth-min"))
 	viper.BindPFlag("rotator.azimuth-max", cmd.Flags().Lookup("azimuth-max"))
 	viper.BindPFlag("rotator.azimuth-stop", cmd.Flags().Lookup("azimuth-stop"))
 	viper.BindPFlag("rotator.elevation-min", cmd.Flags().Lookup("elevation-min"))
 	viper.BindPFlag("rotator.elevation-max", cmd.Flags().Lookup("elevation-max"))
+	viper.BindPFlag("rotator.calibration.offset", cmd.Flags().Lookup("azimuth-offset"))
+	viper.BindPFlag("rotator.calibration.mounting", cmd.Flags().Lookup("mounting-offset"))
+	viper.BindPFlag("rotator.monitor.enabled", cmd.Flags().Lookup("monitor-enabled"))
+	viper.BindPFlag("rotator.monitor.stall-timeout", cmd.Flags().Lookup("stall-timeout"))
+	viper.BindPFlag("rotator.duty.budget", cmd.Flags().Lookup("duty-budget"))
+	viper.BindPFlag("rotator.duty.cool-down", cmd.Flags().Lookup("duty-cool-down"))
 
 	if err := sanityCheckRotatorInputs(); err != nil {
 		fmt.Println(err)
@@ -126,7 +152,22 @@ func lanServer(cmd *cobra.Command, args []string) {
 
 	bcast := make(chan hub.Event, 10)
 
+	st, err := initState()
+	if err != nil {
+		fmt.Println("unable to initialize state:", err)
+		os.Exit(1)
+	}
+	defer st.Close()
+
+	headings, err := initHistory()
+	if err != nil {
+		fmt.Println("unable to initialize heading history:", err)
+		os.Exit(1)
+	}
+	defer headings.Close()
+
 	var rEventHandler = func(r rotator.Rotator, heading rotator.Heading) {
+		headings.Add(r, heading)
 		e := hub.Event{
 			Name:        hub.UpdateHeading,
 			RotatorName: r.Name(),
@@ -135,20 +176,62 @@ func lanServer(cmd *cobra.Command, args []string) {
 		bcast <- e
 	}
 
+	var rStatusHandler = func(r rotator.Rotator, status rotator.Status) {
+		e := hub.Event{
+			Name:        hub.RotatorStatus,
+			RotatorName: r.Name(),
+			Status:      &status,
+		}
+		bcast <- e
+	}
+
 	rotatorError := make(chan struct{})
 
 	// initialize our Rotator
-	r, err := initRotator(viper.GetString("rotator.type"), rEventHandler, rotatorError)
+	r, err := initRotator(viper.GetString("rotator.type"), rEventHandler, rStatusHandler, rotatorError, st)
 	if err != nil {
 		fmt.Println("unable to initialize rotator:", err)
 		os.Exit(1)
 	}
 
+	// park the rotator when the wind is too strong
+	windGuard, err := startWindGuard(r)
+	if err != nil {
+		fmt.Println("unable to initialize wind parking:", err)
+		os.Exit(1)
+	}
+	if windGuard != nil {
+		defer windGuard.Close()
+	}
+
 	h, err := hub.NewHub(r)
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
+	h.SetLocks(initLocks(st))
+
+	if err := initAuth(h); err != nil {
+		fmt.Println("unable to initialize authentication:", err)
+		os.Exit(1)
+	}
+
+	tlsConfig, err := initTLS("http")
+	if err != nil {
+		fmt.Println("unable to initialize TLS:", err)
+		os.Exit(1)
+	}
+	h.SetTLS(tlsConfig)
+	h.SetOrigins(viper.GetStringSlice("http.allowed-origins")...)
+
+	auditLog, err := initAudit()
+	if err != nil {
+		fmt.Println("unable to initialize audit log:", err)
+		os.Exit(1)
+	}
+	defer auditLog.Close()
+	h.SetAudit(auditLog)
+	h.SetHistory(headings)
 
 	tcpError := make(chan bool)
 
@@ -168,7 +251,7 @@ func lanServer(cmd *cobra.Command, args []string) {
 	mDNSShutdown := make(chan struct{})
 
 	if viper.GetBool("discovery.enabled") {
-		if err := startMdnsServer(mDNSShutdown); err != nil {
+		if err := startMdnsServer(mDNSShutdown, tlsConfig != nil); err != nil {
 			log.Println(err)
 		}
 	}
@@ -199,7 +282,7 @@ func lanServer(cmd *cobra.Command, args []string) {
 	}
 }
 
-func startMdnsServer(shutdown <-chan struct{}) error {
+func startMdnsServer(shutdown <-chan struct{}, tls bool) error {
 
 	if !viper.GetBool("http.enabled") {
 		return fmt.Errorf("discovery disabled; the HTTP server must be enabled and accessible over a network interface (e.g. 0.0.0.0)")
@@ -214,9 +297,15 @@ func startMdnsServer(shutdown <-chan struct{}) error {
 	}
 
 	go func() {
+		// let the web server know that it has to connect via https / wss
+		var txt []string
+		if tls {
+			txt = append(txt, "tls=true")
+		}
+
 		mDNSService, err := mdns.NewMDNSService(viper.GetString("rotator.name"),
 			"_rotator._tcp", "", "", viper.GetInt("http.port"),
-			[]net.IP{getOutboundIP()}, nil)
+			[]net.IP{getOutboundIP()}, txt)
 
 		if err != nil {
 			log.Printf("discovery disabled; unable to start mDNS service: %s\n", err)
@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/asim/go-micro/v3/metadata"
+	"github.com/dh1tw/remoteRotator/audit"
+	"github.com/dh1tw/remoteRotator/hub"
+)
+
+// errLeaseToken is returned if a NATS caller tries to take the control
+// without a lease token.
+var errLeaseToken = errors.New("the Lease-Token metadata is required to take the control")
+
+// Control implements the RPC service through which NATS callers take
+// and release the exclusive control of the rotator (see hub.Locks).
+// A lease belongs to the secret "Lease-Token" metadata of the caller;
+// the "Operator" metadata is only displayed. An admin token in the
+// request overrides the lease of another caller.
+// The messages are encoded as json, so the callers must use the content
+// type "application/json".
+type Control struct {
+	rot *rpcRotator
+}
+
+// Take takes or renews the control of the rotator.
+func (c *Control) Take(ctx context.Context, req *hub.LockRequest, resp *hub.Lease) error {
+	d, err := leaseDuration(req)
+	if err != nil {
+		return err
+	}
+
+	owner, operator, remote := rpcCaller(ctx)
+	name := c.rot.rotator.Name()
+
+	var lease hub.Lease
+	command := hub.WsTakeControl
+	switch {
+	case owner == "":
+		err = errLeaseToken
+	case req.Token != "":
+		command = hub.WsOverride
+		lease, err = c.rot.locks.Override(name, owner, operator, req.Token, d)
+	default:
+		lease, err = c.rot.locks.Acquire(name, owner, operator, d)
+	}
+	c.record(name, operator, remote, command, req.Duration, err)
+	if err != nil {
+		return err
+	}
+
+	*resp = lease
+	return nil
+}
+
+// Release gives up the control of the rotator.
+func (c *Control) Release(ctx context.Context, req *hub.LockRequest, resp *hub.Lease) error {
+	owner, operator, remote := rpcCaller(ctx)
+	name := c.rot.rotator.Name()
+
+	var err error
+	command := hub.WsRelease
+	if req.Token != "" {
+		command = "override_release"
+		_, err = c.rot.locks.Override(name, "", "", req.Token, 0)
+	} else {
+		err = c.rot.locks.Release(name, owner)
+	}
+	c.record(name, operator, remote, command, "", err)
+
+	return err
+}
+
+func (c *Control) record(name, operator, remote, command, duration string, err error) {
+	rec := audit.Record{
+		Frontend: audit.NATS,
+		Client:   remote,
+		User:     operator,
+		Rotator:  name,
+		Command:  command,
+		Duration: duration,
+	}
+	if err != nil {
+		rec.Error = err.Error()
+	}
+	c.rot.audit.Record(rec)
+}
+
+// leaseDuration returns the requested duration of the lease; 0 selects
+// the default lease time.
+func leaseDuration(req *hub.LockRequest) (time.Duration, error) {
+	if req.Duration == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(req.Duration)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration '%s'", req.Duration)
+	}
+	return d, nil
+}
+
+// rpcCaller returns the owner of the NATS caller's leases, the operator
+// name it claims and its address. NATS doesn't reveal who sent a
+// request, so the owner is derived from the secret "Lease-Token"
+// metadata; only its hash is shown in the leases. Callers without a
+// token are anonymous (empty owner): they can't take the control and
+// their commands are refused while the rotator is leased. The operator
+// ("Operator" metadata, "nats" if missing) is only displayed.
+func rpcCaller(ctx context.Context) (owner, operator, remote string) {
+	if token, ok := metadata.Get(ctx, "Lease-Token"); ok && token != "" {
+		sum := sha256.Sum256([]byte(token))
+		owner = "nats:" + hex.EncodeToString(sum[:6])
+	}
+	operator = "nats"
+	if op, ok := metadata.Get(ctx, "Operator"); ok && op != "" {
+		operator = op
+	}
+	remote, _ = metadata.Get(ctx, "Remote")
+	return owner, operator, remote
+}
@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dh1tw/remoteRotator/rotator/move"
+)
+
+// Mover implements the RPC shackbus.Rotator.Mover service. A move
+// replies once the rotator has arrived, stalled or the time is up; the
+// outcome is reported in the state of the progress. Errors are only
+// returned if the move has been refused. The messages are encoded as
+// json, so the callers must use the content type "application/json"
+// and a request timeout longer than the timeout of the move.
+type Mover struct {
+	rot *rpcRotator
+}
+
+// Azimuth turns the rotator to the azimuth and waits for its arrival.
+func (m *Mover) Azimuth(ctx context.Context, req *move.Request, resp *move.Progress) error {
+	mv, err := newMover(req)
+	if err != nil {
+		return err
+	}
+	p, err := mv.Azimuth(ctx, m.rot.controller(ctx), req.Heading)
+	if p.State == "" {
+		return err
+	}
+	*resp = p
+	return nil
+}
+
+// Elevation turns the rotator to the elevation and waits for its
+// arrival.
+func (m *Mover) Elevation(ctx context.Context, req *move.Request, resp *move.Progress) error {
+	mv, err := newMover(req)
+	if err != nil {
+		return err
+	}
+	p, err := mv.Elevation(ctx, m.rot.controller(ctx), req.Heading)
+	if p.State == "" {
+		return err
+	}
+	*resp = p
+	return nil
+}
+
+// newMover returns a Mover with the timeout and tolerance of the request.
+func newMover(req *move.Request) (*move.Mover, error) {
+	opts := []func(*move.Mover){}
+
+	if req.Timeout != "" {
+		d, err := time.ParseDuration(req.Timeout)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid timeout '%s'", req.Timeout)
+		}
+		opts = append(opts, move.Timeout(d))
+	}
+	if req.Tolerance != nil {
+		opts = append(opts, move.Tolerance(*req.Tolerance))
+	}
+
+	return move.New(opts...), nil
+}
@@ -12,6 +12,8 @@ import (
 	natsReg "github.com/asim/go-micro/plugins/registry/nats/v3"
 	natsTr "github.com/asim/go-micro/plugins/transport/nats/v3"
 	micro "github.com/asim/go-micro/v3"
+	"github.com/dh1tw/remoteRotator/audit"
+	"github.com/dh1tw/remoteRotator/hub"
 	"github.com/dh1tw/remoteRotator/rotator"
 	sbRotator "github.com/dh1tw/remoteRotator/sb_rotator"
 	"google.golang.org/protobuf/proto"
@@ -52,18 +54,27 @@ not support full 360°.
 func init() {
 	serverCmd.AddCommand(natsServerCmd)
 
-	natsServerCmd.Flags().StringP("portname", "d", "/dev/ttyACM0", "portname / path to the rotator (e.g. COM1)")
+	natsServerCmd.Flags().StringP("portname", "d", "/dev/ttyACM0", "portname / path or url to the rotator (e.g. COM1, serial:///dev/ttyUSB0?baud=4800, rfc2217://host:port) or 'auto' to detect the port")
 	natsServerCmd.Flags().IntP("baudrate", "b", 9600, "baudrate")
 	natsServerCmd.Flags().StringP("type", "t", "yaesu", "Rotator type (supported: yaesu, dummy")
 	natsServerCmd.Flags().StringP("name", "n", "myRotator", "Name tag for the rotator")
 	natsServerCmd.Flags().BoolP("has-azimuth", "", true, "rotator supports Azimuth")
 	natsServerCmd.Flags().BoolP("has-elevation", "", false, "rotator supports Elevation")
 	natsServerCmd.Flags().DurationP("pollingrate", "", time.Second*1, "rotator polling rate")
+	natsServerCmd.Flags().DurationP("pollingrate-fast", "", 0, "rotator polling rate while moving (0 = disabled)")
+	natsServerCmd.Flags().DurationP("command-interval", "", 0, "minimum interval between two set commands sent to the rotator (0 = disabled)")
 	natsServerCmd.Flags().IntP("azimuth-min", "", 0, "metadata: minimum azimuth (in deg)")
 	natsServerCmd.Flags().IntP("azimuth-max", "", 360, "metadata: maximum azimuth (in deg)")
 	natsServerCmd.Flags().IntP("azimuth-stop", "", 0, "metadata: mechanical azimuth stop (in deg)")
 	natsServerCmd.Flags().IntP("elevation-min", "", 0, "metadata: minimum elevation (in deg)")
 	natsServerCmd.Flags().IntP("elevation-max", "", 180, "metadata: maximum elevation (in deg)")
+	natsServerCmd.Flags().BoolP("state-enabled", "", false, "restore the presets, leases and unacknowledged faults after a restart")
+	natsServerCmd.Flags().IntP("azimuth-offset", "", 0, "calibration: correction of the azimuth indicator (true = indicated + offset)")
+	natsServerCmd.Flags().IntP("mounting-offset", "", 0, "calibration: direction of the antenna relative to the zero of the rotator")
+	natsServerCmd.Flags().BoolP("monitor-enabled", "", false, "stop and lock out the rotator on stalls, runaways and limit violations")
+	natsServerCmd.Flags().DurationP("stall-timeout", "", time.Second*10, "time after which a commanded rotator without heading change is considered stalled")
+	natsServerCmd.Flags().DurationP("duty-budget", "", 0, "maximum continuous motor-on time (0 = duty cycle limiter disabled)")
+	natsServerCmd.Flags().DurationP("duty-cool-down", "", time.Minute*15, "time the motor needs to recover the full duty cycle budget")
 	natsServerCmd.Flags().StringP("broker-url", "u", "localhost", "Broker URL")
 	natsServerCmd.Flags().IntP("broker-port", "p", 4222, "Broker Port")
 	natsServerCmd.Flags().StringP("password", "P", "", "NATS Password")
@@ -86,6 +97,7 @@ func natsServer(cmd *cobra.Command, args []string) {
 	}
 
 	// bind the pflags to viper settings
+	viper.BindPFlag("state.enabled", cmd.Flags().Lookup("state-enabled"))
 	viper.BindPFlag("rotator.portname", cmd.Flags().Lookup("portname"))
 	viper.BindPFlag("rotator.baudrate", cmd.Flags().Lookup("baudrate"))
 	viper.BindPFlag("rotator.type", cmd.Flags().Lookup("type"))
@@ -93,11 +105,19 @@ func natsServer(cmd *cobra.Command, args []string) {
 	viper.BindPFlag("rotator.has-azimuth", cmd.Flags().Lookup("has-azimuth"))
 	viper.BindPFlag("rotator.has-elevation", cmd.Flags().Lookup("has-elevation"))
 	viper.BindPFlag("rotator.pollingrate", cmd.Flags().Lookup("pollingrate"))
+	viper.BindPFlag("rotator.pollingrate-fast", cmd.Flags().Lookup("pollingrate-fast"))
+	viper.BindPFlag("rotator.command-interval", cmd.Flags().Lookup("command-interval"))
 	viper.BindPFlag("rotator.azimuth-min", cmd.Flags().Lookup("azimuth-min"))
 	viper.BindPFlag("rotator.azimuth-max", cmd.Flags().Lookup("azimuth-max"))
 	viper.BindPFlag("rotator.azimuth-stop", cmd.Flags().Lookup("azimuth-stop"))
 	viper.BindPFlag("rotator.elevation-min", cmd.Flags().Lookup("elevation-min"))
 	viper.BindPFlag("rotator.elevation-max", cmd.Flags().Lookup("elevation-max"))
+	viper.BindPFlag("rotator.calibration.offset", cmd.Flags().Lookup("azimuth-offset"))
+	viper.BindPFlag("rotator.calibration.mounting", cmd.Flags().Lookup("mounting-offset"))
+	viper.BindPFlag("rotator.monitor.enabled", cmd.Flags().Lookup("monitor-enabled"))
+	viper.BindPFlag("rotator.monitor.stall-timeout", cmd.Flags().Lookup("stall-timeout"))
+	viper.BindPFlag("rotator.duty.budget", cmd.Flags().Lookup("duty-budget"))
+	viper.BindPFlag("rotator.duty.cool-down", cmd.Flags().Lookup("duty-cool-down"))
 	viper.BindPFlag("nats.broker-url", cmd.Flags().Lookup("broker-url"))
 	viper.BindPFlag("nats.broker-port", cmd.Flags().Lookup("broker-port"))
 	viper.BindPFlag("nats.password", cmd.Flags().Lookup("password"))
@@ -113,19 +133,47 @@ func natsServer(cmd *cobra.Command, args []string) {
 	// 	log.Println(http.ListenAndServe("0.0.0.0:6060", http.DefaultServeMux))
 	// }()
 
+	st, err := initState()
+	if err != nil {
+		fmt.Println("unable to initialize state:", err)
+		os.Exit(1)
+	}
+	defer st.Close()
+
 	// struct which holds the rotator.Rotator instance, implements the
 	// RPC Service methods and publishes changes via the Broker
-	rpcRot := &rpcRotator{}
+	rpcRot := &rpcRotator{
+		locks: initLocks(st),
+	}
+
+	auditLog, err := initAudit()
+	if err != nil {
+		fmt.Println("unable to initialize audit log:", err)
+		os.Exit(1)
+	}
+	defer auditLog.Close()
+	rpcRot.audit = auditLog
 
 	rotatorError := make(chan struct{})
 
-	// initialize our Rotator
-	r, err := initRotator(viper.GetString("rotator.type"), rpcRot.PublishState, rotatorError)
+	// initialize our Rotator; the shackbus protocol doesn't support
+	// status messages, faults are only logged
+	r, err := initRotator(viper.GetString("rotator.type"), rpcRot.PublishState, nil, rotatorError, st)
 	if err != nil {
 		fmt.Println("unable to initialize rotator:", err)
 		os.Exit(1)
 	}
 
+	// park the rotator when the wind is too strong
+	windGuard, err := startWindGuard(r)
+	if err != nil {
+		fmt.Println("unable to initialize wind parking:", err)
+		os.Exit(1)
+	}
+	if windGuard != nil {
+		defer windGuard.Close()
+	}
+
 	// better call this Addrs(?)
 	serviceName := fmt.Sprintf("shackbus.rotator.%s", viper.GetString("rotator.name"))
 
@@ -180,6 +228,9 @@ func natsServer(cmd *cobra.Command, args []string) {
 		server.Transport(tr),
 		server.Registry(reg),
 		server.Broker(br),
+		// the antennas are announced through the metadata of the
+		// service; the protobuf metadata doesn't cover them
+		server.Metadata(antennaMetadata(r)),
 	)
 
 	// version is typically defined through a git tag and injected during
@@ -220,6 +271,16 @@ func natsServer(cmd *cobra.Command, args []string) {
 	// register our Rotator RPC handler
 	sbRotator.RegisterRotatorHandler(rs.Server(), rpcRot)
 
+	// the move waits for the arrival of the rotator (see Mover)
+	if err := rs.Server().Handle(rs.Server().NewHandler(&Mover{rpcRot})); err != nil {
+		log.Fatal(err)
+	}
+
+	// the callers take the exclusive control of the rotator (see Control)
+	if err := rs.Server().Handle(rs.Server().NewHandler(&Control{rpcRot})); err != nil {
+		log.Fatal(err)
+	}
+
 	rpcRot.initialized = true
 
 	go func() {
@@ -245,9 +306,19 @@ type rpcRotator struct {
 	initialized bool
 	service     micro.Service
 	rotator     rotator.Rotator
+	locks       *hub.Locks
+	audit       *audit.Logger
 	pubSubTopic string
 }
 
+// controller returns a view of the rotator for the caller, which only
+// accepts commands if the rotator is not leased by another client (see
+// rpcCaller). All commands are recorded in the audit log.
+func (r *rpcRotator) controller(ctx context.Context) rotator.Rotator {
+	owner, operator, remote := rpcCaller(ctx)
+	return audit.Bind(r.locks.Bind(r.rotator, owner), r.audit, audit.NATS, remote, operator)
+}
+
 func (r *rpcRotator) PublishState(rot rotator.Rotator, heading rotator.Heading) {
 
 	if !r.initialized {
@@ -278,7 +349,7 @@ func (r *rpcRotator) PublishState(rot rotator.Rotator, heading rotator.Heading)
 //implementation of the RPC shackbus.Rotator.Rotator Service
 func (r *rpcRotator) SetAzimuth(ctx context.Context, req *sbRotator.HeadingReq, resp *sbRotator.None) error {
 	if r.rotator.HasAzimuth() {
-		err := r.rotator.SetAzimuth(int(req.Heading))
+		err := r.controller(ctx).SetAzimuth(int(req.Heading))
 		return err
 	}
 	return fmt.Errorf("rotator does not support azimuth")
@@ -286,7 +357,7 @@ func (r *rpcRotator) SetAzimuth(ctx context.Context, req *sbRotator.HeadingReq,
 
 func (r *rpcRotator) SetElevation(ctx context.Context, req *sbRotator.HeadingReq, resp *sbRotator.None) error {
 	if r.rotator.HasElevation() {
-		err := r.rotator.SetElevation(int(req.Heading))
+		err := r.controller(ctx).SetElevation(int(req.Heading))
 		return err
 	}
 	return fmt.Errorf("rotator does not support elevation")
@@ -294,14 +365,14 @@ func (r *rpcRotator) SetElevation(ctx context.Context, req *sbRotator.HeadingReq
 
 func (r *rpcRotator) StopAzimuth(ctx context.Context, req *sbRotator.None, resp *sbRotator.None) error {
 	if r.rotator.HasAzimuth() {
-		return r.rotator.StopAzimuth()
+		return r.controller(ctx).StopAzimuth()
 	}
 	return fmt.Errorf("rotator does not support azimuth")
 }
 
 func (r *rpcRotator) StopElevation(ctx context.Context, req *sbRotator.None, resp *sbRotator.None) error {
 	if r.rotator.HasElevation() {
-		return r.rotator.StopElevation()
+		return r.controller(ctx).StopElevation()
 	}
 	return fmt.Errorf("rotator does not support elevation")
 }
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/dh1tw/remoteRotator/rotator/probe"
+	"github.com/spf13/cobra"
+)
+
+var probeCmd = &cobra.Command{
+	Use:   "probe",
+	Short: "detect rotator controllers connected to the serial ports",
+	Long: `detect rotator controllers connected to the serial ports
+
+This command enumerates the local serial ports and sends the handshake of each
+known rotator protocol (Yaesu GS-232, SPID Rot2Prog, EasyComm) at common
+baudrates. All found controllers are reported with their port and baudrate.
+
+The servers perform the same detection when started with '--portname auto'.`,
+	Run: probePorts,
+}
+
+func init() {
+	RootCmd.AddCommand(probeCmd)
+	probeCmd.Flags().StringSliceP("ports", "P", nil, "ports to be probed (default: all local serial ports)")
+	probeCmd.Flags().IntSliceP("baudrates", "b", nil, "baudrates to be probed (default: 9600,4800,1200,600,19200,38400,115200)")
+}
+
+func probePorts(cmd *cobra.Command, args []string) {
+
+	ports, _ := cmd.Flags().GetStringSlice("ports")
+	if len(ports) == 0 {
+		ports = probe.SerialPorts()
+	}
+
+	opts := []func(*probe.Prober){}
+	if bauds, _ := cmd.Flags().GetIntSlice("baudrates"); len(bauds) > 0 {
+		opts = append(opts, probe.Baudrates(bauds...))
+	}
+
+	fmt.Printf("\n...probing %d port(s) (please wait)\n", len(ports))
+	results := probe.New(opts...).Scan(ports)
+
+	if err := probeTmpl.Execute(os.Stdout, results); err != nil {
+		fmt.Println(err)
+	}
+}
+
+var probeTmpl = template.Must(template.New("").Parse(
+	`
+Found {{. | len}} rotator controller(s)
+
+{{range .}}Controller:
+   Port:         {{.Port}}
+   Baudrate:     {{.Baudrate}}
+   Protocol:     {{.Protocol.Name}}
+   Type:         {{if .Protocol.Driver}}{{.Protocol.Driver}}{{else}}(no driver available){{end}}
+   Response:     {{printf "%q" .Response}}
+
+{{end}}
+`,
+))
+
+// autoDetectPort probes the local serial ports and returns the port and
+// baudrate of the first controller which can be handled by the driver
+// of the rotator type.
+func autoDetectPort(rType string) (string, int, error) {
+
+	ports := probe.SerialPorts()
+	prober := probe.New()
+
+	for _, pn := range ports {
+		res, err := prober.Probe(pn)
+		if err != nil {
+			continue
+		}
+		if strings.EqualFold(res.Protocol.Driver, rType) {
+			return res.Port, res.Baudrate, nil
+		}
+	}
+
+	return "", 0, fmt.Errorf("no %s rotator found on the serial ports %v", rType, ports)
+}
@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+
+	"github.com/dh1tw/remoteRotator/rotator"
+	"github.com/dh1tw/remoteRotator/rotator/dummy"
+	"github.com/dh1tw/remoteRotator/rotator/simulator"
+	"github.com/spf13/cobra"
+)
+
+var simulateCmd = &cobra.Command{
+	Use:   "simulate",
+	Short: "simulate a Yaesu GS-232 rotator controller",
+	Long: `simulate a Yaesu GS-232 rotator controller
+
+This command emulates the serial interface of a Yaesu GS-232A/B controller on a
+pseudo terminal (Linux only) or a TCP socket. The simulated rotator moves like
+the dummy rotator. remoteRotator (--portname) or any other software supporting
+the GS-232 protocol can be connected to the simulator.
+
+Faults can be injected in order to test the behaviour on unreliable links:
+noisy headings, dropped bytes and garbled replies.`,
+	Run: simulate,
+}
+
+func init() {
+	RootCmd.AddCommand(simulateCmd)
+	simulateCmd.Flags().StringP("tcp-address", "t", "", "listen on this TCP address (e.g. 127.0.0.1:6001) instead of a pty")
+	simulateCmd.Flags().StringP("protocol", "", "A", "emulated protocol variant (A = GS-232A, B = GS-232B)")
+	simulateCmd.Flags().BoolP("has-elevation", "", false, "simulated rotator supports elevation")
+	simulateCmd.Flags().IntP("azimuth-speed", "", 8, "azimuth speed (deg/sec)")
+	simulateCmd.Flags().IntP("elevation-speed", "", 5, "elevation speed (deg/sec)")
+	simulateCmd.Flags().Float64P("acceleration", "", 0, "acceleration / deceleration (deg/sec²); 0 = no inertia")
+	simulateCmd.Flags().DurationP("start-delay", "", 0, "delay until the motor starts turning")
+	simulateCmd.Flags().DurationP("brake-release", "", 0, "time needed to release the azimuth brake")
+	simulateCmd.Flags().IntP("azimuth-max", "", 450, "maximum azimuth (> 360 for overlap)")
+	simulateCmd.Flags().IntP("azimuth-stop", "", 0, "mechanical stop of the rotator")
+	simulateCmd.Flags().IntP("noise", "", 0, "maximum random deviation of the reported headings (deg)")
+	simulateCmd.Flags().Float64P("drop-rate", "", 0, "probability (0...1) that a byte of a reply is dropped")
+	simulateCmd.Flags().Float64P("garble-rate", "", 0, "probability (0...1) that a reply is garbled")
+}
+
+func simulate(cmd *cobra.Command, args []string) {
+
+	hasElevation, _ := cmd.Flags().GetBool("has-elevation")
+	azSpeed, _ := cmd.Flags().GetInt("azimuth-speed")
+	elSpeed, _ := cmd.Flags().GetInt("elevation-speed")
+	accel, _ := cmd.Flags().GetFloat64("acceleration")
+	startDelay, _ := cmd.Flags().GetDuration("start-delay")
+	brakeRelease, _ := cmd.Flags().GetDuration("brake-release")
+	azMax, _ := cmd.Flags().GetInt("azimuth-max")
+	azStop, _ := cmd.Flags().GetInt("azimuth-stop")
+	protocol, _ := cmd.Flags().GetString("protocol")
+	noise, _ := cmd.Flags().GetInt("noise")
+	dropRate, _ := cmd.Flags().GetFloat64("drop-rate")
+	garbleRate, _ := cmd.Flags().GetFloat64("garble-rate")
+	tcpAddress, _ := cmd.Flags().GetString("tcp-address")
+
+	if protocol != simulator.GS232A && protocol != simulator.GS232B {
+		fmt.Printf("unknown protocol variant %s (A or B)\n", protocol)
+		os.Exit(1)
+	}
+
+	r, err := dummy.New(
+		dummy.Name("simulator"),
+		dummy.HasElevation(hasElevation),
+		dummy.AzimuthSpeed(azSpeed),
+		dummy.ElevationSpeed(elSpeed),
+		dummy.AzimuthMax(azMax),
+		dummy.AzimuthStop(azStop),
+		dummy.Acceleration(accel),
+		dummy.StartDelay(startDelay),
+		dummy.BrakeRelease(brakeRelease),
+		dummy.EventHandler(func(rotator.Rotator, rotator.Heading) {}),
+	)
+	if err != nil {
+		log.Println(err)
+		os.Exit(1)
+	}
+	defer r.Close()
+
+	sim := simulator.New(r,
+		simulator.Protocol(protocol),
+		simulator.Noise(noise),
+		simulator.DropRate(dropRate),
+		simulator.GarbleRate(garbleRate),
+	)
+
+	errCh := make(chan error, 1)
+
+	if tcpAddress != "" {
+		log.Printf("simulating GS-232%s controller on tcp://%s\n", protocol, tcpAddress)
+		go func() {
+			errCh <- sim.ListenTCP(tcpAddress)
+		}()
+	} else {
+		pty, err := simulator.OpenPty()
+		if err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
+		defer pty.Close()
+
+		log.Printf("simulating GS-232%s controller on %s\n", protocol, pty.Name)
+		go func() {
+			errCh <- sim.Serve(pty.Master)
+		}()
+	}
+
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt)
+
+	select {
+	case <-c:
+	case err := <-errCh:
+		log.Println(err)
+	}
+}
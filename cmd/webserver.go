@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"crypto/tls"
 	"fmt"
 	"log"
 	"os"
@@ -43,6 +44,11 @@ func init() {
 	webServerCmd.Flags().StringP("broker-url", "u", "localhost", "Broker URL")
 	webServerCmd.Flags().IntP("broker-port", "p", 4222, "Broker Port")
 	webServerCmd.Flags().StringP("password", "P", "", "NATS Password")
+	webServerCmd.Flags().StringP("tls-cert", "", "", "TLS certificate (PEM) for HTTPS / wss")
+	webServerCmd.Flags().StringP("tls-key", "", "", "TLS private key (PEM) for HTTPS / wss")
+	webServerCmd.Flags().BoolP("tls-self-signed", "", false, "generate a self-signed TLS certificate if the certificate doesn't exist")
+	webServerCmd.Flags().StringSliceP("allowed-origins", "", nil, "origins of third party web pages which may use the REST API and websocket ('*' for all)")
+	webServerCmd.Flags().StringSliceP("tls-pin", "", nil, "SHA-256 fingerprints of the (self-signed) certificates accepted from lan rotators")
 	webServerCmd.Flags().StringP("username", "U", "", "NATS Username")
 }
 
@@ -73,6 +79,11 @@ func webServer(cmd *cobra.Command, args []string) {
 	viper.BindPFlag("nats.broker-port", cmd.Flags().Lookup("broker-port"))
 	viper.BindPFlag("nats.password", cmd.Flags().Lookup("password"))
 	viper.BindPFlag("nats.username", cmd.Flags().Lookup("username"))
+	viper.BindPFlag("web.tls-cert", cmd.Flags().Lookup("tls-cert"))
+	viper.BindPFlag("web.tls-key", cmd.Flags().Lookup("tls-key"))
+	viper.BindPFlag("web.tls-self-signed", cmd.Flags().Lookup("tls-self-signed"))
+	viper.BindPFlag("web.tls-pins", cmd.Flags().Lookup("tls-pin"))
+	viper.BindPFlag("web.allowed-origins", cmd.Flags().Lookup("allowed-origins"))
 
 	h, err := hub.NewHub()
 	if err != nil {
@@ -149,7 +160,31 @@ func webServer(cmd *cobra.Command, args []string) {
 		ttl:   time.Second * 25,
 		cache: make(map[string]time.Time),
 	}
-	w := webserver{h, cl, cache}
+	comps, err := initComposites("web", h)
+	if err != nil {
+		fmt.Println("unable to initialize composite rotators:", err)
+		os.Exit(1)
+	}
+
+	w := webserver{h, cl, cache, comps}
+
+	tlsConfig, err := initTLS("web")
+	if err != nil {
+		fmt.Println("unable to initialize TLS:", err)
+		os.Exit(1)
+	}
+	w.SetTLS(tlsConfig)
+	w.SetOrigins(viper.GetStringSlice("web.allowed-origins")...)
+
+	groups, err := initGroups("web")
+	if err != nil {
+		fmt.Println("unable to initialize groups:", err)
+		os.Exit(1)
+	}
+	if err := w.SetGroups(groups...); err != nil {
+		fmt.Println("invalid groups:", err)
+		os.Exit(1)
+	}
 
 	// will be closed when an error occurs in the webserver goroutine
 	webserverErrorCh := make(chan struct{})
@@ -204,6 +239,9 @@ func webServer(cmd *cobra.Command, args []string) {
 				Heading:     u.heading,
 			}
 			w.Broadcast(ev)
+			if r, ok := w.Rotator(u.rotatorName); ok {
+				w.composites.handle(r, u.heading)
+			}
 		}
 	}
 }
@@ -216,8 +254,9 @@ type serviceCache struct {
 
 type webserver struct {
 	*hub.Hub
-	cli   client.Client
-	cache *serviceCache
+	cli        client.Client
+	cache      *serviceCache
+	composites *composites
 }
 
 type update struct {
@@ -255,9 +294,10 @@ func (w *webserver) addRotator(rotatorServiceName string) error {
 	eh := sbProxy.EventHandler(ev)
 	name := sbProxy.Name(rotatorName)
 	serviceName := sbProxy.ServiceName(strings.Replace(rotatorServiceName, " ", "_", -1))
+	antennas := sbProxy.Antennas(w.serviceAntennas(rotatorServiceName))
 
 	// create new rotator proxy object
-	r, err := sbProxy.New(done, cli, eh, name, serviceName)
+	r, err := sbProxy.New(done, cli, eh, name, serviceName, antennas)
 	if err != nil {
 		close(doneCh)
 		return fmt.Errorf("unable to create proxy object: %v", err)
@@ -267,16 +307,39 @@ func (w *webserver) addRotator(rotatorServiceName string) error {
 		close(doneCh)
 		return fmt.Errorf("unable to add proxy objects: %v", err)
 	}
+	w.composites.update()
 
 	go func() {
 		<-doneCh
 		// fmt.Println("disposing:", r.Name())
 		w.RemoveRotator(r)
+		w.composites.update()
 	}()
 
 	return nil
 }
 
+// serviceAntennas returns the antennas announced in the registry by
+// the rotator service.
+func (w *webserver) serviceAntennas(rotatorServiceName string) []rotator.Antenna {
+
+	services, err := w.cli.Options().Registry.GetService(rotatorServiceName)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+
+	for _, service := range services {
+		for _, node := range service.Nodes {
+			if antennas := antennasFromMetadata(node.Metadata); antennas != nil {
+				return antennas
+			}
+		}
+	}
+
+	return nil
+}
+
 // listAndAddRotators is a convenience function which queries the
 // registry for all rotator services and then add proxy objects for
 // each of them.
@@ -376,7 +439,14 @@ func (w *webserver) update() {
 		host := proxy.Host(dr.AddrV4.String())
 		port := proxy.Port(dr.Port)
 		eh := proxy.EventHandler(ev)
-		r, err := proxy.New(done, host, port, eh)
+		opts := []func(*proxy.Proxy){done, host, port, eh}
+		if dr.TLS {
+			opts = append(opts, proxy.TLS(&tls.Config{MinVersion: tls.VersionTLS12}))
+			if pins := viper.GetStringSlice("web.tls-pins"); len(pins) > 0 {
+				opts = append(opts, proxy.PinnedCertificates(pins...))
+			}
+		}
+		r, err := proxy.New(opts...)
 		if err != nil {
 			log.Println("unable to create proxy object:", err)
 			r.Close()
@@ -387,9 +457,11 @@ func (w *webserver) update() {
 			log.Println(err)
 			continue
 		}
+		w.composites.update()
 		go func() {
 			<-doneCh
 			w.RemoveRotator(r)
+			w.composites.update()
 		}()
 	}
 }
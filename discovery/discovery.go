@@ -15,6 +15,7 @@ type RotatorMdnsEntry struct {
 	AddrV4 net.IP
 	AddrV6 net.IP
 	Port   int
+	TLS    bool // the rotator only accepts https / wss connections
 }
 
 // LookupRotators will perform an mDNS query are lookup all available
@@ -47,6 +48,11 @@ func LookupRotators() ([]RotatorMdnsEntry, error) {
 				AddrV6: entry.AddrV6,
 				Port:   entry.Port,
 			}
+			for _, field := range entry.InfoFields {
+				if field == "tls=true" {
+					r.TLS = true
+				}
+			}
 			rotators = append(rotators, r)
 		}
 	}()
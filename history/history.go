@@ -0,0 +1,252 @@
+// Package history stores the headings of the rotators as a time series
+// in an embedded database (bbolt), so that the heading of an antenna can
+// be correlated with signal reports and propagation logs afterwards.
+package history
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/dh1tw/remoteRotator/rotator"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Sample is the heading of a rotator at a point in time.
+type Sample struct {
+	Time time.Time `json:"time"`
+	rotator.Heading
+}
+
+// ErrTooManySamples is returned if a query would return more samples
+// than allowed.
+var ErrTooManySamples = errors.New("too many samples; increase the step or reduce the time range")
+
+// Store persists the headings of the rotators. Each rotator has its own
+// bucket; the samples are keyed by their timestamp.
+type Store struct {
+	db         *bolt.DB
+	retention  time.Duration
+	maxSamples int
+	closeCh    chan struct{}
+}
+
+// Open opens (or creates) the history database at the path.
+// Configuration settings can be set through functional options.
+// Default settings are:
+// retention: 90 days,
+// maxSamples: 100000 (per query).
+func Open(path string, opts ...func(*Store)) (*Store, error) {
+
+	s := &Store{
+		retention:  time.Hour * 24 * 90,
+		maxSamples: 100000,
+		closeCh:    make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	db, err := bolt.Open(path, 0o640, &bolt.Options{Timeout: time.Second * 2})
+	if err != nil {
+		return nil, fmt.Errorf("unable to open history database %s: %v", path, err)
+	}
+	s.db = db
+
+	if s.retention > 0 {
+		go s.prune()
+	}
+
+	return s, nil
+}
+
+// Retention is a functional option to set the time after which samples
+// are deleted. A retention <= 0 keeps the samples forever.
+func Retention(d time.Duration) func(*Store) {
+	return func(s *Store) {
+		s.retention = d
+	}
+}
+
+// MaxSamples is a functional option to set the maximum number of
+// samples which are returned by a query.
+func MaxSamples(n int) func(*Store) {
+	return func(s *Store) {
+		s.maxSamples = n
+	}
+}
+
+// Close closes the database.
+func (s *Store) Close() error {
+	if s == nil {
+		return nil
+	}
+	close(s.closeCh)
+	return s.db.Close()
+}
+
+// Add stores the heading of the rotator with the current time. It can
+// be used directly in the event handler of a rotator. Errors are
+// logged.
+func (s *Store) Add(r rotator.Rotator, h rotator.Heading) {
+	if s == nil {
+		return
+	}
+	if err := s.Insert(r.Name(), Sample{Time: time.Now(), Heading: h}); err != nil {
+		log.Printf("unable to store heading of %s: %v\n", r.Name(), err)
+	}
+}
+
+// Insert stores a sample of the rotator. Concurrent inserts are written
+// in batches.
+func (s *Store) Insert(rName string, smpl Sample) error {
+	return s.db.Batch(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(rName))
+		if err != nil {
+			return err
+		}
+		return b.Put(encodeKey(smpl.Time), encodeHeading(smpl.Heading))
+	})
+}
+
+// Query returns the samples of the rotator between from and to. If step
+// is > 0, the time series is downsampled to one sample per step, which
+// contains the heading at this point in time (the latest sample before
+// it). Otherwise all recorded samples are returned; the first sample is
+// the heading at the time 'from' (if known).
+func (s *Store) Query(rName string, from, to time.Time, step time.Duration) ([]Sample, error) {
+
+	if to.Before(from) {
+		return nil, fmt.Errorf("invalid time range")
+	}
+	if step > 0 && int64(to.Sub(from)/step) >= int64(s.maxSamples) {
+		return nil, ErrTooManySamples
+	}
+
+	samples := []Sample{}
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(rName))
+		if b == nil {
+			return nil
+		}
+		c := b.Cursor()
+
+		// the heading at 'from' is the latest sample before
+		var current *Sample
+		k, v := c.Seek(encodeKey(from))
+		if k == nil || decodeKey(k).After(from) {
+			var pk, pv []byte
+			if k == nil {
+				pk, pv = c.Last()
+			} else {
+				pk, pv = c.Prev()
+			}
+			if pk != nil {
+				current = &Sample{Time: from, Heading: decodeHeading(pv)}
+			}
+			// restore the cursor position
+			k, v = c.Seek(encodeKey(from))
+		}
+
+		if step <= 0 {
+			if current != nil {
+				samples = append(samples, *current)
+			}
+			for ; k != nil && !decodeKey(k).After(to); k, v = c.Next() {
+				if len(samples) >= s.maxSamples {
+					return ErrTooManySamples
+				}
+				samples = append(samples, Sample{Time: decodeKey(k), Heading: decodeHeading(v)})
+			}
+			return nil
+		}
+
+		for t := from; !t.After(to); t = t.Add(step) {
+			// advance to the latest sample at or before t
+			for ; k != nil && !decodeKey(k).After(t); k, v = c.Next() {
+				current = &Sample{Heading: decodeHeading(v)}
+			}
+			if current == nil {
+				continue // no heading known yet
+			}
+			samples = append(samples, Sample{Time: t, Heading: current.Heading})
+		}
+
+		return nil
+	})
+
+	return samples, err
+}
+
+// prune deletes the samples which are older than the retention time
+// once per hour.
+func (s *Store) prune() {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		if err := s.deleteBefore(time.Now().Add(-s.retention)); err != nil {
+			log.Printf("unable to prune heading history: %v\n", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-s.closeCh:
+			return
+		}
+	}
+}
+
+// deleteBefore deletes all samples older than t.
+func (s *Store) deleteBefore(t time.Time) error {
+	limit := encodeKey(t)
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			c := b.Cursor()
+			for k, _ := c.First(); k != nil && bytes.Compare(k, limit) < 0; k, _ = c.First() {
+				if err := c.Delete(); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	})
+}
+
+// keys are big endian encoded unix nano timestamps, so that they are
+// sorted chronologically
+func encodeKey(t time.Time) []byte {
+	k := make([]byte, 8)
+	binary.BigEndian.PutUint64(k, uint64(t.UnixNano()))
+	return k
+}
+
+func decodeKey(k []byte) time.Time {
+	return time.Unix(0, int64(binary.BigEndian.Uint64(k)))
+}
+
+func encodeHeading(h rotator.Heading) []byte {
+	v := make([]byte, 16)
+	binary.BigEndian.PutUint32(v[0:], uint32(int32(h.Azimuth)))
+	binary.BigEndian.PutUint32(v[4:], uint32(int32(h.AzPreset)))
+	binary.BigEndian.PutUint32(v[8:], uint32(int32(h.Elevation)))
+	binary.BigEndian.PutUint32(v[12:], uint32(int32(h.ElPreset)))
+	return v
+}
+
+func decodeHeading(v []byte) rotator.Heading {
+	if len(v) < 16 {
+		return rotator.Heading{}
+	}
+	return rotator.Heading{
+		Azimuth:   int(int32(binary.BigEndian.Uint32(v[0:]))),
+		AzPreset:  int(int32(binary.BigEndian.Uint32(v[4:]))),
+		Elevation: int(int32(binary.BigEndian.Uint32(v[8:]))),
+		ElPreset:  int(int32(binary.BigEndian.Uint32(v[12:]))),
+	}
+}
@@ -0,0 +1,153 @@
+package history
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dh1tw/remoteRotator/rotator"
+)
+
+func openStore(t *testing.T, opts ...func(*Store)) *Store {
+	s, err := Open(filepath.Join(t.TempDir(), "history.db"), opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestQuery(t *testing.T) {
+
+	s := openStore(t)
+
+	t0 := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	// heading changes at 0s, 10s, 25s, 60s
+	inserts := []struct {
+		offset time.Duration
+		az     int
+	}{
+		{0, 10},
+		{10 * time.Second, 20},
+		{25 * time.Second, 30},
+		{60 * time.Second, 40},
+	}
+	for _, in := range inserts {
+		smpl := Sample{Time: t0.Add(in.offset), Heading: rotator.Heading{Azimuth: in.az, AzPreset: 40}}
+		if err := s.Insert("myRotator", smpl); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tt := []struct {
+		name     string
+		rotator  string
+		from, to time.Duration
+		step     time.Duration
+		expTimes []time.Duration
+		expAz    []int
+		expErr   bool
+	}{
+		{"raw", "myRotator", 0, 60 * time.Second, 0,
+			[]time.Duration{0, 10 * time.Second, 25 * time.Second, 60 * time.Second},
+			[]int{10, 20, 30, 40}, false},
+		{"raw with heading at from", "myRotator", 5 * time.Second, 30 * time.Second, 0,
+			[]time.Duration{5 * time.Second, 10 * time.Second, 25 * time.Second},
+			[]int{10, 20, 30}, false},
+		{"raw after last sample", "myRotator", 90 * time.Second, 100 * time.Second, 0,
+			[]time.Duration{90 * time.Second},
+			[]int{40}, false},
+		{"downsampled", "myRotator", 0, 60 * time.Second, 20 * time.Second,
+			[]time.Duration{0, 20 * time.Second, 40 * time.Second, 60 * time.Second},
+			[]int{10, 20, 30, 40}, false},
+		{"downsampled before first sample", "myRotator", -20 * time.Second, 0, 10 * time.Second,
+			[]time.Duration{0},
+			[]int{10}, false},
+		{"unknown rotator", "other", 0, 60 * time.Second, 0,
+			nil, nil, false},
+		{"invalid range", "myRotator", 60 * time.Second, 0, 0,
+			nil, nil, true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := s.Query(tc.rotator, t0.Add(tc.from), t0.Add(tc.to), tc.step)
+			if tc.expErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(res) != len(tc.expAz) {
+				t.Fatalf("got %d samples (%v), expected %d", len(res), res, len(tc.expAz))
+			}
+			for i, smpl := range res {
+				if !smpl.Time.Equal(t0.Add(tc.expTimes[i])) {
+					t.Errorf("sample %d: got time %v, expected %v", i, smpl.Time, t0.Add(tc.expTimes[i]))
+				}
+				if smpl.Azimuth != tc.expAz[i] {
+					t.Errorf("sample %d: got azimuth %d, expected %d", i, smpl.Azimuth, tc.expAz[i])
+				}
+				if smpl.AzPreset != 40 {
+					t.Errorf("sample %d: got preset %d, expected 40", i, smpl.AzPreset)
+				}
+			}
+		})
+	}
+}
+
+func TestMaxSamples(t *testing.T) {
+
+	s := openStore(t, MaxSamples(3))
+
+	t0 := time.Now().Add(-time.Minute)
+	for i := 0; i < 5; i++ {
+		if err := s.Insert("myRotator", Sample{Time: t0.Add(time.Duration(i) * time.Second)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := s.Query("myRotator", t0, t0.Add(time.Minute), 0); err != ErrTooManySamples {
+		t.Fatalf("expected ErrTooManySamples, got %v", err)
+	}
+	if _, err := s.Query("myRotator", t0, t0.Add(time.Minute), time.Second); err != ErrTooManySamples {
+		t.Fatalf("expected ErrTooManySamples, got %v", err)
+	}
+	res, err := s.Query("myRotator", t0, t0.Add(time.Minute), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 2 {
+		t.Fatalf("got %d samples, expected 2", len(res))
+	}
+}
+
+func TestRetention(t *testing.T) {
+
+	s := openStore(t, Retention(0))
+
+	now := time.Now()
+	old := Sample{Time: now.Add(-48 * time.Hour), Heading: rotator.Heading{Azimuth: 100}}
+	recent := Sample{Time: now.Add(-time.Hour), Heading: rotator.Heading{Azimuth: 200}}
+	for _, smpl := range []Sample{old, recent} {
+		if err := s.Insert("myRotator", smpl); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := s.deleteBefore(now.Add(-24 * time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := s.Query("myRotator", now.Add(-72*time.Hour), now, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || res[0].Azimuth != 200 {
+		t.Fatalf("expected only the recent sample, got %v", res)
+	}
+}
@@ -0,0 +1,71 @@
+package hub
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dh1tw/remoteRotator/rotator"
+	"github.com/dh1tw/remoteRotator/rotator/antenna"
+	"github.com/dh1tw/remoteRotator/rotator/dummy"
+	"github.com/gorilla/mux"
+)
+
+func TestAntennaREST(t *testing.T) {
+	d, _ := dummy.New(dummy.Name("rot"))
+	defer d.Close()
+	r, err := antenna.New(d,
+		rotator.Antenna{Name: "yagi"},
+		rotator.Antenna{Name: "lpda", Offset: 90, Bands: []string{"20m"}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := NewHub(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.router = mux.NewRouter()
+	h.routes()
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		h.router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do("PUT", "/api/v1.0/rotator/rot/antenna/lpda", `{"bearing": 45}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+	if d.AzPreset() != 315 {
+		t.Fatalf("expected rotator to turn to 315°, got %d", d.AzPreset())
+	}
+
+	rec = do("GET", "/api/v1.0/rotator/rot/antenna/lpda", "")
+	res := rotator.Antenna{}
+	if err := json.NewDecoder(rec.Body).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Offset != 90 || res.Preset != 45 || len(res.Bands) != 1 {
+		t.Fatalf("unexpected antenna %+v", res)
+	}
+
+	if rec := do("GET", "/api/v1.0/rotator/rot/antenna/dipole", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected unknown antenna to be rejected, got %d", rec.Code)
+	}
+	if rec := do("PUT", "/api/v1.0/rotator/rot/antenna/yagi", `{}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected missing bearing to be rejected, got %d", rec.Code)
+	}
+
+	// the bearings of the antennas are added to the heading events
+	h.Broadcast(Event{Name: UpdateHeading, RotatorName: "rot", Heading: rotator.Heading{Azimuth: 300}})
+	ev := h.eventLog[len(h.eventLog)-1].ev
+	if len(ev.Antennas) != 2 || ev.Antennas[1].Bearing != 30 {
+		t.Fatalf("unexpected antennas %+v", ev.Antennas)
+	}
+}
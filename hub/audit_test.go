@@ -0,0 +1,68 @@
+package hub
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dh1tw/remoteRotator/audit"
+	"github.com/dh1tw/remoteRotator/rotator/dummy"
+	"github.com/gorilla/mux"
+)
+
+func TestAuditREST(t *testing.T) {
+	r, _ := dummy.New(dummy.Name("rot"))
+	defer r.Close()
+
+	h, err := NewHub(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.router = mux.NewRouter()
+	h.routes()
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-Operator", "DH1TW")
+		rec := httptest.NewRecorder()
+		h.router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := do("GET", "/api/v1.0/audit", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected audit log to be disabled, got %d", rec.Code)
+	}
+
+	l, _ := audit.New()
+	h.SetAudit(l)
+
+	do("POST", "/api/v1.0/rotator/rot/lock", `{"duration": "1m"}`)
+	do("PUT", "/api/v1.0/rotator/rot/azimuth", `{"azimuth": 120}`)
+	do("GET", "/api/v1.0/rotator/rot/azimuth", "")
+
+	rec := do("GET", "/api/v1.0/audit?rotator=rot&user=DH1TW", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d", rec.Code)
+	}
+
+	records := []audit.Record{}
+	if err := json.NewDecoder(rec.Body).Decode(&records); err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %v", records)
+	}
+	if records[0].Command != WsTakeControl || records[0].Duration != "1m" {
+		t.Fatalf("unexpected record %+v", records[0])
+	}
+	if records[1].Command != "set_azimuth" || *records[1].Azimuth != 120 ||
+		records[1].Frontend != audit.REST || records[1].Client == "" {
+		t.Fatalf("unexpected record %+v", records[1])
+	}
+
+	if rec := do("GET", "/api/v1.0/audit?since=yesterday", ""); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected invalid time to be rejected, got %d", rec.Code)
+	}
+}
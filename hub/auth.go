@@ -0,0 +1,406 @@
+package hub
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/dh1tw/remoteRotator/rotator"
+	"github.com/gorilla/mux"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Role defines what a client is allowed to do with a rotator.
+type Role int
+
+// Roles in ascending order of their permissions
+const (
+	RoleNone    Role = iota // no access
+	RoleRead                // read-only access
+	RoleControl             // read access and control of the rotator
+)
+
+// AllRotators is the key of the role which applies to all rotators
+// without an explicit role.
+const AllRotators = "*"
+
+// ParseRole converts the name of a role ("none", "read" or "control")
+// into a Role.
+func ParseRole(s string) (Role, error) {
+	switch strings.ToLower(s) {
+	case "none", "":
+		return RoleNone, nil
+	case "read":
+		return RoleRead, nil
+	case "control":
+		return RoleControl, nil
+	}
+	return RoleNone, fmt.Errorf("unknown role '%s' (none, read or control)", s)
+}
+
+func (r Role) String() string {
+	switch r {
+	case RoleRead:
+		return "read"
+	case RoleControl:
+		return "control"
+	}
+	return "none"
+}
+
+var (
+	// ErrUnauthorized is returned if the credentials of a client are
+	// invalid.
+	ErrUnauthorized = errors.New("invalid credentials")
+
+	// ErrQueryToken is returned if a token is sent in the query of a
+	// route other than the websocket or SSE routes.
+	ErrQueryToken = errors.New("token only accepted in the query of the websocket and SSE routes")
+
+	// ErrForbidden is returned if a client doesn't have the role
+	// required for a command.
+	ErrForbidden = errors.New("permission denied")
+)
+
+// Identity is an (authenticated) client and its roles.
+type Identity struct {
+	Name  string
+	Roles map[string]Role // key: rotator name or AllRotators
+}
+
+// everyone is the identity of all clients when authentication is
+// disabled.
+var everyone = &Identity{Roles: map[string]Role{AllRotators: RoleControl}}
+
+// Role returns the role of the identity for the rotator. The rotator
+// names are matched case-insensitive since the keys of the config file
+// are lower case.
+func (id *Identity) Role(rName string) Role {
+	if role, ok := id.Roles[rName]; ok {
+		return role
+	}
+	for name, role := range id.Roles {
+		if strings.EqualFold(name, rName) {
+			return role
+		}
+	}
+	return id.Roles[AllRotators]
+}
+
+// Can returns true if the identity has (at least) the role for the
+// rotator.
+func (id *Identity) Can(role Role, rName string) bool {
+	return id.Role(rName) >= role
+}
+
+// readable returns the rotators the identity is allowed to read.
+func (id *Identity) readable(objs rotator.Objects) rotator.Objects {
+	filtered := rotator.Objects{}
+	for name, obj := range objs {
+		if id.Can(RoleRead, name) {
+			filtered[name] = obj
+		}
+	}
+	return filtered
+}
+
+// hasAccess returns true if the identity has access to any rotator.
+func (id *Identity) hasAccess() bool {
+	for _, role := range id.Roles {
+		if role > RoleNone {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticator validates the credentials of the clients.
+type Authenticator interface {
+	// Login returns the identity of the user if the password is valid.
+	Login(username, password string) (*Identity, error)
+	// Token returns the identity to which the API token belongs.
+	Token(token string) (*Identity, error)
+}
+
+// Users is an Authenticator for a static list of users with bcrypt
+// hashed passwords and API tokens (e.g. from the config file).
+type Users struct {
+	sync.RWMutex
+	users  map[string]user
+	tokens map[[sha256.Size]byte]*Identity
+}
+
+type user struct {
+	hash     []byte
+	identity *Identity
+}
+
+// dummyHash is compared against the password of unknown users so that
+// the existence of a user can't be probed through the response time.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("remoteRotator"), bcrypt.DefaultCost)
+
+// NewUsers returns an empty list of users.
+func NewUsers() *Users {
+	return &Users{
+		users:  make(map[string]user),
+		tokens: make(map[[sha256.Size]byte]*Identity),
+	}
+}
+
+// AddUser adds a user with a bcrypt hashed password (e.g. generated
+// with 'remoteRotator hash-password' or 'htpasswd -nB').
+func (u *Users) AddUser(name, hash string, roles map[string]Role) error {
+	if name == "" {
+		return fmt.Errorf("user name missing")
+	}
+	if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+		return fmt.Errorf("invalid password hash for user %s: %v", name, err)
+	}
+
+	u.Lock()
+	defer u.Unlock()
+	u.users[name] = user{
+		hash:     []byte(hash),
+		identity: &Identity{Name: name, Roles: roles},
+	}
+	return nil
+}
+
+// AddToken adds an API token. The name identifies the clients using
+// this token (e.g. for the leases).
+func (u *Users) AddToken(name, token string, roles map[string]Role) error {
+	if len(token) < 16 {
+		return fmt.Errorf("token %s is too short (min. 16 characters)", name)
+	}
+
+	u.Lock()
+	defer u.Unlock()
+	u.tokens[sha256.Sum256([]byte(token))] = &Identity{Name: name, Roles: roles}
+	return nil
+}
+
+// Login returns the identity of the user if the password is valid.
+func (u *Users) Login(username, password string) (*Identity, error) {
+	u.RLock()
+	usr, ok := u.users[username]
+	u.RUnlock()
+
+	if !ok {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return nil, ErrUnauthorized
+	}
+	if err := bcrypt.CompareHashAndPassword(usr.hash, []byte(password)); err != nil {
+		return nil, ErrUnauthorized
+	}
+	return usr.identity, nil
+}
+
+// Token returns the identity to which the API token belongs.
+func (u *Users) Token(token string) (*Identity, error) {
+	u.RLock()
+	defer u.RUnlock()
+
+	// the tokens are stored hashed, so the lookup doesn't leak
+	// the token through timing
+	id, ok := u.tokens[sha256.Sum256([]byte(token))]
+	if !ok {
+		return nil, ErrUnauthorized
+	}
+	return id, nil
+}
+
+// SetAuth enables the authentication of the HTTP, websocket and TCP
+// clients. Unauthenticated clients get the anonymous role for all
+// rotators. This method must be called before the listeners are
+// started.
+func (hub *Hub) SetAuth(a Authenticator, anonymous Role) {
+	hub.Lock()
+	defer hub.Unlock()
+
+	hub.auth = a
+	hub.anonymous = &Identity{Roles: map[string]Role{AllRotators: anonymous}}
+}
+
+// login validates the credentials sent by a TCP client. The credentials
+// are either a user name and a password or an API token.
+func (hub *Hub) login(credentials []string) (*Identity, error) {
+	if hub.auth == nil {
+		return everyone, nil
+	}
+
+	switch len(credentials) {
+	case 1:
+		return hub.auth.Token(credentials[0])
+	case 2:
+		return hub.auth.Login(credentials[0], credentials[1])
+	}
+	return nil, ErrUnauthorized
+}
+
+// defaultIdentity returns the identity of clients which haven't
+// authenticated (yet).
+func (hub *Hub) defaultIdentity() *Identity {
+	if hub.auth == nil {
+		return everyone
+	}
+	return hub.anonymous
+}
+
+type identityKey struct{}
+
+// authenticate is an http middleware which validates the credentials
+// of the request (HTTP Basic, bearer token or, on the websocket and
+// SSE routes, the 'token' query parameter, since browsers can't set
+// headers for websockets and EventSources) and stores the identity of
+// the client in the request context. Requests with invalid credentials are rejected. If
+// unauthenticated clients don't have access, the browser is asked for
+// the credentials.
+func (hub *Hub) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+
+		if hub.auth == nil {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		id := hub.anonymous
+		var err error
+
+		auth := req.Header.Get("Authorization")
+		username, password, basic := req.BasicAuth()
+		token := req.URL.Query().Get("token")
+
+		switch {
+		case token != "" && !queryTokenRoute(req.URL.Path):
+			err = ErrQueryToken
+		case basic:
+			id, err = hub.auth.Login(username, password)
+		case strings.HasPrefix(auth, "Bearer "):
+			id, err = hub.auth.Token(strings.TrimPrefix(auth, "Bearer "))
+		case token != "":
+			id, err = hub.auth.Token(token)
+		case !id.hasAccess():
+			err = ErrUnauthorized
+		}
+
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Basic realm="remoteRotator", charset="UTF-8"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		ctx := context.WithValue(req.Context(), identityKey{}, id)
+		next.ServeHTTP(w, req.WithContext(ctx))
+	})
+}
+
+// queryTokenRoute returns true for the routes which browsers open
+// without being able to set headers (websocket, EventSource). The token
+// is not accepted in the query of other routes, since URLs end up in
+// the logs of proxies and web servers.
+func queryTokenRoute(path string) bool {
+	if path == "/ws" {
+		return true
+	}
+	return strings.HasPrefix(path, "/api/") &&
+		(strings.HasSuffix(path, "/ws") || strings.HasSuffix(path, "/events"))
+}
+
+// authorize is a router middleware which checks the role of the client
+// for the rotator addressed by the route. Reading requires the read
+// role, all other methods the control role.
+func (hub *Hub) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+
+		rName, ok := mux.Vars(req)["rotator"]
+		if !ok {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		role := RoleControl
+		if req.Method == "GET" || req.Method == "HEAD" {
+			role = RoleRead
+		}
+
+		if !identity(req).Can(role, rName) {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(ErrForbidden.Error()))
+			return
+		}
+
+		next.ServeHTTP(w, req)
+	})
+}
+
+// identity returns the identity of the client which sent the request.
+func identity(req *http.Request) *Identity {
+	if id, ok := req.Context().Value(identityKey{}).(*Identity); ok {
+		return id
+	}
+	return everyone
+}
+
+// guard returns a view of the rotator which refuses all commands if
+// the identity doesn't have the control role for the rotator.
+func guard(r rotator.Rotator, id *Identity) rotator.Rotator {
+	return &guarded{Rotator: r, id: id}
+}
+
+// guarded is a view of a rotator for an identity.
+type guarded struct {
+	rotator.Rotator
+	id *Identity
+}
+
+func (g *guarded) check() error {
+	if !g.id.Can(RoleControl, g.Name()) {
+		return ErrForbidden
+	}
+	return nil
+}
+
+func (g *guarded) SetAzimuth(az int) error {
+	if err := g.check(); err != nil {
+		return err
+	}
+	return g.Rotator.SetAzimuth(az)
+}
+
+func (g *guarded) SetElevation(el int) error {
+	if err := g.check(); err != nil {
+		return err
+	}
+	return g.Rotator.SetElevation(el)
+}
+
+func (g *guarded) StopAzimuth() error {
+	if err := g.check(); err != nil {
+		return err
+	}
+	return g.Rotator.StopAzimuth()
+}
+
+func (g *guarded) StopElevation() error {
+	if err := g.check(); err != nil {
+		return err
+	}
+	return g.Rotator.StopElevation()
+}
+
+func (g *guarded) Stop() error {
+	if err := g.check(); err != nil {
+		return err
+	}
+	return g.Rotator.Stop()
+}
+
+// Unwrap returns the guarded rotator.
+func (g *guarded) Unwrap() rotator.Rotator {
+	return g.Rotator
+}
@@ -0,0 +1,234 @@
+package hub
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dh1tw/remoteRotator/rotator"
+	"github.com/dh1tw/remoteRotator/rotator/dummy"
+	"github.com/gorilla/mux"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const testToken = "0123456789abcdef"
+
+func testUsers(t *testing.T) *Users {
+	t.Helper()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u := NewUsers()
+	if err := u.AddUser("alice", string(hash), map[string]Role{AllRotators: RoleControl}); err != nil {
+		t.Fatal(err)
+	}
+	if err := u.AddUser("bob", string(hash), map[string]Role{"rot": RoleRead}); err != nil {
+		t.Fatal(err)
+	}
+	if err := u.AddToken("logger", testToken, map[string]Role{"ROT": RoleControl}); err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestUsers(t *testing.T) {
+	u := testUsers(t)
+
+	tt := []struct {
+		name     string
+		login    func() (*Identity, error)
+		identity string
+		role     Role
+	}{
+		{"valid password", func() (*Identity, error) { return u.Login("alice", "secret") }, "alice", RoleControl},
+		{"read only", func() (*Identity, error) { return u.Login("bob", "secret") }, "bob", RoleRead},
+		{"wrong password", func() (*Identity, error) { return u.Login("alice", "wrong") }, "", RoleNone},
+		{"unknown user", func() (*Identity, error) { return u.Login("eve", "secret") }, "", RoleNone},
+		{"valid token", func() (*Identity, error) { return u.Token(testToken) }, "logger", RoleControl},
+		{"invalid token", func() (*Identity, error) { return u.Token("fedcba9876543210") }, "", RoleNone},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			id, err := tc.login()
+			if tc.identity == "" {
+				if err != ErrUnauthorized {
+					t.Fatalf("expected ErrUnauthorized, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if id.Name != tc.identity {
+				t.Fatalf("expected identity %s, got %s", tc.identity, id.Name)
+			}
+			if role := id.Role("rot"); role != tc.role {
+				t.Fatalf("expected role %v, got %v", tc.role, role)
+			}
+			if role := id.Role("other"); tc.identity != "alice" && role != RoleNone {
+				t.Fatalf("expected no access to other rotators, got %v", role)
+			}
+		})
+	}
+
+	if err := u.AddUser("eve", "plaintext", nil); err == nil {
+		t.Fatal("expected plain text passwords to be rejected")
+	}
+	if err := u.AddToken("short", "1234", nil); err == nil {
+		t.Fatal("expected short tokens to be rejected")
+	}
+}
+
+func TestAuthREST(t *testing.T) {
+	r, _ := dummy.New(dummy.Name("rot"))
+	defer r.Close()
+
+	h, err := NewHub(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.SetAuth(testUsers(t), RoleNone)
+	h.router = mux.NewRouter()
+	h.routes()
+	h.router.Use(h.authorize)
+	handler := h.authenticate(h.router)
+
+	tt := []struct {
+		name   string
+		method string
+		path   string
+		auth   func(*http.Request)
+		status int
+	}{
+		{"anonymous", "GET", "/api/v1.0/rotators", func(*http.Request) {}, http.StatusUnauthorized},
+		{"wrong password", "GET", "/api/v1.0/rotators",
+			func(req *http.Request) { req.SetBasicAuth("alice", "wrong") }, http.StatusUnauthorized},
+		{"read", "GET", "/api/v1.0/rotator/rot",
+			func(req *http.Request) { req.SetBasicAuth("bob", "secret") }, http.StatusOK},
+		{"read only", "PUT", "/api/v1.0/rotator/rot/azimuth",
+			func(req *http.Request) { req.SetBasicAuth("bob", "secret") }, http.StatusForbidden},
+		{"control", "PUT", "/api/v1.0/rotator/rot/azimuth",
+			func(req *http.Request) { req.SetBasicAuth("alice", "secret") }, http.StatusOK},
+		{"bearer token", "PUT", "/api/v1.0/rotator/rot/azimuth",
+			func(req *http.Request) { req.Header.Set("Authorization", "Bearer "+testToken) }, http.StatusOK},
+		{"query token", "GET", "/api/v1.0/rotator/rot?token=" + testToken,
+			func(*http.Request) {}, http.StatusUnauthorized},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(`{"azimuth": 10}`))
+			tc.auth(req)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tc.status {
+				t.Fatalf("expected %d, got %d (%s)", tc.status, rec.Code, rec.Body.String())
+			}
+		})
+	}
+
+	// the rotator list only contains the rotators the client may read
+	r2, _ := dummy.New(dummy.Name("hidden"))
+	defer r2.Close()
+	h.AddRotator(r2)
+
+	req := httptest.NewRequest("GET", "/api/v1.0/rotators", nil)
+	req.SetBasicAuth("bob", "secret")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	objs := rotator.Objects{}
+	if err := json.NewDecoder(rec.Body).Decode(&objs); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := objs["hidden"]; ok || len(objs) != 1 {
+		t.Fatalf("expected only rotator 'rot', got %v", objs)
+	}
+}
+
+func TestAuthTCP(t *testing.T) {
+	r, _ := dummy.New(dummy.Name("rot"))
+	defer r.Close()
+
+	h, err := NewHub(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.SetAuth(testUsers(t), RoleNone)
+
+	server, client := net.Pipe()
+	defer client.Close()
+
+	c := &TCPClient{
+		Conn:     server,
+		queue:    newSendQueue(clientQueueSize),
+		identity: h.defaultIdentity(),
+	}
+	closer := make(chan *TCPClient, 1)
+	go c.listen(h, r, closer)
+
+	rd := bufio.NewReader(client)
+	expect := func(cmd, prefix string) {
+		t.Helper()
+		client.SetDeadline(time.Now().Add(time.Second))
+		if _, err := client.Write([]byte(cmd + "\r")); err != nil {
+			t.Fatal(err)
+		}
+		buf := make([]byte, len(prefix))
+		if _, err := rd.Read(buf); err != nil {
+			t.Fatal(err)
+		}
+		if string(buf) != prefix {
+			t.Fatalf("%s: expected %q, got %q", cmd, prefix, buf)
+		}
+		rd.Reset(client)
+	}
+
+	expect("C2", "?>")
+	expect("LOGIN bob wrong", "?>")
+	expect("LOGIN bob secret", "OK")
+	expect("C2", "+0")
+	expect("M090", "?>")
+	expect("LOGIN alice wrong", "?>")
+
+	// the third failed login disconnects the client
+	client.Write([]byte("LOGIN alice wrong\r"))
+	select {
+	case <-closer:
+	case <-time.After(time.Second):
+		t.Fatal("expected client to be disconnected")
+	}
+}
+
+func TestQueryTokenRoute(t *testing.T) {
+	tt := []struct {
+		path    string
+		allowed bool
+	}{
+		{"/ws", true},
+		{"/api/v1.0/ws", true},
+		{"/api/v1.0/events", true},
+		{"/api/v1.0/rotator/rot/events", true},
+		{"/api/v1.0/rotator/rot", false},
+		{"/api/v1.0/rotator/rot/azimuth", false},
+		{"/api/v1.0/audit", false},
+		{"/index.html", false},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.path, func(t *testing.T) {
+			if allowed := queryTokenRoute(tc.path); allowed != tc.allowed {
+				t.Fatalf("expected %v, got %v", tc.allowed, allowed)
+			}
+		})
+	}
+}
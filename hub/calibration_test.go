@@ -0,0 +1,123 @@
+package hub
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dh1tw/remoteRotator/audit"
+	"github.com/dh1tw/remoteRotator/rotator/calibration"
+	"github.com/dh1tw/remoteRotator/rotator/dummy"
+	"github.com/dh1tw/remoteRotator/rotator/monitor"
+	"github.com/gorilla/mux"
+)
+
+func TestCalibrationREST(t *testing.T) {
+	cal, err := calibration.New(calibration.Offset(7),
+		calibration.Table(calibration.Point{Indicated: 0, Actual: 1}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, _ := dummy.New(dummy.Name("rot"))
+	defer d.Close()
+	plain, _ := dummy.New(dummy.Name("plain"))
+	defer plain.Close()
+
+	h, err := NewHub(cal.Bind(d), plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.router = mux.NewRouter()
+	h.routes()
+	l, _ := audit.New()
+	h.SetAudit(l)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		h.router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do("PUT", "/api/v1.0/rotator/rot/calibration", `{"mounting": 90}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+
+	records, err := l.Query(audit.Query{Rotator: "rot"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].PreviousOffsets == nil || records[0].Offsets == nil ||
+		*records[0].PreviousOffsets != (audit.Offsets{Offset: 7, Mounting: 0}) ||
+		*records[0].Offsets != (audit.Offsets{Offset: 7, Mounting: 90}) {
+		t.Fatalf("unexpected audit records %+v", records)
+	}
+
+	rec = do("GET", "/api/v1.0/rotator/rot/calibration", "")
+	res := Calibration{}
+	if err := json.NewDecoder(rec.Body).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Offset != 7 || res.Mounting != 90 || len(res.Table) != 1 {
+		t.Fatalf("unexpected calibration %+v", res)
+	}
+
+	// the dummy starts at 0°
+	rec = do("GET", "/api/v1.0/rotator/rot", "")
+	if !strings.Contains(rec.Body.String(), `"azimuth":98`) {
+		t.Fatalf("expected calibrated azimuth, got %s", rec.Body.String())
+	}
+
+	if rec := do("GET", "/api/v1.0/rotator/plain/calibration", ""); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected rotator without calibration to be rejected, got %d", rec.Code)
+	}
+	if rec := do("PUT", "/api/v1.0/rotator/rot/calibration", "{"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected invalid json to be rejected, got %d", rec.Code)
+	}
+}
+
+func TestCalibrationRebaseline(t *testing.T) {
+	cal, err := calibration.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, _ := dummy.New(dummy.Name("rot"), dummy.InitialAzimuth(100))
+	defer d.Close()
+
+	// the monitor sits in front of the calibration
+	m, err := monitor.New(cal.Bind(d),
+		monitor.Interval(time.Millisecond*10),
+		monitor.SettleTime(time.Millisecond*50))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := NewHub(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.router = mux.NewRouter()
+	h.routes()
+
+	// let the idle rotator settle before the heading jumps
+	time.Sleep(time.Millisecond * 100)
+
+	req := httptest.NewRequest("PUT", "/api/v1.0/rotator/rot/calibration", strings.NewReader(`{"offset": 7}`))
+	rec := httptest.NewRecorder()
+	h.router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+	if az := m.Azimuth(); az != 107 {
+		t.Fatalf("expected calibrated azimuth 107°, got %d°", az)
+	}
+
+	time.Sleep(time.Millisecond * 200)
+	if f := m.Fault(); f != nil {
+		t.Fatalf("unexpected fault %+v", *f)
+	}
+}
@@ -0,0 +1,134 @@
+package hub
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// SetOrigins sets the origins (e.g. "https://dashboard.example.com")
+// from which browsers may use the REST API and connect to the
+// websocket. "*" allows all origins; a leading wildcard allows all
+// subdomains (e.g. "https://*.example.com"). Without allowed origins,
+// only the web interface served by the hub itself (same origin) has
+// access. This method must be called before ListenHTTP.
+func (hub *Hub) SetOrigins(origins ...string) {
+	hub.Lock()
+	defer hub.Unlock()
+
+	hub.origins = nil
+	for _, o := range origins {
+		if o = strings.TrimRight(strings.TrimSpace(o), "/"); o != "" {
+			hub.origins = append(hub.origins, strings.ToLower(o))
+		}
+	}
+}
+
+// originAllowed returns true if a browser from the origin may access
+// the hub. Requests without an Origin header (e.g. from curl or
+// scripts) and requests from the same origin are always allowed.
+func (hub *Hub) originAllowed(req *http.Request) bool {
+
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(u.Host, req.Host) {
+		return true
+	}
+
+	return hub.allOrigins() || hub.originListed(origin)
+}
+
+// allOrigins returns true if all origins ("*") are allowed.
+func (hub *Hub) allOrigins() bool {
+	for _, allowed := range hub.origins {
+		if allowed == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// originListed returns true if the origin is allowed explicitly or
+// through a subdomain wildcard; the wildcard "*" doesn't count.
+func (hub *Hub) originListed(origin string) bool {
+
+	origin = strings.ToLower(origin)
+	for _, allowed := range hub.origins {
+		if allowed == origin {
+			return true
+		}
+		// e.g. https://*.example.com
+		if i := strings.Index(allowed, "*."); i >= 0 &&
+			strings.HasPrefix(origin, allowed[:i]) &&
+			strings.HasSuffix(origin, allowed[i+1:]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// corsMaxAge is the time (in seconds) browsers may cache the result of
+// a preflight request.
+const corsMaxAge = 600
+
+// cors is an http middleware which adds the CORS headers to the
+// responses of the REST API for the allowed origins and answers the
+// preflight requests. Since browsers send the preflight requests
+// without credentials, this middleware must run before the
+// authentication.
+func (hub *Hub) cors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+
+		origin := req.Header.Get("Origin")
+		if origin == "" || !strings.HasPrefix(req.URL.Path, "/api/") {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		preflight := req.Method == http.MethodOptions &&
+			req.Header.Get("Access-Control-Request-Method") != ""
+
+		w.Header().Add("Vary", "Origin")
+
+		if !hub.originAllowed(req) {
+			if preflight {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			// the browser blocks the response without the
+			// CORS headers
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		// only listed origins get the credentials; with "*" any
+		// website an operator visits could otherwise turn the
+		// rotators with the operator's credentials
+		if hub.originListed(origin) {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		} else {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		}
+
+		if !preflight {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers",
+			"Authorization, Content-Type, X-Operator, Last-Event-ID")
+		w.Header().Set("Access-Control-Max-Age", strconv.Itoa(corsMaxAge))
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
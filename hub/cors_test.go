@@ -0,0 +1,134 @@
+package hub
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOriginAllowed(t *testing.T) {
+	h := &Hub{}
+	h.SetOrigins("https://dashboard.example.com/", "https://*.dh1tw.de")
+
+	tt := []struct {
+		name    string
+		origin  string
+		allowed bool
+	}{
+		{"no origin", "", true},
+		{"same origin", "http://rotator.local:7070", true},
+		{"allowed origin", "https://dashboard.example.com", true},
+		{"allowed origin case", "https://Dashboard.Example.com", true},
+		{"wrong scheme", "http://dashboard.example.com", false},
+		{"subdomain", "https://contest.dh1tw.de", true},
+		{"not a subdomain", "https://evil-dh1tw.de", false},
+		{"other origin", "https://evil.com", false},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "http://rotator.local:7070/ws", nil)
+			if tc.origin != "" {
+				req.Header.Set("Origin", tc.origin)
+			}
+			if allowed := h.originAllowed(req); allowed != tc.allowed {
+				t.Fatalf("expected %v, got %v", tc.allowed, allowed)
+			}
+		})
+	}
+
+	h.SetOrigins("*")
+	req := httptest.NewRequest("GET", "http://rotator.local:7070/ws", nil)
+	req.Header.Set("Origin", "https://evil.com")
+	if !h.originAllowed(req) {
+		t.Fatal("expected all origins to be allowed")
+	}
+}
+
+func TestCORS(t *testing.T) {
+	h := &Hub{}
+	h.SetOrigins("https://dashboard.example.com")
+
+	called := false
+	handler := h.cors(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		called = true
+	}))
+
+	tt := []struct {
+		name        string
+		method      string
+		path        string
+		origin      string
+		preflight   bool
+		status      int
+		allowOrigin string
+		called      bool
+	}{
+		{"preflight", "OPTIONS", "/api/v1.0/rotator/rot/azimuth", "https://dashboard.example.com", true,
+			http.StatusNoContent, "https://dashboard.example.com", false},
+		{"preflight forbidden", "OPTIONS", "/api/v1.0/rotators", "https://evil.com", true,
+			http.StatusForbidden, "", false},
+		{"request", "PUT", "/api/v1.0/rotator/rot/azimuth", "https://dashboard.example.com", false,
+			http.StatusOK, "https://dashboard.example.com", true},
+		{"request other origin", "GET", "/api/v1.0/rotators", "https://evil.com", false,
+			http.StatusOK, "", true},
+		{"no api", "GET", "/index.html", "https://dashboard.example.com", false,
+			http.StatusOK, "", true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			called = false
+			req := httptest.NewRequest(tc.method, tc.path, nil)
+			req.Header.Set("Origin", tc.origin)
+			if tc.preflight {
+				req.Header.Set("Access-Control-Request-Method", "PUT")
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tc.status {
+				t.Fatalf("expected status %d, got %d", tc.status, rec.Code)
+			}
+			if o := rec.Header().Get("Access-Control-Allow-Origin"); o != tc.allowOrigin {
+				t.Fatalf("expected allowed origin '%s', got '%s'", tc.allowOrigin, o)
+			}
+			if called != tc.called {
+				t.Fatalf("expected handler called: %v, got %v", tc.called, called)
+			}
+		})
+	}
+}
+
+func TestCORSCredentials(t *testing.T) {
+	h := &Hub{}
+	h.SetOrigins("*", "https://dashboard.example.com")
+
+	handler := h.cors(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+
+	tt := []struct {
+		name        string
+		origin      string
+		allowOrigin string
+		credentials string
+	}{
+		{"listed origin", "https://dashboard.example.com", "https://dashboard.example.com", "true"},
+		{"wildcard", "https://evil.com", "*", ""},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("PUT", "/api/v1.0/rotator/rot/azimuth", nil)
+			req.Header.Set("Origin", tc.origin)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if o := rec.Header().Get("Access-Control-Allow-Origin"); o != tc.allowOrigin {
+				t.Fatalf("expected allowed origin '%s', got '%s'", tc.allowOrigin, o)
+			}
+			if c := rec.Header().Get("Access-Control-Allow-Credentials"); c != tc.credentials {
+				t.Fatalf("expected credentials '%s', got '%s'", tc.credentials, c)
+			}
+		})
+	}
+}
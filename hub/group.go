@@ -0,0 +1,280 @@
+package hub
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+
+	"github.com/dh1tw/remoteRotator/rotator"
+)
+
+// groupTolerance is the deviation (in degrees) from the preset within
+// which a member of a group is considered to have arrived.
+const groupTolerance = 2
+
+// Group is a named set of rotators which are moved together, e.g. the
+// masts of a stacked array with separate controllers.
+type Group struct {
+	Name    string        `json:"name"`
+	Members []GroupMember `json:"members"`
+}
+
+// GroupMember is a rotator of a group. The offset (in degrees) is added
+// to the azimuth commanded to the group.
+type GroupMember struct {
+	Rotator string `json:"rotator"`
+	Offset  int    `json:"offset"`
+}
+
+// GroupStatus is the status of a group, aggregated from its members.
+// The group is moving until all members have arrived and faulty if
+// any member is faulty or unavailable.
+type GroupStatus struct {
+	Name    string              `json:"name"`
+	Moving  bool                `json:"moving"`
+	Fault   bool                `json:"fault"`
+	Members []GroupMemberStatus `json:"members"`
+}
+
+// GroupMemberStatus is the status of a member of a group.
+type GroupMemberStatus struct {
+	GroupMember
+	Available bool             `json:"available"`
+	Moving    bool             `json:"moving"`
+	Fault     bool             `json:"fault"`
+	Heading   rotator.Heading  `json:"heading"`
+	Status    []rotator.Status `json:"status,omitempty"`
+}
+
+// SetGroups sets the groups of rotators. The names of the groups must be
+// unique and a rotator can only be member of a group once. The members
+// don't have to exist yet (e.g. remote rotators which are discovered
+// later). This method must be called before the listeners are started.
+func (hub *Hub) SetGroups(groups ...Group) error {
+
+	gs := make(map[string]Group)
+
+	for _, g := range groups {
+		if g.Name == "" {
+			return fmt.Errorf("group without name")
+		}
+		if _, ok := gs[g.Name]; ok {
+			return fmt.Errorf("group names must be unique; %s exists more than once", g.Name)
+		}
+		if len(g.Members) == 0 {
+			return fmt.Errorf("group %s has no members", g.Name)
+		}
+		members := make(map[string]bool)
+		for _, m := range g.Members {
+			if members[m.Rotator] {
+				return fmt.Errorf("rotator %s is member of group %s more than once", m.Rotator, g.Name)
+			}
+			members[m.Rotator] = true
+		}
+		gs[g.Name] = g
+	}
+
+	hub.Lock()
+	defer hub.Unlock()
+	hub.groups = gs
+
+	return nil
+}
+
+// Group returns the group with the given name.
+func (hub *Hub) Group(name string) (Group, bool) {
+	hub.RLock()
+	defer hub.RUnlock()
+
+	g, ok := hub.groups[name]
+	return g, ok
+}
+
+// Groups returns all groups, sorted by name.
+func (hub *Hub) Groups() []Group {
+	hub.RLock()
+	defer hub.RUnlock()
+
+	groups := make([]Group, 0, len(hub.groups))
+	for _, g := range hub.groups {
+		groups = append(groups, g)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Name < groups[j].Name })
+
+	return groups
+}
+
+// groupStatus aggregates the status of the members of the group.
+func (hub *Hub) groupStatus(g Group) GroupStatus {
+
+	gs := GroupStatus{
+		Name:    g.Name,
+		Members: make([]GroupMemberStatus, 0, len(g.Members)),
+	}
+
+	for _, m := range g.Members {
+		ms := GroupMemberStatus{GroupMember: m}
+
+		r, ok := hub.Rotator(m.Rotator)
+		if ok {
+			obj := r.Serialize()
+			ms.Available = true
+			ms.Heading = obj.Heading
+			ms.Status = obj.Status
+			ms.Moving = moving(obj)
+			for _, s := range obj.Status {
+				if s.Level == rotator.StatusFault {
+					ms.Fault = true
+				}
+			}
+		} else {
+			ms.Fault = true
+		}
+
+		gs.Moving = gs.Moving || ms.Moving
+		gs.Fault = gs.Fault || ms.Fault
+		gs.Members = append(gs.Members, ms)
+	}
+
+	return gs
+}
+
+// broadcastGroups broadcasts the status of the groups of which the
+// rotator is a member. The status is aggregated without holding the
+// lock of the hub, since the members are serialized.
+func (hub *Hub) broadcastGroups(rName string) {
+	for _, g := range hub.Groups() {
+		if !g.has(rName) {
+			continue
+		}
+		gs := hub.groupStatus(g)
+
+		hub.Lock()
+		hub.broadcast(Event{
+			Name:  UpdateGroup,
+			Group: &gs,
+		})
+		hub.Unlock()
+	}
+}
+
+// has returns true if the rotator is a member of the group.
+func (g Group) has(rName string) bool {
+	for _, m := range g.Members {
+		if m.Rotator == rName {
+			return true
+		}
+	}
+	return false
+}
+
+// moving returns true if the rotator hasn't arrived at its presets yet.
+func moving(obj rotator.Object) bool {
+	h := obj.Heading
+	if obj.Config.HasAzimuth && rotator.AzDiff(h.AzPreset, h.Azimuth) > groupTolerance {
+		return true
+	}
+	if obj.Config.HasElevation && rotator.ElDiff(h.ElPreset, h.Elevation) > groupTolerance {
+		return true
+	}
+	return false
+}
+
+// groupCheck returns an error if any member of the group is not
+// available, may not be controlled by the client or currently refuses
+// moves (e.g. locked out after a fault, parked or out of its duty
+// cycle). Moves are only commanded if all members can follow. The check
+// doesn't take auto leases; they are taken by the bound members once
+// the move is commanded, so a refused move leaves no leases behind.
+func (hub *Hub) groupCheck(g Group, owner string, id *Identity) error {
+	for _, m := range g.Members {
+		r, ok := hub.Rotator(m.Rotator)
+		if !ok {
+			return fmt.Errorf("member %s of group %s is not available", m.Rotator, g.Name)
+		}
+		if err := rotator.CanMove(r); err != nil {
+			return fmt.Errorf("%s: %w", m.Rotator, err)
+		}
+		if !id.Can(RoleControl, m.Rotator) {
+			return fmt.Errorf("%s: %w", m.Rotator, ErrForbidden)
+		}
+		if err := hub.locks.check(m.Rotator, owner, 0); err != nil {
+			return fmt.Errorf("%s: %w", m.Rotator, err)
+		}
+	}
+	return nil
+}
+
+// groupExec executes the function on all available members of the
+// group at once. The members are bound to the client through bind,
+// which checks the role and the lease of the client. If any member
+// fails, the other members are stopped, so that the group doesn't end
+// up pointing into different directions.
+func (hub *Hub) groupExec(g Group, bind func(rotator.Rotator) rotator.Rotator,
+	f func(rotator.Rotator, GroupMember) error) error {
+
+	errs := make([]error, len(g.Members))
+	bound := make([]rotator.Rotator, len(g.Members))
+	var wg sync.WaitGroup
+
+	for i, m := range g.Members {
+		r, ok := hub.Rotator(m.Rotator)
+		if !ok {
+			errs[i] = fmt.Errorf("member %s of group %s is not available", m.Rotator, g.Name)
+			continue
+		}
+		bound[i] = bind(r)
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := f(bound[i], g.Members[i]); err != nil {
+				errs[i] = fmt.Errorf("%s: %w", g.Members[i].Rotator, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	err := errors.Join(errs...)
+	if err == nil {
+		return nil
+	}
+
+	for i, r := range bound {
+		if r != nil && errs[i] == nil {
+			if stopErr := r.Stop(); stopErr != nil {
+				log.Printf("unable to stop %s of group %s: %v\n", g.Members[i].Rotator, g.Name, stopErr)
+			}
+		}
+	}
+
+	return err
+}
+
+// groupSetAzimuth turns all members of the group to the azimuth plus
+// their offset.
+func groupSetAzimuth(az int) func(rotator.Rotator, GroupMember) error {
+	return func(r rotator.Rotator, m GroupMember) error {
+		if !r.HasAzimuth() {
+			return fmt.Errorf("rotator does not support azimuth")
+		}
+		return r.SetAzimuth(rotator.NormAzimuth(az + m.Offset))
+	}
+}
+
+// groupSetElevation turns all members of the group which support
+// elevation to the elevation.
+func groupSetElevation(el int) func(rotator.Rotator, GroupMember) error {
+	return func(r rotator.Rotator, m GroupMember) error {
+		if !r.HasElevation() {
+			return nil
+		}
+		return r.SetElevation(el)
+	}
+}
+
+// groupStop stops all members of the group.
+func groupStop(r rotator.Rotator, m GroupMember) error {
+	return r.Stop()
+}
@@ -0,0 +1,281 @@
+package hub
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dh1tw/remoteRotator/rotator"
+	"github.com/dh1tw/remoteRotator/rotator/dummy"
+	"github.com/dh1tw/remoteRotator/rotator/monitor"
+	"github.com/dh1tw/remoteRotator/rotator/rotatortest"
+	"github.com/gorilla/mux"
+)
+
+func TestSetGroups(t *testing.T) {
+	tt := []struct {
+		name   string
+		groups []Group
+		err    bool
+	}{
+		{"valid", []Group{{Name: "stack", Members: []GroupMember{{Rotator: "a"}, {Rotator: "b", Offset: 3}}}}, false},
+		{"no name", []Group{{Members: []GroupMember{{Rotator: "a"}}}}, true},
+		{"no members", []Group{{Name: "stack"}}, true},
+		{"duplicate member", []Group{{Name: "stack", Members: []GroupMember{{Rotator: "a"}, {Rotator: "a"}}}}, true},
+		{"duplicate group", []Group{
+			{Name: "stack", Members: []GroupMember{{Rotator: "a"}}},
+			{Name: "stack", Members: []GroupMember{{Rotator: "b"}}},
+		}, true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			h, _ := NewHub()
+			err := h.SetGroups(tc.groups...)
+			if tc.err != (err != nil) {
+				t.Fatalf("unexpected error %v", err)
+			}
+		})
+	}
+}
+
+func TestGroupREST(t *testing.T) {
+	upper, _ := dummy.New(dummy.Name("upper"), dummy.AzimuthSpeed(1))
+	defer upper.Close()
+	lower, _ := dummy.New(dummy.Name("lower"), dummy.AzimuthSpeed(1))
+	defer lower.Close()
+
+	h, err := NewHub(upper, lower)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = h.SetGroups(
+		Group{Name: "stack", Members: []GroupMember{{Rotator: "upper"}, {Rotator: "lower", Offset: -5}}},
+		Group{Name: "incomplete", Members: []GroupMember{{Rotator: "upper"}, {Rotator: "missing"}}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.router = mux.NewRouter()
+	h.routes()
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-Operator", "alice")
+		rec := httptest.NewRecorder()
+		h.router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	status := func(name string) GroupStatus {
+		rec := do("GET", "/api/v1.0/group/"+name, "")
+		gs := GroupStatus{}
+		if err := json.NewDecoder(rec.Body).Decode(&gs); err != nil {
+			t.Fatal(err)
+		}
+		return gs
+	}
+
+	if gs := status("stack"); gs.Moving || gs.Fault || len(gs.Members) != 2 {
+		t.Fatalf("unexpected status %+v", gs)
+	}
+
+	rec := do("PUT", "/api/v1.0/group/stack/azimuth", `{"azimuth": 2}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+	if upper.AzPreset() != 2 || lower.AzPreset() != 357 {
+		t.Fatalf("unexpected presets %d, %d", upper.AzPreset(), lower.AzPreset())
+	}
+	if gs := status("stack"); !gs.Moving || !gs.Members[1].Moving {
+		t.Fatalf("expected group to be moving %+v", gs)
+	}
+
+	rec = do("POST", "/api/v1.0/group/stack/stop", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+	if gs := status("stack"); gs.Moving {
+		t.Fatalf("expected group to be stopped %+v", gs)
+	}
+
+	// a member leased by another client refuses the move of the group;
+	// none of the members is moved
+	if _, err := h.locks.Acquire("lower", "bob", "", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	upperPreset := upper.AzPreset()
+	rec = do("PUT", "/api/v1.0/group/stack/azimuth", `{"azimuth": 90}`)
+	if rec.Code != http.StatusLocked {
+		t.Fatalf("expected group to be locked, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if upper.AzPreset() != upperPreset {
+		t.Fatalf("expected upper rotator not to move, got preset %d", upper.AzPreset())
+	}
+
+	if gs := status("incomplete"); !gs.Fault || gs.Members[1].Available {
+		t.Fatalf("expected missing member to fault the group %+v", gs)
+	}
+	if rec := do("PUT", "/api/v1.0/group/incomplete/azimuth", `{"azimuth": 90}`); rec.Code == http.StatusOK {
+		t.Fatal("expected incomplete group to be refused")
+	}
+	if rec := do("GET", "/api/v1.0/group/unknown", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected unknown group to be rejected, got %d", rec.Code)
+	}
+
+	groups := []GroupStatus{}
+	if err := json.NewDecoder(do("GET", "/api/v1.0/groups", "").Body).Decode(&groups); err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 2 || groups[0].Name != "incomplete" {
+		t.Fatalf("unexpected groups %+v", groups)
+	}
+}
+
+func TestGroupRefusedNoAutoLease(t *testing.T) {
+	upper, _ := dummy.New(dummy.Name("upper"))
+	defer upper.Close()
+	lower, _ := dummy.New(dummy.Name("lower"))
+	defer lower.Close()
+
+	h, err := NewHub(upper, lower)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.SetLocks(NewLocks(AutoLease(time.Minute)))
+	err = h.SetGroups(Group{Name: "stack", Members: []GroupMember{{Rotator: "upper"}, {Rotator: "lower"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.router = mux.NewRouter()
+	h.routes()
+
+	if _, err := h.locks.Acquire("lower", "bob", "", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("PUT", "/api/v1.0/group/stack/azimuth", strings.NewReader(`{"azimuth": 90}`))
+	rec := httptest.NewRecorder()
+	h.router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusLocked {
+		t.Fatalf("expected group to be locked, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// the refused move must not leave an auto lease on the upper rotator
+	if lease, ok := h.locks.Lease("upper"); ok {
+		t.Fatalf("unexpected lease %+v", lease)
+	}
+}
+
+func TestGroupBroadcast(t *testing.T) {
+	upper, _ := dummy.New(dummy.Name("upper"))
+	defer upper.Close()
+	lower, _ := dummy.New(dummy.Name("lower"))
+	defer lower.Close()
+
+	h, err := NewHub(upper, lower)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = h.SetGroups(Group{Name: "stack", Members: []GroupMember{{Rotator: "upper"}, {Rotator: "lower"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a client of one member and a client which may only read one member
+	member := &SseClient{rotator: "lower", identity: everyone, queue: newSendQueue(16)}
+	partial := &SseClient{identity: &Identity{Roles: map[string]Role{"lower": RoleRead}}, queue: newSendQueue(16)}
+	h.Lock()
+	h.sseClients[member] = true
+	h.sseClients[partial] = true
+	h.Unlock()
+
+	h.Broadcast(Event{Name: UpdateHeading, RotatorName: "lower", Heading: lower.Serialize().Heading})
+	member.queue.close()
+	partial.queue.close()
+
+	groupEvent := func(c *SseClient) *GroupStatus {
+		for {
+			v, ok := c.queue.pop()
+			if !ok {
+				return nil
+			}
+			if rec := v.(eventRecord); rec.ev.Name == UpdateGroup {
+				return rec.ev.Group
+			}
+		}
+	}
+
+	if gs := groupEvent(member); gs == nil || gs.Name != "stack" || len(gs.Members) != 2 {
+		t.Fatalf("expected status of the group, got %+v", gs)
+	}
+	if gs := groupEvent(partial); gs != nil {
+		t.Fatalf("expected group status to be hidden from the client, got %+v", gs)
+	}
+}
+
+// refusing is a rotator which refuses all moves without announcing it
+// beforehand (e.g. a remote rotator).
+type refusing struct {
+	*dummy.Dummy
+}
+
+func (r refusing) SetAzimuth(int) error { return errors.New("refused") }
+
+func TestGroupMemberRefusesMove(t *testing.T) {
+	upper, _ := dummy.New(dummy.Name("upper"), dummy.AzimuthSpeed(1))
+	defer upper.Close()
+	lower, _ := dummy.New(dummy.Name("lower"), dummy.AzimuthSpeed(1))
+	defer lower.Close()
+	remote, _ := dummy.New(dummy.Name("remote"))
+	defer remote.Close()
+
+	// the lower rotator is locked out after a fault
+	locked, err := monitor.New(lower, monitor.InitialFault(rotator.Status{Code: monitor.Stall}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fake := rotatortest.New(rotator.Config{HasAzimuth: true, AzimuthMax: 360})
+
+	h, err := NewHub(upper, locked, refusing{remote}, fake)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = h.SetGroups(
+		Group{Name: "stack", Members: []GroupMember{{Rotator: "fake"}, {Rotator: "lower"}}},
+		Group{Name: "mixed", Members: []GroupMember{{Rotator: "upper"}, {Rotator: "remote"}}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.router = mux.NewRouter()
+	h.routes()
+
+	do := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PUT", path, strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		h.router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// the locked member is detected before any member is commanded
+	if rec := do("/api/v1.0/group/stack/azimuth", `{"azimuth": 90}`); rec.Code != http.StatusConflict {
+		t.Fatalf("expected group with locked member to be refused, got %d", rec.Code)
+	}
+	if fake.AzPreset() != 0 || fake.Stops() != 0 {
+		t.Fatalf("expected member not to be commanded, got preset %d", fake.AzPreset())
+	}
+
+	// a member which refuses unexpectedly stops the others
+	if rec := do("/api/v1.0/group/mixed/azimuth", `{"azimuth": 90}`); rec.Code == http.StatusOK {
+		t.Fatal("expected group with refusing member to fail")
+	}
+	if upper.AzPreset() != upper.Azimuth() {
+		t.Fatalf("expected upper rotator to be stopped at %d, got preset %d", upper.Azimuth(), upper.AzPreset())
+	}
+}
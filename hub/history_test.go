@@ -0,0 +1,90 @@
+package hub
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dh1tw/remoteRotator/history"
+	"github.com/dh1tw/remoteRotator/rotator"
+	"github.com/gorilla/mux"
+)
+
+func TestHistoryREST(t *testing.T) {
+	h, err := NewHub()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.router = mux.NewRouter()
+	h.routes()
+
+	do := func(path, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		rec := httptest.NewRecorder()
+		h.router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := do("/api/v1.0/rotator/rot/history", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected history to be disabled, got %d", rec.Code)
+	}
+
+	s, err := history.Open(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	h.SetHistory(s)
+
+	t0 := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i, az := range []int{10, 20, 30} {
+		smpl := history.Sample{
+			Time:    t0.Add(time.Duration(i) * time.Minute),
+			Heading: rotator.Heading{Azimuth: az, AzPreset: 30},
+		}
+		if err := s.Insert("rot", smpl); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// JSON, downsampled
+	rec := do("/api/v1.0/rotator/rot/history?from=2024-05-01T12:00:00Z&to=2024-05-01T12:02:00Z&step=2m", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+	samples := []history.Sample{}
+	if err := json.NewDecoder(rec.Body).Decode(&samples); err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 2 || samples[0].Azimuth != 10 || samples[1].Azimuth != 30 {
+		t.Fatalf("unexpected samples %+v", samples)
+	}
+
+	// CSV, unix timestamps
+	path := "/api/v1.0/rotator/rot/history?from=" + strconv.FormatInt(t0.Unix(), 10) +
+		"&to=" + strconv.FormatInt(t0.Add(time.Minute).Unix(), 10)
+	rec = do(path, "text/csv")
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+		t.Fatalf("unexpected content type %s", ct)
+	}
+	exp := "time,azimuth,az_preset,elevation,el_preset\n" +
+		"2024-05-01T12:00:00Z,10,30,0,0\n" +
+		"2024-05-01T12:01:00Z,20,30,0,0\n"
+	if rec.Body.String() != exp {
+		t.Fatalf("unexpected csv:\n%s", rec.Body.String())
+	}
+
+	for _, q := range []string{"from=yesterday", "step=often", "from=2024-05-02T00:00:00Z&to=2024-05-01T00:00:00Z"} {
+		if rec := do("/api/v1.0/rotator/rot/history?"+q, ""); rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected bad request, got %d", q, rec.Code)
+		}
+	}
+}
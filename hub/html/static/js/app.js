@@ -3,6 +3,7 @@ var vm = new Vue({
 
     data: {
         ws: null, // websocket
+        cmdID: 0, // id of the last command sent over the websocket
         rotators: {},
         selectedAzRotator: {
             name: "n/a",
@@ -174,6 +175,10 @@ var vm = new Vue({
                 } else if (eventMsg.name == 'remove') {
                     this.removeRotator(eventMsg.rotator_name);
 
+                // command rejected by the server
+                } else if (eventMsg.name == 'error') {
+                    console.log("command " + eventMsg.id + " failed: " + eventMsg.error);
+
                 // update heading
                 } else if (eventMsg.name == 'heading') {
                     var newHeading = eventMsg.heading;
@@ -182,6 +187,14 @@ var vm = new Vue({
                         // copy values
                         this.$set(this.rotators[rotatorName], 'heading', newHeading);
                     }
+
+                // fault raised or cleared
+                } else if (eventMsg.name == 'status') {
+                    this.updateStatus(eventMsg.rotator_name, eventMsg.status);
+
+                // rotator leased by an operator or released
+                } else if (eventMsg.name == 'lock' || eventMsg.name == 'unlock') {
+                    this.updateLease(eventMsg.rotator_name, eventMsg.name, eventMsg.lease);
                 }
             }.bind(this));
 
@@ -218,8 +231,57 @@ var vm = new Vue({
             }
         },
 
+        // send a command over the websocket. Returns false if the
+        // websocket is not connected.
+        sendCommand: function (cmd) {
+            if (!this.connected) {
+                return false;
+            }
+            this.cmdID++;
+            cmd.version = "1.0";
+            cmd.id = String(this.cmdID);
+            this.ws.send(JSON.stringify(cmd));
+            return true;
+        },
+
+        // replace the status of the rotator raised by the same source
+        updateStatus: function (name, status) {
+            if (!(name in this.rotators)) {
+                return;
+            }
+            var statuses = (this.rotators[name].status || []).filter(function (s) {
+                return s.source != status.source;
+            });
+            if (status.level != 'info') {
+                statuses.push(status);
+            }
+            this.$set(this.rotators[name], 'status', statuses);
+        },
+
+        // show the operator who has taken the control of the rotator
+        updateLease: function (name, event, lease) {
+            var status = {source: "lock", level: "info"};
+            if (event == 'lock') {
+                var expires = new Date(lease.expires).toLocaleTimeString();
+                status.level = "warning";
+                status.message = "locked by " + lease.owner + " until " + expires;
+            }
+            this.updateStatus(name, status);
+        },
+
+        // acknowledge the faults of a rotator
+        acknowledge: function (name) {
+            if (this.sendCommand({command: "acknowledge", rotator: name})) {
+                return;
+            }
+            this.$http.post("/api/v1.0/rotator/" + name + "/acknowledge");
+        },
+
         // send a request to the server to set azimuth
         setAzimuth: function (name, heading) {
+            if (this.sendCommand({command: "set_azimuth", rotator: name, azimuth: heading})) {
+                return;
+            }
             this.$http.put("/api/rotator/" + name + "/azimuth",
                 JSON.stringify({
                     azimuth: heading,
@@ -228,6 +290,9 @@ var vm = new Vue({
 
         // send a request to the server to set elevation
         setElevation: function (name, heading) {
+            if (this.sendCommand({command: "set_elevation", rotator: name, elevation: heading})) {
+                return;
+            }
             this.$http.put("/api/rotator/" + name + "/elevation",
                 JSON.stringify({
                     elevation: heading,
@@ -275,6 +340,17 @@ var vm = new Vue({
         },
     },
     computed: {
+        // returns the faults and warnings of all rotators
+        faults: function () {
+            var rotators = this.rotators;
+            var faults = [];
+            Object.keys(rotators).forEach(function (key) {
+                (rotators[key].status || []).forEach(function (s) {
+                    faults.push({rotator: key, level: s.level, message: s.message});
+                });
+            });
+            return faults;
+        },
         // returns an object containing all azimuth rotators
         azRotators: function ()  {
             var rotators = this.rotators;
@@ -1,12 +1,26 @@
 package hub
 
 import (
+	"context"
+	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/dh1tw/remoteRotator/audit"
 	"github.com/dh1tw/remoteRotator/rotator"
+	"github.com/dh1tw/remoteRotator/rotator/calibration"
+	"github.com/dh1tw/remoteRotator/rotator/duty"
+	"github.com/dh1tw/remoteRotator/rotator/monitor"
+	"github.com/dh1tw/remoteRotator/rotator/move"
+	"github.com/dh1tw/remoteRotator/wind"
 	"github.com/gorilla/mux"
 	"github.com/gorilla/websocket"
 )
@@ -16,6 +30,7 @@ func (hub *Hub) wsHandler(w http.ResponseWriter, r *http.Request) {
 	upgrader := websocket.Upgrader{
 		ReadBufferSize:  1024,
 		WriteBufferSize: 1024,
+		CheckOrigin:     hub.originAllowed,
 	}
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
@@ -24,21 +39,11 @@ func (hub *Hub) wsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	c := &WsClient{
-		Conn: conn,
+		Conn:     conn,
+		queue:    newSendQueue(clientQueueSize),
+		identity: identity(r),
 	}
 
-	hub.RLock()
-	for _, r := range hub.rotators {
-		ev := Event{
-			Name:        AddRotator,
-			RotatorName: r.Name(),
-		}
-		if err := c.write(ev); err != nil {
-			fmt.Println(err)
-		}
-	}
-	hub.RUnlock()
-
 	hub.addWsClient(c)
 }
 
@@ -46,7 +51,7 @@ func (hub *Hub) rotatorsHandler(w http.ResponseWriter, req *http.Request) {
 	defer req.Body.Close()
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 
-	rotators := hub.serializeRotators()
+	rotators := identity(req).readable(hub.serializeRotators())
 
 	if err := json.NewEncoder(w).Encode(rotators); err != nil {
 		log.Println(err)
@@ -127,9 +132,16 @@ func (hub *Hub) azimuthHandler(w http.ResponseWriter, req *http.Request) {
 			return
 		}
 
-		err := r.SetAzimuth(*azPUT.Azimuth)
+		if wait, _ := strconv.ParseBool(req.URL.Query().Get("wait")); wait {
+			hub.waitMove(w, req, func(ctx context.Context, m *move.Mover) (move.Progress, error) {
+				return m.Azimuth(ctx, hub.bind(req, r), *azPUT.Azimuth)
+			})
+			return
+		}
+
+		err := hub.bind(req, r).SetAzimuth(*azPUT.Azimuth)
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
+			w.WriteHeader(errorStatus(err))
 			w.Write([]byte(fmt.Sprintf("unable to set azimuth to %v: %s", *azPUT.Azimuth, err)))
 		}
 
@@ -190,9 +202,16 @@ func (hub *Hub) elevationHandler(w http.ResponseWriter, req *http.Request) {
 			return
 		}
 
-		err := r.SetElevation(*elPUT.Elevation)
+		if wait, _ := strconv.ParseBool(req.URL.Query().Get("wait")); wait {
+			hub.waitMove(w, req, func(ctx context.Context, m *move.Mover) (move.Progress, error) {
+				return m.Elevation(ctx, hub.bind(req, r), *elPUT.Elevation)
+			})
+			return
+		}
+
+		err := hub.bind(req, r).SetElevation(*elPUT.Elevation)
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
+			w.WriteHeader(errorStatus(err))
 			w.Write([]byte(fmt.Sprintf("unable to set elevation to %v: %s", *elPUT.Elevation, err)))
 		}
 
@@ -201,6 +220,63 @@ func (hub *Hub) elevationHandler(w http.ResponseWriter, req *http.Request) {
 	}
 }
 
+// waitMove executes a move and replies with its final progress once the
+// rotator has arrived, stalled or the time is up. The timeout and the
+// tolerance of the move can be set with query parameters.
+func (hub *Hub) waitMove(w http.ResponseWriter, req *http.Request,
+	f func(context.Context, *move.Mover) (move.Progress, error)) {
+
+	opts, err := moveOptions(req.URL.Query())
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	p, err := f(req.Context(), move.New(opts...))
+	if p.State == "" {
+		// the move has been refused
+		w.WriteHeader(errorStatus(err))
+		w.Write([]byte(fmt.Sprintf("unable to move rotator: %v", err)))
+		return
+	}
+
+	switch p.State {
+	case move.StateTimeout:
+		w.WriteHeader(http.StatusGatewayTimeout)
+	case move.StateStalled, move.StateAborted:
+		w.WriteHeader(http.StatusConflict)
+	}
+
+	if err := json.NewEncoder(w).Encode(p); err != nil {
+		log.Println(err)
+	}
+}
+
+// moveOptions returns the options of a move from the parameters
+// "timeout" (e.g. "60s") and "tolerance" (degrees).
+func moveOptions(params url.Values) ([]func(*move.Mover), error) {
+	opts := []func(*move.Mover){}
+
+	if v := params.Get("timeout"); v != "" {
+		d, err := parseDuration(v)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid timeout '%s'", v)
+		}
+		opts = append(opts, move.Timeout(d))
+	}
+
+	if v := params.Get("tolerance"); v != "" {
+		deg, err := strconv.Atoi(v)
+		if err != nil || deg < 0 {
+			return nil, fmt.Errorf("invalid tolerance '%s'", v)
+		}
+		opts = append(opts, move.Tolerance(deg))
+	}
+
+	return opts, nil
+}
+
 func (hub *Hub) stopAzimuthHandler(w http.ResponseWriter, req *http.Request) {
 	defer req.Body.Close()
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
@@ -221,9 +297,9 @@ func (hub *Hub) stopAzimuthHandler(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	err := r.StopAzimuth()
+	err := hub.bind(req, r).StopAzimuth()
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		w.WriteHeader(errorStatus(err))
 		w.Write([]byte(fmt.Sprintf("unable to stop rotator: %v", err.Error())))
 		return
 	}
@@ -249,9 +325,9 @@ func (hub *Hub) stopElevationHandler(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	err := r.StopElevation()
+	err := hub.bind(req, r).StopElevation()
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		w.WriteHeader(errorStatus(err))
 		w.Write([]byte(fmt.Sprintf("unable to stop rotator: %v", err.Error())))
 		return
 	}
@@ -271,15 +347,551 @@ func (hub *Hub) stopHandler(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	err := r.Stop()
+	err := hub.bind(req, r).Stop()
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		w.WriteHeader(errorStatus(err))
 		w.Write([]byte(fmt.Sprintf("unable to stop rotator: %v", err.Error())))
 		log.Println(err)
 		return
 	}
 }
 
+// acknowledgeHandler acknowledges the faults of a rotator and releases
+// the lock out of further moves.
+func (hub *Hub) acknowledgeHandler(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	vars := mux.Vars(req)
+	rName := vars["rotator"]
+
+	r, ok := hub.Rotator(rName)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("unable to find rotator"))
+		return
+	}
+
+	if err := hub.locks.Check(rName, hub.owner(req)); err != nil {
+		w.WriteHeader(errorStatus(err))
+		w.Write([]byte(fmt.Sprintf("unable to acknowledge: %v", err.Error())))
+		return
+	}
+
+	found, err := rotator.Acknowledge(r)
+	if !found {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("rotator does not support acknowledge"))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(fmt.Sprintf("unable to acknowledge: %v", err.Error())))
+		log.Println(err)
+		return
+	}
+}
+
+// Calibration is the calibration of a rotator's azimuth. The lookup
+// table can only be set in the config file.
+type Calibration struct {
+	Offset   int                 `json:"offset"`
+	Mounting int                 `json:"mounting"`
+	Table    []calibration.Point `json:"table,omitempty"`
+}
+
+// CalibrationRequest is the body of a request to change the offsets of
+// the calibration. Omitted offsets remain unchanged.
+type CalibrationRequest struct {
+	Offset   *int `json:"offset"`
+	Mounting *int `json:"mounting"`
+}
+
+// calibrationHandler returns (GET) or changes (PUT) the calibration of
+// a rotator's azimuth.
+func (hub *Hub) calibrationHandler(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	vars := mux.Vars(req)
+	rName := vars["rotator"]
+
+	r, ok := hub.Rotator(rName)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("unable to find rotator"))
+		return
+	}
+
+	cr, ok := rotator.As[*calibration.Rotator](r)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("rotator does not support calibration"))
+		return
+	}
+	cal := cr.Calibrator()
+
+	if req.Method == "PUT" {
+		cReq := CalibrationRequest{}
+		if err := json.NewDecoder(req.Body).Decode(&cReq); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("invalid json"))
+			return
+		}
+
+		prevOffset, prevMounting := cal.Offsets()
+		offset, mounting := prevOffset, prevMounting
+		if cReq.Offset != nil {
+			offset = *cReq.Offset
+		}
+		if cReq.Mounting != nil {
+			mounting = *cReq.Mounting
+		}
+
+		err := hub.locks.Check(rName, hub.owner(req))
+		hub.audit.Record(audit.Record{
+			Frontend:        audit.REST,
+			Client:          req.RemoteAddr,
+			User:            clientName(req),
+			Rotator:         rName,
+			Command:         "set_calibration",
+			Offsets:         &audit.Offsets{Offset: offset, Mounting: mounting},
+			PreviousOffsets: &audit.Offsets{Offset: prevOffset, Mounting: prevMounting},
+			Error:           errString(err),
+		})
+		if err != nil {
+			w.WriteHeader(errorStatus(err))
+			w.Write([]byte(fmt.Sprintf("unable to set calibration: %v", err.Error())))
+			return
+		}
+
+		cal.SetOffsets(offset, mounting)
+		// the true heading jumps; the outer layers must not mistake
+		// this for a movement
+		rotator.Rebaseline(r)
+
+		// the driver only reports changes of the physical heading
+		hub.Broadcast(Event{
+			Name:        UpdateHeading,
+			RotatorName: rName,
+			Heading:     r.Serialize().Heading,
+		})
+	}
+
+	offset, mounting := cal.Offsets()
+	res := Calibration{
+		Offset:   offset,
+		Mounting: mounting,
+		Table:    cal.Table(),
+	}
+
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		log.Println(err)
+	}
+}
+
+// antennaHandler returns (GET) an antenna of the rotator or turns the
+// rotator so that the antenna points at a bearing (PUT).
+func (hub *Hub) antennaHandler(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	vars := mux.Vars(req)
+	rName := vars["rotator"]
+	aName := vars["antenna"]
+
+	r, ok := hub.Rotator(rName)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("unable to find rotator"))
+		return
+	}
+
+	var ant *rotator.Antenna
+	antennas := r.Serialize().Antennas
+	for i := range antennas {
+		if antennas[i].Name == aName {
+			ant = &antennas[i]
+		}
+	}
+	if ant == nil {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("unable to find antenna"))
+		return
+	}
+
+	switch req.Method {
+	case "GET":
+		if err := json.NewEncoder(w).Encode(ant); err != nil {
+			log.Println(err)
+		}
+
+	case "PUT":
+		antPUT := rotator.AntennaPut{}
+		if err := json.NewDecoder(req.Body).Decode(&antPUT); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("invalid json"))
+			return
+		}
+
+		if antPUT.Bearing == nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("invalid request"))
+			return
+		}
+
+		if !r.HasAzimuth() {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(("rotator does not support azimuth")))
+			return
+		}
+
+		az, err := rotator.AntennaTarget(antennas, aName, *antPUT.Bearing)
+		if err == nil {
+			err = hub.bind(req, r).SetAzimuth(az)
+		}
+		if err != nil {
+			w.WriteHeader(errorStatus(err))
+			w.Write([]byte(fmt.Sprintf("unable to point %s to %v: %s", aName, *antPUT.Bearing, err)))
+		}
+	}
+}
+
+// groupsHandler returns the status of all groups whose members the
+// client is allowed to read.
+func (hub *Hub) groupsHandler(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	res := []GroupStatus{}
+	for _, g := range hub.Groups() {
+		if groupReadable(g, identity(req)) {
+			res = append(res, hub.groupStatus(g))
+		}
+	}
+
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		log.Println(err)
+	}
+}
+
+// groupHandler returns the aggregated status of a group.
+func (hub *Hub) groupHandler(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	g, ok := hub.lookupGroup(w, req)
+	if !ok {
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(hub.groupStatus(g)); err != nil {
+		log.Println(err)
+	}
+}
+
+// groupAzimuthHandler turns all members of a group to the azimuth (plus
+// their offsets).
+func (hub *Hub) groupAzimuthHandler(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	g, ok := hub.lookupGroup(w, req)
+	if !ok {
+		return
+	}
+
+	azPUT := rotator.AzimuthPut{}
+	if err := json.NewDecoder(req.Body).Decode(&azPUT); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("invalid json"))
+		return
+	}
+
+	if azPUT.Azimuth == nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("invalid request"))
+		return
+	}
+
+	err := hub.groupCheck(g, hub.owner(req), identity(req))
+	if err == nil {
+		err = hub.groupExec(g, hub.binder(req), groupSetAzimuth(*azPUT.Azimuth))
+	}
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		w.Write([]byte(fmt.Sprintf("unable to set azimuth of group %s to %v: %s", g.Name, *azPUT.Azimuth, err)))
+	}
+}
+
+// groupElevationHandler turns all members of a group which support
+// elevation to the elevation.
+func (hub *Hub) groupElevationHandler(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	g, ok := hub.lookupGroup(w, req)
+	if !ok {
+		return
+	}
+
+	elPUT := rotator.ElevationPut{}
+	if err := json.NewDecoder(req.Body).Decode(&elPUT); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("invalid json"))
+		return
+	}
+
+	if elPUT.Elevation == nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("invalid request"))
+		return
+	}
+
+	err := hub.groupCheck(g, hub.owner(req), identity(req))
+	if err == nil {
+		err = hub.groupExec(g, hub.binder(req), groupSetElevation(*elPUT.Elevation))
+	}
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		w.Write([]byte(fmt.Sprintf("unable to set elevation of group %s to %v: %s", g.Name, *elPUT.Elevation, err)))
+	}
+}
+
+// groupStopHandler stops all members of a group at once. Members which
+// can't be stopped by the client don't prevent the others from being
+// stopped.
+func (hub *Hub) groupStopHandler(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	g, ok := hub.lookupGroup(w, req)
+	if !ok {
+		return
+	}
+
+	if err := hub.groupExec(g, hub.binder(req), groupStop); err != nil {
+		w.WriteHeader(errorStatus(err))
+		w.Write([]byte(fmt.Sprintf("unable to stop group %s: %v", g.Name, err)))
+		log.Println(err)
+	}
+}
+
+// lookupGroup returns the group addressed by the route. If the group
+// doesn't exist or the client may not read all of its members, an
+// error is written to the client.
+func (hub *Hub) lookupGroup(w http.ResponseWriter, req *http.Request) (Group, bool) {
+	g, ok := hub.Group(mux.Vars(req)["group"])
+	if !ok || !groupReadable(g, identity(req)) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("unable to find group"))
+		return Group{}, false
+	}
+	return g, true
+}
+
+// groupReadable returns true if the identity may read all members of
+// the group.
+func groupReadable(g Group, id *Identity) bool {
+	for _, m := range g.Members {
+		if !id.Can(RoleRead, m.Rotator) {
+			return false
+		}
+	}
+	return true
+}
+
+// LockRequest is the body of a request to take the control of a
+// rotator. An admin token overrides the lease of another client.
+type LockRequest struct {
+	Operator string `json:"operator,omitempty"`
+	Duration string `json:"duration,omitempty"`
+	Token    string `json:"token,omitempty"`
+}
+
+// lockHandler returns (GET), takes (POST) or releases (DELETE) the
+// control of a rotator.
+func (hub *Hub) lockHandler(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	vars := mux.Vars(req)
+	rName := vars["rotator"]
+
+	if _, ok := hub.Rotator(rName); !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("unable to find rotator"))
+		return
+	}
+
+	lr := LockRequest{}
+	if req.Method != "GET" && req.ContentLength != 0 {
+		if err := json.NewDecoder(req.Body).Decode(&lr); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("invalid json"))
+			return
+		}
+	}
+
+	// the operator is only displayed; the lease belongs to the
+	// client (see owner), so that nobody can take over the lease of
+	// another client by claiming its name
+	owner := hub.owner(req)
+	operator := clientName(req)
+	if identity(req).Name == "" && lr.Operator != "" {
+		operator = lr.Operator
+	}
+
+	var d time.Duration
+	if lr.Duration != "" {
+		var err error
+		if d, err = time.ParseDuration(lr.Duration); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(fmt.Sprintf("invalid duration '%s'", lr.Duration)))
+			return
+		}
+	}
+
+	var lease Lease
+	var ok bool
+	var err error
+
+	switch req.Method {
+	case "GET":
+		lease, ok = hub.locks.Lease(rName)
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("rotator not locked"))
+			return
+		}
+	case "POST":
+		if lr.Token != "" {
+			lease, err = hub.locks.Override(rName, owner, operator, lr.Token, d)
+		} else {
+			lease, err = hub.locks.Acquire(rName, owner, operator, d)
+		}
+	case "DELETE":
+		if lr.Token != "" {
+			_, err = hub.locks.Override(rName, "", "", lr.Token, 0)
+		} else {
+			err = hub.locks.Release(rName, owner)
+		}
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if req.Method != "GET" {
+		hub.audit.Record(audit.Record{
+			Frontend: audit.REST,
+			Client:   req.RemoteAddr,
+			User:     operator,
+			Rotator:  rName,
+			Command:  lockCommand(req.Method, lr.Token != ""),
+			Duration: lr.Duration,
+			Error:    errString(err),
+		})
+	}
+
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	if req.Method == "DELETE" {
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(lease); err != nil {
+		log.Println(err)
+	}
+}
+
+// lockCommand returns the name of the lock request for the audit log.
+func lockCommand(method string, admin bool) string {
+	switch {
+	case method == "POST" && admin:
+		return WsOverride
+	case method == "POST":
+		return WsTakeControl
+	case admin:
+		return "override_release"
+	default:
+		return WsRelease
+	}
+}
+
+// errString returns the message of the error or "" if err is nil.
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// bind returns a view of the rotator which only accepts commands if
+// the client has the control role and the rotator is not leased by
+// another client. All commands are recorded in the audit log.
+func (hub *Hub) bind(req *http.Request, r rotator.Rotator) rotator.Rotator {
+	return bindRotator(r, func(r rotator.Rotator) rotator.Rotator {
+		ctl := guard(hub.locks.Bind(r, hub.owner(req)), identity(req))
+		return audit.Bind(ctl, hub.audit, audit.REST, req.RemoteAddr, clientName(req))
+	})
+}
+
+// binder returns a function which binds rotators to the client of the
+// request (see bind).
+func (hub *Hub) binder(req *http.Request) func(rotator.Rotator) rotator.Rotator {
+	return func(r rotator.Rotator) rotator.Rotator {
+		return hub.bind(req, r)
+	}
+}
+
+// clientName returns the name of a REST client. Authenticated clients are
+// identified by their name. Other clients can identify themselves with
+// the X-Operator header.
+func clientName(req *http.Request) string {
+	if name := identity(req).Name; name != "" {
+		return name
+	}
+	return req.Header.Get("X-Operator")
+}
+
+// owner returns the identity of a REST client for the leases.
+// Authenticated clients are identified by their name, all other clients
+// by their address; the X-Operator header is only displayed.
+func (hub *Hub) owner(req *http.Request) string {
+	if name := identity(req).Name; name != "" {
+		return name
+	}
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	return "http:" + host
+}
+
+// errorStatus returns the HTTP status code for an error of a command.
+// Moves refused by the safety layers (fault, wind, duty cycle) are a
+// conflict with the state of the rotator, not a server error.
+func errorStatus(err error) int {
+	var le *LockedError
+	switch {
+	case errors.As(err, &le):
+		return http.StatusLocked
+	case errors.Is(err, monitor.ErrLocked), errors.Is(err, duty.ErrExhausted),
+		errors.Is(err, wind.ErrParked):
+		return http.StatusConflict
+	case errors.Is(err, ErrNotAdmin), errors.Is(err, ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, rotator.ErrOutOfRange):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
 func (hub *Hub) serializeRotators() rotator.Objects {
 
 	hub.RLock()
@@ -294,3 +906,174 @@ func (hub *Hub) serializeRotators() rotator.Objects {
 
 	return rs
 }
+
+// auditHandler returns the records of the audit log. The records can be
+// filtered with the query parameters rotator, frontend, client, user,
+// since, until (RFC3339) and limit. Clients only receive the records of
+// the rotators they are allowed to read.
+func (hub *Hub) auditHandler(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	if hub.audit == nil {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("audit log disabled"))
+		return
+	}
+
+	params := req.URL.Query()
+	id := identity(req)
+
+	q := audit.Query{
+		Rotator:  params.Get("rotator"),
+		Frontend: params.Get("frontend"),
+		Client:   params.Get("client"),
+		User:     params.Get("user"),
+		Filter: func(rec audit.Record) bool {
+			return id.Can(RoleRead, rec.Rotator)
+		},
+	}
+
+	var err error
+	if v := params.Get("since"); v != "" {
+		if q.Since, err = time.Parse(time.RFC3339, v); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(fmt.Sprintf("invalid time '%s' (RFC3339)", v)))
+			return
+		}
+	}
+	if v := params.Get("until"); v != "" {
+		if q.Until, err = time.Parse(time.RFC3339, v); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(fmt.Sprintf("invalid time '%s' (RFC3339)", v)))
+			return
+		}
+	}
+	if v := params.Get("limit"); v != "" {
+		if q.Limit, err = strconv.Atoi(v); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(fmt.Sprintf("invalid limit '%s'", v)))
+			return
+		}
+	}
+
+	records, err := hub.audit.Query(q)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("unable to read audit log"))
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(records); err != nil {
+		log.Println(err)
+	}
+}
+
+// historyHandler returns the heading history of a rotator between the
+// query parameters from and to (RFC3339 or unix timestamp; default: the
+// last hour). With step (e.g. "10s", "5m"), the history is downsampled
+// to the heading at each step. The samples are returned as JSON or, with
+// format=csv or an "Accept: text/csv" header, as CSV.
+func (hub *Hub) historyHandler(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+
+	rName := mux.Vars(req)["rotator"]
+	params := req.URL.Query()
+
+	asCSV := params.Get("format") == "csv" ||
+		(params.Get("format") == "" && strings.Contains(req.Header.Get("Accept"), "text/csv"))
+
+	if hub.history == nil {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("heading history disabled"))
+		return
+	}
+
+	to := time.Now()
+	from := to.Add(-time.Hour)
+	var step time.Duration
+	var err error
+
+	if v := params.Get("to"); v != "" {
+		if to, err = parseTime(v); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		if params.Get("from") == "" {
+			from = to.Add(-time.Hour)
+		}
+	}
+	if v := params.Get("from"); v != "" {
+		if from, err = parseTime(v); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+	}
+	if v := params.Get("step"); v != "" {
+		if step, err = parseDuration(v); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(fmt.Sprintf("invalid step '%s'", v)))
+			return
+		}
+	}
+
+	samples, err := hub.history.Query(rName, from, to, step)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	if asCSV {
+		w.Header().Set("Content-Type", "text/csv; charset=UTF-8")
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"time", "azimuth", "az_preset", "elevation", "el_preset"})
+		for _, s := range samples {
+			cw.Write([]string{
+				s.Time.UTC().Format(time.RFC3339Nano),
+				strconv.Itoa(s.Azimuth),
+				strconv.Itoa(s.AzPreset),
+				strconv.Itoa(s.Elevation),
+				strconv.Itoa(s.ElPreset),
+			})
+		}
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			log.Println(err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	if err := json.NewEncoder(w).Encode(samples); err != nil {
+		log.Println(err)
+	}
+}
+
+// parseDuration parses a duration (e.g. "10s", "5m"). Plain numbers are
+// seconds.
+func parseDuration(v string) (time.Duration, error) {
+	if d, err := time.ParseDuration(v); err == nil {
+		return d, nil
+	}
+	secs, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration '%s'", v)
+	}
+	return time.Duration(secs) * time.Second, nil
+}
+
+// parseTime parses a time in RFC3339 format or as unix timestamp (seconds).
+func parseTime(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	secs, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time '%s' (RFC3339 or unix timestamp)", v)
+	}
+	return time.Unix(0, int64(secs*float64(time.Second))), nil
+}
@@ -1,6 +1,7 @@
 package hub
 
 import (
+	"crypto/tls"
 	"embed"
 	"fmt"
 	"io/fs"
@@ -11,6 +12,8 @@ import (
 	"sync"
 
 	nfs "github.com/dh1tw/nolistfs"
+	"github.com/dh1tw/remoteRotator/audit"
+	"github.com/dh1tw/remoteRotator/history"
 	"github.com/dh1tw/remoteRotator/rotator"
 	"github.com/gorilla/mux"
 )
@@ -26,13 +29,36 @@ type Hub struct {
 	closeTCPClient chan *TCPClient
 	wsClients      map[*WsClient]bool
 	closeWsClient  chan *WsClient
-	rotators       map[string]rotator.Rotator //key: Rotator name
+	sseClients     map[*SseClient]bool
+	eventID        uint64                       // sequence number of the last broadcasted event
+	eventLog       []eventRecord                // backlog of the latest events
+	rotators       map[string]rotator.Rotator   //key: Rotator name
+	antennas       map[string][]rotator.Antenna //key: Rotator name
+	groups         map[string]Group             //key: Group name
+	locks          *Locks
+	auth           Authenticator  // nil: authentication disabled
+	anonymous      *Identity      // identity of unauthenticated clients
+	tlsConfig      *tls.Config    // nil: plain HTTP
+	origins        []string       // allowed (cross) origins
+	audit          *audit.Logger  // nil: audit log disabled
+	history        *history.Store // nil: heading history disabled
 	router         *mux.Router
 	fileServer     http.Handler
 	apiVersion     string
 	apiMatch       *regexp.Regexp
 }
 
+// bindRotator binds the rotator to a client with bind (roles, leases,
+// audit). The members of composite rotators are bound as well, so that
+// clients can't move a rotator through a composite rotator which they
+// may not control directly.
+func bindRotator(r rotator.Rotator, bind func(rotator.Rotator) rotator.Rotator) rotator.Rotator {
+	if c, ok := r.(rotator.Composite); ok {
+		r = c.WithMembers(bind)
+	}
+	return bind(r)
+}
+
 // NewHub returns the pointer to an initialized Hub object.
 func NewHub(rotators ...rotator.Rotator) (*Hub, error) {
 	hub := &Hub{
@@ -40,7 +66,11 @@ func NewHub(rotators ...rotator.Rotator) (*Hub, error) {
 		closeTCPClient: make(chan *TCPClient),
 		wsClients:      make(map[*WsClient]bool),
 		closeWsClient:  make(chan *WsClient),
+		sseClients:     make(map[*SseClient]bool),
 		rotators:       make(map[string]rotator.Rotator),
+		antennas:       make(map[string][]rotator.Antenna),
+		groups:         make(map[string]Group),
+		locks:          NewLocks(),
 		apiVersion:     "1.0",
 		apiMatch:       regexp.MustCompile(`api\/v\d\.\d\/`),
 	}
@@ -56,6 +86,43 @@ func NewHub(rotators ...rotator.Rotator) (*Hub, error) {
 	return hub, nil
 }
 
+// SetLocks replaces the leases of the hub. Changes of the leases are
+// broadcasted to the clients. This method must be called before the
+// listeners are started.
+func (hub *Hub) SetLocks(l *Locks) {
+	hub.Lock()
+	defer hub.Unlock()
+
+	l.onChange = func(lease Lease, released bool) {
+		ev := Event{
+			Name:        LockRotator,
+			RotatorName: lease.Rotator,
+			Lease:       &lease,
+		}
+		if released {
+			ev.Name = UnlockRotator
+		}
+		hub.Broadcast(ev)
+	}
+	hub.locks = l
+}
+
+// SetAudit enables the audit log of the commands received from the
+// clients. This method must be called before the listeners are started.
+func (hub *Hub) SetAudit(l *audit.Logger) {
+	hub.Lock()
+	defer hub.Unlock()
+	hub.audit = l
+}
+
+// SetHistory enables the query API of the heading history. This method
+// must be called before ListenHTTP.
+func (hub *Hub) SetHistory(s *history.Store) {
+	hub.Lock()
+	defer hub.Unlock()
+	hub.history = s
+}
+
 func (hub *Hub) handleClose() {
 	for {
 		select {
@@ -69,18 +136,29 @@ func (hub *Hub) handleClose() {
 
 // AddRotator adds / registers a rotator. The rotator's name must be unique.
 func (hub *Hub) AddRotator(r rotator.Rotator) error {
+	// the antennas are serialized before locking the hub, since the
+	// rotator might be broadcasting an event at the same time
+	antennas := r.Serialize().Antennas
+
 	hub.Lock()
-	defer hub.Unlock()
+	err := hub.addRotator(r, antennas)
+	hub.Unlock()
 
-	return hub.addRotator(r)
+	if err == nil {
+		hub.broadcastGroups(r.Name())
+	}
+	return err
 }
 
-func (hub *Hub) addRotator(r rotator.Rotator) error {
+func (hub *Hub) addRotator(r rotator.Rotator, antennas []rotator.Antenna) error {
 	_, ok := hub.rotators[r.Name()]
 	if ok {
 		return fmt.Errorf("rotator names must be unique; %s exists more than once", r.Name())
 	}
 	hub.rotators[r.Name()] = r
+	if len(antennas) > 0 {
+		hub.antennas[r.Name()] = antennas
+	}
 	ev := Event{
 		Name:        AddRotator,
 		RotatorName: r.Name(),
@@ -94,7 +172,6 @@ func (hub *Hub) addRotator(r rotator.Rotator) error {
 // RemoveRotator deletes / de-registers a rotator.
 func (hub *Hub) RemoveRotator(r rotator.Rotator) {
 	hub.Lock()
-	defer hub.Unlock()
 
 	ev := Event{
 		Name:        RemoveRotator,
@@ -105,7 +182,11 @@ func (hub *Hub) RemoveRotator(r rotator.Rotator) {
 
 	r.Close()
 	delete(hub.rotators, r.Name())
+	delete(hub.antennas, r.Name())
 	log.Printf("removed rotator '%s'\n", r.Name())
+	hub.Unlock()
+
+	hub.broadcastGroups(r.Name())
 }
 
 // Rotator returns a particular rotator stored from the hub. If no
@@ -140,13 +221,14 @@ func (hub *Hub) addTCPClient(client *TCPClient) {
 		delete(hub.tcpClients, client)
 	}
 	hub.tcpClients[client] = true
-	// start listening on TCP socket
 	log.Printf("tcp client connected (%v)\n", client.RemoteAddr())
 
+	go client.send(hub.closeTCPClient)
+
 	// we always pick the first rotator since the TCP client implements
 	// the Yaesu GS232 protocol which can only talk to a single rotator.
 	for _, r := range hub.rotators {
-		go client.listen(r, hub.closeTCPClient)
+		go client.listen(hub, r, hub.closeTCPClient)
 		break
 	}
 }
@@ -156,10 +238,12 @@ func (hub *Hub) removeTCPClient(c *TCPClient) {
 	hub.Lock()
 	defer hub.Unlock()
 
-	if _, ok := hub.tcpClients[c]; ok {
-		delete(hub.tcpClients, c)
+	if _, ok := hub.tcpClients[c]; !ok {
+		return
 	}
+	delete(hub.tcpClients, c)
 
+	c.queue.close()
 	c.Close()
 	log.Printf("tcp client disconnected (%v)\n", c.RemoteAddr())
 }
@@ -174,9 +258,34 @@ func (hub *Hub) addWsClient(client *WsClient) {
 	}
 	hub.wsClients[client] = true
 
-	// we need to listen on the websocket so that the incoming ping
-	// messages can be (automatically) answered (with a pong message)
-	go client.listen(hub.closeWsClient)
+	// announce the existing rotators to the new client
+	for _, r := range hub.rotators {
+		ev := Event{
+			Name:        AddRotator,
+			RotatorName: r.Name(),
+		}
+		if client.wants(ev) {
+			client.queue.push(eventKey(ev), ev)
+		}
+	}
+
+	// announce the active leases
+	for _, lease := range hub.locks.Leases() {
+		ev := Event{
+			Name:        LockRotator,
+			RotatorName: lease.Rotator,
+			Lease:       &lease,
+		}
+		if client.wants(ev) {
+			client.queue.push(eventKey(ev), ev)
+		}
+	}
+
+	go client.send(hub.closeWsClient)
+
+	// listen on the websocket for incoming commands; this also ensures
+	// that incoming ping messages are (automatically) answered
+	go client.listen(hub, hub.closeWsClient)
 
 	log.Printf("websocket client connected (%v)\n", client.RemoteAddr())
 }
@@ -186,10 +295,12 @@ func (hub *Hub) removeWsClient(c *WsClient) {
 	hub.Lock()
 	defer hub.Unlock()
 
-	if _, ok := hub.wsClients[c]; ok {
-		delete(hub.wsClients, c)
+	if _, ok := hub.wsClients[c]; !ok {
+		return
 	}
+	delete(hub.wsClients, c)
 
+	c.queue.close()
 	c.Close()
 	log.Printf("websocket client disconnected (%v)\n", c.RemoteAddr())
 }
@@ -221,14 +332,17 @@ func (hub *Hub) ListenTCP(host string, port int, tcpError chan<- bool) {
 		}
 
 		c := &TCPClient{
-			Conn: conn,
+			Conn:     conn,
+			queue:    newSendQueue(clientQueueSize),
+			identity: hub.defaultIdentity(),
 		}
 		hub.addTCPClient(c)
 	}
 }
 
 // ListenHTTP starts a HTTP Server on a given network adapter / port and
-// sets a HTTP and Websocket handler.
+// sets a HTTP and Websocket handler. If TLS has been enabled with SetTLS,
+// the server accepts HTTPS (and wss) connections.
 // Since this function contains an endless loop, it should be executed
 // in a go routine. If the listener can not be initialized, it will
 // close the errorCh channel.
@@ -250,27 +364,52 @@ func (hub *Hub) ListenHTTP(host string, port int, errorCh chan<- struct{}) {
 
 	// load the HTTP routes with their respective endpoints
 	hub.routes()
+	hub.router.Use(hub.authorize)
 
-	// Listen for incoming connections.
-	log.Printf("listening on %s:%d for HTTP connections\n", host, port)
+	server := &http.Server{
+		Addr:      fmt.Sprintf("%s:%d", host, port),
+		Handler:   hub.cors(hub.authenticate(hub.apiRedirectRouter(hub.router))),
+		TLSConfig: hub.tlsConfig,
+	}
 
-	err = http.ListenAndServe(fmt.Sprintf("%s:%d", host, port), hub.apiRedirectRouter(hub.router))
+	// Listen for incoming connections.
+	if server.TLSConfig != nil {
+		log.Printf("listening on %s:%d for HTTPS connections\n", host, port)
+		// the certificates are provided by the TLS config
+		err = server.ListenAndServeTLS("", "")
+	} else {
+		log.Printf("listening on %s:%d for HTTP connections\n", host, port)
+		err = server.ListenAndServe()
+	}
 	if err != nil {
 		log.Println(err)
 		return
 	}
 }
 
-// Broadcast sends a rotator event to all connected clients
+// Broadcast sends a rotator event to all connected clients. The event
+// is queued for each client and written asynchronously, so that slow
+// clients can not stall the hub. Changes of the heading or the status
+// of a rotator are followed by the status of its groups.
 func (hub *Hub) Broadcast(ev Event) {
 	hub.Lock()
-	defer hub.Unlock()
 	hub.broadcast(ev)
+	hub.Unlock()
+
+	if ev.Name == UpdateHeading || ev.Name == RotatorStatus {
+		hub.broadcastGroups(ev.RotatorName)
+	}
 }
 
 func (hub *Hub) broadcast(ev Event) {
+	// the bearings of the antennas follow the heading of the rotator
+	if ev.Name == UpdateHeading && ev.Antennas == nil {
+		ev.Antennas = rotator.Bearings(hub.antennas[ev.RotatorName], ev.Heading)
+	}
+	rec := hub.record(ev)
 	hub.broadcastToTCPClients(ev)
 	hub.broadcastToWsClients(ev)
+	hub.broadcastToSseClients(rec)
 }
 
 // BroadcastToTCPClients will send a rotator event to all connected TCP Clients
@@ -281,24 +420,46 @@ func (hub *Hub) broadcastToTCPClients(ev Event) {
 		return
 	}
 
-	// update the tcp Clients
+	// queue the event for the tcp Clients
 	for c := range hub.tcpClients {
-		// EA4TX's ARSVCOM doesn't understand single Azimuth
-		// messages (+0nnn). It always expects +0nnn+0nnn
-		data := fmt.Sprintf("+0%.3d+0%.3d\r\n", ev.Heading.Azimuth, ev.Heading.Elevation)
-		if err := c.write(data); err != nil {
-			log.Printf("error writing to client %v: %v\n", c.RemoteAddr(), err)
-			log.Printf("disconnecting client %v\n", c.RemoteAddr())
-			c.Close()
-			delete(hub.tcpClients, c)
+		if c.Identity().Can(RoleRead, ev.RotatorName) {
+			c.queue.push(eventKey(ev), ev)
 		}
 	}
 }
 
 type Event struct {
-	Name        RotatorEvent    `json:"name,omitempty"`
-	RotatorName string          `json:"rotator_name,omitempty"`
-	Heading     rotator.Heading `json:"heading,omitempty"`
+	Name        RotatorEvent      `json:"name,omitempty"`
+	RotatorName string            `json:"rotator_name,omitempty"`
+	Heading     rotator.Heading   `json:"heading,omitempty"`
+	Status      *rotator.Status   `json:"status,omitempty"`
+	Lease       *Lease            `json:"lease,omitempty"`
+	Antennas    []rotator.Antenna `json:"antennas,omitempty"`
+	Group       *GroupStatus      `json:"group,omitempty"`
+}
+
+// rotators returns the names of the rotators the event is about. Group
+// events are about all members of the group.
+func (ev Event) rotators() []string {
+	if ev.Group == nil {
+		return []string{ev.RotatorName}
+	}
+	names := make([]string, 0, len(ev.Group.Members))
+	for _, m := range ev.Group.Members {
+		names = append(names, m.Rotator)
+	}
+	return names
+}
+
+// readable returns true if the client may read the event, i.e. all
+// rotators the event is about.
+func (ev Event) readable(id *Identity) bool {
+	for _, name := range ev.rotators() {
+		if !id.Can(RoleRead, name) {
+			return false
+		}
+	}
+	return true
 }
 
 type RotatorEvent string
@@ -307,16 +468,21 @@ const (
 	AddRotator    RotatorEvent = "add"
 	RemoveRotator RotatorEvent = "remove"
 	UpdateHeading RotatorEvent = "heading"
+	CommandAck    RotatorEvent = "ack"
+	CommandError  RotatorEvent = "error"
+	RotatorStatus RotatorEvent = "status"
+	LockRotator   RotatorEvent = "lock"
+	UnlockRotator RotatorEvent = "unlock"
+	MoveProgress  RotatorEvent = "progress"
+	UpdateGroup   RotatorEvent = "group"
 )
 
 func (hub *Hub) broadcastToWsClients(event Event) {
 
 	for c := range hub.wsClients {
-		if err := c.write(event); err != nil {
-			log.Printf("error writing to client %v: %v\n", c.RemoteAddr(), err)
-			log.Printf("disconnecting client %v\n", c.RemoteAddr())
-			c.Close()
-			delete(hub.wsClients, c)
+		if !c.wants(event) {
+			continue
 		}
+		c.queue.push(eventKey(event), event)
 	}
 }
@@ -0,0 +1,368 @@
+package hub
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dh1tw/remoteRotator/rotator"
+)
+
+// Lease grants a client the exclusive control of a rotator until it
+// expires or is released. The owner identifies the client (its user
+// name or, without authentication, its address); the operator is the
+// name the client claims and is only displayed.
+type Lease struct {
+	Rotator  string    `json:"rotator"`
+	Owner    string    `json:"owner"`
+	Operator string    `json:"operator,omitempty"`
+	Expires  time.Time `json:"expires"`
+}
+
+// holder returns the name under which the lease is displayed.
+func (l Lease) holder() string {
+	if l.Operator != "" {
+		return l.Operator
+	}
+	return l.Owner
+}
+
+// LockedError is returned when a client tries to control a rotator
+// which is leased by another client.
+type LockedError struct {
+	Lease Lease
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("locked by %s until %s", e.Lease.holder(),
+		e.Lease.Expires.Format("15:04:05"))
+}
+
+// ErrNotAdmin is returned if a lease is overridden with an invalid
+// admin token.
+var ErrNotAdmin = errors.New("invalid admin token")
+
+// Locks manages the leases of the rotators. A client can take control
+// of a rotator for a lease period; all other clients have read-only
+// access until the lease expires, is released or is overridden by an
+// admin.
+type Locks struct {
+	sync.Mutex
+	leases       map[string]Lease // key: rotator name
+	leaseTime    time.Duration
+	maxLease     time.Duration
+	autoLease    time.Duration
+	tcpAutoLease time.Duration
+	adminToken   string
+	onChange     func(lease Lease, released bool)
+	persist      func(rName string, lease *Lease)
+}
+
+// NewLocks returns an initialized Locks object. Configuration settings
+// can be set through functional options.
+// Default settings are:
+// leaseTime: 5min,
+// maxLease: 1h,
+// autoLease: 0 (disabled),
+// tcpAutoLease: 1min,
+// adminToken: "" (override disabled).
+func NewLocks(opts ...func(*Locks)) *Locks {
+	l := &Locks{
+		leases:       make(map[string]Lease),
+		leaseTime:    time.Minute * 5,
+		maxLease:     time.Hour,
+		tcpAutoLease: time.Minute,
+	}
+
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	return l
+}
+
+// LeaseTime is a functional option to set the default duration of a
+// lease.
+func LeaseTime(d time.Duration) func(*Locks) {
+	return func(l *Locks) {
+		l.leaseTime = d
+	}
+}
+
+// MaxLease is a functional option to set the maximum duration of a
+// lease which can be requested by a client.
+func MaxLease(d time.Duration) func(*Locks) {
+	return func(l *Locks) {
+		l.maxLease = d
+	}
+}
+
+// AutoLease is a functional option to let every command implicitly take
+// (or renew) the lease for the given duration. This allows clients which
+// don't support leases (e.g. GS-232 TCP clients) to take control.
+func AutoLease(d time.Duration) func(*Locks) {
+	return func(l *Locks) {
+		l.autoLease = d
+	}
+}
+
+// TCPAutoLease is a functional option to set the auto lease (see
+// AutoLease) of the TCP clients. GS-232 has no commands to take a lease,
+// so every command of a TCP client takes or renews the lease instead.
+// A duration <= 0 applies the auto lease of all clients.
+func TCPAutoLease(d time.Duration) func(*Locks) {
+	return func(l *Locks) {
+		l.tcpAutoLease = d
+	}
+}
+
+// AdminToken is a functional option to set the token which allows to
+// override the leases of other clients.
+func AdminToken(token string) func(*Locks) {
+	return func(l *Locks) {
+		l.adminToken = token
+	}
+}
+
+// Restore is a functional option to restore leases (e.g. after a
+// restart). Expired leases are ignored.
+func Restore(leases ...Lease) func(*Locks) {
+	return func(l *Locks) {
+		for _, lease := range leases {
+			if time.Now().Before(lease.Expires) {
+				l.leases[lease.Rotator] = lease
+			}
+		}
+	}
+}
+
+// Persist is a functional option to set a callback which is executed
+// whenever a lease is taken, renewed or released (lease == nil), so
+// that the leases can be restored after a restart.
+func Persist(f func(rName string, lease *Lease)) func(*Locks) {
+	return func(l *Locks) {
+		l.persist = f
+	}
+}
+
+// Acquire takes or renews the control of a rotator for the duration. A
+// duration <= 0 selects the default lease time.
+func (l *Locks) Acquire(rName, owner, operator string, d time.Duration) (Lease, error) {
+	l.Lock()
+
+	if lease, ok := l.active(rName); ok && lease.Owner != owner {
+		l.Unlock()
+		return Lease{}, &LockedError{lease}
+	}
+
+	lease := l.grant(rName, owner, operator, d)
+	l.Unlock()
+
+	l.save(rName, &lease)
+	l.notify(lease, false)
+	return lease, nil
+}
+
+// Release gives up the control of a rotator.
+func (l *Locks) Release(rName, owner string) error {
+	l.Lock()
+
+	lease, ok := l.active(rName)
+	if !ok {
+		l.Unlock()
+		return nil
+	}
+	if lease.Owner != owner {
+		l.Unlock()
+		return &LockedError{lease}
+	}
+	delete(l.leases, rName)
+	l.Unlock()
+
+	l.save(rName, nil)
+	l.notify(lease, true)
+	return nil
+}
+
+// Override transfers the control of a rotator to the owner regardless
+// of the current lease. An empty owner releases the rotator. The admin
+// token is required.
+func (l *Locks) Override(rName, owner, operator, token string, d time.Duration) (Lease, error) {
+	if l.adminToken == "" ||
+		subtle.ConstantTimeCompare([]byte(token), []byte(l.adminToken)) != 1 {
+		return Lease{}, ErrNotAdmin
+	}
+
+	l.Lock()
+
+	if owner == "" {
+		lease, ok := l.active(rName)
+		delete(l.leases, rName)
+		l.Unlock()
+		if ok {
+			l.save(rName, nil)
+			l.notify(lease, true)
+		}
+		return lease, nil
+	}
+
+	lease := l.grant(rName, owner, operator, d)
+	l.Unlock()
+
+	l.save(rName, &lease)
+	l.notify(lease, false)
+	return lease, nil
+}
+
+// Check returns a LockedError if the rotator is leased by another
+// client. If auto leases are enabled, the lease is taken or renewed
+// for the owner.
+func (l *Locks) Check(rName, owner string) error {
+	return l.check(rName, owner, l.autoLease)
+}
+
+// check returns a LockedError if the rotator is leased by another
+// client and takes or renews the auto lease (if autoLease > 0).
+func (l *Locks) check(rName, owner string, autoLease time.Duration) error {
+	l.Lock()
+
+	lease, ok := l.active(rName)
+	if ok && lease.Owner != owner {
+		l.Unlock()
+		return &LockedError{lease}
+	}
+
+	if autoLease <= 0 || owner == "" {
+		l.Unlock()
+		return nil
+	}
+
+	// renew, but never shorten an explicit lease
+	expires := time.Now().Add(autoLease)
+	if ok && lease.Expires.After(expires) {
+		l.Unlock()
+		return nil
+	}
+	if !ok {
+		lease = Lease{Rotator: rName, Owner: owner}
+	}
+	lease.Expires = expires
+	l.leases[rName] = lease
+	l.Unlock()
+
+	// only persist and announce new leases, not every renewal; an auto
+	// lease is short and renewed with the next command anyway
+	if !ok {
+		l.save(rName, &lease)
+		l.notify(lease, false)
+	}
+	return nil
+}
+
+// Lease returns the active lease of a rotator.
+func (l *Locks) Lease(rName string) (Lease, bool) {
+	l.Lock()
+	defer l.Unlock()
+	return l.active(rName)
+}
+
+// Leases returns all active leases.
+func (l *Locks) Leases() []Lease {
+	l.Lock()
+	defer l.Unlock()
+
+	leases := make([]Lease, 0, len(l.leases))
+	for name := range l.leases {
+		if lease, ok := l.active(name); ok {
+			leases = append(leases, lease)
+		}
+	}
+	return leases
+}
+
+// active returns the lease of the rotator if it hasn't expired yet.
+// Must be called with the lock held.
+func (l *Locks) active(rName string) (Lease, bool) {
+	lease, ok := l.leases[rName]
+	if !ok {
+		return Lease{}, false
+	}
+	if time.Now().After(lease.Expires) {
+		delete(l.leases, rName)
+		return Lease{}, false
+	}
+	return lease, true
+}
+
+// grant stores a lease. Must be called with the lock held.
+func (l *Locks) grant(rName, owner, operator string, d time.Duration) Lease {
+	if d <= 0 {
+		d = l.leaseTime
+	}
+	if l.maxLease > 0 && d > l.maxLease {
+		d = l.maxLease
+	}
+
+	lease := Lease{Rotator: rName, Owner: owner, Operator: operator, Expires: time.Now().Add(d)}
+	l.leases[rName] = lease
+	return lease
+}
+
+func (l *Locks) save(rName string, lease *Lease) {
+	if l.persist != nil {
+		l.persist(rName, lease)
+	}
+}
+
+func (l *Locks) notify(lease Lease, released bool) {
+	if l.onChange != nil {
+		l.onChange(lease, released)
+	}
+}
+
+// Bind returns a view of the rotator for the owner. Set commands sent
+// through the view are refused with a LockedError while the rotator is
+// leased by another client. Stop commands are always passed through;
+// anyone must be able to stop a rotator in an emergency.
+func (l *Locks) Bind(r rotator.Rotator, owner string) rotator.Rotator {
+	return &controller{Rotator: r, locks: l, owner: owner, autoLease: l.autoLease}
+}
+
+// BindTCP returns a view of the rotator for a TCP client (see Bind).
+// Every set command takes or renews the lease (see TCPAutoLease).
+func (l *Locks) BindTCP(r rotator.Rotator, owner string) rotator.Rotator {
+	autoLease := l.tcpAutoLease
+	if autoLease <= 0 {
+		autoLease = l.autoLease
+	}
+	return &controller{Rotator: r, locks: l, owner: owner, autoLease: autoLease}
+}
+
+// controller is a view of a rotator bound to a client.
+type controller struct {
+	rotator.Rotator
+	locks     *Locks
+	owner     string
+	autoLease time.Duration
+}
+
+func (c *controller) SetAzimuth(az int) error {
+	if err := c.locks.check(c.Name(), c.owner, c.autoLease); err != nil {
+		return err
+	}
+	return c.Rotator.SetAzimuth(az)
+}
+
+func (c *controller) SetElevation(el int) error {
+	if err := c.locks.check(c.Name(), c.owner, c.autoLease); err != nil {
+		return err
+	}
+	return c.Rotator.SetElevation(el)
+}
+
+// Unwrap returns the bound rotator.
+func (c *controller) Unwrap() rotator.Rotator {
+	return c.Rotator
+}
@@ -0,0 +1,242 @@
+package hub
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dh1tw/remoteRotator/rotator"
+	"github.com/dh1tw/remoteRotator/rotator/composite"
+	"github.com/dh1tw/remoteRotator/rotator/dummy"
+	"github.com/gorilla/mux"
+)
+
+func TestLocks(t *testing.T) {
+	l := NewLocks(AdminToken("secret"))
+
+	if _, err := l.Acquire("rot", "alice", "", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	var le *LockedError
+	if err := l.Check("rot", "bob"); !errors.As(err, &le) || le.Lease.Owner != "alice" {
+		t.Fatalf("expected rotator to be locked by alice, got %v", err)
+	}
+	if !strings.Contains(le.Error(), "locked by alice") {
+		t.Fatalf("unexpected error message: %v", le)
+	}
+	if _, err := l.Acquire("rot", "bob", "", 0); err == nil {
+		t.Fatal("expected bob not to be able to take control")
+	}
+	if err := l.Release("rot", "bob"); err == nil {
+		t.Fatal("expected bob not to be able to release the lease of alice")
+	}
+	if err := l.Check("rot", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Check("other", "bob"); err != nil {
+		t.Fatal(err)
+	}
+
+	// admin override
+	if _, err := l.Override("rot", "bob", "", "wrong", 0); err != ErrNotAdmin {
+		t.Fatalf("expected ErrNotAdmin, got %v", err)
+	}
+	if _, err := l.Override("rot", "bob", "", "secret", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Check("rot", "alice"); err == nil {
+		t.Fatal("expected rotator to be locked by bob")
+	}
+
+	if err := l.Release("rot", "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Check("rot", "alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	// expiry
+	l.Acquire("rot", "alice", "", time.Millisecond)
+	time.Sleep(time.Millisecond * 5)
+	if err := l.Check("rot", "bob"); err != nil {
+		t.Fatalf("expected lease to be expired, got %v", err)
+	}
+}
+
+func TestAutoLease(t *testing.T) {
+	l := NewLocks(AutoLease(time.Minute))
+
+	if err := l.Check("rot", "tcp:1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Check("rot", "tcp:2"); err == nil {
+		t.Fatal("expected rotator to be auto leased by tcp:1")
+	}
+
+	// an explicit lease is not shortened
+	l.Release("rot", "tcp:1")
+	l.Acquire("rot", "alice", "", time.Hour)
+	l.Check("rot", "alice")
+	if lease, _ := l.Lease("rot"); time.Until(lease.Expires) < time.Minute*59 {
+		t.Fatalf("expected explicit lease to be kept, expires %v", lease.Expires)
+	}
+}
+
+func TestTCPAutoLease(t *testing.T) {
+	r, _ := dummy.New(dummy.Name("rot"))
+	defer r.Close()
+
+	l := NewLocks()
+	tcp := l.BindTCP(r, "tcp:1")
+	if err := tcp.SetAzimuth(10); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Check("rot", "alice"); err == nil {
+		t.Fatal("expected rotator to be auto leased by the tcp client")
+	}
+	if err := l.BindTCP(r, "tcp:2").SetAzimuth(20); err == nil {
+		t.Fatal("expected other tcp client to be locked out")
+	}
+
+	// the other frontends don't take a lease implicitly
+	l.Release("rot", "tcp:1")
+	if err := l.Bind(r, "alice").SetAzimuth(10); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := l.Lease("rot"); ok {
+		t.Fatal("expected no auto lease")
+	}
+}
+
+func TestRestoreLeases(t *testing.T) {
+	saved := map[string]*Lease{}
+	persist := Persist(func(rName string, lease *Lease) {
+		saved[rName] = lease
+	})
+
+	l := NewLocks(persist)
+	l.Acquire("rot", "alice", "", time.Minute)
+	l.Acquire("other", "bob", "", time.Minute)
+	l.Release("other", "bob")
+
+	if saved["rot"] == nil || saved["rot"].Owner != "alice" || saved["other"] != nil {
+		t.Fatalf("unexpected saved leases %v", saved)
+	}
+
+	expired := Lease{Rotator: "old", Owner: "carol", Expires: time.Now().Add(-time.Second)}
+	l = NewLocks(Restore(*saved["rot"], expired))
+
+	if err := l.Check("rot", "bob"); err == nil {
+		t.Fatal("expected restored lease of alice")
+	}
+	if _, ok := l.Lease("old"); ok {
+		t.Fatal("expected expired lease not to be restored")
+	}
+}
+
+func TestLockREST(t *testing.T) {
+	r, _ := dummy.New(dummy.Name("rot"))
+	defer r.Close()
+
+	h, err := NewHub(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.router = mux.NewRouter()
+	h.routes()
+
+	// clients without authentication are identified by their address;
+	// the operator name is only displayed
+	alice, bob := "192.0.2.1:4000", "192.0.2.2:4000"
+
+	do := func(method, path, addr, operator, body string) int {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.RemoteAddr = addr
+		if operator != "" {
+			req.Header.Set("X-Operator", operator)
+		}
+		rec := httptest.NewRecorder()
+		h.router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := do("POST", "/api/v1.0/rotator/rot/lock", alice, "alice", `{"duration": "1m"}`); code != http.StatusOK {
+		t.Fatalf("expected alice to take control, got %d", code)
+	}
+	if lease, _ := h.locks.Lease("rot"); lease.Owner != "http:192.0.2.1" || lease.Operator != "alice" {
+		t.Fatalf("unexpected lease %+v", lease)
+	}
+	if code := do("PUT", "/api/v1.0/rotator/rot/azimuth", bob, "bob", `{"azimuth": 10}`); code != http.StatusLocked {
+		t.Fatalf("expected %d, got %d", http.StatusLocked, code)
+	}
+	if code := do("PUT", "/api/v1.0/rotator/rot/azimuth", bob, "alice", `{"azimuth": 10}`); code != http.StatusLocked {
+		t.Fatalf("expected bob not to pass the lease by claiming the name of alice, got %d", code)
+	}
+	if code := do("PUT", "/api/v1.0/rotator/rot/azimuth", alice, "alice", `{"azimuth": 10}`); code != http.StatusOK {
+		t.Fatalf("expected alice to control the rotator, got %d", code)
+	}
+	for _, path := range []string{"/api/v1.0/rotator/rot/stop", "/api/v1.0/rotator/rot/stop_azimuth"} {
+		if code := do("POST", path, bob, "bob", ""); code != http.StatusOK {
+			t.Fatalf("expected bob to be able to stop the leased rotator (%s), got %d", path, code)
+		}
+	}
+	if code := do("DELETE", "/api/v1.0/rotator/rot/lock", bob, "alice", ""); code != http.StatusLocked {
+		t.Fatalf("expected bob not to release the lease of alice, got %d", code)
+	}
+	if code := do("DELETE", "/api/v1.0/rotator/rot/lock", alice, "alice", ""); code != http.StatusOK {
+		t.Fatalf("expected alice to release the rotator, got %d", code)
+	}
+	if code := do("GET", "/api/v1.0/rotator/rot/lock", bob, "", ""); code != http.StatusNotFound {
+		t.Fatalf("expected rotator not to be locked, got %d", code)
+	}
+	if code := do("PUT", "/api/v1.0/rotator/rot/azimuth", bob, "bob", `{"azimuth": 10}`); code != http.StatusOK {
+		t.Fatalf("expected bob to control the rotator, got %d", code)
+	}
+}
+
+func TestCompositeMembers(t *testing.T) {
+	az, _ := dummy.New(dummy.Name("az"), dummy.HasElevation(false))
+	defer az.Close()
+	el, _ := dummy.New(dummy.Name("el"), dummy.HasAzimuth(false), dummy.HasElevation(true))
+	defer el.Close()
+	sat, err := composite.New(az, el, composite.Name("sat"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l := NewLocks()
+	bind := func(id *Identity, owner string) rotator.Rotator {
+		return bindRotator(sat, func(r rotator.Rotator) rotator.Rotator {
+			return guard(l.Bind(r, owner), id)
+		})
+	}
+
+	// the lease of a member applies to the composite rotator
+	if _, err := l.Acquire("az", "bob", "", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	var le *LockedError
+	if err := bind(everyone, "alice").SetAzimuth(10); !errors.As(err, &le) {
+		t.Fatalf("expected azimuth rotator to be locked, got %v", err)
+	}
+	if err := bind(everyone, "alice").SetElevation(10); err != nil {
+		t.Fatal(err)
+	}
+	if err := bind(everyone, "alice").Stop(); err != nil {
+		t.Fatalf("expected stop to pass the lease, got %v", err)
+	}
+	l.Release("az", "bob")
+
+	// the roles of the members apply to the composite rotator
+	id := &Identity{Name: "carol", Roles: map[string]Role{"sat": RoleControl, "el": RoleControl, "az": RoleRead}}
+	if err := bind(id, "carol").SetAzimuth(10); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("expected %v, got %v", ErrForbidden, err)
+	}
+	if err := bind(id, "carol").SetElevation(20); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,111 @@
+package hub
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dh1tw/remoteRotator/rotator"
+	"github.com/dh1tw/remoteRotator/rotator/dummy"
+	"github.com/dh1tw/remoteRotator/rotator/duty"
+	"github.com/dh1tw/remoteRotator/rotator/monitor"
+	"github.com/dh1tw/remoteRotator/rotator/move"
+	"github.com/dh1tw/remoteRotator/wind"
+	"github.com/gorilla/mux"
+)
+
+func TestMoveAndWait(t *testing.T) {
+	fast, _ := dummy.New(dummy.Name("fast"), dummy.AzimuthSpeed(100))
+	defer fast.Close()
+	slow, _ := dummy.New(dummy.Name("slow"), dummy.AzimuthSpeed(1))
+	defer slow.Close()
+
+	h, err := NewHub(fast, slow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.router = mux.NewRouter()
+	h.routes()
+
+	tt := []struct {
+		name   string
+		path   string
+		body   string
+		status int
+		state  string
+	}{
+		{"arrived", "/api/v1.0/rotator/fast/azimuth?wait=true&timeout=10s", `{"azimuth":20}`, http.StatusOK, move.StateArrived},
+		{"timeout", "/api/v1.0/rotator/slow/azimuth?wait=true&timeout=1", `{"azimuth":180}`, http.StatusGatewayTimeout, move.StateTimeout},
+		{"invalid timeout", "/api/v1.0/rotator/fast/azimuth?wait=true&timeout=x", `{"azimuth":40}`, http.StatusBadRequest, ""},
+		{"invalid tolerance", "/api/v1.0/rotator/fast/azimuth?wait=true&tolerance=-1", `{"azimuth":40}`, http.StatusBadRequest, ""},
+		{"no elevation", "/api/v1.0/rotator/fast/elevation?wait=true", `{"elevation":40}`, http.StatusInternalServerError, ""},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("PUT", tc.path, strings.NewReader(tc.body))
+			rec := httptest.NewRecorder()
+			h.router.ServeHTTP(rec, req)
+
+			if rec.Code != tc.status {
+				t.Fatalf("expected status %d, got %d (%s)", tc.status, rec.Code, rec.Body.String())
+			}
+			if tc.state == "" {
+				return
+			}
+			p := move.Progress{}
+			if err := json.NewDecoder(rec.Body).Decode(&p); err != nil {
+				t.Fatal(err)
+			}
+			if p.State != tc.state {
+				t.Fatalf("expected state %s, got %+v", tc.state, p)
+			}
+		})
+	}
+}
+
+func TestWsMoveTolerance(t *testing.T) {
+	d, _ := dummy.New(dummy.Name("fast"))
+	defer d.Close()
+
+	h, err := NewHub(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	az, tolerance := 40, -1
+	c := &WsClient{identity: h.defaultIdentity()}
+	err = h.wsMove(c, WsCommand{Command: WsMove, Rotator: "fast", Azimuth: &az, Tolerance: &tolerance})
+	if err == nil || !strings.Contains(err.Error(), "invalid tolerance") {
+		t.Fatalf("expected negative tolerance to be rejected, got %v", err)
+	}
+}
+
+func TestErrorStatus(t *testing.T) {
+	tt := []struct {
+		name   string
+		err    error
+		status int
+	}{
+		{"leased", &LockedError{}, http.StatusLocked},
+		{"forbidden", ErrForbidden, http.StatusForbidden},
+		{"out of range", rotator.ErrOutOfRange, http.StatusBadRequest},
+		{"monitor fault", monitor.ErrLocked, http.StatusConflict},
+		{"duty cycle", duty.ErrExhausted, http.StatusConflict},
+		{"wind", wind.ErrParked, http.StatusConflict},
+		{"wrapped", fmt.Errorf("upper: %w", wind.ErrParked), http.StatusConflict},
+		{"other", errors.New("serial port closed"), http.StatusInternalServerError},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if status := errorStatus(tc.err); status != tc.status {
+				t.Fatalf("expected %d, got %d", tc.status, status)
+			}
+		})
+	}
+}
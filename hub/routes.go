@@ -9,7 +9,21 @@ func (hub *Hub) routes() {
 	hub.router.HandleFunc("/api/v1.0/rotator/{rotator}/stop", hub.stopHandler)
 	hub.router.HandleFunc("/api/v1.0/rotator/{rotator}/stop_azimuth", hub.stopAzimuthHandler)
 	hub.router.HandleFunc("/api/v1.0/rotator/{rotator}/stop_elevation", hub.stopElevationHandler)
+	hub.router.HandleFunc("/api/v1.0/rotator/{rotator}/lock", hub.lockHandler).Methods("GET", "POST", "DELETE")
+	hub.router.HandleFunc("/api/v1.0/rotator/{rotator}/acknowledge", hub.acknowledgeHandler).Methods("POST")
+	hub.router.HandleFunc("/api/v1.0/rotator/{rotator}/calibration", hub.calibrationHandler).Methods("GET", "PUT")
+	hub.router.HandleFunc("/api/v1.0/rotator/{rotator}/antenna/{antenna}", hub.antennaHandler).Methods("GET", "PUT")
+	hub.router.HandleFunc("/api/v1.0/rotator/{rotator}/history", hub.historyHandler).Methods("GET")
+	hub.router.HandleFunc("/api/v1.0/rotator/{rotator}/events", hub.sseHandler).Methods("GET")
+	hub.router.HandleFunc("/api/v1.0/events", hub.sseHandler).Methods("GET")
+	hub.router.HandleFunc("/api/v1.0/groups", hub.groupsHandler).Methods("GET")
+	hub.router.HandleFunc("/api/v1.0/group/{group}", hub.groupHandler).Methods("GET")
+	hub.router.HandleFunc("/api/v1.0/group/{group}/azimuth", hub.groupAzimuthHandler).Methods("PUT")
+	hub.router.HandleFunc("/api/v1.0/group/{group}/elevation", hub.groupElevationHandler).Methods("PUT")
+	hub.router.HandleFunc("/api/v1.0/group/{group}/stop", hub.groupStopHandler).Methods("PUT", "POST")
+	hub.router.HandleFunc("/api/v1.0/audit", hub.auditHandler).Methods("GET")
 
+	hub.router.HandleFunc("/api/v1.0/ws", hub.wsHandler)
 	hub.router.HandleFunc("/ws", hub.wsHandler)
 	hub.router.PathPrefix("/").Handler(hub.fileServer)
 }
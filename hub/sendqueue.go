@@ -0,0 +1,132 @@
+package hub
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// maximum number of messages which can be queued for a client.
+	clientQueueSize = 64
+
+	// time allowed to write a message to a client. Clients which
+	// don't accept a message within this time are disconnected.
+	clientWriteWait = 5 * time.Second
+)
+
+// sendQueue is the bounded outbound queue of a single client. It
+// decouples the hub from its clients, so that a slow client can not
+// delay the delivery of events to other clients.
+// Messages with a key (e.g. heading updates of a rotator) are coalesced;
+// only the latest value will be delivered. If the queue is full, the
+// oldest message will be dropped.
+type sendQueue struct {
+	sync.Mutex
+	items   []queueItem
+	size    int
+	notify  chan struct{}
+	closeCh chan struct{}
+	closer  sync.Once
+}
+
+type queueItem struct {
+	key string
+	v   interface{}
+}
+
+func newSendQueue(size int) *sendQueue {
+	return &sendQueue{
+		items:   make([]queueItem, 0, size),
+		size:    size,
+		notify:  make(chan struct{}, 1),
+		closeCh: make(chan struct{}),
+	}
+}
+
+// eventKey returns the key under which an event can be coalesced.
+// Heading updates are coalesced per rotator and group updates per
+// group, all other events must be delivered.
+func eventKey(ev Event) string {
+	switch {
+	case ev.Name == UpdateHeading:
+		return string(UpdateHeading) + "/" + ev.RotatorName
+	case ev.Name == UpdateGroup && ev.Group != nil:
+		return string(UpdateGroup) + "/" + ev.Group.Name
+	}
+	return ""
+}
+
+// push adds a message to the queue without blocking. If a message
+// with the same (non empty) key is already queued, it will be replaced.
+func (q *sendQueue) push(key string, v interface{}) {
+	q.Lock()
+	defer q.Unlock()
+
+	if key != "" {
+		for i := range q.items {
+			if q.items[i].key == key {
+				q.items[i].v = v
+				return
+			}
+		}
+	}
+
+	if len(q.items) >= q.size {
+		q.items = q.items[1:]
+	}
+	q.items = append(q.items, queueItem{key, v})
+
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+// pop returns the oldest message of the queue. It blocks until a
+// message is available or the queue has been closed.
+func (q *sendQueue) pop() (interface{}, bool) {
+	for {
+		q.Lock()
+		if len(q.items) > 0 {
+			item := q.items[0]
+			q.items = q.items[1:]
+			q.Unlock()
+			return item.v, true
+		}
+		q.Unlock()
+
+		select {
+		case <-q.notify:
+		case <-q.closeCh:
+			return nil, false
+		}
+	}
+}
+
+// done returns a channel which is closed when the queue is closed.
+func (q *sendQueue) done() <-chan struct{} {
+	return q.closeCh
+}
+
+// close the queue and unblock the consumer.
+func (q *sendQueue) close() {
+	q.closer.Do(func() {
+		close(q.closeCh)
+	})
+}
+
+// run takes the messages from the queue and hands them over to the
+// write function until the queue gets closed or the write function
+// returns an error. Since this method contains an endless loop it
+// should be executed in a go routine.
+func (q *sendQueue) run(write func(interface{}) error) error {
+	for {
+		v, ok := q.pop()
+		if !ok {
+			return nil
+		}
+		if err := write(v); err != nil {
+			return err
+		}
+	}
+}
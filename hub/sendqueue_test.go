@@ -0,0 +1,74 @@
+package hub
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dh1tw/remoteRotator/rotator"
+)
+
+func TestSendQueue(t *testing.T) {
+
+	heading := func(name string, az int) Event {
+		return Event{
+			Name:        UpdateHeading,
+			RotatorName: name,
+			Heading:     rotator.Heading{Azimuth: az},
+		}
+	}
+
+	add := Event{Name: AddRotator, RotatorName: "rot2"}
+
+	tt := []struct {
+		name   string
+		size   int
+		input  []Event
+		output []Event
+	}{
+		{"single event", 4, []Event{heading("rot1", 10)}, []Event{heading("rot1", 10)}},
+		{"coalesce headings of same rotator", 4,
+			[]Event{heading("rot1", 10), heading("rot1", 11), heading("rot1", 12)},
+			[]Event{heading("rot1", 12)}},
+		{"don't coalesce headings of different rotators", 4,
+			[]Event{heading("rot1", 10), heading("rot2", 20), heading("rot1", 11)},
+			[]Event{heading("rot1", 11), heading("rot2", 20)}},
+		{"don't coalesce other events", 4,
+			[]Event{add, add},
+			[]Event{add, add}},
+		{"drop oldest when full", 2,
+			[]Event{heading("rot1", 10), add, heading("rot3", 30)},
+			[]Event{add, heading("rot3", 30)}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			q := newSendQueue(tc.size)
+			for _, ev := range tc.input {
+				q.push(eventKey(ev), ev)
+			}
+			q.close()
+
+			res := []Event{}
+			for len(q.items) > 0 {
+				v, ok := q.pop()
+				if !ok {
+					break
+				}
+				res = append(res, v.(Event))
+			}
+
+			if !reflect.DeepEqual(res, tc.output) {
+				t.Fatalf("expected %v, got %v", tc.output, res)
+			}
+		})
+	}
+}
+
+func TestSendQueuePopAfterClose(t *testing.T) {
+	q := newSendQueue(4)
+	q.close()
+
+	if _, ok := q.pop(); ok {
+		t.Fatal("pop must return false on a closed and empty queue")
+	}
+}
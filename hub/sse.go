@@ -0,0 +1,315 @@
+package hub
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/dh1tw/remoteRotator/rotator"
+	"github.com/gorilla/mux"
+)
+
+const (
+	// number of events kept in memory so that SSE clients can resume
+	// a stream through the Last-Event-ID header.
+	sseBacklog = 256
+
+	// period in which a comment is sent to keep proxies from closing
+	// an idle stream.
+	sseKeepAlive = 15 * time.Second
+)
+
+// SseClient is a wrapper for clients connected through a
+// Server-Sent Events (text/event-stream) connection.
+type SseClient struct {
+	remoteAddr string
+	rotator    string // only forward events of this rotator; all if empty
+	identity   *Identity
+	queue      *sendQueue
+}
+
+// eventRecord is an event with the sequence number under which it
+// has been broadcasted.
+type eventRecord struct {
+	id uint64
+	ev Event
+}
+
+// RemoteAddr returns the remote network address of the client.
+func (c *SseClient) RemoteAddr() string {
+	return c.remoteAddr
+}
+
+// wants returns true if the event is relevant for this client and the
+// client is allowed to read the rotator.
+func (c *SseClient) wants(ev Event) bool {
+	if !ev.readable(c.identity) {
+		return false
+	}
+	return c.rotator == "" || slices.Contains(ev.rotators(), c.rotator)
+}
+
+// write an event in the text/event-stream format.
+func (c *SseClient) write(w http.ResponseWriter, rec eventRecord) error {
+
+	b, err := json.Marshal(rec.ev)
+	if err != nil {
+		return fmt.Errorf("unable to serialize msg %v: %v", rec.ev, err)
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\ndata: %s\n\n", rec.id, b)
+	return err
+}
+
+// sseHandler streams the hub events to the client. If the rotator
+// variable is set in the route, only the events of this rotator will
+// be sent. A client which reconnects with a Last-Event-ID header will
+// receive the events it has missed, as long as they are still in the
+// backlog. Otherwise it receives a snapshot of the current state.
+func (hub *Hub) sseHandler(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("streaming not supported"))
+		return
+	}
+
+	vars := mux.Vars(req)
+	rName := vars["rotator"]
+
+	if rName != "" {
+		if _, ok := hub.Rotator(rName); !ok {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("unable to find rotator"))
+			return
+		}
+	}
+
+	c := &SseClient{
+		remoteAddr: req.RemoteAddr,
+		rotator:    rName,
+		identity:   identity(req),
+		queue:      newSendQueue(clientQueueSize),
+	}
+
+	rc := http.NewResponseController(w)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	replay := hub.addSseClient(c, req.Header.Get("Last-Event-ID"))
+	defer hub.removeSseClient(c)
+
+	fmt.Fprintf(w, "retry: %d\n\n", 3000)
+	for _, rec := range replay {
+		if err := c.write(w, rec); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	// the keep-alive comments are queued like events, so that the
+	// stream is written by a single go routine
+	go func() {
+		keepAlive := time.NewTicker(sseKeepAlive)
+		defer keepAlive.Stop()
+		for {
+			select {
+			case <-keepAlive.C:
+				c.queue.push("keep-alive", nil)
+			case <-c.queue.done():
+				return
+			case <-req.Context().Done():
+				c.queue.close()
+				return
+			}
+		}
+	}()
+
+	err := c.queue.run(func(v interface{}) error {
+		rc.SetWriteDeadline(time.Now().Add(clientWriteWait))
+		if rec, ok := v.(eventRecord); ok {
+			if err := c.write(w, rec); err != nil {
+				return err
+			}
+		} else if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+			return err
+		}
+		return rc.Flush()
+	})
+	if err != nil {
+		log.Printf("error writing to client %v: %v\n", c.RemoteAddr(), err)
+	}
+}
+
+// addSseClient registers a new SSE client and returns the events which
+// have to be sent to the client before the live stream starts.
+func (hub *Hub) addSseClient(c *SseClient, lastEventID string) []eventRecord {
+	hub.Lock()
+
+	replay, ok := hub.eventsSince(lastEventID)
+	if !ok {
+		// the rotators are serialized without holding the lock, since
+		// the rotators might be broadcasting an event at the same time
+		rotators := make([]rotator.Rotator, 0, len(hub.rotators))
+		for _, r := range hub.rotators {
+			rotators = append(rotators, r)
+		}
+		id := hub.eventID
+		hub.Unlock()
+
+		objs := make([]rotator.Object, len(rotators))
+		for i, r := range rotators {
+			objs[i] = r.Serialize()
+		}
+
+		hub.Lock()
+		replay = hub.snapshot(id, rotators, objs)
+		// events which have been broadcasted in the meantime
+		if missed, ok := hub.eventsSince(strconv.FormatUint(id, 10)); ok {
+			replay = append(replay, missed...)
+		}
+	}
+	defer hub.Unlock()
+
+	filtered := make([]eventRecord, 0, len(replay))
+	for _, rec := range replay {
+		if c.wants(rec.ev) {
+			filtered = append(filtered, rec)
+		}
+	}
+
+	hub.sseClients[c] = true
+	log.Printf("sse client connected (%v)\n", c.RemoteAddr())
+
+	return filtered
+}
+
+// removeSseClient removes a SSE client
+func (hub *Hub) removeSseClient(c *SseClient) {
+	hub.Lock()
+	defer hub.Unlock()
+
+	if _, ok := hub.sseClients[c]; ok {
+		delete(hub.sseClients, c)
+	}
+	c.queue.close()
+	log.Printf("sse client disconnected (%v)\n", c.RemoteAddr())
+}
+
+// eventsSince returns all events from the backlog which are newer than
+// the given event id. If the id is invalid or already evicted from
+// the backlog, false is returned.
+func (hub *Hub) eventsSince(lastEventID string) ([]eventRecord, bool) {
+
+	if lastEventID == "" {
+		return nil, false
+	}
+
+	id, err := strconv.ParseUint(lastEventID, 10, 64)
+	if err != nil || id > hub.eventID {
+		return nil, false
+	}
+
+	if id == hub.eventID {
+		return []eventRecord{}, true
+	}
+
+	if len(hub.eventLog) == 0 || hub.eventLog[0].id > id+1 {
+		return nil, false
+	}
+
+	for i, rec := range hub.eventLog {
+		if rec.id > id {
+			return append([]eventRecord{}, hub.eventLog[i:]...), true
+		}
+	}
+
+	return []eventRecord{}, true
+}
+
+// snapshot returns the events describing the state of the rotators
+// (serialized as objs) and the active leases. The events carry the id
+// of the latest event broadcasted before the rotators were serialized,
+// so that a client can resume from there. Must be called with the lock
+// held.
+func (hub *Hub) snapshot(id uint64, rotators []rotator.Rotator, objs []rotator.Object) []eventRecord {
+
+	recs := make([]eventRecord, 0, 2*len(rotators))
+
+	for i, r := range rotators {
+		obj := objs[i]
+		recs = append(recs, eventRecord{
+			id: id,
+			ev: Event{
+				Name:        AddRotator,
+				RotatorName: r.Name(),
+			},
+		})
+		recs = append(recs, eventRecord{
+			id: id,
+			ev: Event{
+				Name:        UpdateHeading,
+				RotatorName: r.Name(),
+				Heading:     obj.Heading,
+			},
+		})
+		for i := range obj.Status {
+			recs = append(recs, eventRecord{
+				id: id,
+				ev: Event{
+					Name:        RotatorStatus,
+					RotatorName: r.Name(),
+					Status:      &obj.Status[i],
+				},
+			})
+		}
+	}
+
+	for _, lease := range hub.locks.Leases() {
+		recs = append(recs, eventRecord{
+			id: id,
+			ev: Event{
+				Name:        LockRotator,
+				RotatorName: lease.Rotator,
+				Lease:       &lease,
+			},
+		})
+	}
+
+	return recs
+}
+
+// record assigns the next sequence number to the event and stores it
+// in the backlog.
+func (hub *Hub) record(ev Event) eventRecord {
+	hub.eventID++
+	rec := eventRecord{id: hub.eventID, ev: ev}
+
+	hub.eventLog = append(hub.eventLog, rec)
+	if len(hub.eventLog) > sseBacklog {
+		hub.eventLog = hub.eventLog[len(hub.eventLog)-sseBacklog:]
+	}
+
+	return rec
+}
+
+// broadcastToSseClients queues an event for all connected SSE clients.
+func (hub *Hub) broadcastToSseClients(rec eventRecord) {
+
+	for c := range hub.sseClients {
+		if !c.wants(rec.ev) {
+			continue
+		}
+		c.queue.push(eventKey(rec.ev), rec)
+	}
+}
@@ -0,0 +1,62 @@
+package hub
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dh1tw/remoteRotator/rotator"
+	"github.com/dh1tw/remoteRotator/rotator/rotatortest"
+)
+
+// broadcasting is a rotator which reports a new heading to the hub
+// while it is being serialized.
+type broadcasting struct {
+	*rotatortest.Rotator
+	hub *Hub
+}
+
+func (r *broadcasting) Serialize() rotator.Object {
+	obj := r.Rotator.Serialize()
+	if r.hub != nil {
+		r.hub.Broadcast(Event{Name: UpdateHeading, RotatorName: obj.Name, Heading: rotator.Heading{Azimuth: 42}})
+	}
+	return obj
+}
+
+func TestSseSnapshot(t *testing.T) {
+	h, err := NewHub()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := &broadcasting{Rotator: rotatortest.New(rotator.Config{HasAzimuth: true}, rotatortest.Heading(10, 0))}
+	if err := h.AddRotator(r); err != nil {
+		t.Fatal(err)
+	}
+	r.hub = h
+
+	c := &SseClient{remoteAddr: "test", identity: h.defaultIdentity(), queue: newSendQueue(10)}
+	done := make(chan []eventRecord)
+	go func() {
+		done <- h.addSseClient(c, "")
+	}()
+
+	var recs []eventRecord
+	select {
+	case recs = <-done:
+	case <-time.After(time.Second * 2):
+		t.Fatal("snapshot blocked while the rotator was serialized")
+	}
+	defer h.removeSseClient(c)
+
+	// the heading broadcasted during the snapshot follows the snapshot
+	last := recs[len(recs)-1]
+	if last.ev.Name != UpdateHeading || last.ev.Heading.Azimuth != 42 {
+		t.Fatalf("expected the heading broadcasted during the snapshot last, got %+v", last.ev)
+	}
+	for _, rec := range recs[:len(recs)-1] {
+		if rec.id >= last.id {
+			t.Fatalf("expected the snapshot to be older than the broadcasted heading, got ids %d and %d", rec.id, last.id)
+		}
+	}
+}
@@ -1,39 +1,103 @@
 package hub
 
 import (
-	"bufio"
 	"fmt"
 	"io"
 	"log"
 	"net"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/dh1tw/remoteRotator/audit"
 	"github.com/dh1tw/remoteRotator/rotator"
+	"github.com/dh1tw/remoteRotator/rotator/framing"
 )
 
 //TCPClient is a wrapper for clients connected through plain a TCP socket.
 type TCPClient struct {
 	net.Conn
+	queue    *sendQueue
+	idMu     sync.RWMutex
+	identity *Identity // changes when the client logs in
+}
+
+// maximum number of failed logins before a tcp client is disconnected
+const tcpMaxLogins = 3
+
+// send takes the events from the client's queue and writes them to
+// the tcp socket. If the client does not accept the data within
+// clientWriteWait, it will be disconnected.
+// Since this method contains an endless loop it should be executed
+// in a go routine.
+func (c *TCPClient) send(closer chan<- *TCPClient) {
+	err := c.queue.run(func(v interface{}) error {
+		ev, ok := v.(Event)
+		if !ok {
+			return nil
+		}
+		// EA4TX's ARSVCOM doesn't understand single Azimuth
+		// messages (+0nnn). It always expects +0nnn+0nnn
+		data := fmt.Sprintf("+0%.3d+0%.3d\r\n", ev.Heading.Azimuth, ev.Heading.Elevation)
+		return c.write(data)
+	})
+	if err != nil {
+		log.Printf("error writing to client %v: %v\n", c.RemoteAddr(), err)
+		closer <- c
+	}
 }
 
 // listen starts listening for incoming messages from tcp connections. When
 // a error occurs, the routine returns and deletes the tcp connection.
 // Since this method contains an endless loop it should be executed
 // in a go routine.
-func (c *TCPClient) listen(rotator rotator.Rotator, closer chan<- *TCPClient) {
+func (c *TCPClient) listen(hub *Hub, r rotator.Rotator, closer chan<- *TCPClient) {
 	defer func() {
 		closer <- c
 	}()
 
+	rotator := c.bind(hub, r)
+	failedLogins := 0
+
+	// clients don't send prompts
+	rd := framing.NewReader(c.Conn, framing.Prompts())
+
 	for {
-		msg, err := bufio.NewReader(c.Conn).ReadString('\n')
+		frame, err := rd.ReadFrame()
 		if err != nil {
 			if err != io.EOF {
 				log.Printf("socket read error (%v): %v\n", c.Conn.RemoteAddr(), err)
 			}
 			return //disconnect and remove client
 		}
+		msg := frame.Data
+
+		// login (not part of GS-232): "LOGIN <user> <password>" or
+		// "LOGIN <token>"
+		if fields := strings.Fields(msg); len(fields) > 1 && strings.ToUpper(fields[0]) == "LOGIN" {
+			id, err := hub.login(fields[1:])
+			if err != nil {
+				failedLogins++
+				log.Printf("login of tcp client %v failed\n", c.Conn.RemoteAddr())
+				if failedLogins >= tcpMaxLogins {
+					return
+				}
+				if err := c.prompt(); err != nil {
+					log.Println(err)
+					return
+				}
+				continue
+			}
+			c.setIdentity(id)
+			rotator = c.bind(hub, r)
+			log.Printf("tcp client %v logged in as %s\n", c.Conn.RemoteAddr(), id.Name)
+			if err := c.write("OK\r\n"); err != nil {
+				log.Println(err)
+				return
+			}
+			continue
+		}
 
 		switch strings.ToUpper(msg[0:1]) {
 		// set azimuth / elevation heading
@@ -53,7 +117,12 @@ func (c *TCPClient) listen(rotator rotator.Rotator, closer chan<- *TCPClient) {
 				log.Printf("parse error (%v): %v; msg: %s\n", c.Conn.RemoteAddr(), err, msg)
 				continue
 			}
-			rotator.SetAzimuth(az)
+			if err := rotator.SetAzimuth(az); err != nil {
+				if err := c.refuse(err); err != nil {
+					log.Println(err)
+					return
+				}
+			}
 		// set azimuth / elevation heading 
 		case "W":
 			msg = strings.TrimRight(msg[1:], "\r\n")
@@ -81,19 +150,38 @@ func (c *TCPClient) listen(rotator rotator.Rotator, closer chan<- *TCPClient) {
 				log.Printf("parse error (%v): %v; msg: %s\n", c.Conn.RemoteAddr(), err, msg)
 				continue
 			}
-			rotator.SetAzimuth(az)
 
 			el, err := strconv.Atoi(cmdArray[1])
 			if err != nil {
 				log.Printf("parse error (%v): %v; msg: %s\n", c.Conn.RemoteAddr(), err, msg)
 				continue
 			}
-			rotator.SetElevation(el)
+
+			if err := rotator.SetAzimuth(az); err != nil {
+				if err := c.refuse(err); err != nil {
+					log.Println(err)
+					return
+				}
+				continue
+			}
+			if err := rotator.SetElevation(el); err != nil {
+				if err := c.refuse(err); err != nil {
+					log.Println(err)
+					return
+				}
+			}
 
 		// query
 		case "C":
+			if !c.Identity().Can(RoleRead, r.Name()) {
+				if err := c.refuse(ErrForbidden); err != nil {
+					log.Println(err)
+					return
+				}
+				continue
+			}
 			// azimuth + elevation
-			if msg[1] == '2' {
+			if len(msg) > 1 && msg[1] == '2' {
 				az := rotator.Azimuth()
 				el := rotator.Elevation()
 				if err := c.write(fmt.Sprintf("+0%.3d+0%.3d\r\n", az, el)); err != nil {
@@ -111,20 +199,26 @@ func (c *TCPClient) listen(rotator rotator.Rotator, closer chan<- *TCPClient) {
 		// stop azimuth
 		case "A":
 			if err := rotator.StopAzimuth(); err != nil {
-				log.Println(err)
-				return
+				if err := c.refuse(err); err != nil {
+					log.Println(err)
+					return
+				}
 			}
 		// stop elevation
 		case "E":
 			if err := rotator.StopElevation(); err != nil {
-				log.Println(err)
-				return
+				if err := c.refuse(err); err != nil {
+					log.Println(err)
+					return
+				}
 			}
 		// stop all
 		case "S":
 			if err := rotator.Stop(); err != nil {
-				log.Println(err)
-				return
+				if err := c.refuse(err); err != nil {
+					log.Println(err)
+					return
+				}
 			}
 			// unknown commando
 		default:
@@ -136,6 +230,42 @@ func (c *TCPClient) listen(rotator rotator.Rotator, closer chan<- *TCPClient) {
 	}
 }
 
+// bind returns a view of the rotator which only accepts the commands
+// of the client if it has the control role and the rotator is not
+// leased by another client. All commands are recorded in the audit log. TCP clients can't take a lease explicitly;
+// their set commands take the lease implicitly (see TCPAutoLease).
+func (c *TCPClient) bind(hub *Hub, r rotator.Rotator) rotator.Rotator {
+	id := c.Identity()
+	owner := id.Name
+	if owner == "" {
+		owner = "tcp:" + c.RemoteAddr().String()
+	}
+	return bindRotator(r, func(r rotator.Rotator) rotator.Rotator {
+		ctl := guard(hub.locks.BindTCP(r, owner), id)
+		return audit.Bind(ctl, hub.audit, audit.TCP, c.RemoteAddr().String(), id.Name)
+	})
+}
+
+// Identity returns the identity of the client.
+func (c *TCPClient) Identity() *Identity {
+	c.idMu.RLock()
+	defer c.idMu.RUnlock()
+	return c.identity
+}
+
+func (c *TCPClient) setIdentity(id *Identity) {
+	c.idMu.Lock()
+	defer c.idMu.Unlock()
+	c.identity = id
+}
+
+// refuse logs a command which has been refused (e.g. because the
+// rotator is leased by another client) and answers it with a prompt.
+func (c *TCPClient) refuse(err error) error {
+	log.Printf("command of tcp client %v refused: %v\n", c.Conn.RemoteAddr(), err)
+	return c.prompt()
+}
+
 // writes a prompt to the tcp socket
 func (c *TCPClient) prompt() error {
 	if _, err := c.Conn.Write([]byte("?>")); err != nil {
@@ -161,6 +291,7 @@ func (c *TCPClient) write(v interface{}) error {
 		return nil
 	}
 
+	c.Conn.SetWriteDeadline(time.Now().Add(clientWriteWait))
 	if _, err := c.Conn.Write(data); err != nil {
 		return fmt.Errorf("socket write error (%v): %v", c.Conn.RemoteAddr(), err)
 	}
@@ -0,0 +1,131 @@
+package hub
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// SetTLS enables TLS (https / wss) for the HTTP server. This method must
+// be called before ListenHTTP.
+func (hub *Hub) SetTLS(cfg *tls.Config) {
+	hub.Lock()
+	defer hub.Unlock()
+	hub.tlsConfig = cfg
+}
+
+// LoadCertificate loads a certificate and its private key from PEM
+// encoded files. If selfSigned is true and the files don't exist yet,
+// a self-signed certificate for the hosts is generated and written to
+// the files, so that its fingerprint stays the same across restarts.
+func LoadCertificate(certFile, keyFile string, selfSigned bool, hosts ...string) (tls.Certificate, error) {
+
+	_, err := os.Stat(certFile)
+	if selfSigned && errors.Is(err, os.ErrNotExist) {
+		certPEM, keyPEM, err := SelfSignedCertificate(hosts...)
+		if err != nil {
+			return tls.Certificate{}, err
+		}
+		if err := os.MkdirAll(filepath.Dir(certFile), 0o755); err != nil {
+			return tls.Certificate{}, err
+		}
+		if err := os.MkdirAll(filepath.Dir(keyFile), 0o700); err != nil {
+			return tls.Certificate{}, err
+		}
+		if err := os.WriteFile(keyFile, keyPEM, 0o600); err != nil {
+			return tls.Certificate{}, err
+		}
+		if err := os.WriteFile(certFile, certPEM, 0o644); err != nil {
+			return tls.Certificate{}, err
+		}
+	}
+
+	return tls.LoadX509KeyPair(certFile, keyFile)
+}
+
+// SelfSignedCertificate generates a self-signed certificate (ECDSA
+// P-256, valid for 10 years) for the hosts (dns names or IP addresses)
+// and returns the PEM encoded certificate and private key.
+func SelfSignedCertificate(hosts ...string) (certPEM, keyPEM []byte, err error) {
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tmpl := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"remoteRotator"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(10, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else if h != "" {
+			tmpl.DNSNames = append(tmpl.DNSNames, h)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &tmpl, &tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+
+	return certPEM, keyPEM, nil
+}
+
+// Fingerprint returns the SHA-256 fingerprint of a DER encoded
+// certificate as colon separated hex string (like 'openssl x509
+// -fingerprint -sha256').
+func Fingerprint(der []byte) string {
+	sum := sha256.Sum256(der)
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(parts, ":")
+}
+
+// NormalizeFingerprint removes the separators of a SHA-256 fingerprint
+// and converts it to lower case hex, so that fingerprints in different
+// notations can be compared.
+func NormalizeFingerprint(fp string) (string, error) {
+	fp = strings.ToLower(strings.NewReplacer(":", "", " ", "", "-", "").Replace(fp))
+	b, err := hex.DecodeString(fp)
+	if err != nil || len(b) != sha256.Size {
+		return "", fmt.Errorf("invalid SHA-256 fingerprint '%s'", fp)
+	}
+	return fp, nil
+}
@@ -0,0 +1,69 @@
+package hub
+
+import (
+	"crypto/x509"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "tls", "cert.pem")
+	keyFile := filepath.Join(dir, "tls", "key.pem")
+
+	if _, err := LoadCertificate(certFile, keyFile, false); err == nil {
+		t.Fatal("expected error for missing certificate")
+	}
+
+	cert, err := LoadCertificate(certFile, keyFile, true, "localhost", "192.168.1.10")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := leaf.VerifyHostname("192.168.1.10"); err != nil {
+		t.Fatal(err)
+	}
+	if err := leaf.VerifyHostname("localhost"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the generated certificate is reused
+	again, err := LoadCertificate(certFile, keyFile, true, "localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if Fingerprint(again.Certificate[0]) != Fingerprint(cert.Certificate[0]) {
+		t.Fatal("expected the generated certificate to be reused")
+	}
+}
+
+func TestNormalizeFingerprint(t *testing.T) {
+	fp := Fingerprint([]byte("certificate"))
+
+	tt := []struct {
+		name  string
+		input string
+		valid bool
+	}{
+		{"colon separated", fp, true},
+		{"lower case", "6cc8f1bd2a7cb8a1b4a5ce8e7e9eb0b7c0b5ea43dd60ba5dbc1e8ab37a4b5e1f", true},
+		{"too short", "AB:CD", false},
+		{"no hex", "xyz", false},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NormalizeFingerprint(tc.input)
+			if tc.valid && err != nil {
+				t.Fatal(err)
+			}
+			if !tc.valid && err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
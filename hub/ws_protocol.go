@@ -0,0 +1,413 @@
+package hub
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/dh1tw/remoteRotator/audit"
+	"github.com/dh1tw/remoteRotator/rotator"
+	"github.com/dh1tw/remoteRotator/rotator/move"
+)
+
+// WsCommand is a request sent by a client over the websocket. The
+// protocol is versioned together with the REST API; a command with a
+// version which does not match the hub's api version will be rejected.
+// An empty version is treated as the current api version.
+type WsCommand struct {
+	Version   string   `json:"version,omitempty"`
+	ID        string   `json:"id,omitempty"`
+	Command   string   `json:"command"`
+	Rotator   string   `json:"rotator,omitempty"`
+	Group     string   `json:"group,omitempty"`
+	Rotators  []string `json:"rotators,omitempty"`
+	Azimuth   *int     `json:"azimuth,omitempty"`
+	Elevation *int     `json:"elevation,omitempty"`
+	Antenna   string   `json:"antenna,omitempty"`
+	Bearing   *int     `json:"bearing,omitempty"`
+	Operator  string   `json:"operator,omitempty"`
+	Duration  string   `json:"duration,omitempty"`
+	Token     string   `json:"token,omitempty"`
+	Timeout   string   `json:"timeout,omitempty"`
+	Tolerance *int     `json:"tolerance,omitempty"`
+}
+
+// WsReply is sent back to the client for every WsCommand. The ID
+// corresponds to the ID of the command. While a move is executed, its
+// progress is sent with the ID of the move command.
+type WsReply struct {
+	Name     RotatorEvent    `json:"name"`
+	Version  string          `json:"version"`
+	ID       string          `json:"id,omitempty"`
+	Error    string          `json:"error,omitempty"`
+	Rotators rotator.Objects `json:"rotators,omitempty"`
+	Groups   []GroupStatus   `json:"groups,omitempty"`
+	Lease    *Lease          `json:"lease,omitempty"`
+	Progress *move.Progress  `json:"progress,omitempty"`
+}
+
+// Commands supported by the websocket protocol
+const (
+	WsSetAzimuth    = "set_azimuth"
+	WsSetElevation  = "set_elevation"
+	WsPointAntenna  = "point_antenna"
+	WsStop          = "stop"
+	WsStopAzimuth   = "stop_azimuth"
+	WsStopElevation = "stop_elevation"
+	WsSubscribe     = "subscribe"
+	WsSnapshot      = "snapshot"
+	WsAcknowledge   = "acknowledge"
+	WsTakeControl   = "take_control"
+	WsRelease       = "release_control"
+	WsOverride      = "override_control"
+	WsGroupStatus   = "group_status"
+	WsMove          = "move"
+)
+
+// handleWsCommand decodes and executes a command received from a
+// websocket client and returns the reply for the client. The reply of
+// a move is sent once the move has finished; in this case the returned
+// reply is empty.
+func (hub *Hub) handleWsCommand(c *WsClient, msg []byte) WsReply {
+
+	cmd := WsCommand{}
+	if err := json.Unmarshal(msg, &cmd); err != nil {
+		return hub.wsError(cmd, fmt.Errorf("invalid json"))
+	}
+
+	if cmd.Version != "" && cmd.Version != hub.apiVersion {
+		return hub.wsError(cmd, fmt.Errorf("unsupported protocol version %s (supported: %s)",
+			cmd.Version, hub.apiVersion))
+	}
+
+	var err error
+	reply := WsReply{
+		Name:    CommandAck,
+		Version: hub.apiVersion,
+		ID:      cmd.ID,
+	}
+
+	// commands addressed to a group are executed by all its members
+	if cmd.Group != "" && cmd.Command != WsGroupStatus {
+		if err := hub.wsGroup(c, cmd); err != nil {
+			return hub.wsError(cmd, err)
+		}
+		return reply
+	}
+
+	switch cmd.Command {
+	case WsSetAzimuth:
+		err = hub.wsExec(c, cmd, func(r rotator.Rotator) error {
+			if cmd.Azimuth == nil {
+				return fmt.Errorf("azimuth missing")
+			}
+			if !r.HasAzimuth() {
+				return fmt.Errorf("rotator does not support azimuth")
+			}
+			return r.SetAzimuth(*cmd.Azimuth)
+		})
+	case WsSetElevation:
+		err = hub.wsExec(c, cmd, func(r rotator.Rotator) error {
+			if cmd.Elevation == nil {
+				return fmt.Errorf("elevation missing")
+			}
+			if !r.HasElevation() {
+				return fmt.Errorf("rotator does not support elevation")
+			}
+			return r.SetElevation(*cmd.Elevation)
+		})
+	case WsPointAntenna:
+		err = hub.wsExec(c, cmd, func(r rotator.Rotator) error {
+			if cmd.Bearing == nil {
+				return fmt.Errorf("bearing missing")
+			}
+			if !r.HasAzimuth() {
+				return fmt.Errorf("rotator does not support azimuth")
+			}
+			az, err := rotator.AntennaTarget(r.Serialize().Antennas, cmd.Antenna, *cmd.Bearing)
+			if err != nil {
+				return err
+			}
+			return r.SetAzimuth(az)
+		})
+	case WsStop:
+		err = hub.wsExec(c, cmd, func(r rotator.Rotator) error {
+			return r.Stop()
+		})
+	case WsStopAzimuth:
+		err = hub.wsExec(c, cmd, func(r rotator.Rotator) error {
+			if !r.HasAzimuth() {
+				return fmt.Errorf("rotator does not support azimuth")
+			}
+			return r.StopAzimuth()
+		})
+	case WsStopElevation:
+		err = hub.wsExec(c, cmd, func(r rotator.Rotator) error {
+			if !r.HasElevation() {
+				return fmt.Errorf("rotator does not support elevation")
+			}
+			return r.StopElevation()
+		})
+	case WsAcknowledge:
+		err = hub.wsExec(c, cmd, func(r rotator.Rotator) error {
+			if err := hub.locks.Check(r.Name(), c.owner()); err != nil {
+				return err
+			}
+			found, err := rotator.Acknowledge(r)
+			if !found {
+				return fmt.Errorf("rotator does not support acknowledge")
+			}
+			return err
+		})
+	case WsMove:
+		if err = hub.wsMove(c, cmd); err == nil {
+			return WsReply{}
+		}
+	case WsTakeControl, WsRelease, WsOverride:
+		reply.Lease, err = hub.wsLock(c, cmd)
+	case WsSubscribe:
+		c.subscribe(cmd.Rotators)
+	case WsSnapshot:
+		reply.Rotators = c.identity.readable(hub.serializeRotators())
+		if len(cmd.Rotators) > 0 {
+			filtered := rotator.Objects{}
+			for _, name := range cmd.Rotators {
+				if obj, ok := reply.Rotators[name]; ok {
+					filtered[name] = obj
+				}
+			}
+			reply.Rotators = filtered
+		}
+	case WsGroupStatus:
+		reply.Groups = []GroupStatus{}
+		for _, g := range hub.Groups() {
+			if (cmd.Group == "" || cmd.Group == g.Name) && groupReadable(g, c.identity) {
+				reply.Groups = append(reply.Groups, hub.groupStatus(g))
+			}
+		}
+		if cmd.Group != "" && len(reply.Groups) == 0 {
+			err = fmt.Errorf("unable to find group '%s'", cmd.Group)
+		}
+	default:
+		err = fmt.Errorf("unknown command '%s'", cmd.Command)
+	}
+
+	if err != nil {
+		return hub.wsError(cmd, err)
+	}
+
+	return reply
+}
+
+// wsExec looks up the rotator addressed by the command and executes
+// the function on it. Commands are refused if the client doesn't have
+// the control role or the rotator is leased by another client.
+func (hub *Hub) wsExec(c *WsClient, cmd WsCommand, f func(rotator.Rotator) error) error {
+
+	r, ok := hub.Rotator(cmd.Rotator)
+	if !ok || !c.identity.Can(RoleRead, cmd.Rotator) {
+		return fmt.Errorf("unable to find rotator '%s'", cmd.Rotator)
+	}
+	if !c.identity.Can(RoleControl, cmd.Rotator) {
+		return ErrForbidden
+	}
+
+	return f(hub.wsBind(c, r))
+}
+
+// wsBind returns a view of the rotator which only accepts the commands
+// of the client if it has the control role and the rotator is not leased
+// by another client. All commands are recorded in the audit log.
+func (hub *Hub) wsBind(c *WsClient, r rotator.Rotator) rotator.Rotator {
+	return bindRotator(r, func(r rotator.Rotator) rotator.Rotator {
+		ctl := guard(hub.locks.Bind(r, c.owner()), c.identity)
+		return audit.Bind(ctl, hub.audit, audit.WS, c.RemoteAddr().String(), c.user())
+	})
+}
+
+// wsGroup executes a command on all members of a group. Moves are
+// refused unless the client may control all members; a stop reaches
+// all members the client may control.
+func (hub *Hub) wsGroup(c *WsClient, cmd WsCommand) error {
+
+	g, ok := hub.Group(cmd.Group)
+	if !ok || !groupReadable(g, c.identity) {
+		return fmt.Errorf("unable to find group '%s'", cmd.Group)
+	}
+
+	bind := func(r rotator.Rotator) rotator.Rotator {
+		return hub.wsBind(c, r)
+	}
+
+	var f func(rotator.Rotator, GroupMember) error
+
+	switch cmd.Command {
+	case WsSetAzimuth:
+		if cmd.Azimuth == nil {
+			return fmt.Errorf("azimuth missing")
+		}
+		f = groupSetAzimuth(*cmd.Azimuth)
+	case WsSetElevation:
+		if cmd.Elevation == nil {
+			return fmt.Errorf("elevation missing")
+		}
+		f = groupSetElevation(*cmd.Elevation)
+	case WsStop:
+		return hub.groupExec(g, bind, groupStop)
+	default:
+		return fmt.Errorf("command '%s' is not supported for groups", cmd.Command)
+	}
+
+	if err := hub.groupCheck(g, c.owner(), c.identity); err != nil {
+		return err
+	}
+	return hub.groupExec(g, bind, f)
+}
+
+// wsMove starts a move of the rotator to the azimuth or the elevation of
+// the command. The progress of the move is streamed to the client until
+// the rotator has arrived, stalled or the time is up; then the reply to
+// the command is sent. The move is abandoned if the client disconnects.
+func (hub *Hub) wsMove(c *WsClient, cmd WsCommand) error {
+
+	if (cmd.Azimuth == nil) == (cmd.Elevation == nil) {
+		return fmt.Errorf("either azimuth or elevation required")
+	}
+
+	opts := []func(*move.Mover){}
+	if cmd.Timeout != "" {
+		d, err := parseDuration(cmd.Timeout)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid timeout '%s'", cmd.Timeout)
+		}
+		opts = append(opts, move.Timeout(d))
+	}
+	if cmd.Tolerance != nil {
+		if *cmd.Tolerance < 0 {
+			return fmt.Errorf("invalid tolerance '%d'", *cmd.Tolerance)
+		}
+		opts = append(opts, move.Tolerance(*cmd.Tolerance))
+	}
+
+	r, ok := hub.Rotator(cmd.Rotator)
+	if !ok || !c.identity.Can(RoleRead, cmd.Rotator) {
+		return fmt.Errorf("unable to find rotator '%s'", cmd.Rotator)
+	}
+	if !c.identity.Can(RoleControl, cmd.Rotator) {
+		return ErrForbidden
+	}
+	ctl := hub.wsBind(c, r)
+
+	// progress messages are coalesced, a slow client only receives the
+	// latest progress
+	key := "progress/" + cmd.Rotator + "/" + cmd.ID
+	opts = append(opts, move.OnProgress(func(p move.Progress) {
+		c.queue.push(key, WsReply{
+			Name:     MoveProgress,
+			Version:  hub.apiVersion,
+			ID:       cmd.ID,
+			Progress: &p,
+		})
+	}))
+	m := move.New(opts...)
+
+	go func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			select {
+			case <-c.queue.done():
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+
+		var p move.Progress
+		var err error
+		if cmd.Azimuth != nil {
+			p, err = m.Azimuth(ctx, ctl, *cmd.Azimuth)
+		} else {
+			p, err = m.Elevation(ctx, ctl, *cmd.Elevation)
+		}
+
+		reply := WsReply{
+			Name:    CommandAck,
+			Version: hub.apiVersion,
+			ID:      cmd.ID,
+		}
+		if err != nil {
+			reply = hub.wsError(cmd, err)
+		}
+		if p.State != "" {
+			reply.Progress = &p
+		}
+		c.queue.push("", reply)
+	}()
+
+	return nil
+}
+
+// wsLock executes the commands which take, release or override the
+// control of a rotator. An operator sent with the command is displayed
+// as the name of the client from then on.
+func (hub *Hub) wsLock(c *WsClient, cmd WsCommand) (*Lease, error) {
+
+	if _, ok := hub.Rotator(cmd.Rotator); !ok || !c.identity.Can(RoleRead, cmd.Rotator) {
+		return nil, fmt.Errorf("unable to find rotator '%s'", cmd.Rotator)
+	}
+	if !c.identity.Can(RoleControl, cmd.Rotator) {
+		return nil, ErrForbidden
+	}
+
+	var d time.Duration
+	if cmd.Duration != "" {
+		var err error
+		if d, err = time.ParseDuration(cmd.Duration); err != nil {
+			return nil, fmt.Errorf("invalid duration '%s'", cmd.Duration)
+		}
+	}
+
+	if cmd.Operator != "" {
+		c.setOperator(cmd.Operator)
+	}
+
+	var lease *Lease
+	var err error
+
+	switch cmd.Command {
+	case WsTakeControl:
+		var l Lease
+		l, err = hub.locks.Acquire(cmd.Rotator, c.owner(), c.user(), d)
+		lease = &l
+	case WsRelease:
+		err = hub.locks.Release(cmd.Rotator, c.owner())
+	default:
+		// the admin takes over the control; it can be released
+		// afterwards with release_control
+		var l Lease
+		l, err = hub.locks.Override(cmd.Rotator, c.owner(), c.user(), cmd.Token, d)
+		lease = &l
+	}
+
+	hub.audit.Record(audit.Record{
+		Frontend: audit.WS,
+		Client:   c.RemoteAddr().String(),
+		User:     c.user(),
+		Rotator:  cmd.Rotator,
+		Command:  cmd.Command,
+		Duration: cmd.Duration,
+		Error:    errString(err),
+	})
+
+	return lease, err
+}
+
+func (hub *Hub) wsError(cmd WsCommand, err error) WsReply {
+	return WsReply{
+		Name:    CommandError,
+		Version: hub.apiVersion,
+		ID:      cmd.ID,
+		Error:   err.Error(),
+	}
+}
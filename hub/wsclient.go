@@ -3,6 +3,9 @@ package hub
 import (
 	"encoding/json"
 	"fmt"
+	"log"
+	"sync"
+	"time"
 
 	"github.com/gorilla/websocket"
 )
@@ -10,29 +13,128 @@ import (
 //WsClient is a wrapper for clients connected through a Websocket
 type WsClient struct {
 	*websocket.Conn
+	queue         *sendQueue
+	subMu         sync.RWMutex
+	subscriptions map[string]bool // nil: subscribed to all rotators
+	operator      string          // name of the operator (if provided)
+	identity      *Identity       // authenticated identity of the client
 }
 
-// listen on the websocket. Despite that no data is read, this function
-// is necessary to reply to incoming ping messages.
-func (c *WsClient) listen(closer chan<- *WsClient) {
+// listen on the websocket for incoming commands. Every command is
+// answered with a WsReply. Reading from the websocket is also necessary
+// to reply to incoming ping messages.
+func (c *WsClient) listen(hub *Hub, closer chan<- *WsClient) {
 	defer func() {
 		closer <- c
 	}()
 
 	for {
 		// in case of an error just return and signal closing down of the ws
-		if _, _, err := c.ReadMessage(); err != nil {
+		_, msg, err := c.ReadMessage()
+		if err != nil {
 			return
 		}
+
+		// the reply of a move is sent when the move has finished
+		reply := hub.handleWsCommand(c, msg)
+		if reply.Name != "" {
+			c.queue.push("", reply)
+		}
+	}
+}
+
+// send takes the messages from the client's queue and writes them to
+// the websocket. If the client does not accept a message within
+// clientWriteWait, it will be disconnected.
+// Since this method contains an endless loop it should be executed
+// in a go routine.
+func (c *WsClient) send(closer chan<- *WsClient) {
+	if err := c.queue.run(c.write); err != nil {
+		log.Printf("error writing to client %v: %v\n", c.RemoteAddr(), err)
+		closer <- c
+	}
+}
+
+// subscribe limits the events forwarded to this client to the given
+// rotators. An empty list subscribes the client to all rotators.
+func (c *WsClient) subscribe(rotators []string) {
+	c.subMu.Lock()
+	defer c.subMu.Unlock()
+
+	if len(rotators) == 0 {
+		c.subscriptions = nil
+		return
+	}
+
+	c.subscriptions = make(map[string]bool)
+	for _, name := range rotators {
+		c.subscriptions[name] = true
+	}
+}
+
+// setOperator sets the name under which the client takes control of
+// the rotators.
+func (c *WsClient) setOperator(name string) {
+	c.subMu.Lock()
+	defer c.subMu.Unlock()
+	c.operator = name
+}
+
+// user returns the name of the client. Authenticated clients are
+// identified by their name, other clients by the operator (if any).
+func (c *WsClient) user() string {
+	c.subMu.RLock()
+	defer c.subMu.RUnlock()
+	if c.identity.Name != "" {
+		return c.identity.Name
+	}
+	return c.operator
+}
+
+// owner returns the identity of the client for the leases.
+// Authenticated clients are identified by their name, all other clients
+// by their connection; the operator is only displayed.
+func (c *WsClient) owner() string {
+	if c.identity.Name != "" {
+		return c.identity.Name
 	}
+	return "ws:" + c.RemoteAddr().String()
 }
 
-func (c *WsClient) write(event Event) error {
+// wants returns true if the event should be forwarded to the client.
+// Events announcing new or removed rotators are always forwarded to
+// clients which are allowed to read the rotator.
+func (c *WsClient) wants(ev Event) bool {
+	if !ev.readable(c.identity) {
+		return false
+	}
+	if ev.Name == AddRotator || ev.Name == RemoveRotator {
+		return true
+	}
+
+	c.subMu.RLock()
+	defer c.subMu.RUnlock()
 
-	b, err := json.Marshal(event)
+	if c.subscriptions == nil {
+		return true
+	}
+	for _, name := range ev.rotators() {
+		if c.subscriptions[name] {
+			return true
+		}
+	}
+	return false
+}
+
+// write serializes the value to json and sends it to the client.
+func (c *WsClient) write(v interface{}) error {
+
+	b, err := json.Marshal(v)
 	if err != nil {
-		return fmt.Errorf("unable to serialize msg %v: %v", event, err)
+		return fmt.Errorf("unable to serialize msg %v: %v", v, err)
 	}
+
+	c.SetWriteDeadline(time.Now().Add(clientWriteWait))
 	if err := c.WriteMessage(websocket.TextMessage, b); err != nil {
 		return err
 	}
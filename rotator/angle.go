@@ -0,0 +1,23 @@
+package rotator
+
+// NormAzimuth returns the azimuth in the range 0 ... 359°.
+func NormAzimuth(az int) int {
+	return (az%360 + 360) % 360
+}
+
+// AzDiff returns the angular distance (0 ... 180°) between two azimuths.
+func AzDiff(a, b int) int {
+	d := NormAzimuth(a - b)
+	if d > 180 {
+		d = 360 - d
+	}
+	return d
+}
+
+// ElDiff returns the distance between two elevations.
+func ElDiff(a, b int) int {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
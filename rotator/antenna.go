@@ -0,0 +1,29 @@
+package rotator
+
+import "fmt"
+
+// Bearings returns the antennas with their bearings at the heading h of
+// the rotator.
+func Bearings(antennas []Antenna, h Heading) []Antenna {
+	if len(antennas) == 0 {
+		return nil
+	}
+	res := make([]Antenna, 0, len(antennas))
+	for _, a := range antennas {
+		a.Bearing = NormAzimuth(h.Azimuth + a.Offset)
+		a.Preset = NormAzimuth(h.AzPreset + a.Offset)
+		res = append(res, a)
+	}
+	return res
+}
+
+// AntennaTarget returns the azimuth to which the rotator has to turn,
+// so that the antenna with the given name points at the bearing.
+func AntennaTarget(antennas []Antenna, name string, bearing int) (int, error) {
+	for _, a := range antennas {
+		if a.Name == name {
+			return NormAzimuth(bearing - a.Offset), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown antenna '%s'", name)
+}
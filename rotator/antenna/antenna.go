@@ -0,0 +1,70 @@
+// Package antenna describes the antennas which share the mast of a
+// rotator. Each antenna is mounted with an offset relative to the
+// azimuth of the rotator, so that the bearing of every antenna can be
+// reported and any antenna can be pointed at a bearing.
+package antenna
+
+import (
+	"fmt"
+
+	"github.com/dh1tw/remoteRotator/rotator"
+)
+
+// Rotator is a layer which adds the antennas mounted on the mast to the
+// serialized rotator.
+type Rotator struct {
+	rotator.Rotator
+	antennas []rotator.Antenna
+}
+
+// New puts the antennas in front of the rotator. The names of the
+// antennas must be unique. If no antennas are provided, the rotator is
+// returned unchanged.
+func New(r rotator.Rotator, antennas ...rotator.Antenna) (rotator.Rotator, error) {
+	if len(antennas) == 0 {
+		return r, nil
+	}
+
+	names := make(map[string]bool)
+	for _, a := range antennas {
+		if a.Name == "" {
+			return nil, fmt.Errorf("antenna without name")
+		}
+		if names[a.Name] {
+			return nil, fmt.Errorf("antenna names must be unique; %s exists more than once", a.Name)
+		}
+		names[a.Name] = true
+	}
+
+	return &Rotator{
+		Rotator:  r,
+		antennas: append([]rotator.Antenna{}, antennas...),
+	}, nil
+}
+
+// Antennas returns the antennas mounted on the mast.
+func (r *Rotator) Antennas() []rotator.Antenna {
+	return append([]rotator.Antenna{}, r.antennas...)
+}
+
+// Point turns the rotator so that the antenna points at the bearing.
+func (r *Rotator) Point(name string, bearing int) error {
+	az, err := rotator.AntennaTarget(r.antennas, name, bearing)
+	if err != nil {
+		return err
+	}
+	return r.SetAzimuth(az)
+}
+
+// Serialize the data of the rotator together with the bearings of the
+// antennas.
+func (r *Rotator) Serialize() rotator.Object {
+	obj := r.Rotator.Serialize()
+	obj.Antennas = rotator.Bearings(r.antennas, obj.Heading)
+	return obj
+}
+
+// Unwrap returns the rotator carrying the antennas.
+func (r *Rotator) Unwrap() rotator.Rotator {
+	return r.Rotator
+}
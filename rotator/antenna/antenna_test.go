@@ -0,0 +1,98 @@
+package antenna
+
+import (
+	"testing"
+
+	"github.com/dh1tw/remoteRotator/rotator"
+	"github.com/dh1tw/remoteRotator/rotator/dummy"
+)
+
+func TestAntennas(t *testing.T) {
+
+	d, _ := dummy.New(dummy.AzimuthSpeed(1000))
+	defer d.Close()
+
+	r, err := New(d,
+		rotator.Antenna{Name: "yagi"},
+		rotator.Antenna{Name: "lpda", Offset: 90, Bands: []string{"20m", "15m"}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ant, ok := rotator.As[*Rotator](r)
+	if !ok {
+		t.Fatal("expected to find the antenna layer")
+	}
+
+	tt := []struct {
+		name    string
+		antenna string
+		bearing int
+		azimuth int
+		err     bool
+	}{
+		{"main antenna", "yagi", 120, 120, false},
+		{"mounted at 90°", "lpda", 120, 30, false},
+		{"wrap around", "lpda", 45, 315, false},
+		{"unknown antenna", "dipole", 0, 0, true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ant.Point(tc.antenna, tc.bearing)
+			if tc.err {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d.AzPreset() != tc.azimuth {
+				t.Fatalf("expected azimuth %d, got %d", tc.azimuth, d.AzPreset())
+			}
+
+			obj := r.Serialize()
+			if len(obj.Antennas) != 2 {
+				t.Fatalf("expected 2 antennas, got %+v", obj.Antennas)
+			}
+			for _, a := range obj.Antennas {
+				if a.Name == tc.antenna && a.Preset != tc.bearing {
+					t.Fatalf("expected preset %d of %s, got %+v", tc.bearing, a.Name, a)
+				}
+			}
+		})
+	}
+}
+
+func TestBearings(t *testing.T) {
+	antennas := []rotator.Antenna{{Name: "yagi"}, {Name: "lpda", Offset: 90}}
+
+	res := rotator.Bearings(antennas, rotator.Heading{Azimuth: 300, AzPreset: 10})
+	if res[0].Bearing != 300 || res[1].Bearing != 30 || res[1].Preset != 100 {
+		t.Fatalf("unexpected bearings %+v", res)
+	}
+	if antennas[1].Bearing != 0 {
+		t.Fatal("expected antennas to remain unchanged")
+	}
+	if res := rotator.Bearings(nil, rotator.Heading{}); res != nil {
+		t.Fatalf("expected no antennas, got %+v", res)
+	}
+}
+
+func TestInvalidAntennas(t *testing.T) {
+	d, _ := dummy.New()
+	defer d.Close()
+
+	if r, err := New(d); err != nil || r != rotator.Rotator(d) {
+		t.Fatal("expected rotator to be returned unchanged")
+	}
+	if _, err := New(d, rotator.Antenna{Name: "yagi"}, rotator.Antenna{Name: "yagi", Offset: 90}); err == nil {
+		t.Fatal("expected duplicate names to be rejected")
+	}
+	if _, err := New(d, rotator.Antenna{Offset: 90}); err == nil {
+		t.Fatal("expected antenna without name to be rejected")
+	}
+}
@@ -0,0 +1,275 @@
+// Package calibration converts between the azimuth reported by the
+// rotator controller (physical heading) and the direction into which the
+// antenna actually points (true heading). The correction consists of a
+// lookup table (e.g. from a compass survey), a constant offset of the
+// indicator and the mounting offset of the antenna relative to the zero
+// of the rotator.
+package calibration
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/dh1tw/remoteRotator/rotator"
+)
+
+// Point is an entry of the lookup table: the azimuth indicated by the
+// rotator and the actual (surveyed) azimuth.
+type Point struct {
+	Indicated float64 `json:"indicated"`
+	Actual    float64 `json:"actual"`
+}
+
+// Calibrator converts the azimuth between the physical and the true
+// heading. The offsets can be changed at runtime.
+type Calibrator struct {
+	sync.RWMutex
+	offset   int
+	mounting int
+	table    []Point
+	forward  curve // correction at the indicated azimuth
+	inverse  curve // correction at the actual azimuth
+	onChange func(offset, mounting int)
+}
+
+// New returns an initialized Calibrator. Configuration settings can be
+// set through functional options.
+// Default settings are:
+// offset: 0,
+// mounting: 0,
+// table: none.
+func New(opts ...func(*Calibrator)) (*Calibrator, error) {
+
+	c := &Calibrator{}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	var err error
+	if c.forward, c.inverse, err = newCurves(c.table); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Offset is a functional option to set the constant correction (in
+// degrees) of the azimuth indicator (true = indicated + offset).
+func Offset(deg int) func(*Calibrator) {
+	return func(c *Calibrator) {
+		c.offset = deg
+	}
+}
+
+// Mounting is a functional option to set the direction (in degrees) of
+// the antenna relative to the zero of the rotator.
+func Mounting(deg int) func(*Calibrator) {
+	return func(c *Calibrator) {
+		c.mounting = deg
+	}
+}
+
+// Table is a functional option to set the lookup table. The correction
+// is interpolated linearly between the points.
+func Table(points ...Point) func(*Calibrator) {
+	return func(c *Calibrator) {
+		c.table = append([]Point{}, points...)
+	}
+}
+
+// OnChange is a functional option to set a callback which is executed
+// when the offsets are changed at runtime.
+func OnChange(f func(offset, mounting int)) func(*Calibrator) {
+	return func(c *Calibrator) {
+		c.onChange = f
+	}
+}
+
+// Offsets returns the offset of the indicator and the mounting offset
+// of the antenna.
+func (c *Calibrator) Offsets() (offset, mounting int) {
+	c.RLock()
+	defer c.RUnlock()
+	return c.offset, c.mounting
+}
+
+// SetOffsets changes the offset of the indicator and the mounting offset
+// of the antenna.
+func (c *Calibrator) SetOffsets(offset, mounting int) {
+	c.Lock()
+	c.offset = offset
+	c.mounting = mounting
+	c.Unlock()
+
+	if c.onChange != nil {
+		c.onChange(offset, mounting)
+	}
+}
+
+// Table returns the lookup table.
+func (c *Calibrator) Table() []Point {
+	return append([]Point{}, c.table...)
+}
+
+// identity returns true if the calibrator doesn't change the headings.
+// Must be called with the lock held.
+func (c *Calibrator) identity() bool {
+	return c.offset == 0 && c.mounting == 0 && len(c.table) == 0
+}
+
+// ToTrue converts a physical azimuth into the true azimuth (0 ... 359°).
+// Physical azimuths in the overlap (>= 360°) are converted into true
+// azimuths >= 360°, the same way as without calibration.
+func (c *Calibrator) ToTrue(az int) int {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.identity() {
+		return az
+	}
+
+	overlap := 0
+	if az >= 360 {
+		az -= 360
+		overlap = 360
+	}
+
+	a := float64(az)
+	a += c.forward.at(a)
+	return rotator.NormAzimuth(int(math.Round(a))+c.offset+c.mounting) + overlap
+}
+
+// ToPhysical converts a true azimuth into the physical azimuth. Azimuths
+// >= 360° request the overlap of the rotator; they are converted into
+// physical azimuths >= 360° if the overlap reaches that far (azimuthMax
+// is the maximum physical azimuth of the rotator).
+func (c *Calibrator) ToPhysical(az, azimuthMax int) int {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.identity() {
+		return az
+	}
+
+	a := float64(rotator.NormAzimuth(az - c.offset - c.mounting))
+	a += c.inverse.at(a)
+	p := rotator.NormAzimuth(int(math.Round(a)))
+	if az >= 360 && p+360 <= azimuthMax {
+		p += 360
+	}
+	return p
+}
+
+// Heading converts a physical heading into the true heading.
+func (c *Calibrator) Heading(h rotator.Heading) rotator.Heading {
+	h.Azimuth = c.ToTrue(h.Azimuth)
+	h.AzPreset = c.ToTrue(h.AzPreset)
+	return h
+}
+
+// EventHandler returns an event handler which converts the headings
+// reported by the rotator driver before they are passed to h.
+func (c *Calibrator) EventHandler(h rotator.EventHandler) rotator.EventHandler {
+	return func(r rotator.Rotator, heading rotator.Heading) {
+		if h != nil {
+			h(r, c.Heading(heading))
+		}
+	}
+}
+
+// curve is a circular, piecewise linear correction.
+type curve struct {
+	xs []float64 // positions (0 ... 360°), sorted
+	cs []float64 // corrections at the positions
+}
+
+// newCurves returns the correction curves for the conversion from the
+// indicated to the actual azimuth and back.
+func newCurves(table []Point) (forward, inverse curve, err error) {
+
+	type point struct{ x, c float64 }
+
+	fwd := make([]point, 0, len(table))
+	inv := make([]point, 0, len(table))
+
+	for _, p := range table {
+		c := math.Remainder(p.Actual-p.Indicated, 360) // -180 ... 180
+		fwd = append(fwd, point{fmod(p.Indicated), c})
+		inv = append(inv, point{fmod(p.Indicated + c), -c})
+	}
+
+	sort.Slice(fwd, func(i, j int) bool { return fwd[i].x < fwd[j].x })
+	sort.Slice(inv, func(i, j int) bool { return inv[i].x < inv[j].x })
+
+	// the conversion must be strictly increasing, otherwise it can't
+	// be inverted
+	for i := range fwd {
+		next := fwd[(i+1)%len(fwd)]
+		dx := next.x - fwd[i].x
+		if i == len(fwd)-1 {
+			dx += 360
+		}
+		if len(fwd) > 1 && (dx <= 0 || dx+next.c-fwd[i].c <= 0) {
+			return curve{}, curve{}, fmt.Errorf("invalid calibration table at %v°: the actual azimuths must increase with the indicated azimuths", fwd[i].x)
+		}
+	}
+
+	for _, p := range fwd {
+		forward.xs = append(forward.xs, p.x)
+		forward.cs = append(forward.cs, p.c)
+	}
+	for _, p := range inv {
+		inverse.xs = append(inverse.xs, p.x)
+		inverse.cs = append(inverse.cs, p.c)
+	}
+
+	return forward, inverse, nil
+}
+
+// at returns the correction at the position x.
+func (cv curve) at(x float64) float64 {
+
+	n := len(cv.xs)
+	switch n {
+	case 0:
+		return 0
+	case 1:
+		return cv.cs[0]
+	}
+
+	x = fmod(x)
+	i := sort.SearchFloat64s(cv.xs, x) // first position >= x
+	if i < n && cv.xs[i] == x {
+		return cv.cs[i]
+	}
+
+	// neighbours of x; wrap around at 0° / 360°
+	lo, hi := i-1, i
+	x0, x1 := 0.0, 0.0
+	if lo < 0 {
+		lo = n - 1
+		x0 = cv.xs[lo] - 360
+	} else {
+		x0 = cv.xs[lo]
+	}
+	if hi == n {
+		hi = 0
+		x1 = cv.xs[hi] + 360
+	} else {
+		x1 = cv.xs[hi]
+	}
+
+	return cv.cs[lo] + (cv.cs[hi]-cv.cs[lo])*(x-x0)/(x1-x0)
+}
+
+// fmod returns x in the range 0 ... 360°.
+func fmod(x float64) float64 {
+	x = math.Mod(x, 360)
+	if x < 0 {
+		x += 360
+	}
+	return x
+}
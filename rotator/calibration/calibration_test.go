@@ -0,0 +1,215 @@
+package calibration
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dh1tw/remoteRotator/rotator"
+	"github.com/dh1tw/remoteRotator/rotator/dummy"
+)
+
+func TestConversion(t *testing.T) {
+
+	survey := Table(
+		Point{Indicated: 0, Actual: 2},
+		Point{Indicated: 90, Actual: 96},
+		Point{Indicated: 180, Actual: 180},
+		Point{Indicated: 270, Actual: 266},
+	)
+
+	tt := []struct {
+		name     string
+		opts     []func(*Calibrator)
+		physical int
+		true     int
+	}{
+		{"identity", nil, 400, 400},
+		{"offset", []func(*Calibrator){Offset(7)}, 100, 107},
+		{"negative offset", []func(*Calibrator){Offset(-7)}, 3, 356},
+		{"mounting", []func(*Calibrator){Mounting(90)}, 300, 30},
+		{"offset and mounting", []func(*Calibrator){Offset(-7), Mounting(90)}, 0, 83},
+		{"table at point", []func(*Calibrator){survey}, 90, 96},
+		{"table interpolated", []func(*Calibrator){survey}, 45, 49},
+		{"table wrap around", []func(*Calibrator){survey}, 315, 314},
+		{"table and mounting", []func(*Calibrator){survey, Mounting(180)}, 0, 182},
+		{"single point", []func(*Calibrator){Table(Point{Indicated: 10, Actual: 15})}, 200, 205},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := New(tc.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res := c.ToTrue(tc.physical); res != tc.true {
+				t.Fatalf("ToTrue(%d): got %d, expected %d", tc.physical, res, tc.true)
+			}
+			if res := c.ToPhysical(tc.true, 450); res != tc.physical {
+				t.Fatalf("ToPhysical(%d): got %d, expected %d", tc.true, res, tc.physical)
+			}
+		})
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	c, err := New(Offset(7), Mounting(90), Table(
+		Point{Indicated: 0, Actual: 3},
+		Point{Indicated: 120, Actual: 118},
+		Point{Indicated: 240, Actual: 245},
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the physical azimuths are integers; where the table stretches
+	// the scale, not every true azimuth can be reached exactly
+	for az := 0; az < 360; az++ {
+		if res := c.ToTrue(c.ToPhysical(az, 450)); rotator.NormAzimuth(res-az+1) > 2 {
+			t.Fatalf("round trip of %d° returned %d°", az, res)
+		}
+	}
+
+	// overlap; true 460° is physical ~0°, which the rotator reaches
+	// again at ~360°
+	if res := c.ToPhysical(460, 450); res < 360 {
+		t.Fatalf("expected physical azimuth in the overlap, got %d", res)
+	}
+}
+
+func TestOverlap(t *testing.T) {
+	tt := []struct {
+		name       string
+		opts       []func(*Calibrator)
+		azimuthMax int
+		physical   int
+		true       int
+	}{
+		{"identity", nil, 450, 400, 400},
+		{"offset", []func(*Calibrator){Offset(7)}, 450, 400, 407},
+		{"offset at 360", []func(*Calibrator){Offset(7)}, 450, 360, 367},
+		{"offset at max", []func(*Calibrator){Offset(7)}, 450, 450, 457},
+		{"negative offset", []func(*Calibrator){Offset(-7)}, 450, 365, 358 + 360},
+		{"mounting", []func(*Calibrator){Mounting(90)}, 450, 400, 490},
+		{"mounting at max", []func(*Calibrator){Mounting(90)}, 450, 450, 540},
+		{"table", []func(*Calibrator){Table(Point{Indicated: 0, Actual: 2}, Point{Indicated: 180, Actual: 180})}, 450, 360, 362},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := New(tc.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res := c.ToTrue(tc.physical); res != tc.true {
+				t.Fatalf("ToTrue(%d): got %d, expected %d", tc.physical, res, tc.true)
+			}
+			if res := c.ToPhysical(tc.true, tc.azimuthMax); res != tc.physical {
+				t.Fatalf("ToPhysical(%d): got %d, expected %d", tc.true, res, tc.physical)
+			}
+		})
+	}
+}
+
+func TestOverlapBeyondMax(t *testing.T) {
+	c, err := New(Mounting(90))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the overlap is only used if the rotator reaches it
+	tt := []struct {
+		true     int
+		physical int
+	}{
+		{360, 270},
+		{449, 359},
+		{450, 360},
+		{540, 450},
+	}
+	for _, tc := range tt {
+		if res := c.ToPhysical(tc.true, 450); res != tc.physical {
+			t.Fatalf("ToPhysical(%d): got %d, expected %d", tc.true, res, tc.physical)
+		}
+	}
+	if res := c.ToPhysical(400, 360); res != 310 {
+		t.Fatalf("expected rotator without overlap to use 310°, got %d", res)
+	}
+}
+
+func TestInvalidTable(t *testing.T) {
+	_, err := New(Table(
+		Point{Indicated: 0, Actual: 0},
+		Point{Indicated: 10, Actual: 30},
+		Point{Indicated: 20, Actual: 25},
+	))
+	if err == nil {
+		t.Fatal("expected decreasing table to be rejected")
+	}
+
+	_, err = New(Table(Point{Indicated: 10, Actual: 10}, Point{Indicated: 370, Actual: 5}))
+	if err == nil {
+		t.Fatal("expected duplicate points to be rejected")
+	}
+}
+
+func TestRotator(t *testing.T) {
+
+	changed := [2]int{}
+	c, err := New(Mounting(90), OnChange(func(offset, mounting int) {
+		changed = [2]int{offset, mounting}
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events := make(chan rotator.Heading, 100)
+	d, _ := dummy.New(dummy.AzimuthSpeed(1000), dummy.EventHandler(
+		c.EventHandler(func(r rotator.Rotator, h rotator.Heading) {
+			events <- h
+		})))
+	defer d.Close()
+
+	r := c.Bind(d)
+
+	if err := r.SetAzimuth(180); err != nil {
+		t.Fatal(err)
+	}
+	if d.AzPreset() != 90 {
+		t.Fatalf("expected physical preset 90, got %d", d.AzPreset())
+	}
+
+	timeout := time.After(time.Second * 2)
+	for r.Azimuth() != 180 {
+		select {
+		case <-events:
+		case <-timeout:
+			t.Fatalf("rotator did not arrive, azimuth %d", r.Azimuth())
+		}
+	}
+
+	obj := r.Serialize()
+	if obj.Heading.Azimuth != 180 || obj.Heading.AzPreset != 180 || obj.Config.AzimuthStop != 90 {
+		t.Fatalf("unexpected serialized rotator %+v", obj)
+	}
+
+	c.SetOffsets(-7, 90)
+	if r.Azimuth() != 173 || changed != [2]int{-7, 90} {
+		t.Fatalf("unexpected azimuth %d after changing the offsets (%v)", r.Azimuth(), changed)
+	}
+
+	// the physical 360° of the dummy is the end of the overlap; true
+	// azimuths beyond it use the rotator's range without overlap
+	if max := r.Serialize().Config.AzimuthMax; max != 443 {
+		t.Fatalf("expected true maximum azimuth 443, got %d", max)
+	}
+	if err := r.SetAzimuth(400); err != nil {
+		t.Fatal(err)
+	}
+	if d.AzPreset() != 317 {
+		t.Fatalf("expected physical preset 317, got %d", d.AzPreset())
+	}
+
+	if cal, ok := rotator.As[*Rotator](r); !ok || cal.Calibrator() != c {
+		t.Fatal("expected to find the calibration layer")
+	}
+}
@@ -0,0 +1,66 @@
+package calibration
+
+import "github.com/dh1tw/remoteRotator/rotator"
+
+// Rotator is a layer which is put directly in front of a rotator
+// driver. It reports and accepts true azimuths, while the driver works
+// with the physical azimuths of the controller. The events of the driver
+// must be converted with Calibrator.EventHandler.
+type Rotator struct {
+	rotator.Rotator
+	cal *Calibrator
+}
+
+// Bind returns the calibrated view of the rotator.
+func (c *Calibrator) Bind(r rotator.Rotator) *Rotator {
+	return &Rotator{Rotator: r, cal: c}
+}
+
+// Calibrator returns the calibration of the rotator.
+func (r *Rotator) Calibrator() *Calibrator {
+	return r.cal
+}
+
+// Azimuth returns the true azimuth.
+func (r *Rotator) Azimuth() int {
+	return r.cal.ToTrue(r.Rotator.Azimuth())
+}
+
+// AzPreset returns the true azimuth to which the rotator turns.
+func (r *Rotator) AzPreset() int {
+	return r.cal.ToTrue(r.Rotator.AzPreset())
+}
+
+// SetAzimuth turns the rotator to the true azimuth.
+func (r *Rotator) SetAzimuth(az int) error {
+	max := r.Rotator.Serialize().Config.AzimuthMax
+	return r.Rotator.SetAzimuth(r.cal.ToPhysical(az, max))
+}
+
+// Serialize the data of the rotator with the true azimuth. The
+// mechanical stop is converted as well; the range of rotators which
+// cover less than 360° is shifted accordingly. The maximum azimuth of
+// rotators with an overlap is the true azimuth at the end of the
+// overlap.
+func (r *Rotator) Serialize() rotator.Object {
+	obj := r.Rotator.Serialize()
+	obj.Heading = r.cal.Heading(obj.Heading)
+
+	cfg := &obj.Config
+	if cfg.HasAzimuth {
+		cfg.AzimuthStop = rotator.NormAzimuth(r.cal.ToTrue(cfg.AzimuthStop))
+		if span := cfg.AzimuthMax - cfg.AzimuthMin; span < 360 {
+			cfg.AzimuthMin = rotator.NormAzimuth(r.cal.ToTrue(cfg.AzimuthMin))
+			cfg.AzimuthMax = rotator.NormAzimuth(r.cal.ToTrue(cfg.AzimuthMax))
+		} else if cfg.AzimuthMax >= 360 {
+			cfg.AzimuthMax = r.cal.ToTrue(cfg.AzimuthMax)
+		}
+	}
+
+	return obj
+}
+
+// Unwrap returns the calibrated rotator.
+func (r *Rotator) Unwrap() rotator.Rotator {
+	return r.Rotator
+}
@@ -0,0 +1,269 @@
+// Package composite presents two single axis rotators (e.g. an azimuth
+// only and an elevation only controller of a satellite station) as one
+// az/el rotator. The underlying rotators can be any rotator.Rotator,
+// including proxies of remote rotators.
+package composite
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/dh1tw/remoteRotator/rotator"
+)
+
+// Rotator is a virtual az/el rotator composed of an azimuth and an
+// elevation rotator. The events of the underlying rotators must be
+// passed to Handle.
+type Rotator struct {
+	sync.RWMutex
+	name         string
+	az           rotator.Rotator
+	el           rotator.Rotator
+	eventHandler rotator.EventHandler
+	heading      rotator.Heading // last reported heading
+}
+
+// New returns a composite rotator which turns the azimuth with az and
+// the elevation with el. Configuration settings can be set through
+// functional options.
+// Default settings are:
+// name: "<azimuth rotator>/<elevation rotator>",
+// event handler: none.
+func New(az, el rotator.Rotator, opts ...func(*Rotator)) (*Rotator, error) {
+
+	if az == nil || el == nil {
+		return nil, fmt.Errorf("composite rotator requires an azimuth and an elevation rotator")
+	}
+	if !az.HasAzimuth() {
+		return nil, fmt.Errorf("rotator %s does not support azimuth", az.Name())
+	}
+	if !el.HasElevation() {
+		return nil, fmt.Errorf("rotator %s does not support elevation", el.Name())
+	}
+	if az.Name() == el.Name() {
+		return nil, fmt.Errorf("the azimuth and elevation rotators must be different")
+	}
+
+	r := &Rotator{
+		name: az.Name() + "/" + el.Name(),
+		az:   az,
+		el:   el,
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	r.heading = r.serializeHeading()
+
+	return r, nil
+}
+
+// Name is a functional option to set the name of the composite rotator.
+func Name(name string) func(*Rotator) {
+	return func(r *Rotator) {
+		r.name = name
+	}
+}
+
+// EventHandler is a functional option to set the callback through which
+// the composite rotator reports changes of its heading.
+func EventHandler(h rotator.EventHandler) func(*Rotator) {
+	return func(r *Rotator) {
+		r.eventHandler = h
+	}
+}
+
+// Members returns the azimuth and the elevation rotator.
+func (r *Rotator) Members() (az, el rotator.Rotator) {
+	return r.az, r.el
+}
+
+// Handle merges an event of one of the underlying rotators into the
+// heading of the composite rotator. Events of other rotators are
+// ignored, so Handle can be called with the events of all rotators.
+func (r *Rotator) Handle(src rotator.Rotator, h rotator.Heading) {
+
+	r.Lock()
+	heading := r.heading
+	switch src.Name() {
+	case r.az.Name():
+		heading.Azimuth = h.Azimuth
+		heading.AzPreset = h.AzPreset
+	case r.el.Name():
+		heading.Elevation = h.Elevation
+		heading.ElPreset = h.ElPreset
+	default:
+		r.Unlock()
+		return
+	}
+	changed := heading != r.heading
+	r.heading = heading
+	r.Unlock()
+
+	if changed && r.eventHandler != nil {
+		r.eventHandler(r, heading)
+	}
+}
+
+// WithMembers returns a view of the composite rotator which commands the
+// members returned by bind (see rotator.Composite).
+func (r *Rotator) WithMembers(bind func(rotator.Rotator) rotator.Rotator) rotator.Rotator {
+	r.RLock()
+	defer r.RUnlock()
+
+	return &Rotator{
+		name:    r.name,
+		az:      bind(r.az),
+		el:      bind(r.el),
+		heading: r.heading,
+	}
+}
+
+// Name returns the name of the composite rotator.
+func (r *Rotator) Name() string {
+	return r.name
+}
+
+// HasAzimuth always returns true.
+func (r *Rotator) HasAzimuth() bool {
+	return true
+}
+
+// HasElevation always returns true.
+func (r *Rotator) HasElevation() bool {
+	return true
+}
+
+// Azimuth returns the azimuth of the azimuth rotator.
+func (r *Rotator) Azimuth() int {
+	return r.az.Azimuth()
+}
+
+// AzPreset returns the preset of the azimuth rotator.
+func (r *Rotator) AzPreset() int {
+	return r.az.AzPreset()
+}
+
+// SetAzimuth turns the azimuth rotator.
+func (r *Rotator) SetAzimuth(az int) error {
+	return r.az.SetAzimuth(az)
+}
+
+// Elevation returns the elevation of the elevation rotator.
+func (r *Rotator) Elevation() int {
+	return r.el.Elevation()
+}
+
+// ElPreset returns the preset of the elevation rotator.
+func (r *Rotator) ElPreset() int {
+	return r.el.ElPreset()
+}
+
+// SetElevation turns the elevation rotator.
+func (r *Rotator) SetElevation(el int) error {
+	return r.el.SetElevation(el)
+}
+
+// StopAzimuth stops the azimuth rotator.
+func (r *Rotator) StopAzimuth() error {
+	return r.az.StopAzimuth()
+}
+
+// StopElevation stops the elevation rotator.
+func (r *Rotator) StopElevation() error {
+	return r.el.StopElevation()
+}
+
+// Stop stops both rotators at once.
+func (r *Rotator) Stop() error {
+	var azErr, elErr error
+	var wg sync.WaitGroup
+
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		azErr = r.az.StopAzimuth()
+	}()
+	go func() {
+		defer wg.Done()
+		elErr = r.el.StopElevation()
+	}()
+	wg.Wait()
+
+	return errors.Join(azErr, elErr)
+}
+
+// CanMove returns the errors with which the rotators would currently
+// refuse a move.
+func (r *Rotator) CanMove() error {
+	return errors.Join(rotator.CanMove(r.az), rotator.CanMove(r.el))
+}
+
+// Acknowledge acknowledges the faults of both rotators.
+func (r *Rotator) Acknowledge() error {
+	_, azErr := rotator.Acknowledge(r.az)
+	_, elErr := rotator.Acknowledge(r.el)
+	return errors.Join(azErr, elErr)
+}
+
+// Serialize the data of the composite rotator. The azimuth is taken from
+// the azimuth rotator, the elevation from the elevation rotator. The
+// status of both rotators is reported, prefixed with the name of the
+// rotator which raised it.
+func (r *Rotator) Serialize() rotator.Object {
+	azObj := r.az.Serialize()
+	elObj := r.el.Serialize()
+
+	obj := rotator.Object{
+		Name: r.name,
+		Heading: rotator.Heading{
+			Azimuth:   azObj.Heading.Azimuth,
+			AzPreset:  azObj.Heading.AzPreset,
+			Elevation: elObj.Heading.Elevation,
+			ElPreset:  elObj.Heading.ElPreset,
+		},
+		Config: rotator.Config{
+			HasAzimuth:     true,
+			AzimuthMin:     azObj.Config.AzimuthMin,
+			AzimuthMax:     azObj.Config.AzimuthMax,
+			AzimuthStop:    azObj.Config.AzimuthStop,
+			AzimuthOverlap: azObj.Config.AzimuthOverlap,
+			HasElevation:   true,
+			ElevationMin:   elObj.Config.ElevationMin,
+			ElevationMax:   elObj.Config.ElevationMax,
+		},
+		Antennas: azObj.Antennas,
+	}
+
+	for _, o := range []rotator.Object{azObj, elObj} {
+		for _, s := range o.Status {
+			s.Source = o.Name + "/" + s.Source
+			obj.Status = append(obj.Status, s)
+		}
+		// the more restrictive duty cycle is reported
+		if dc := o.DutyCycle; dc != nil {
+			cur := obj.DutyCycle
+			if cur == nil || !cur.Exhausted && (dc.Exhausted || dc.Remaining < cur.Remaining) {
+				obj.DutyCycle = dc
+			}
+		}
+	}
+
+	return obj
+}
+
+// serializeHeading returns the current heading of both rotators.
+func (r *Rotator) serializeHeading() rotator.Heading {
+	return rotator.Heading{
+		Azimuth:   r.az.Azimuth(),
+		AzPreset:  r.az.AzPreset(),
+		Elevation: r.el.Elevation(),
+		ElPreset:  r.el.ElPreset(),
+	}
+}
+
+// Close doesn't close the underlying rotators; they may be used on
+// their own as well.
+func (r *Rotator) Close() {}
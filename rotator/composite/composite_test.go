@@ -0,0 +1,99 @@
+package composite
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dh1tw/remoteRotator/rotator"
+	"github.com/dh1tw/remoteRotator/rotator/dummy"
+)
+
+func TestNew(t *testing.T) {
+	az, _ := dummy.New(dummy.Name("az"))
+	defer az.Close()
+	el, _ := dummy.New(dummy.Name("el"), dummy.HasAzimuth(false), dummy.HasElevation(true))
+	defer el.Close()
+
+	tt := []struct {
+		name string
+		az   rotator.Rotator
+		el   rotator.Rotator
+		err  bool
+	}{
+		{"valid", az, el, false},
+		{"missing rotator", az, nil, true},
+		{"no elevation", az, az, true},
+		{"no azimuth", el, el, true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := New(tc.az, tc.el)
+			if tc.err != (err != nil) {
+				t.Fatalf("unexpected error %v", err)
+			}
+		})
+	}
+
+	if r, _ := New(az, el); r.Name() != "az/el" {
+		t.Fatalf("unexpected default name %s", r.Name())
+	}
+}
+
+func TestComposite(t *testing.T) {
+
+	events := make(chan rotator.Heading, 100)
+	var composite atomic.Pointer[Rotator]
+
+	// the events of both rotators are passed to the composite rotator
+	handler := func(src rotator.Rotator, h rotator.Heading) {
+		if r := composite.Load(); r != nil {
+			r.Handle(src, h)
+		}
+	}
+
+	az, _ := dummy.New(dummy.Name("az"), dummy.AzimuthSpeed(1000), dummy.EventHandler(handler))
+	defer az.Close()
+	el, _ := dummy.New(dummy.Name("el"), dummy.HasAzimuth(false), dummy.HasElevation(true),
+		dummy.ElevationSpeed(1000), dummy.EventHandler(handler))
+	defer el.Close()
+
+	r, err := New(az, el, Name("sat"), EventHandler(func(src rotator.Rotator, h rotator.Heading) {
+		if src.Name() != "sat" {
+			t.Errorf("unexpected event source %s", src.Name())
+		}
+		events <- h
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	composite.Store(r)
+
+	if err := r.SetAzimuth(120); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.SetElevation(30); err != nil {
+		t.Fatal(err)
+	}
+
+	timeout := time.After(time.Second * 2)
+	for arrived := false; !arrived; {
+		select {
+		case h := <-events:
+			arrived = h.Azimuth == 120 && h.Elevation == 30
+		case <-timeout:
+			t.Fatal("expected merged heading events")
+		}
+	}
+
+	obj := r.Serialize()
+	if obj.Name != "sat" || !obj.Config.HasAzimuth || !obj.Config.HasElevation ||
+		obj.Heading.AzPreset != 120 || obj.Heading.ElPreset != 30 {
+		t.Fatalf("unexpected serialized rotator %+v", obj)
+	}
+
+	if err := r.Stop(); err != nil {
+		t.Fatal(err)
+	}
+}
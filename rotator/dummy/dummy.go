@@ -9,7 +9,11 @@ import (
 )
 
 // Dummy is the implementation of a Dummy rotator which can be used
-// for testing purposes
+// for testing purposes. The rotator's motion is simulated with
+// acceleration, deceleration, motor start delay and brake release time.
+// Rotators covering more than 360° can turn into the overlap beyond their
+// mechanical stop. Faults (stall, drift, stuck sensor, communication
+// loss) can be injected to reproduce the failure modes of real rotators.
 type Dummy struct {
 	sync.RWMutex
 	eventHandler   func(rotator.Rotator, rotator.Heading)
@@ -20,19 +24,28 @@ type Dummy struct {
 	azimuthOverlap bool
 	elevationMin   int
 	elevationMax   int
-	azimuth        float32
-	azPreset       float32
-	elevation      float32
-	elPreset       float32
 	hasAzimuth     bool
 	hasElevation   bool
 	azSpeed        float32
 	elSpeed        float32
+	acceleration   float64
+	startDelay     time.Duration
+	brakeRelease   time.Duration
+	az             axis
+	el             axis
+	azOrigin       int
+	initialAz      *int
+	initialEl      *int
+	stuckSensor    *rotator.Heading
+	lastHeading    rotator.Heading
 	ticker         *time.Ticker
 	tickerInterval float32 //ms
+	errorCh        chan struct{}
+	failAfter      time.Duration
 	closeCh        chan struct{}
 	starter        sync.Once
 	closer         sync.Once
+	failer         sync.Once
 }
 
 // New creates a new dummy rotator which satisfies the
@@ -45,6 +58,10 @@ type Dummy struct {
 // elevationMax: 180,
 // azSpeed: 8, (deg/sec)
 // elSpeed: 5, (deg/sec)
+// acceleration: 0, (deg/sec²; no inertia)
+// startDelay: 0,
+// brakeRelease: 0,
+// failAfter: 0 (disabled).
 func New(options ...func(*Dummy)) (*Dummy, error) {
 
 	r := &Dummy{
@@ -61,33 +78,74 @@ func New(options ...func(*Dummy)) (*Dummy, error) {
 		opt(r)
 	}
 
-	if r.azimuthMin > 0 {
-		r.azPreset = float32(r.azimuthMin)
-		r.azimuth = float32(r.azimuthMin)
-	}
-
-	if r.elevationMin > 0 {
-		r.elPreset = float32(r.elevationMin)
-		r.elevation = float32(r.elevationMin)
-	}
-
-	r.ticker = time.NewTicker(time.Millisecond * time.Duration(r.tickerInterval))
+	r.initAxes()
 
 	go r.start()
 
 	return r, nil
 }
 
+// initAxes sets up the simulation of the azimuth and elevation axis.
+func (r *Dummy) initAxes() {
+
+	// the mechanical travel of the azimuth axis starts at the
+	// mechanical stop if the rotator covers 360° or more, otherwise
+	// at azimuthMin
+	span := float64(r.azimuthMax - r.azimuthMin)
+	r.azOrigin = r.azimuthStop
+	if span < 360 {
+		span = float64(rotator.NormAzimuth(r.azimuthMax - r.azimuthMin))
+		r.azOrigin = r.azimuthMin
+	}
+
+	r.az = axis{
+		maxSpeed:     float64(r.azSpeed),
+		accel:        r.acceleration,
+		startDelay:   r.startDelay,
+		brakeRelease: r.brakeRelease,
+		span:         span,
+	}
+	az := r.azimuthMin
+	if r.initialAz != nil {
+		az = *r.initialAz
+	}
+	r.az.setTarget(r.azTravel(az))
+	r.az.pos = r.az.target
+
+	r.el = axis{
+		maxSpeed:   float64(r.elSpeed),
+		accel:      r.acceleration,
+		startDelay: r.startDelay,
+		span:       float64(r.elevationMax),
+	}
+	el := r.elevationMin
+	if r.initialEl != nil {
+		el = *r.initialEl
+	}
+	r.el.setTarget(float64(el))
+	r.el.pos = r.el.target
+
+	r.lastHeading = r.heading()
+}
+
 // // start the event loop
 func (r *Dummy) start() {
 
-	r.ticker = time.NewTicker(time.Millisecond * time.Duration(r.tickerInterval))
+	interval := time.Millisecond * time.Duration(r.tickerInterval)
+	r.ticker = time.NewTicker(interval)
 	defer r.ticker.Stop()
 
+	var failCh <-chan time.Time
+	if r.failAfter > 0 {
+		failCh = time.After(r.failAfter)
+	}
+
 	for {
 		select {
-		case <-r.ticker.C:
-			r.updateHeadings()
+		case now := <-r.ticker.C:
+			r.updateHeadings(interval, now)
+		case <-failCh:
+			r.InjectError()
 		case <-r.closeCh:
 			return
 		}
@@ -128,7 +186,7 @@ func (r *Dummy) HasElevation() bool {
 func (r *Dummy) Azimuth() int {
 	r.RLock()
 	defer r.RUnlock()
-	return int(r.azimuth)
+	return r.heading().Azimuth
 }
 
 // AzPreset returns the horizontal heading (preset) to which the rotator
@@ -136,12 +194,14 @@ func (r *Dummy) Azimuth() int {
 func (r *Dummy) AzPreset() int {
 	r.RLock()
 	defer r.RUnlock()
-	return int(r.azPreset)
+	return r.heading().AzPreset
 }
 
 // SetAzimuth sets to value of the horizontal heading to which the
 // rotator shall turn to. Allowed values are 0 ... 450. Values outside
-// of this range will be clipped.
+// of this range will be clipped. If the heading can be reached on two
+// ways (overlap), the rotator takes the shorter one, unless a heading
+// > 360° explicitly requests the overlap.
 func (r *Dummy) SetAzimuth(az int) error {
 	r.Lock()
 	defer r.Unlock()
@@ -150,56 +210,54 @@ func (r *Dummy) SetAzimuth(az int) error {
 		return nil
 	}
 
-	if az > r.azimuthMax {
-		az = r.azimuthMax
+	if az > 450 {
+		az = 450
 	}
 
-	if az < r.azimuthMin {
-		az = r.azimuthMin
+	if az < 0 {
+		az = 0
 	}
 
-	abs := math.Abs(float64(r.azimuthMax - r.azimuthMin))
+	r.az.setTarget(r.azTravel(az))
+	return nil
+}
 
-	// if rotation is only allowed on less than 360°
-	if abs < 360 {
+// azTravel returns the position on the mechanical travel of the azimuth
+// axis for a heading. Headings which can't be reached are clipped to
+// the closer end of the travel.
+func (r *Dummy) azTravel(az int) float64 {
 
-		// special case: overlapping 0°
-		if r.azimuthMin > r.azimuthMax {
-			if az >= r.azimuthMin || az <= r.azimuthMax {
-				r.azPreset = float32(az)
-				return nil
-			}
+	offset := float64(rotator.NormAzimuth(az - r.azOrigin))
+	span := r.az.span
 
-			if math.Abs(float64(az-r.azimuthMin)) < math.Abs(float64(az-r.azimuthMax)) {
-				r.azPreset = float32(r.azimuthMin)
-			} else {
-				r.azPreset = float32(r.azimuthMax)
-			}
-			return nil
+	if offset > span {
+		// outside of the range; turn to the closer end
+		toStart := 360 - offset
+		toEnd := offset - span
+		if toStart < toEnd {
+			return 0
 		}
+		return span
+	}
 
-		if az >= r.azimuthMin && az <= r.azimuthMax {
-			r.azPreset = float32(az)
-			return nil
+	// heading is reachable twice (overlap)
+	if offset+360 <= span {
+		if az >= 360 {
+			return offset + 360
 		}
-
-		if math.Abs(float64(az-r.azimuthMin)) < math.Abs(float64(az-r.azimuthMax)) {
-			r.azPreset = float32(r.azimuthMin)
-		} else {
-			r.azPreset = float32(r.azimuthMax)
+		if math.Abs(offset+360-r.az.pos) < math.Abs(offset-r.az.pos) {
+			return offset + 360
 		}
-		return nil
 	}
 
-	r.azPreset = float32(az)
-	return nil
+	return offset
 }
 
 // Elevation returns the current vertical elevation of the rotator in degrees
 func (r *Dummy) Elevation() int {
 	r.RLock()
 	defer r.RUnlock()
-	return int(r.elevation)
+	return r.heading().Elevation
 }
 
 // ElPreset returns the vertical elevation (preset) to which the rotator
@@ -207,7 +265,7 @@ func (r *Dummy) Elevation() int {
 func (r *Dummy) ElPreset() int {
 	r.RLock()
 	defer r.RUnlock()
-	return int(r.elPreset)
+	return r.heading().ElPreset
 }
 
 // SetElevation sets to value of the vertical elevation to which the
@@ -230,13 +288,13 @@ func (r *Dummy) SetElevation(el int) error {
 	}
 
 	if el < r.elevationMin {
-		r.elPreset = float32(r.elevationMin)
+		el = r.elevationMin
 	} else if el > r.elevationMax {
-		r.elPreset = float32(r.elevationMax)
-	} else {
-		r.elPreset = float32(el)
+		el = r.elevationMax
 	}
 
+	r.el.setTarget(float64(el))
+
 	return nil
 }
 
@@ -245,11 +303,8 @@ func (r *Dummy) StopAzimuth() error {
 	r.Lock()
 	defer r.Unlock()
 
-	r.azPreset = r.azimuth
-	if r.eventHandler != nil {
-		heading := r.serialize().Heading
-		go r.eventHandler(r, heading)
-	}
+	r.az.stop()
+	r.emit()
 
 	return nil
 }
@@ -259,11 +314,9 @@ func (r *Dummy) StopElevation() error {
 	r.Lock()
 	defer r.Unlock()
 
-	r.elPreset = r.elevation
-	if r.eventHandler != nil {
-		heading := r.serialize().Heading
-		go r.eventHandler(r, heading)
-	}
+	r.el.stop()
+	r.emit()
+
 	return nil
 }
 
@@ -272,12 +325,9 @@ func (r *Dummy) Stop() error {
 	r.Lock()
 	defer r.Unlock()
 
-	r.elPreset = r.elevation
-	r.azPreset = r.azimuth
-	if r.eventHandler != nil {
-		heading := r.serialize().Heading
-		go r.eventHandler(r, heading)
-	}
+	r.az.stop()
+	r.el.stop()
+	r.emit()
 
 	return nil
 }
@@ -292,229 +342,71 @@ func (r *Dummy) Serialize() rotator.Object {
 func (r *Dummy) serialize() rotator.Object {
 
 	obj := rotator.Object{
-		Name: r.name,
-		Heading: rotator.Heading{
-			Azimuth:   int(r.azimuth),
-			AzPreset:  int(r.azPreset),
-			Elevation: int(r.elevation),
-			ElPreset:  int(r.elPreset),
-		},
+		Name:    r.name,
+		Heading: r.heading(),
 		Config: rotator.Config{
-			HasAzimuth:   r.hasAzimuth,
-			HasElevation: r.hasElevation,
-			AzimuthMax:   r.azimuthMax,
-			AzimuthMin:   r.azimuthMin,
-			AzimuthStop:  r.azimuthStop,
-			ElevationMax: r.elevationMax,
-			ElevationMin: r.elevationMin,
+			HasAzimuth:     r.hasAzimuth,
+			HasElevation:   r.hasElevation,
+			AzimuthMax:     r.azimuthMax,
+			AzimuthMin:     r.azimuthMin,
+			AzimuthStop:    r.azimuthStop,
+			AzimuthOverlap: r.azimuthOverlap,
+			ElevationMax:   r.elevationMax,
+			ElevationMin:   r.elevationMin,
 		},
 	}
 
 	return obj
 }
 
-func (r *Dummy) updateHeadings() {
-	r.Lock()
-	defer r.Unlock()
-	r.updateAzimuth()
-	r.updateElevation()
-}
+// heading returns the heading as reported by the (simulated) sensors.
+func (r *Dummy) heading() rotator.Heading {
 
-func (r *Dummy) updateAzimuth() {
-
-	if r.hasAzimuth {
-		changed := r.calcNewAzHeading()
-		if changed {
-			heading := r.serialize().Heading
-			go r.eventHandler(r, heading)
-		}
+	h := rotator.Heading{
+		Azimuth:   r.azHeading(r.az.pos),
+		AzPreset:  r.azHeading(r.az.target),
+		Elevation: int(math.Round(r.el.pos)),
+		ElPreset:  int(math.Round(r.el.target)),
 	}
-}
 
-func (r *Dummy) updateElevation() {
-
-	if r.hasElevation {
-		changed := r.calcNewElHeading()
-		if changed {
-			heading := r.serialize().Heading
-			go r.eventHandler(r, heading)
-		}
-	}
-}
-
-func (r *Dummy) calcNewElHeading() bool {
-
-	if int(r.elevation) == int(r.elPreset) {
-		return false
+	// the presets are still known by the controller
+	if r.stuckSensor != nil {
+		h.Azimuth = r.stuckSensor.Azimuth
+		h.Elevation = r.stuckSensor.Elevation
 	}
 
-	moveCCW := false
-	moveCW := false
-
-	delta := r.elSpeed / (r.tickerInterval / 10)
-
-	min := float32(r.elevationMin)
-	max := float32(r.elevationMax)
-
-	if r.elPreset < r.elevation && r.elevation > min {
-		moveCCW = true
-	} else if r.elPreset > r.elevation && r.elevation < max {
-		moveCW = true
-	}
+	return h
+}
 
-	if moveCW {
-		r.elevation += delta
-	}
+// azHeading converts a position on the mechanical travel of the azimuth
+// axis into a heading (0 ... 359°).
+func (r *Dummy) azHeading(pos float64) int {
+	return rotator.NormAzimuth(r.azOrigin + int(math.Round(pos)))
+}
 
-	if moveCCW {
-		r.elevation -= delta
+// emit reports the current heading through the event handler.
+func (r *Dummy) emit() {
+	heading := r.heading()
+	r.lastHeading = heading
+	if r.eventHandler != nil {
+		go r.eventHandler(r, heading)
 	}
-
-	return true
 }
 
-func (r *Dummy) calcNewAzHeading() bool {
+func (r *Dummy) updateHeadings(dt time.Duration, now time.Time) {
+	r.Lock()
+	defer r.Unlock()
 
-	if int(r.azimuth) == int(r.azPreset) {
-		return false
+	if r.hasAzimuth {
+		r.az.step(dt, now)
+		r.azimuthOverlap = r.az.pos >= 360
 	}
 
-	moveCCW := false
-	moveCW := false
-
-	delta := r.azSpeed / (r.tickerInterval / 10)
-
-	abs := math.Abs(float64(r.azimuthMax - r.azimuthMin))
-
-	// if rotation is allowed on < 360°
-	if abs < 360 {
-		min := float32(r.azimuthMin)
-		max := float32(r.azimuthMax)
-
-		// max overlapping 0°
-		if min >= max {
-			if (r.azimuth >= min && r.azPreset < 360 && r.azPreset > r.azimuth) ||
-				(r.azimuth >= min && r.azPreset <= max) ||
-				(r.azimuth < max && r.azPreset > r.azimuth && r.azPreset <= max) {
-				moveCW = true
-			} else {
-				moveCCW = true
-			}
-		} else {
-			if r.azPreset > r.azimuth {
-				moveCW = true
-			} else {
-				moveCCW = true
-			}
-		}
-	} else { // rotation allowed on >= 360°
-		// moving CW or CCW?
-		if r.azPreset-r.azimuth > 0 {
-			if (float32(r.azimuthStop) < r.azPreset) && (float32(r.azimuthStop) > r.azimuth) {
-				moveCCW = true
-			} else {
-				moveCW = true
-			}
-		} else {
-			//crossing mechanical stop
-			if (float32(r.azimuthStop) > r.azPreset) && (float32(r.azimuthStop) < r.azimuth) {
-				moveCW = true
-			} else {
-				moveCCW = true
-			}
-		}
-	}
-	// } else { // rotation allowed on >= 360°
-	// 	azMax := r.azimuthMax - 360 + r.azimuthStop
-
-	// 	// overlap not crossing 0°
-	// 	if r.azimuthStop <= azMax {
-
-	// 		if r.azPreset > r.azimuth {
-	// 			fmt.Println("1")
-	// 			if r.azPreset < float32(azMax) && r.azimuth < float32(azMax) {
-	// 				fmt.Println("2")
-	// 				moveCW = true
-	// 				if int(r.azimuth) == r.azimuthStop {
-	// 					r.azimuthOverlap = true
-	// 					fmt.Println("3")
-	// 				}
-	// 			} else if r.azPreset > float32(azMax) && r.azimuth < float32(azMax) {
-	// 				fmt.Println("4")
-	// 				moveCCW = true
-	// 				if int(r.azimuth) > r.azimuthStop && !r.azimuthOverlap {
-	// 					moveCW = true
-	// 					// r.azimuthOverlap = true
-	// 					fmt.Println("5")
-	// 				} else if int(r.azimuth) > r.azimuthStop && r.azimuthOverlap {
-	// 					fmt.Println(55)
-	// 				} else {
-	// 					r.azimuthOverlap = false
-	// 					fmt.Println("6")
-	// 				}
-	// 			} else if r.azPreset > float32(azMax) && r.azimuth > float32(azMax) {
-	// 				fmt.Println("7")
-	// 				moveCCW = true
-	// 			} else {
-	// 				fmt.Println(100)
-	// 			}
-	// 		} else {
-	// 			if r.azPreset < float32(azMax) && r.azimuth < float32(azMax) {
-	// 				fmt.Println(10)
-	// 				// if int(r.azimuth) > r.azimuthStop && !r.azimuthOverlap {
-	// 				// 	fmt.Println(11)
-	// 				// 	moveCW = true
-	// 				if int(r.azimuth) == r.azimuthStop {
-	// 					r.azimuthOverlap = false
-	// 				}
-	// 				if int(r.azimuth) > r.azimuthStop {
-	// 					moveCCW = true
-	// 					fmt.Println(12)
-	// 					// } else if int(r.azimuth) < r.azimuthStop {
-	// 					// 	moveCCW = true
-	// 					// 	fmt.Println(122)
-	// 				} else {
-	// 					// r.azimuthOverlap = false
-	// 					fmt.Println(13)
-	// 					moveCCW = true
-	// 				}
-	// 			} else if r.azPreset > float32(r.azimuthStop) && r.azimuth > float32(r.azimuthStop) {
-	// 				fmt.Println(14)
-	// 				moveCCW = true
-	// 			} else if r.azPreset < float32(r.azimuthStop) && r.azimuth < float32(azMax) {
-	// 				moveCW = true
-	// 			} else {
-	// 				fmt.Println(200)
-	// 			}
-	// 			// moveCCW = true
-	// 			// fmt.Println("10")
-	// 		}
-	// 		// overlap crossing 0°
-	// 	} else if r.azimuthStop < azMax {
-
-	// 	}
-	// }
-
-	if moveCW {
-		// crossing from 359 -> 0
-		if r.azimuth <= 359 && r.azimuth+delta > 359 {
-			r.azimuth = delta
-			// fmt.Printf("%.2f\n", r.azimuth)
-			return true
-		}
-		r.azimuth += delta
-		// fmt.Printf("%.2f\n", r.azimuth)
-
-	} else if moveCCW {
-		// crossing from 0 <- 360
-		if r.azimuth >= 0 && r.azimuth-delta < 0 {
-			r.azimuth = 359 - delta
-			// fmt.Printf("%.2f\n", r.azimuth)
-			return true
-		}
-		r.azimuth -= delta
-		// fmt.Printf("%.2f\n", r.azimuth)
+	if r.hasElevation {
+		r.el.step(dt, now)
 	}
 
-	return true
+	if r.heading() != r.lastHeading {
+		r.emit()
+	}
 }
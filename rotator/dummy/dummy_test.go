@@ -0,0 +1,194 @@
+package dummy
+
+import (
+	"testing"
+	"time"
+)
+
+// newTestDummy returns a Dummy without running event loop, so that the
+// simulation can be advanced step by step.
+func newTestDummy(opts ...func(*Dummy)) *Dummy {
+	r := &Dummy{
+		hasAzimuth:     true,
+		azimuthMax:     360,
+		elevationMax:   180,
+		azSpeed:        8,
+		elSpeed:        5,
+		tickerInterval: 100,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	r.initAxes()
+	return r
+}
+
+// run advances the simulation in steps of 100ms
+func run(r *Dummy, d time.Duration, now *time.Time) {
+	dt := time.Millisecond * 100
+	for i := time.Duration(0); i < d; i += dt {
+		*now = now.Add(dt)
+		r.updateHeadings(dt, *now)
+	}
+}
+
+func TestAccelerationAndDeceleration(t *testing.T) {
+	r := newTestDummy(AzimuthSpeed(10), Acceleration(5))
+	now := time.Now()
+
+	r.SetAzimuth(100)
+
+	// after 1 sec the rotator has reached half of its speed
+	run(r, time.Second, &now)
+	if v := r.az.velocity; v < 4.9 || v > 5.1 {
+		t.Fatalf("expected velocity 5 deg/sec, got %.2f", v)
+	}
+
+	run(r, time.Second*15, &now)
+	if r.Azimuth() != 100 || r.az.velocity != 0 {
+		t.Fatalf("expected rotator to stop at 100, got %d (%.2f deg/sec)",
+			r.Azimuth(), r.az.velocity)
+	}
+}
+
+func TestStartDelay(t *testing.T) {
+	r := newTestDummy(AzimuthSpeed(10), StartDelay(time.Second), BrakeRelease(time.Second))
+	now := time.Now()
+
+	r.SetAzimuth(100)
+	run(r, time.Millisecond*1900, &now)
+	if r.Azimuth() != 0 {
+		t.Fatalf("expected rotator not to move yet, got %d", r.Azimuth())
+	}
+
+	run(r, time.Second, &now)
+	if r.Azimuth() == 0 {
+		t.Fatal("expected rotator to move")
+	}
+}
+
+func TestStopDecelerates(t *testing.T) {
+	r := newTestDummy(AzimuthSpeed(10), Acceleration(10))
+	now := time.Now()
+
+	r.SetAzimuth(180)
+	run(r, time.Second*3, &now)
+	r.StopAzimuth()
+
+	// stopping distance at 10 deg/sec: 5 deg
+	az := r.Azimuth()
+	run(r, time.Second*3, &now)
+	if d := r.Azimuth() - az; d < 4 || d > 6 {
+		t.Fatalf("expected stopping distance of 5 deg, got %d", d)
+	}
+}
+
+func TestOverlap(t *testing.T) {
+
+	tt := []struct {
+		name       string
+		start      int
+		target     int
+		expTravel  float64
+		expOverlap bool
+	}{
+		{"short way within overlap", 350, 20, 380, true},
+		{"short way back", 20, 350, 350, false},
+		{"explicit overlap", 10, 400, 400, true},
+		{"without overlap", 10, 40, 40, false},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			r := newTestDummy(AzimuthMax(450), AzimuthSpeed(1000))
+			now := time.Now()
+
+			r.SetAzimuth(tc.start)
+			run(r, time.Second*2, &now)
+			r.SetAzimuth(tc.target)
+			run(r, time.Second*2, &now)
+
+			if r.az.pos != tc.expTravel {
+				t.Fatalf("expected travel position %.0f, got %.0f", tc.expTravel, r.az.pos)
+			}
+			if r.Serialize().Config.AzimuthOverlap != tc.expOverlap {
+				t.Fatalf("expected overlap %v", tc.expOverlap)
+			}
+			if r.Azimuth() != tc.target%360 {
+				t.Fatalf("expected azimuth %d, got %d", tc.target%360, r.Azimuth())
+			}
+		})
+	}
+}
+
+func TestLimitedRange(t *testing.T) {
+	r := newTestDummy(AzimuthMin(300), AzimuthMax(60), AzimuthSpeed(1000))
+	now := time.Now()
+
+	r.SetAzimuth(30)
+	run(r, time.Second, &now)
+	if r.Azimuth() != 30 {
+		t.Fatalf("expected azimuth 30, got %d", r.Azimuth())
+	}
+
+	// outside of the range; closer to 60
+	r.SetAzimuth(100)
+	run(r, time.Second, &now)
+	if r.Azimuth() != 60 {
+		t.Fatalf("expected azimuth 60, got %d", r.Azimuth())
+	}
+}
+
+func TestInitialHeading(t *testing.T) {
+	r := newTestDummy(HasElevation(true), AzimuthMax(450), InitialAzimuth(200), InitialElevation(45))
+
+	if r.Azimuth() != 200 || r.AzPreset() != 200 {
+		t.Fatalf("expected azimuth 200, got %d (preset %d)", r.Azimuth(), r.AzPreset())
+	}
+	if r.Elevation() != 45 || r.ElPreset() != 45 {
+		t.Fatalf("expected elevation 45, got %d (preset %d)", r.Elevation(), r.ElPreset())
+	}
+}
+
+func TestFaults(t *testing.T) {
+	r := newTestDummy(AzimuthSpeed(10))
+	now := time.Now()
+
+	r.InjectStall(50)
+	r.SetAzimuth(100)
+	run(r, time.Second*10, &now)
+	if r.Azimuth() != 50 {
+		t.Fatalf("expected rotator to stall at 50, got %d", r.Azimuth())
+	}
+
+	// can be turned back
+	r.SetAzimuth(20)
+	run(r, time.Second*5, &now)
+	if r.Azimuth() != 20 {
+		t.Fatalf("expected azimuth 20, got %d", r.Azimuth())
+	}
+
+	r.ClearFaults()
+	r.InjectDrift(1)
+	run(r, time.Second*5, &now)
+	if r.Azimuth() != 25 {
+		t.Fatalf("expected rotator to drift to 25, got %d", r.Azimuth())
+	}
+
+	r.ClearFaults()
+	r.InjectStuckSensor()
+	r.SetAzimuth(90)
+	run(r, time.Second*10, &now)
+	if r.Azimuth() != 25 || r.az.pos != 90 {
+		t.Fatalf("expected stuck azimuth 25, got %d (position %.0f)", r.Azimuth(), r.az.pos)
+	}
+
+	errorCh := make(chan struct{})
+	r.errorCh = errorCh
+	r.InjectError()
+	select {
+	case <-errorCh:
+	default:
+		t.Fatal("expected errorCh to be closed")
+	}
+}
@@ -0,0 +1,49 @@
+package dummy
+
+// InjectStall lets the azimuth motor stall when the rotator reaches the
+// heading. The rotator can't pass this heading anymore, but can still
+// be turned back.
+func (r *Dummy) InjectStall(az int) {
+	r.Lock()
+	defer r.Unlock()
+	pos := r.azTravel(az)
+	r.az.stallAt = &pos
+	r.az.stallDir = 0
+}
+
+// InjectDrift lets the azimuth drift with the given speed (deg/sec;
+// negative = counter clockwise) while the rotator is idle, like a
+// rotator with a worn brake in the wind.
+func (r *Dummy) InjectDrift(degPerSec float64) {
+	r.Lock()
+	defer r.Unlock()
+	r.az.drift = degPerSec
+}
+
+// InjectStuckSensor freezes the reported azimuth and elevation at their
+// current values while the rotator continues to move.
+func (r *Dummy) InjectStuckSensor() {
+	r.Lock()
+	defer r.Unlock()
+	h := r.heading()
+	r.stuckSensor = &h
+}
+
+// InjectError simulates the loss of the communication with the rotator
+// by closing the ErrorCh.
+func (r *Dummy) InjectError() {
+	r.failer.Do(func() {
+		if r.errorCh != nil {
+			close(r.errorCh)
+		}
+	})
+}
+
+// ClearFaults removes the injected stall, drift and stuck sensor faults.
+func (r *Dummy) ClearFaults() {
+	r.Lock()
+	defer r.Unlock()
+	r.az.stallAt = nil
+	r.az.drift = 0
+	r.stuckSensor = nil
+}
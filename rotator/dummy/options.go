@@ -1,6 +1,10 @@
 package dummy
 
-import "github.com/dh1tw/remoteRotator/rotator"
+import (
+	"time"
+
+	"github.com/dh1tw/remoteRotator/rotator"
+)
 
 // Name is a functional option to set the name of the rotator
 func Name(name string) func(*Dummy) {
@@ -51,6 +55,15 @@ func AzimuthSpeed(speed int) func(*Dummy) {
 	}
 }
 
+// InitialAzimuth is a functional option to set the azimuth at which the
+// simulated rotator starts (e.g. the last preset before a restart). By
+// default the rotator starts at azimuthMin.
+func InitialAzimuth(az int) func(*Dummy) {
+	return func(r *Dummy) {
+		r.initialAz = &az
+	}
+}
+
 // ElevationMin is a functional option to set the minimum elevation angle.
 func ElevationMin(min int) func(*Dummy) {
 	return func(r *Dummy) {
@@ -65,6 +78,15 @@ func ElevationMax(max int) func(*Dummy) {
 	}
 }
 
+// InitialElevation is a functional option to set the elevation at which
+// the simulated rotator starts. By default the rotator starts at
+// elevationMin.
+func InitialElevation(el int) func(*Dummy) {
+	return func(r *Dummy) {
+		r.initialEl = &el
+	}
+}
+
 // ElevationSpeed sets the simulated speed of the rotator in degrees / second
 func ElevationSpeed(speed int) func(*Dummy) {
 	return func(r *Dummy) {
@@ -79,3 +101,44 @@ func EventHandler(h func(rotator.Rotator, rotator.Heading)) func(*Dummy) {
 		r.eventHandler = h
 	}
 }
+
+// Acceleration sets the simulated acceleration and deceleration of the
+// rotator in degrees / second². A value of 0 disables the inertia.
+func Acceleration(accel float64) func(*Dummy) {
+	return func(r *Dummy) {
+		r.acceleration = accel
+	}
+}
+
+// StartDelay sets the simulated delay until the motor starts turning
+// after a command has been received.
+func StartDelay(d time.Duration) func(*Dummy) {
+	return func(r *Dummy) {
+		r.startDelay = d
+	}
+}
+
+// BrakeRelease sets the simulated time the azimuth brake needs to be
+// released before the rotator can move.
+func BrakeRelease(d time.Duration) func(*Dummy) {
+	return func(r *Dummy) {
+		r.brakeRelease = d
+	}
+}
+
+// ErrorCh is a functional option allows you to pass a channel to the rotator.
+// The channel will be closed when a (simulated) communication error occures.
+func ErrorCh(ch chan struct{}) func(*Dummy) {
+	return func(r *Dummy) {
+		r.errorCh = ch
+	}
+}
+
+// FailAfter is a functional option to simulate the loss of the
+// communication with the rotator after the given duration. The ErrorCh
+// will be closed.
+func FailAfter(d time.Duration) func(*Dummy) {
+	return func(r *Dummy) {
+		r.failAfter = d
+	}
+}
@@ -0,0 +1,145 @@
+package dummy
+
+import (
+	"math"
+	"time"
+)
+
+// axis simulates the motion of one axis (azimuth or elevation) of the
+// rotator. Positions are measured along the mechanical travel of the
+// axis, starting at 0 (the mechanical stop) up to span.
+type axis struct {
+	pos          float64       // current position (deg)
+	target       float64       // position to which the axis is moving (deg)
+	velocity     float64       // current velocity (deg/sec); negative = CCW / down
+	maxSpeed     float64       // deg/sec
+	accel        float64       // deg/sec²; 0 = no inertia
+	startDelay   time.Duration // delay until the motor starts turning
+	brakeRelease time.Duration // delay until the brake has been released
+	startAt      time.Time     // when the motion from standstill begins
+	span         float64       // length of the mechanical travel (deg)
+	stallAt      *float64      // position at which the motor stalls
+	stallDir     float64       // direction in which the axis got stalled
+	drift        float64       // deg/sec the axis drifts while idle
+}
+
+// moving returns true if the axis has a pending movement.
+func (a *axis) moving() bool {
+	return a.velocity != 0 || a.pos != a.target
+}
+
+// setTarget sets the position to which the axis shall move.
+func (a *axis) setTarget(target float64) {
+	a.target = a.clip(target)
+}
+
+// stop decelerates the axis. Without inertia it stops immediately,
+// otherwise it comes to a halt after its stopping distance.
+func (a *axis) stop() {
+	a.startAt = time.Time{}
+	if a.velocity == 0 || a.accel == 0 {
+		a.velocity = 0
+		a.target = a.pos
+		return
+	}
+	dist := a.velocity * a.velocity / (2 * a.accel)
+	a.target = a.clip(a.pos + math.Copysign(dist, a.velocity))
+}
+
+// step advances the simulation by dt.
+func (a *axis) step(dt time.Duration, now time.Time) {
+
+	sec := dt.Seconds()
+
+	if !a.moving() {
+		a.pos = a.clip(a.pos + a.drift*sec)
+		a.target = a.pos
+		return
+	}
+
+	// starting from standstill; wait for the brake and the motor
+	if a.velocity == 0 {
+		if a.startAt.IsZero() {
+			a.startAt = now.Add(a.brakeRelease + a.startDelay)
+		}
+		if now.Before(a.startAt) {
+			return
+		}
+	}
+
+	remaining := a.target - a.pos
+	dir := math.Copysign(1, remaining)
+
+	switch {
+	case a.accel == 0:
+		a.velocity = dir * a.maxSpeed
+
+	// moving in the wrong direction (target has changed); brake first
+	case a.velocity != 0 && math.Signbit(a.velocity) != math.Signbit(remaining):
+		a.velocity = towards(a.velocity, 0, a.accel*sec)
+
+	// close to the target; decelerate
+	case a.velocity*a.velocity/(2*a.accel) >= math.Abs(remaining):
+		a.velocity = towards(a.velocity, 0, a.accel*sec)
+		// never stop short of the target
+		if a.velocity == 0 {
+			a.velocity = dir * math.Min(a.accel*sec, a.maxSpeed)
+		}
+
+	default:
+		a.velocity = towards(a.velocity, dir*a.maxSpeed, a.accel*sec)
+	}
+
+	next := a.pos + a.velocity*sec
+
+	// the motor stalls; the axis can't pass the stall position but
+	// can still be turned back
+	if a.stallAt != nil && between(*a.stallAt, a.pos, next) {
+		dir := math.Copysign(1, a.velocity)
+		if a.pos != *a.stallAt || dir == a.stallDir {
+			a.pos = *a.stallAt
+			a.stallDir = dir
+			a.velocity = 0
+			return
+		}
+	}
+
+	// target reached (or passed)
+	if between(a.target, a.pos, next) && math.Signbit(a.velocity) == math.Signbit(remaining) {
+		a.pos = a.target
+		a.velocity = 0
+		a.startAt = time.Time{}
+		return
+	}
+
+	a.pos = next
+
+	// mechanical limits
+	if a.pos <= 0 || a.pos >= a.span {
+		a.pos = a.clip(a.pos)
+		a.target = a.pos
+		a.velocity = 0
+		a.startAt = time.Time{}
+	}
+}
+
+// clip limits the position to the mechanical travel.
+func (a *axis) clip(pos float64) float64 {
+	return math.Max(0, math.Min(pos, a.span))
+}
+
+// towards changes value by at most delta towards goal.
+func towards(value, goal, delta float64) float64 {
+	if math.Abs(goal-value) <= delta {
+		return goal
+	}
+	return value + math.Copysign(delta, goal-value)
+}
+
+// between checks if x lies between a and b (inclusive b).
+func between(x, a, b float64) bool {
+	if a > b {
+		a, b = b, a
+	}
+	return x >= a && x <= b
+}
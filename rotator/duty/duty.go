@@ -0,0 +1,472 @@
+// Package duty provides a duty cycle limiter which can be put in front
+// of any rotator.Rotator. Many rotators (e.g. Yaesu G-450 / G-1000,
+// HAM-IV) are only rated for a few minutes of continuous operation. The
+// limiter tracks the cumulative motor-on time with a thermal budget
+// which is consumed while the motor is running and recovers while it
+// rests. When the budget is exhausted, the rotator is stopped and
+// further moves are refused (or queued) until the motor has cooled down.
+package duty
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/dh1tw/remoteRotator/rotator"
+)
+
+// Status codes raised by the limiter
+const (
+	Low       = "duty_low"
+	Exhausted = "duty_exhausted"
+	Recovered = "duty_recovered"
+)
+
+// source is the name under which the limiter reports its status
+const source = "duty"
+
+// ErrExhausted is returned for moves while the budget is exhausted.
+var ErrExhausted = errors.New("motor duty cycle exhausted; wait until the motor has cooled down")
+
+// Limiter is a duty cycle limiter which wraps a rotator.Rotator. It
+// implements the rotator.Rotator interface itself.
+type Limiter struct {
+	rotator.Rotator
+	sync.Mutex
+	statusHandler rotator.StatusHandler
+	interval      time.Duration
+	budget        time.Duration
+	coolDown      time.Duration
+	warnLevel     float64
+	resumeLevel   float64
+	queue         bool
+	tolerance     int
+	used          time.Duration
+	lastCheck     time.Time
+	lastHeading   rotator.Heading
+	status        *rotator.Status
+	azTarget      *int
+	elTarget      *int
+	closeCh       chan struct{}
+	closer        sync.Once
+}
+
+// New returns a Limiter for the rotator. Configuration settings can
+// be set through functional options.
+// Default settings are:
+// interval: 1s,
+// budget: 5min, (continuous motor-on time)
+// coolDown: 15min, (time to recover the full budget)
+// warnLevel: 0.2, (remaining fraction of the budget)
+// resumeLevel: 0.5, (remaining fraction of the budget)
+// queue: false.
+func New(r rotator.Rotator, opts ...func(*Limiter)) (*Limiter, error) {
+
+	l := &Limiter{
+		Rotator:     r,
+		interval:    time.Second,
+		budget:      time.Minute * 5,
+		coolDown:    time.Minute * 15,
+		warnLevel:   0.2,
+		resumeLevel: 0.5,
+		tolerance:   2,
+		closeCh:     make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	if l.interval <= 0 {
+		return nil, fmt.Errorf("invalid duty cycle interval %v", l.interval)
+	}
+	if l.budget <= 0 || l.coolDown <= 0 {
+		return nil, fmt.Errorf("duty cycle budget and cool down must be > 0")
+	}
+	if l.resumeLevel <= 0 || l.resumeLevel > 1 {
+		return nil, fmt.Errorf("invalid duty cycle resume level %v (0...1)", l.resumeLevel)
+	}
+
+	l.lastCheck = time.Now()
+	l.lastHeading = r.Serialize().Heading
+
+	go l.start()
+
+	return l, nil
+}
+
+// StatusHandler is a functional option to set the callback which is
+// executed when the budget runs low, is exhausted or has recovered.
+func StatusHandler(h rotator.StatusHandler) func(*Limiter) {
+	return func(l *Limiter) {
+		l.statusHandler = h
+	}
+}
+
+// Interval is a functional option to set the interval in which the
+// motor-on time is accounted.
+func Interval(d time.Duration) func(*Limiter) {
+	return func(l *Limiter) {
+		l.interval = d
+	}
+}
+
+// Budget is a functional option to set the maximum continuous motor-on
+// time of a cold motor.
+func Budget(d time.Duration) func(*Limiter) {
+	return func(l *Limiter) {
+		l.budget = d
+	}
+}
+
+// CoolDown is a functional option to set the time a resting motor needs
+// to recover the full budget.
+func CoolDown(d time.Duration) func(*Limiter) {
+	return func(l *Limiter) {
+		l.coolDown = d
+	}
+}
+
+// WarnLevel is a functional option to set the remaining fraction
+// (0...1) of the budget below which a warning is emitted.
+func WarnLevel(level float64) func(*Limiter) {
+	return func(l *Limiter) {
+		l.warnLevel = level
+	}
+}
+
+// ResumeLevel is a functional option to set the fraction (0...1) of the
+// budget which has to be recovered before moves are accepted again.
+func ResumeLevel(level float64) func(*Limiter) {
+	return func(l *Limiter) {
+		l.resumeLevel = level
+	}
+}
+
+// Queue is a functional option to queue moves while the budget is
+// exhausted instead of refusing them. Only the latest target is kept;
+// it is sent to the rotator once the budget has recovered.
+func Queue(enabled bool) func(*Limiter) {
+	return func(l *Limiter) {
+		l.queue = enabled
+	}
+}
+
+// start the event loop which accounts the motor-on time. Since this
+// function contains an endless loop, it should be executed in a go
+// routine.
+func (l *Limiter) start() {
+
+	ticker := time.NewTicker(l.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			l.account(l.Rotator.Serialize(), now)
+		case <-l.closeCh:
+			return
+		}
+	}
+}
+
+// account updates the consumed budget depending on whether the motor
+// has been running since the last check and raises or clears the
+// corresponding status.
+func (l *Limiter) account(obj rotator.Object, now time.Time) {
+	l.Lock()
+
+	dt := now.Sub(l.lastCheck)
+	l.lastCheck = now
+
+	if l.running(obj) {
+		l.used += dt
+	} else {
+		l.used -= time.Duration(float64(dt) * float64(l.budget) / float64(l.coolDown))
+	}
+	l.lastHeading = obj.Heading
+
+	if l.used < 0 {
+		l.used = 0
+	}
+	if l.used > l.budget {
+		l.used = l.budget
+	}
+
+	remaining := l.remaining()
+	exhausted := l.exhausted()
+
+	var status *rotator.Status
+	var stop, resume bool
+
+	switch {
+	case !exhausted && remaining <= 0:
+		status = l.newStatus(rotator.StatusWarning, Exhausted, true,
+			"motor duty cycle exhausted; moves %s for %v",
+			l.blockedVerb(), l.recoveryTime(l.resumeLevel).Round(time.Second))
+		stop = true
+	case exhausted && remaining >= l.resumeLevel:
+		status = l.newStatus(rotator.StatusInfo, Recovered, false,
+			"motor duty cycle recovered")
+		resume = true
+	case !exhausted && l.status == nil && remaining < l.warnLevel:
+		status = l.newStatus(rotator.StatusWarning, Low, false,
+			"motor duty cycle low (%.0f%% remaining)", remaining*100)
+	case !exhausted && l.status != nil && remaining >= l.warnLevel:
+		status = l.newStatus(rotator.StatusInfo, Recovered, false,
+			"motor duty cycle recovered")
+	}
+
+	if status == nil {
+		l.Unlock()
+		return
+	}
+
+	if status.Level == rotator.StatusInfo {
+		l.status = nil
+	} else {
+		l.status = status
+	}
+
+	var az, el *int
+	if resume {
+		az, el = l.azTarget, l.elTarget
+		l.azTarget, l.elTarget = nil, nil
+	}
+	l.Unlock()
+
+	log.Printf("%s: %s\n", obj.Name, status.Message)
+
+	if stop {
+		if err := l.Rotator.Stop(); err != nil {
+			log.Printf("unable to stop rotator %s: %v\n", obj.Name, err)
+		}
+	}
+
+	l.emit(*status)
+
+	// send the queued targets
+	if az != nil {
+		if err := l.Rotator.SetAzimuth(*az); err != nil {
+			log.Printf("unable to set azimuth of %s to %d: %v\n", obj.Name, *az, err)
+		}
+	}
+	if el != nil {
+		if err := l.Rotator.SetElevation(*el); err != nil {
+			log.Printf("unable to set elevation of %s to %d: %v\n", obj.Name, *el, err)
+		}
+	}
+}
+
+// running returns true if the motor has been running since the last
+// check. This is the case if the heading has changed or the rotator
+// hasn't reached its preset yet.
+func (l *Limiter) running(obj rotator.Object) bool {
+	h := obj.Heading
+
+	if h.Azimuth != l.lastHeading.Azimuth || h.Elevation != l.lastHeading.Elevation {
+		return true
+	}
+	if obj.Config.HasAzimuth && rotator.AzDiff(h.Azimuth, h.AzPreset) > l.tolerance {
+		return true
+	}
+	if obj.Config.HasElevation && rotator.ElDiff(h.Elevation, h.ElPreset) > l.tolerance {
+		return true
+	}
+
+	return false
+}
+
+// Rebaseline accepts the current heading of the rotator, e.g. after the
+// calibration has changed, so that the jump isn't accounted as motor-on
+// time.
+func (l *Limiter) Rebaseline() {
+	h := l.Rotator.Serialize().Heading
+
+	l.Lock()
+	defer l.Unlock()
+	l.lastHeading = h
+}
+
+// remaining returns the remaining fraction (0...1) of the budget.
+func (l *Limiter) remaining() float64 {
+	return 1 - float64(l.used)/float64(l.budget)
+}
+
+// recoveryTime returns the rest time required until the remaining
+// fraction of the budget reaches the level.
+func (l *Limiter) recoveryTime(level float64) time.Duration {
+	missing := level - l.remaining()
+	if missing <= 0 {
+		return 0
+	}
+	return time.Duration(missing * float64(l.coolDown))
+}
+
+func (l *Limiter) blockedVerb() string {
+	if l.queue {
+		return "queued"
+	}
+	return "refused"
+}
+
+func (l *Limiter) newStatus(level, code string, locked bool, format string,
+	args ...interface{}) *rotator.Status {
+	return &rotator.Status{
+		Source:  source,
+		Level:   level,
+		Code:    code,
+		Message: fmt.Sprintf(format, args...),
+		Locked:  locked,
+	}
+}
+
+// emit reports a status through the status handler.
+func (l *Limiter) emit(s rotator.Status) {
+	if l.statusHandler != nil {
+		l.statusHandler(l, s)
+	}
+}
+
+// exhausted returns true if moves are currently blocked. Must be
+// called with the lock held.
+func (l *Limiter) exhausted() bool {
+	return l.status != nil && l.status.Code == Exhausted
+}
+
+// CanMove returns ErrExhausted while the budget is exhausted. Queued
+// moves are reported as refused as well, since they aren't executed
+// before the budget has recovered.
+func (l *Limiter) CanMove() error {
+	l.Lock()
+	defer l.Unlock()
+	if l.exhausted() {
+		return ErrExhausted
+	}
+	return nil
+}
+
+// SetAzimuth forwards the azimuth to the rotator. While the budget is
+// exhausted, the azimuth is either queued or refused.
+func (l *Limiter) SetAzimuth(az int) error {
+	l.Lock()
+	if l.exhausted() {
+		defer l.Unlock()
+		if l.queue {
+			l.azTarget = &az
+			return nil
+		}
+		return ErrExhausted
+	}
+	l.Unlock()
+
+	return l.Rotator.SetAzimuth(az)
+}
+
+// SetElevation forwards the elevation to the rotator. While the budget
+// is exhausted, the elevation is either queued or refused.
+func (l *Limiter) SetElevation(el int) error {
+	l.Lock()
+	if l.exhausted() {
+		defer l.Unlock()
+		if l.queue {
+			l.elTarget = &el
+			return nil
+		}
+		return ErrExhausted
+	}
+	l.Unlock()
+
+	return l.Rotator.SetElevation(el)
+}
+
+// AzPreset returns the queued azimuth, or if there is none, the preset
+// of the rotator.
+func (l *Limiter) AzPreset() int {
+	l.Lock()
+	defer l.Unlock()
+	if l.azTarget != nil {
+		return *l.azTarget
+	}
+	return l.Rotator.AzPreset()
+}
+
+// ElPreset returns the queued elevation, or if there is none, the
+// preset of the rotator.
+func (l *Limiter) ElPreset() int {
+	l.Lock()
+	defer l.Unlock()
+	if l.elTarget != nil {
+		return *l.elTarget
+	}
+	return l.Rotator.ElPreset()
+}
+
+// Stop discards the queued targets and stops the rotator.
+func (l *Limiter) Stop() error {
+	l.Lock()
+	l.azTarget, l.elTarget = nil, nil
+	l.Unlock()
+	return l.Rotator.Stop()
+}
+
+// StopAzimuth discards the queued azimuth and stops the horizontal
+// movement.
+func (l *Limiter) StopAzimuth() error {
+	l.Lock()
+	l.azTarget = nil
+	l.Unlock()
+	return l.Rotator.StopAzimuth()
+}
+
+// StopElevation discards the queued elevation and stops the vertical
+// movement.
+func (l *Limiter) StopElevation() error {
+	l.Lock()
+	l.elTarget = nil
+	l.Unlock()
+	return l.Rotator.StopElevation()
+}
+
+// Serialize the data of the rotator. The remaining budget is added and
+// queued targets are reported as presets.
+func (l *Limiter) Serialize() rotator.Object {
+	obj := l.Rotator.Serialize()
+
+	l.Lock()
+	defer l.Unlock()
+
+	obj.DutyCycle = &rotator.DutyCycle{
+		Budget:    l.budget.Seconds(),
+		Remaining: (l.budget - l.used).Seconds(),
+		Exhausted: l.exhausted(),
+		Queued:    l.azTarget != nil || l.elTarget != nil,
+	}
+
+	if l.azTarget != nil {
+		obj.Heading.AzPreset = *l.azTarget
+	}
+	if l.elTarget != nil {
+		obj.Heading.ElPreset = *l.elTarget
+	}
+	if l.status != nil {
+		obj.Status = append(obj.Status, *l.status)
+	}
+
+	return obj
+}
+
+// Unwrap returns the limited rotator.
+func (l *Limiter) Unwrap() rotator.Rotator {
+	return l.Rotator
+}
+
+// Close shuts down the limiter and the rotator.
+func (l *Limiter) Close() {
+	l.closer.Do(func() {
+		close(l.closeCh)
+	})
+	l.Rotator.Close()
+}
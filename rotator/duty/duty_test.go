@@ -0,0 +1,142 @@
+package duty
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dh1tw/remoteRotator/rotator"
+	"github.com/dh1tw/remoteRotator/rotator/rotatortest"
+)
+
+var azimuthOnly = rotator.Config{HasAzimuth: true, AzimuthMax: 360}
+
+// newTestLimiter returns a Limiter with a budget of 10 seconds which
+// recovers within 20 seconds. The accounting is triggered by the test.
+func newTestLimiter(t *testing.T, f *rotatortest.Rotator, opts ...func(*Limiter)) (*Limiter, *[]rotator.Status) {
+	statuses := []rotator.Status{}
+	opts = append([]func(*Limiter){
+		Interval(time.Hour),
+		Budget(time.Second * 10),
+		CoolDown(time.Second * 20),
+		StatusHandler(func(r rotator.Rotator, s rotator.Status) {
+			statuses = append(statuses, s)
+		}),
+	}, opts...)
+
+	l, err := New(f, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(l.Close)
+	return l, &statuses
+}
+
+// run advances the rotator and the accounting in steps of 1 second
+func run(l *Limiter, f *rotatortest.Rotator, d time.Duration, now *time.Time) {
+	for i := time.Duration(0); i < d; i += time.Second {
+		*now = now.Add(time.Second)
+		f.Step()
+		l.account(f.Serialize(), *now)
+	}
+}
+
+func TestBudgetExhausted(t *testing.T) {
+	f := rotatortest.New(azimuthOnly)
+	l, statuses := newTestLimiter(t, f)
+	now := l.lastCheck
+
+	if err := l.SetAzimuth(300); err != nil {
+		t.Fatal(err)
+	}
+
+	run(l, f, time.Second*9, &now)
+	if len(*statuses) != 1 || (*statuses)[0].Code != Low {
+		t.Fatalf("expected low budget warning, got %v", *statuses)
+	}
+	if rem := l.Serialize().DutyCycle.Remaining; rem != 1 {
+		t.Fatalf("expected 1 sec remaining, got %v", rem)
+	}
+
+	run(l, f, time.Second, &now)
+	if len(*statuses) != 2 || (*statuses)[1].Code != Exhausted || !(*statuses)[1].Locked {
+		t.Fatalf("expected exhausted status, got %v", *statuses)
+	}
+	if f.Stops() != 1 {
+		t.Fatal("expected rotator to be stopped")
+	}
+	if err := l.SetAzimuth(200); err != ErrExhausted {
+		t.Fatalf("expected ErrExhausted, got %v", err)
+	}
+	if obj := l.Serialize(); !obj.DutyCycle.Exhausted || len(obj.Status) != 1 {
+		t.Fatalf("expected exhausted duty cycle in serialized rotator, got %v", obj)
+	}
+
+	// half of the budget recovers within 10 seconds
+	run(l, f, time.Second*9, &now)
+	if err := l.SetAzimuth(200); err != ErrExhausted {
+		t.Fatalf("expected ErrExhausted, got %v", err)
+	}
+	run(l, f, time.Second, &now)
+	if last := (*statuses)[len(*statuses)-1]; last.Code != Recovered {
+		t.Fatalf("expected recovered status, got %v", last)
+	}
+	if err := l.SetAzimuth(200); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestQueue(t *testing.T) {
+	f := rotatortest.New(azimuthOnly)
+	l, _ := newTestLimiter(t, f, Queue(true))
+	now := l.lastCheck
+
+	l.SetAzimuth(300)
+	run(l, f, time.Second*10, &now)
+
+	if err := l.SetAzimuth(50); err != nil {
+		t.Fatalf("expected move to be queued, got %v", err)
+	}
+	if l.AzPreset() != 50 || !l.Serialize().DutyCycle.Queued {
+		t.Fatalf("expected queued preset 50, got %d", l.AzPreset())
+	}
+	if f.AzPreset() == 50 {
+		t.Fatal("queued move must not be sent to the rotator")
+	}
+
+	run(l, f, time.Second*10, &now)
+	if f.AzPreset() != 50 {
+		t.Fatalf("expected queued move to be sent, got preset %d", f.AzPreset())
+	}
+}
+
+func TestStopDiscardsQueue(t *testing.T) {
+	f := rotatortest.New(azimuthOnly)
+	l, _ := newTestLimiter(t, f, Queue(true))
+	now := l.lastCheck
+
+	l.SetAzimuth(300)
+	run(l, f, time.Second*10, &now)
+	l.SetAzimuth(50)
+	l.Stop()
+
+	run(l, f, time.Second*10, &now)
+	if f.AzPreset() == 50 {
+		t.Fatal("expected queued move to be discarded")
+	}
+}
+
+func TestRebaseline(t *testing.T) {
+	f := rotatortest.New(azimuthOnly, rotatortest.Instant(), rotatortest.Heading(100, 0))
+	l, _ := newTestLimiter(t, f)
+	now := l.lastCheck
+
+	// the heading jumps (e.g. a new calibration) without a movement
+	f.SetAzimuth(107)
+	l.Rebaseline()
+
+	now = now.Add(time.Second)
+	l.account(f.Serialize(), now)
+	if l.used != 0 {
+		t.Fatalf("expected the jump not to be accounted, used %v", l.used)
+	}
+}
@@ -0,0 +1,218 @@
+// Package framing splits the byte stream of line based (ASCII) rotator
+// protocols into frames. It handles CR, LF and CRLF terminated lines,
+// frames which arrive in fragments and prompts (e.g. "?>") which are
+// not terminated at all. Responses can be correlated with the commands
+// which triggered them.
+package framing
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"time"
+)
+
+// Frame is a single message received from the rotator.
+type Frame struct {
+	Data    string        // content of the frame without terminator
+	Prompt  bool          // the frame is a prompt (e.g. "?>")
+	Command string        // command which triggered this frame; empty if unsolicited
+	Latency time.Duration // time between the command and this frame
+}
+
+type pendingCmd struct {
+	cmd string
+	ts  time.Time
+}
+
+// Reader reads frames from an underlying io.Reader (e.g. a serial port).
+// The Reader keeps its state between calls, so that no data is lost
+// when several frames arrive at once or a frame arrives in fragments.
+// ReadFrame must only be called from one go routine at a time; Expect
+// can be called concurrently.
+type Reader struct {
+	sync.Mutex // protects pending
+	rd         io.Reader
+	buf        []byte
+	chunk      []byte
+	skipLF     bool // the last frame was terminated by CR; skip a following LF
+	prompts    [][]byte
+	maxLen     int
+	timeout    time.Duration
+	pending    []pendingCmd
+}
+
+// NewReader returns a Reader which reads from rd. Configuration settings
+// can be set through functional options.
+// Default settings are:
+// prompts: "?>",
+// maxFrameLength: 256 bytes,
+// responseTimeout: 2sec.
+func NewReader(rd io.Reader, opts ...func(*Reader)) *Reader {
+
+	r := &Reader{
+		rd:      rd,
+		chunk:   make([]byte, 256),
+		prompts: [][]byte{[]byte("?>")},
+		maxLen:  256,
+		timeout: time.Second * 2,
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+// Prompts is a functional option to set the prompts which are sent by
+// the device without a line terminator. Calling Prompts without
+// arguments disables the prompt detection.
+func Prompts(prompts ...string) func(*Reader) {
+	return func(r *Reader) {
+		r.prompts = nil
+		for _, p := range prompts {
+			r.prompts = append(r.prompts, []byte(p))
+		}
+	}
+}
+
+// MaxFrameLength is a functional option to set the maximum length of a
+// frame. Data exceeding this length without a terminator is returned
+// as a frame, so that the buffer can't grow without limit.
+func MaxFrameLength(n int) func(*Reader) {
+	return func(r *Reader) {
+		r.maxLen = n
+	}
+}
+
+// ResponseTimeout is a functional option to set the time after which a
+// command which is still waiting for its response is discarded.
+func ResponseTimeout(d time.Duration) func(*Reader) {
+	return func(r *Reader) {
+		r.timeout = d
+	}
+}
+
+// Expect registers a command which has been sent to the device and
+// expects a response. The next frame will be correlated with the
+// oldest command which is still waiting for its response.
+func (r *Reader) Expect(cmd string) {
+	r.Lock()
+	defer r.Unlock()
+	r.pending = append(r.pending, pendingCmd{cmd, time.Now()})
+}
+
+// ReadFrame returns the next frame. It blocks until a complete frame
+// has been received or the underlying reader returns an error (e.g.
+// io.EOF on a read timeout). Incomplete frames are kept until the
+// next call.
+func (r *Reader) ReadFrame() (Frame, error) {
+
+	emptyReads := 0
+
+	for {
+		if f, ok := r.extract(); ok {
+			r.correlate(&f)
+			return f, nil
+		}
+
+		n, err := r.rd.Read(r.chunk)
+		if n > len(r.chunk) {
+			n = len(r.chunk)
+		}
+		r.append(r.chunk[:n])
+
+		if err != nil {
+			return Frame{}, err
+		}
+
+		if n == 0 {
+			emptyReads++
+			if emptyReads >= 100 {
+				return Frame{}, io.ErrNoProgress
+			}
+		}
+	}
+}
+
+// append adds the received data to the buffer.
+func (r *Reader) append(data []byte) {
+	if len(data) == 0 {
+		return
+	}
+
+	// a LF following a CR terminated frame belongs to that frame
+	if r.skipLF {
+		r.skipLF = false
+		if data[0] == '\n' {
+			data = data[1:]
+		}
+	}
+
+	r.buf = append(r.buf, data...)
+}
+
+// extract takes the next complete frame from the buffer.
+func (r *Reader) extract() (Frame, bool) {
+
+	for len(r.buf) > 0 {
+
+		for _, p := range r.prompts {
+			if bytes.HasPrefix(r.buf, p) {
+				r.buf = r.buf[len(p):]
+				return Frame{Data: string(p), Prompt: true}, true
+			}
+		}
+
+		i := bytes.IndexAny(r.buf, "\r\n")
+		if i < 0 {
+			if len(r.buf) >= r.maxLen {
+				f := Frame{Data: string(r.buf[:r.maxLen])}
+				r.buf = r.buf[r.maxLen:]
+				return f, true
+			}
+			return Frame{}, false
+		}
+
+		data := string(r.buf[:i])
+
+		// consume the terminator (CR, LF or CRLF)
+		end := i + 1
+		if r.buf[i] == '\r' {
+			if end < len(r.buf) && r.buf[end] == '\n' {
+				end++
+			} else if end == len(r.buf) {
+				r.skipLF = true
+			}
+		}
+		r.buf = r.buf[end:]
+
+		// skip empty lines
+		if len(data) == 0 {
+			continue
+		}
+
+		return Frame{Data: data}, true
+	}
+
+	return Frame{}, false
+}
+
+// correlate assigns the oldest pending command to the frame.
+func (r *Reader) correlate(f *Frame) {
+	r.Lock()
+	defer r.Unlock()
+
+	for len(r.pending) > 0 && time.Since(r.pending[0].ts) > r.timeout {
+		r.pending = r.pending[1:]
+	}
+
+	if len(r.pending) == 0 {
+		return
+	}
+
+	f.Command = r.pending[0].cmd
+	f.Latency = time.Since(r.pending[0].ts)
+	r.pending = r.pending[1:]
+}
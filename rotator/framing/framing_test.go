@@ -0,0 +1,121 @@
+package framing
+
+import (
+	"io"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// chunkReader returns one chunk per Read call and io.EOF (like a serial
+// port running into its read timeout) when no chunk is left.
+type chunkReader struct {
+	chunks []string
+}
+
+func (c *chunkReader) Read(b []byte) (int, error) {
+	if len(c.chunks) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(b, c.chunks[0])
+	c.chunks = c.chunks[1:]
+	return n, nil
+}
+
+// readAll reads frames until the chunks are consumed
+func readAll(r *Reader) []Frame {
+	frames := []Frame{}
+	for {
+		f, err := r.ReadFrame()
+		if err != nil {
+			return frames
+		}
+		frames = append(frames, f)
+	}
+}
+
+func TestReadFrame(t *testing.T) {
+
+	tt := []struct {
+		name   string
+		chunks []string
+		exp    []Frame
+	}{
+		{"CRLF", []string{"+0300+0150\r\n"}, []Frame{{Data: "+0300+0150"}}},
+		{"CR only", []string{"+0300\r+0310\r"}, []Frame{{Data: "+0300"}, {Data: "+0310"}}},
+		{"LF only", []string{"AZ=100\nAZ=110\n"}, []Frame{{Data: "AZ=100"}, {Data: "AZ=110"}}},
+		{"fragmented", []string{"+03", "00+0", "150\r\n"}, []Frame{{Data: "+0300+0150"}}},
+		{"CRLF split", []string{"+0300\r", "\n+0310\r\n"}, []Frame{{Data: "+0300"}, {Data: "+0310"}}},
+		{"multiple frames", []string{"+0300\r\n+0310\r\n+0320\r\n"},
+			[]Frame{{Data: "+0300"}, {Data: "+0310"}, {Data: "+0320"}}},
+		{"empty lines", []string{"\r\n\r\n+0300\r\n"}, []Frame{{Data: "+0300"}}},
+		{"prompt", []string{"?>"}, []Frame{{Data: "?>", Prompt: true}}},
+		{"prompt before frame", []string{"?>+0300\r\n"},
+			[]Frame{{Data: "?>", Prompt: true}, {Data: "+0300"}}},
+		{"incomplete frame", []string{"+0300\r\n+03"}, []Frame{{Data: "+0300"}}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			r := NewReader(&chunkReader{chunks: tc.chunks})
+			res := readAll(r)
+			if !reflect.DeepEqual(res, tc.exp) {
+				t.Fatalf("expected %v, got %v", tc.exp, res)
+			}
+		})
+	}
+}
+
+func TestPartialFrameSurvivesTimeout(t *testing.T) {
+	cr := &chunkReader{chunks: []string{"+03"}}
+	r := NewReader(cr)
+
+	if _, err := r.ReadFrame(); err != io.EOF {
+		t.Fatalf("expected io.EOF, got %v", err)
+	}
+
+	cr.chunks = []string{"00\r\n"}
+	f, err := r.ReadFrame()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Data != "+0300" {
+		t.Fatalf("expected +0300, got %s", f.Data)
+	}
+}
+
+func TestMaxFrameLength(t *testing.T) {
+	r := NewReader(&chunkReader{chunks: []string{"abcdefgh"}}, MaxFrameLength(4))
+
+	exp := []Frame{{Data: "abcd"}, {Data: "efgh"}}
+	if res := readAll(r); !reflect.DeepEqual(res, exp) {
+		t.Fatalf("expected %v, got %v", exp, res)
+	}
+}
+
+func TestCorrelation(t *testing.T) {
+	cr := &chunkReader{}
+	r := NewReader(cr, ResponseTimeout(time.Millisecond*50))
+
+	r.Expect("C2")
+	r.Expect("C")
+	cr.chunks = []string{"+0300+0150\r\n?>"}
+
+	res := readAll(r)
+	if len(res) != 2 {
+		t.Fatalf("expected 2 frames, got %d", len(res))
+	}
+	if res[0].Command != "C2" || res[1].Command != "C" || !res[1].Prompt {
+		t.Fatalf("unexpected correlation %v", res)
+	}
+
+	// expired commands are discarded
+	r.Expect("C2")
+	time.Sleep(time.Millisecond * 100)
+	cr.chunks = []string{"+0300+0150\r\n"}
+
+	res = readAll(r)
+	if len(res) != 1 || res[0].Command != "" {
+		t.Fatalf("expected uncorrelated frame, got %v", res)
+	}
+}
@@ -20,10 +20,34 @@ type ElevationPut struct {
 	Elevation *int `json:"elevation"`
 }
 
+type AntennaPut struct {
+	Bearing *int `json:"bearing"`
+}
+
 type Object struct {
-	Name    string  `json:"name"`
-	Heading Heading `json:"heading"`
-	Config  Config  `json:"config"`
+	Name      string     `json:"name"`
+	Heading   Heading    `json:"heading"`
+	Config    Config     `json:"config"`
+	Status    []Status   `json:"status,omitempty"`
+	DutyCycle *DutyCycle `json:"duty_cycle,omitempty"`
+	Antennas  []Antenna  `json:"antennas,omitempty"`
+}
+
+// Antenna is an antenna mounted on the mast of a rotator.
+type Antenna struct {
+	Name    string   `json:"name"`
+	Offset  int      `json:"offset"` // direction relative to the rotator's azimuth
+	Bands   []string `json:"bands,omitempty"`
+	Bearing int      `json:"bearing"` // direction into which the antenna points
+	Preset  int      `json:"preset"`  // direction into which the antenna will point
+}
+
+// DutyCycle is the motor-on time budget of a rotator (in seconds).
+type DutyCycle struct {
+	Budget    float64 `json:"budget"`
+	Remaining float64 `json:"remaining"`
+	Exhausted bool    `json:"exhausted"`
+	Queued    bool    `json:"queued"`
 }
 
 type Heading struct {
@@ -36,11 +60,12 @@ type Heading struct {
 type Objects map[string]Object
 
 type Config struct {
-	HasAzimuth   bool `json:"has_azimuth"`
-	AzimuthMin   int  `json:"azimuth_min"`
-	AzimuthMax   int  `json:"azimuth_max"`
-	AzimuthStop  int  `json:"azimuth_stop"`
-	HasElevation bool `json:"has_elevation"`
-	ElevationMin int  `json:"elevation_min"`
-	ElevationMax int  `json:"elevation_max"`
+	HasAzimuth     bool `json:"has_azimuth"`
+	AzimuthMin     int  `json:"azimuth_min"`
+	AzimuthMax     int  `json:"azimuth_max"`
+	AzimuthStop    int  `json:"azimuth_stop"`
+	AzimuthOverlap bool `json:"azimuth_overlap"`
+	HasElevation   bool `json:"has_elevation"`
+	ElevationMin   int  `json:"elevation_min"`
+	ElevationMax   int  `json:"elevation_max"`
 }
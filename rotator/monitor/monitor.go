@@ -0,0 +1,431 @@
+// Package monitor provides a safety monitor which can be put in front
+// of any rotator.Rotator. The monitor watches the reported headings and
+// detects stalls (the rotator is commanded to move but the heading
+// doesn't change), runaways (the heading changes without a command, e.g.
+// due to a brake failure) and headings outside of the configured limits.
+// When a fault is detected, the rotator is stopped and all further moves
+// are refused until the fault has been acknowledged by an operator.
+package monitor
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/dh1tw/remoteRotator/rotator"
+)
+
+// Fault codes raised by the monitor
+const (
+	Stall   = "stall"
+	Runaway = "runaway"
+	Limit   = "limit"
+	Cleared = "cleared"
+)
+
+// source is the name under which the monitor reports its status
+const source = "monitor"
+
+// ErrLocked is returned for moves while the monitor is locked out
+// after a fault.
+var ErrLocked = errors.New("rotator locked after fault; acknowledge to continue")
+
+// Monitor is a safety monitor which wraps a rotator.Rotator. It
+// implements the rotator.Rotator interface itself.
+type Monitor struct {
+	rotator.Rotator
+	sync.Mutex
+	statusHandler    rotator.StatusHandler
+	interval         time.Duration
+	stallTimeout     time.Duration
+	settleTime       time.Duration
+	tolerance        int
+	runawayTolerance int
+	az               tracker
+	el               tracker
+	fault            *rotator.Status
+	closeCh          chan struct{}
+	closer           sync.Once
+}
+
+// New returns a Monitor for the rotator. Configuration settings can
+// be set through functional options.
+// Default settings are:
+// interval: 500ms,
+// stallTimeout: 10s,
+// settleTime: 5s,
+// tolerance: 2, (deg)
+// runawayTolerance: 5. (deg)
+func New(r rotator.Rotator, opts ...func(*Monitor)) (*Monitor, error) {
+
+	m := &Monitor{
+		Rotator:          r,
+		interval:         time.Millisecond * 500,
+		stallTimeout:     time.Second * 10,
+		settleTime:       time.Second * 5,
+		tolerance:        2,
+		runawayTolerance: 5,
+		closeCh:          make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	if m.interval <= 0 {
+		return nil, fmt.Errorf("invalid monitor interval %v", m.interval)
+	}
+
+	m.baseline(r.Serialize(), time.Now())
+
+	go m.start()
+
+	return m, nil
+}
+
+// StatusHandler is a functional option to set the callback which is
+// executed when a fault is raised or cleared.
+func StatusHandler(h rotator.StatusHandler) func(*Monitor) {
+	return func(m *Monitor) {
+		m.statusHandler = h
+	}
+}
+
+// Interval is a functional option to set the interval in which the
+// headings of the rotator are checked.
+func Interval(d time.Duration) func(*Monitor) {
+	return func(m *Monitor) {
+		m.interval = d
+	}
+}
+
+// StallTimeout is a functional option to set the time after which a
+// commanded rotator which doesn't change its heading is considered
+// stalled.
+func StallTimeout(d time.Duration) func(*Monitor) {
+	return func(m *Monitor) {
+		m.stallTimeout = d
+	}
+}
+
+// SettleTime is a functional option to set the time the rotator may
+// coast after it has been stopped or has reached its preset.
+func SettleTime(d time.Duration) func(*Monitor) {
+	return func(m *Monitor) {
+		m.settleTime = d
+	}
+}
+
+// Tolerance is a functional option to set the deviation (in degrees)
+// from the preset and the limits which is still accepted.
+func Tolerance(deg int) func(*Monitor) {
+	return func(m *Monitor) {
+		m.tolerance = deg
+	}
+}
+
+// RunawayTolerance is a functional option to set the deviation (in
+// degrees) which an idle rotator may drift before a runaway is raised.
+func RunawayTolerance(deg int) func(*Monitor) {
+	return func(m *Monitor) {
+		m.runawayTolerance = deg
+	}
+}
+
+// InitialFault is a functional option to restore a fault which hasn't
+// been acknowledged before a restart. The monitor starts locked out.
+func InitialFault(f rotator.Status) func(*Monitor) {
+	return func(m *Monitor) {
+		f.Locked = true
+		m.fault = &f
+	}
+}
+
+// start the event loop which checks the headings of the rotator. Since
+// this function contains an endless loop, it should be executed in a
+// go routine.
+func (m *Monitor) start() {
+
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			m.check(m.Rotator.Serialize(), now)
+		case <-m.closeCh:
+			return
+		}
+	}
+}
+
+// check evaluates the heading of the rotator and raises a fault if
+// necessary.
+func (m *Monitor) check(obj rotator.Object, now time.Time) {
+	m.Lock()
+
+	if m.fault != nil {
+		m.Unlock()
+		return
+	}
+
+	fault := m.evaluate(obj, now)
+	if fault == nil {
+		m.Unlock()
+		return
+	}
+
+	m.fault = fault
+	m.az.stop(now)
+	m.el.stop(now)
+	m.Unlock()
+
+	log.Printf("%s: %s; stopping rotator\n", obj.Name, fault.Message)
+
+	if err := m.Rotator.Stop(); err != nil {
+		log.Printf("unable to stop rotator %s: %v\n", obj.Name, err)
+	}
+
+	m.emit(*fault)
+}
+
+// evaluate returns a fault status if the heading of the rotator
+// indicates a malfunction.
+func (m *Monitor) evaluate(obj rotator.Object, now time.Time) *rotator.Status {
+
+	cfg := obj.Config
+	h := obj.Heading
+
+	if cfg.HasAzimuth {
+		if !azWithinLimits(h.Azimuth, cfg, m.tolerance) {
+			return m.newFault(Limit, "azimuth %d° outside of the limits (%d° ... %d°)",
+				h.Azimuth, cfg.AzimuthMin, cfg.AzimuthMax)
+		}
+		if f := m.evaluateAxis(&m.az, "azimuth", h.Azimuth, h.AzPreset, rotator.AzDiff, now); f != nil {
+			return f
+		}
+	}
+
+	if cfg.HasElevation {
+		if h.Elevation < cfg.ElevationMin-m.tolerance || h.Elevation > cfg.ElevationMax+m.tolerance {
+			return m.newFault(Limit, "elevation %d° outside of the limits (%d° ... %d°)",
+				h.Elevation, cfg.ElevationMin, cfg.ElevationMax)
+		}
+		if f := m.evaluateAxis(&m.el, "elevation", h.Elevation, h.ElPreset, rotator.ElDiff, now); f != nil {
+			return f
+		}
+	}
+
+	return nil
+}
+
+// evaluateAxis updates the tracker of an axis with the latest heading
+// and checks for stalls and runaways.
+func (m *Monitor) evaluateAxis(t *tracker, axis string, pos, preset int,
+	diff func(a, b int) int, now time.Time) *rotator.Status {
+
+	if t.commanded {
+		if diff(pos, preset) <= m.tolerance {
+			t.stop(now)
+			t.ref = pos
+			return nil
+		}
+		if pos != t.lastPos {
+			t.lastPos = pos
+			t.lastChange = now
+			return nil
+		}
+		if now.Sub(t.lastChange) > m.stallTimeout {
+			return m.newFault(Stall, "%s stalled at %d° (preset %d°)", axis, pos, preset)
+		}
+		return nil
+	}
+
+	t.lastPos = pos
+
+	// the rotator may still coast after a stop
+	if now.Sub(t.idleSince) < m.settleTime {
+		t.ref = pos
+		return nil
+	}
+
+	if d := diff(pos, t.ref); d > m.runawayTolerance {
+		return m.newFault(Runaway, "%s moved from %d° to %d° without command", axis, t.ref, pos)
+	}
+
+	return nil
+}
+
+func (m *Monitor) newFault(code, format string, args ...interface{}) *rotator.Status {
+	return &rotator.Status{
+		Source:  source,
+		Level:   rotator.StatusFault,
+		Code:    code,
+		Message: fmt.Sprintf(format, args...),
+		Locked:  true,
+	}
+}
+
+// baseline resets the trackers to the current heading of the rotator.
+func (m *Monitor) baseline(obj rotator.Object, now time.Time) {
+	m.az = tracker{lastPos: obj.Heading.Azimuth, ref: obj.Heading.Azimuth, idleSince: now}
+	m.el = tracker{lastPos: obj.Heading.Elevation, ref: obj.Heading.Elevation, idleSince: now}
+}
+
+// emit reports a status through the status handler.
+func (m *Monitor) emit(s rotator.Status) {
+	if m.statusHandler != nil {
+		m.statusHandler(m, s)
+	}
+}
+
+// Acknowledge clears the fault and releases the lock out. Acknowledging
+// a monitor without fault is a no-op.
+func (m *Monitor) Acknowledge() error {
+	obj := m.Rotator.Serialize()
+
+	m.Lock()
+	if m.fault == nil {
+		m.Unlock()
+		return nil
+	}
+	fault := m.fault
+	m.fault = nil
+	m.baseline(obj, time.Now())
+	m.Unlock()
+
+	log.Printf("%s: %s fault acknowledged\n", obj.Name, fault.Code)
+
+	m.emit(rotator.Status{
+		Source:  source,
+		Level:   rotator.StatusInfo,
+		Code:    Cleared,
+		Message: fmt.Sprintf("%s fault acknowledged", fault.Code),
+	})
+
+	return nil
+}
+
+// Rebaseline accepts the current heading of the rotator, e.g. after the
+// calibration has changed, without raising a runaway.
+func (m *Monitor) Rebaseline() {
+	obj := m.Rotator.Serialize()
+
+	m.Lock()
+	defer m.Unlock()
+	now := time.Now()
+	m.az.rebaseline(obj.Heading.Azimuth, now)
+	m.el.rebaseline(obj.Heading.Elevation, now)
+}
+
+// Fault returns the active fault or nil if there is none.
+func (m *Monitor) Fault() *rotator.Status {
+	m.Lock()
+	defer m.Unlock()
+	if m.fault == nil {
+		return nil
+	}
+	f := *m.fault
+	return &f
+}
+
+// CanMove returns ErrLocked while the monitor is locked out.
+func (m *Monitor) CanMove() error {
+	m.Lock()
+	defer m.Unlock()
+	if m.fault != nil {
+		return ErrLocked
+	}
+	return nil
+}
+
+// SetAzimuth forwards the azimuth to the rotator unless the monitor is
+// locked out.
+func (m *Monitor) SetAzimuth(az int) error {
+	m.Lock()
+	defer m.Unlock()
+
+	if m.fault != nil {
+		return ErrLocked
+	}
+
+	if err := m.Rotator.SetAzimuth(az); err != nil {
+		return err
+	}
+	m.az.command(time.Now())
+	return nil
+}
+
+// SetElevation forwards the elevation to the rotator unless the monitor
+// is locked out.
+func (m *Monitor) SetElevation(el int) error {
+	m.Lock()
+	defer m.Unlock()
+
+	if m.fault != nil {
+		return ErrLocked
+	}
+
+	if err := m.Rotator.SetElevation(el); err != nil {
+		return err
+	}
+	m.el.command(time.Now())
+	return nil
+}
+
+// StopAzimuth stops the horizontal movement. Stop commands are always
+// forwarded.
+func (m *Monitor) StopAzimuth() error {
+	m.Lock()
+	m.az.stop(time.Now())
+	m.Unlock()
+	return m.Rotator.StopAzimuth()
+}
+
+// StopElevation stops the vertical movement. Stop commands are always
+// forwarded.
+func (m *Monitor) StopElevation() error {
+	m.Lock()
+	m.el.stop(time.Now())
+	m.Unlock()
+	return m.Rotator.StopElevation()
+}
+
+// Stop stops all movement. Stop commands are always forwarded.
+func (m *Monitor) Stop() error {
+	m.Lock()
+	now := time.Now()
+	m.az.stop(now)
+	m.el.stop(now)
+	m.Unlock()
+	return m.Rotator.Stop()
+}
+
+// Serialize the data of the rotator. An active fault is added to the
+// status of the rotator.
+func (m *Monitor) Serialize() rotator.Object {
+	obj := m.Rotator.Serialize()
+
+	m.Lock()
+	defer m.Unlock()
+	if m.fault != nil {
+		obj.Status = append(obj.Status, *m.fault)
+	}
+
+	return obj
+}
+
+// Unwrap returns the monitored rotator.
+func (m *Monitor) Unwrap() rotator.Rotator {
+	return m.Rotator
+}
+
+// Close shuts down the monitor and the rotator.
+func (m *Monitor) Close() {
+	m.closer.Do(func() {
+		close(m.closeCh)
+	})
+	m.Rotator.Close()
+}
@@ -0,0 +1,207 @@
+package monitor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dh1tw/remoteRotator/rotator"
+	"github.com/dh1tw/remoteRotator/rotator/rotatortest"
+)
+
+var fullCircle = rotator.Config{HasAzimuth: true, AzimuthMax: 360, ElevationMax: 180}
+
+// newTestMonitor returns a Monitor whose checks are triggered by the test
+func newTestMonitor(t *testing.T, f *rotatortest.Rotator) (*Monitor, *[]rotator.Status) {
+	statuses := []rotator.Status{}
+	m, err := New(f,
+		Interval(time.Hour),
+		StallTimeout(time.Second*5),
+		SettleTime(time.Second*2),
+		StatusHandler(func(r rotator.Rotator, s rotator.Status) {
+			statuses = append(statuses, s)
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(m.Close)
+	return m, &statuses
+}
+
+func TestStall(t *testing.T) {
+	f := rotatortest.New(fullCircle)
+	m, statuses := newTestMonitor(t, f)
+	now := time.Now()
+
+	if err := m.SetAzimuth(90); err != nil {
+		t.Fatal(err)
+	}
+
+	// moving
+	for i := 1; i <= 4; i++ {
+		f.SetHeading(i*5, 0)
+		m.check(f.Serialize(), now.Add(time.Duration(i)*time.Second))
+	}
+	if m.Fault() != nil {
+		t.Fatalf("unexpected fault: %v", m.Fault())
+	}
+
+	// stuck at 20°
+	m.check(f.Serialize(), now.Add(time.Second*10))
+	fault := m.Fault()
+	if fault == nil || fault.Code != Stall {
+		t.Fatalf("expected stall, got %v", fault)
+	}
+	if f.Stops() != 1 {
+		t.Fatalf("expected rotator to be stopped")
+	}
+	if len(*statuses) != 1 || !(*statuses)[0].Locked {
+		t.Fatalf("expected locked fault status, got %v", *statuses)
+	}
+	if obj := m.Serialize(); len(obj.Status) != 1 {
+		t.Fatalf("expected fault in serialized rotator, got %v", obj.Status)
+	}
+
+	// locked out until acknowledged
+	if err := m.SetAzimuth(30); err != ErrLocked {
+		t.Fatalf("expected ErrLocked, got %v", err)
+	}
+	if err := m.Stop(); err != nil {
+		t.Fatalf("stop must be possible while locked: %v", err)
+	}
+
+	if err := m.Acknowledge(); err != nil {
+		t.Fatal(err)
+	}
+	if m.Fault() != nil {
+		t.Fatal("expected fault to be cleared")
+	}
+	if len(*statuses) != 2 || (*statuses)[1].Code != Cleared {
+		t.Fatalf("expected cleared status, got %v", *statuses)
+	}
+	if err := m.SetAzimuth(30); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestArrival(t *testing.T) {
+	f := rotatortest.New(fullCircle)
+	m, _ := newTestMonitor(t, f)
+	now := time.Now()
+
+	m.SetAzimuth(359)
+	f.SetHeading(358, 0)
+	m.check(f.Serialize(), now.Add(time.Second))
+
+	// the rotator has arrived and is not expected to move anymore
+	m.check(f.Serialize(), now.Add(time.Minute))
+	if m.Fault() != nil {
+		t.Fatalf("unexpected fault: %v", m.Fault())
+	}
+}
+
+func TestRunaway(t *testing.T) {
+	f := rotatortest.New(fullCircle)
+	m, _ := newTestMonitor(t, f)
+	now := time.Now()
+
+	m.Stop()
+
+	// coasting after stop is tolerated
+	f.SetHeading(10, 0)
+	m.check(f.Serialize(), now.Add(time.Second))
+	m.check(f.Serialize(), now.Add(time.Second*3))
+	if m.Fault() != nil {
+		t.Fatalf("unexpected fault: %v", m.Fault())
+	}
+
+	// small deviations (e.g. sensor noise) are tolerated
+	f.SetHeading(7, 0)
+	m.check(f.Serialize(), now.Add(time.Second*4))
+	if m.Fault() != nil {
+		t.Fatalf("unexpected fault: %v", m.Fault())
+	}
+
+	f.SetHeading(350, 0)
+	m.check(f.Serialize(), now.Add(time.Second*5))
+	if fault := m.Fault(); fault == nil || fault.Code != Runaway {
+		t.Fatalf("expected runaway, got %v", fault)
+	}
+}
+
+func TestLimits(t *testing.T) {
+
+	tt := []struct {
+		name     string
+		cfg      rotator.Config
+		az, el   int
+		expFault bool
+	}{
+		{"full circle", fullCircle, 200, 0, false},
+		{"within limited range", rotator.Config{HasAzimuth: true, AzimuthMin: 300, AzimuthMax: 60}, 10, 0, false},
+		{"tolerance", rotator.Config{HasAzimuth: true, AzimuthMin: 300, AzimuthMax: 60}, 62, 0, false},
+		{"outside limited range", rotator.Config{HasAzimuth: true, AzimuthMin: 300, AzimuthMax: 60}, 180, 0, true},
+		{"elevation", rotator.Config{HasElevation: true, ElevationMax: 90}, 0, 100, true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			f := rotatortest.New(tc.cfg)
+			m, _ := newTestMonitor(t, f)
+
+			f.SetHeading(tc.az, tc.el)
+			m.check(f.Serialize(), time.Now())
+
+			fault := m.Fault()
+			if tc.expFault && (fault == nil || fault.Code != Limit) {
+				t.Fatalf("expected limit fault, got %v", fault)
+			}
+			if !tc.expFault && fault != nil {
+				t.Fatalf("unexpected fault: %v", fault)
+			}
+		})
+	}
+}
+
+func TestLayers(t *testing.T) {
+	f := rotatortest.New(fullCircle)
+	m, _ := newTestMonitor(t, f)
+
+	if _, ok := rotator.As[*Monitor](m); !ok {
+		t.Fatal("expected to find the monitor")
+	}
+
+	ok, err := rotator.Acknowledge(m)
+	if !ok || err != nil {
+		t.Fatalf("expected monitor to be acknowledged (%v, %v)", ok, err)
+	}
+
+	if ok, _ := rotator.Acknowledge(f); ok {
+		t.Fatal("expected fake rotator not to support acknowledge")
+	}
+}
+
+func TestInitialFault(t *testing.T) {
+	f := rotatortest.New(fullCircle)
+	m, err := New(f, Interval(time.Hour), InitialFault(rotator.Status{
+		Source: source,
+		Level:  rotator.StatusFault,
+		Code:   Stall,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	if fault := m.Fault(); fault == nil || fault.Code != Stall || !fault.Locked {
+		t.Fatalf("expected restored stall, got %v", fault)
+	}
+	if err := m.SetAzimuth(30); err != ErrLocked {
+		t.Fatalf("expected ErrLocked, got %v", err)
+	}
+	if err := m.Acknowledge(); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetAzimuth(30); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,58 @@
+package monitor
+
+import (
+	"time"
+
+	"github.com/dh1tw/remoteRotator/rotator"
+)
+
+// tracker keeps track of the motion of a single axis.
+type tracker struct {
+	commanded  bool      // a move has been commanded and is in progress
+	lastPos    int       // heading at the last check
+	lastChange time.Time // time of the last heading change while commanded
+	ref        int       // heading of the idle rotator
+	idleSince  time.Time // time when the rotator was stopped / arrived
+}
+
+// command marks the start of a commanded move.
+func (t *tracker) command(now time.Time) {
+	t.commanded = true
+	t.lastChange = now
+}
+
+// stop marks the end of a commanded move. The rotator may coast until
+// the settle time has passed.
+func (t *tracker) stop(now time.Time) {
+	t.commanded = false
+	t.idleSince = now
+}
+
+// rebaseline accepts the heading as the new position of the axis. An
+// idle axis restarts its settle time, so that a heading sampled before
+// the jump isn't mistaken for a runaway.
+func (t *tracker) rebaseline(pos int, now time.Time) {
+	t.lastPos = pos
+	t.ref = pos
+	if t.commanded {
+		t.lastChange = now
+	} else {
+		t.idleSince = now
+	}
+}
+
+// azWithinLimits returns true if the azimuth is within the range of the
+// rotator. Only rotators which don't cover the full circle can violate
+// their limits.
+func azWithinLimits(az int, cfg rotator.Config, tolerance int) bool {
+	span := rotator.NormAzimuth(cfg.AzimuthMax - cfg.AzimuthMin)
+	if cfg.AzimuthMax-cfg.AzimuthMin >= 360 || span == 0 {
+		return true
+	}
+	offset := rotator.NormAzimuth(az - cfg.AzimuthMin)
+	if offset <= span {
+		return true
+	}
+	// distance to the closer end of the range
+	return offset-span <= tolerance || 360-offset <= tolerance
+}
@@ -0,0 +1,269 @@
+// Package move turns a rotator to a heading and waits until it has
+// arrived. While the rotator is moving, the progress is reported with
+// an estimate of the remaining time (ETA), based on the measured speed
+// of the rotator.
+package move
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/dh1tw/remoteRotator/rotator"
+)
+
+// Axes of a move
+const (
+	Azimuth   = "azimuth"
+	Elevation = "elevation"
+)
+
+// States of a move
+const (
+	StateMoving  = "moving"
+	StateArrived = "arrived"
+	StateTimeout = "timeout"
+	StateStalled = "stalled"
+	StateAborted = "aborted"
+)
+
+// Errors returned if the rotator doesn't arrive.
+var (
+	ErrTimeout = errors.New("rotator did not arrive in time")
+	ErrStalled = errors.New("rotator stalled")
+	ErrAborted = errors.New("move was stopped or superseded by another command")
+)
+
+// Progress is the state of a move.
+type Progress struct {
+	Axis      string   `json:"axis"`
+	Target    int      `json:"target"`
+	Position  int      `json:"position"`
+	Remaining int      `json:"remaining"`       // degrees
+	Speed     float64  `json:"speed"`           // measured speed (deg/s)
+	ETA       *float64 `json:"eta,omitempty"`   // estimated remaining time (s); nil: unknown
+	Elapsed   float64  `json:"elapsed"`         // time since the move was commanded (s)
+	State     string   `json:"state"`           // moving, arrived, timeout, stalled or aborted
+	Error     string   `json:"error,omitempty"` // reason why the rotator did not arrive
+}
+
+// Request is the request of a move through an RPC. The timeout (e.g.
+// "60s") and the tolerance (degrees) are optional.
+type Request struct {
+	Heading   int    `json:"heading"`
+	Timeout   string `json:"timeout,omitempty"`
+	Tolerance *int   `json:"tolerance,omitempty"`
+}
+
+// Mover executes moves. The zero value is not usable; use New.
+type Mover struct {
+	tolerance    int
+	timeout      time.Duration
+	stallTimeout time.Duration
+	interval     time.Duration
+	progress     func(Progress)
+}
+
+// New returns an initialized Mover. Configuration settings can be set
+// through functional options.
+// Default settings are:
+// tolerance: 2°,
+// timeout: 60s,
+// stall timeout: 10s,
+// interval: 250ms,
+// progress: none.
+func New(opts ...func(*Mover)) *Mover {
+
+	m := &Mover{
+		tolerance:    2,
+		timeout:      time.Second * 60,
+		stallTimeout: time.Second * 10,
+		interval:     time.Millisecond * 250,
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	return m
+}
+
+// Tolerance is a functional option to set the deviation (in degrees)
+// from the target within which the rotator has arrived.
+func Tolerance(deg int) func(*Mover) {
+	return func(m *Mover) {
+		m.tolerance = deg
+	}
+}
+
+// Timeout is a functional option to set the maximum time to wait for
+// the arrival of the rotator.
+func Timeout(d time.Duration) func(*Mover) {
+	return func(m *Mover) {
+		m.timeout = d
+	}
+}
+
+// StallTimeout is a functional option to set the time after which a
+// rotator which doesn't change its heading is considered stalled.
+func StallTimeout(d time.Duration) func(*Mover) {
+	return func(m *Mover) {
+		m.stallTimeout = d
+	}
+}
+
+// Interval is a functional option to set the interval in which the
+// heading of the rotator is checked and the progress is reported.
+func Interval(d time.Duration) func(*Mover) {
+	return func(m *Mover) {
+		m.interval = d
+	}
+}
+
+// OnProgress is a functional option to set a callback which is executed
+// with the progress of the move in every interval.
+func OnProgress(f func(Progress)) func(*Mover) {
+	return func(m *Mover) {
+		m.progress = f
+	}
+}
+
+// Azimuth turns the rotator to the azimuth and waits until it has
+// arrived. The final progress is returned; if the rotator didn't
+// arrive, the error describes why. The rotator is not stopped on a
+// timeout or stall.
+func (m *Mover) Azimuth(ctx context.Context, r rotator.Rotator, az int) (Progress, error) {
+	if !r.HasAzimuth() {
+		return Progress{}, fmt.Errorf("rotator does not support azimuth")
+	}
+	if err := r.SetAzimuth(az); err != nil {
+		return Progress{}, err
+	}
+	return m.wait(ctx, axis{
+		name:     Azimuth,
+		target:   az,
+		position: r.Azimuth,
+		preset:   r.AzPreset,
+		distance: rotator.AzDiff,
+	})
+}
+
+// Elevation turns the rotator to the elevation and waits until it has
+// arrived (see Azimuth).
+func (m *Mover) Elevation(ctx context.Context, r rotator.Rotator, el int) (Progress, error) {
+	if !r.HasElevation() {
+		return Progress{}, fmt.Errorf("rotator does not support elevation")
+	}
+	if err := r.SetElevation(el); err != nil {
+		return Progress{}, err
+	}
+	return m.wait(ctx, axis{
+		name:     Elevation,
+		target:   el,
+		position: r.Elevation,
+		preset:   r.ElPreset,
+		distance: rotator.ElDiff,
+	})
+}
+
+// axis abstracts the axis of the rotator which is moved.
+type axis struct {
+	name     string
+	target   int
+	position func() int
+	preset   func() int
+	distance func(a, b int) int
+}
+
+// wait checks the heading of the rotator in every interval until it has
+// arrived, stalled, the move has been aborted or the time is up.
+func (m *Mover) wait(ctx context.Context, a axis) (Progress, error) {
+
+	start := time.Now()
+	deadline := time.NewTimer(m.timeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	p := Progress{Axis: a.name, Target: a.target, State: StateMoving}
+
+	lastPos := a.position()
+	lastChange := start
+	presetSeen := false // the rotator has accepted the target
+
+	for {
+		now := time.Now()
+		pos := a.position()
+
+		if d := a.distance(pos, lastPos); d > 0 {
+			// the speed is smoothed, since the heading is only
+			// reported in steps of 1°
+			speed := float64(d) / now.Sub(lastChange).Seconds()
+			if p.Speed == 0 {
+				p.Speed = speed
+			} else {
+				p.Speed = 0.5*p.Speed + 0.5*speed
+			}
+			lastPos = pos
+			lastChange = now
+		}
+
+		p.Position = pos
+		p.Remaining = a.distance(pos, a.target)
+		p.Elapsed = now.Sub(start).Seconds()
+		p.ETA = nil
+		if p.Speed > 0 {
+			eta := math.Round(float64(p.Remaining)/p.Speed*10) / 10
+			p.ETA = &eta
+		}
+
+		// proxies report the new preset with a delay; the move is only
+		// considered aborted once the preset has been seen
+		presetOk := a.distance(a.preset(), a.target) <= m.tolerance
+		presetSeen = presetSeen || presetOk
+
+		var err error
+		switch {
+		case p.Remaining <= m.tolerance:
+			p.State = StateArrived
+			zero := 0.0
+			p.ETA = &zero
+		case presetSeen && !presetOk:
+			p.State, err = StateAborted, ErrAborted
+		case now.Sub(lastChange) >= m.stallTimeout:
+			p.State, err = StateStalled, ErrStalled
+		}
+
+		if p.State != StateMoving {
+			if err != nil {
+				p.Error = err.Error()
+			}
+			m.report(p)
+			return p, err
+		}
+
+		m.report(p)
+
+		select {
+		case <-ctx.Done():
+			p.State = StateAborted
+			p.Error = ctx.Err().Error()
+			m.report(p)
+			return p, ctx.Err()
+		case <-deadline.C:
+			p.State = StateTimeout
+			p.Error = ErrTimeout.Error()
+			m.report(p)
+			return p, ErrTimeout
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *Mover) report(p Progress) {
+	if m.progress != nil {
+		m.progress(p)
+	}
+}
@@ -0,0 +1,94 @@
+package move
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dh1tw/remoteRotator/rotator/dummy"
+)
+
+func TestMove(t *testing.T) {
+
+	tt := []struct {
+		name   string
+		dummy  []func(*dummy.Dummy)
+		opts   []func(*Mover)
+		stopAt int // stop the rotator at this azimuth; 0: don't stop
+		state  string
+		err    error
+	}{
+		{"arrived", []func(*dummy.Dummy){dummy.AzimuthSpeed(100)}, nil, 0, StateArrived, nil},
+		{"timeout", []func(*dummy.Dummy){dummy.AzimuthSpeed(10)},
+			[]func(*Mover){Timeout(time.Millisecond * 200)}, 0, StateTimeout, ErrTimeout},
+		{"stalled", []func(*dummy.Dummy){dummy.AzimuthSpeed(100), dummy.StartDelay(time.Second)},
+			[]func(*Mover){StallTimeout(time.Millisecond * 100)}, 0, StateStalled, ErrStalled},
+		{"aborted", []func(*dummy.Dummy){dummy.AzimuthSpeed(100)}, nil, 20, StateAborted, ErrAborted},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			d, _ := dummy.New(tc.dummy...)
+			defer d.Close()
+
+			progress := []Progress{}
+			opts := append([]func(*Mover){
+				Interval(time.Millisecond * 10),
+				OnProgress(func(p Progress) {
+					progress = append(progress, p)
+					if tc.stopAt > 0 && p.Position >= tc.stopAt {
+						d.Stop()
+					}
+				}),
+			}, tc.opts...)
+
+			p, err := New(opts...).Azimuth(context.Background(), d, 90)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("expected error %v, got %v", tc.err, err)
+			}
+			if p.State != tc.state || p.Target != 90 || p.Axis != Azimuth {
+				t.Fatalf("unexpected progress %+v", p)
+			}
+			if len(progress) == 0 || progress[len(progress)-1].State != tc.state {
+				t.Fatalf("expected final progress to be reported, got %+v", progress)
+			}
+		})
+	}
+}
+
+func TestETA(t *testing.T) {
+	d, _ := dummy.New(dummy.AzimuthSpeed(50))
+	defer d.Close()
+
+	var eta *float64
+	m := New(Interval(time.Millisecond*20), OnProgress(func(p Progress) {
+		if eta == nil && p.ETA != nil && p.Remaining > 50 {
+			eta = p.ETA
+		}
+	}))
+
+	if _, err := m.Azimuth(context.Background(), d, 100); err != nil {
+		t.Fatal(err)
+	}
+	// the dummy accelerates, so the first estimates are too long
+	if eta == nil || *eta <= 0 || *eta > 10 {
+		t.Fatalf("unexpected ETA %v", eta)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	d, _ := dummy.New(dummy.AzimuthSpeed(1))
+	defer d.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+
+	if _, err := New().Elevation(ctx, d, 10); err == nil {
+		t.Fatal("expected rotator without elevation to be rejected")
+	}
+	p, err := New(Interval(time.Millisecond*10)).Azimuth(ctx, d, 90)
+	if !errors.Is(err, context.DeadlineExceeded) || p.State != StateAborted {
+		t.Fatalf("expected move to be cancelled, got %+v, %v", p, err)
+	}
+}
@@ -0,0 +1,38 @@
+package port
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// setFlowControl enables the flow control of the serial port. The
+// serial package doesn't support flow control, however the termios
+// settings belong to the tty device and can therefore be applied
+// through a second file descriptor.
+func setFlowControl(name string, flow FlowControl) error {
+
+	fd, err := unix.Open(name, unix.O_RDWR|unix.O_NOCTTY|unix.O_NONBLOCK, 0)
+	if err != nil {
+		return fmt.Errorf("unable to set flow control of %s: %v", name, err)
+	}
+	defer unix.Close(fd)
+
+	t, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	if err != nil {
+		return fmt.Errorf("unable to set flow control of %s: %v", name, err)
+	}
+
+	switch flow {
+	case FlowRTSCTS:
+		t.Cflag |= unix.CRTSCTS
+	case FlowXONXOFF:
+		t.Iflag |= unix.IXON | unix.IXOFF
+	}
+
+	if err := unix.IoctlSetTermios(fd, unix.TCSETS, t); err != nil {
+		return fmt.Errorf("unable to set flow control of %s: %v", name, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package port
+
+import "fmt"
+
+// setFlowControl is only supported on Linux for local serial ports.
+func setFlowControl(name string, flow FlowControl) error {
+	return fmt.Errorf("flow control %s is not supported for local serial ports on this platform", flow)
+}
@@ -0,0 +1,291 @@
+// Package port opens the connection to a rotator controller. The
+// connection is described by a URL, so that all drivers can be
+// connected locally through a serial port, through a raw TCP socket
+// or through a networked serial server supporting RFC 2217
+// (e.g. Moxa NPort or ser2net):
+//
+//	serial:///dev/ttyUSB0?baud=4800&parity=N&stop=1
+//	serial://COM3?baud=9600&flow=rtscts
+//	tcp://192.168.1.10:4001
+//	rfc2217://192.168.1.10:4001?baud=4800&parity=E&data=7
+//
+// For backwards compatibility, names without a scheme are treated as
+// a serial port, unless they are of the form host:port.
+package port
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Parity of the serial line
+type Parity byte
+
+// Supported parity settings
+const (
+	ParityNone  Parity = 'N'
+	ParityOdd   Parity = 'O'
+	ParityEven  Parity = 'E'
+	ParityMark  Parity = 'M'
+	ParitySpace Parity = 'S'
+)
+
+// StopBits of the serial line
+type StopBits byte
+
+// Supported stop bits
+const (
+	Stop1     StopBits = 1
+	Stop1Half StopBits = 15
+	Stop2     StopBits = 2
+)
+
+// FlowControl of the serial line
+type FlowControl string
+
+// Supported flow control settings
+const (
+	FlowNone    FlowControl = "none"
+	FlowRTSCTS  FlowControl = "rtscts"
+	FlowXONXOFF FlowControl = "xonxoff"
+)
+
+// Config contains the settings of a connection to a rotator.
+type Config struct {
+	Scheme      string // serial, tcp or rfc2217
+	Address     string // device name or host:port
+	Baudrate    int
+	DataBits    int
+	Parity      Parity
+	StopBits    StopBits
+	FlowControl FlowControl
+	ReadTimeout time.Duration
+}
+
+// Baudrate is a functional option to set the default baudrate. It can
+// be overridden through the "baud" parameter of the URL.
+func Baudrate(baudrate int) func(*Config) {
+	return func(c *Config) {
+		c.Baudrate = baudrate
+	}
+}
+
+// ReadTimeout is a functional option to set the time after which a read
+// returns io.EOF if no data has been received.
+func ReadTimeout(d time.Duration) func(*Config) {
+	return func(c *Config) {
+		c.ReadTimeout = d
+	}
+}
+
+// Parse parses the connection URL. Configuration defaults can be set
+// through functional options.
+// Default settings are:
+// baudrate: 9600,
+// dataBits: 8,
+// parity: N,
+// stopBits: 1,
+// flowControl: none,
+// readTimeout: 1sec.
+func Parse(rawURL string, opts ...func(*Config)) (Config, error) {
+
+	c := Config{
+		Scheme:      "serial",
+		Address:     rawURL,
+		Baudrate:    9600,
+		DataBits:    8,
+		Parity:      ParityNone,
+		StopBits:    Stop1,
+		FlowControl: FlowNone,
+		ReadTimeout: time.Second,
+	}
+
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	if !strings.Contains(rawURL, "://") {
+		if isHostPort(rawURL) {
+			c.Scheme = "tcp"
+		}
+		return c, nil
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return c, fmt.Errorf("invalid port url %s: %v", rawURL, err)
+	}
+
+	c.Scheme = strings.ToLower(u.Scheme)
+	c.Address = u.Host + u.Path
+
+	switch c.Scheme {
+	case "serial":
+		if c.Address == "" {
+			return c, fmt.Errorf("invalid port url %s: missing device name", rawURL)
+		}
+	case "tcp", "rfc2217":
+		if !isHostPort(c.Address) {
+			return c, fmt.Errorf("invalid port url %s: expected host:port", rawURL)
+		}
+	default:
+		return c, fmt.Errorf("invalid port url %s: unknown scheme %s", rawURL, u.Scheme)
+	}
+
+	if err := c.parseQuery(u.Query()); err != nil {
+		return c, fmt.Errorf("invalid port url %s: %v", rawURL, err)
+	}
+
+	return c, nil
+}
+
+// parseQuery applies the serial line settings of the URL parameters.
+func (c *Config) parseQuery(q url.Values) error {
+
+	for key, values := range q {
+		value := strings.ToLower(values[0])
+
+		switch key {
+		case "baud":
+			baud, err := strconv.Atoi(value)
+			if err != nil || baud <= 0 {
+				return fmt.Errorf("invalid baudrate %s", value)
+			}
+			c.Baudrate = baud
+		case "data":
+			data, err := strconv.Atoi(value)
+			if err != nil || data < 5 || data > 8 {
+				return fmt.Errorf("invalid data bits %s", value)
+			}
+			c.DataBits = data
+		case "parity":
+			switch value {
+			case "n", "none":
+				c.Parity = ParityNone
+			case "o", "odd":
+				c.Parity = ParityOdd
+			case "e", "even":
+				c.Parity = ParityEven
+			case "m", "mark":
+				c.Parity = ParityMark
+			case "s", "space":
+				c.Parity = ParitySpace
+			default:
+				return fmt.Errorf("invalid parity %s", value)
+			}
+		case "stop":
+			switch value {
+			case "1":
+				c.StopBits = Stop1
+			case "1.5":
+				c.StopBits = Stop1Half
+			case "2":
+				c.StopBits = Stop2
+			default:
+				return fmt.Errorf("invalid stop bits %s", value)
+			}
+		case "flow":
+			switch FlowControl(value) {
+			case FlowNone, FlowRTSCTS, FlowXONXOFF:
+				c.FlowControl = FlowControl(value)
+			default:
+				return fmt.Errorf("invalid flow control %s", value)
+			}
+		default:
+			return fmt.Errorf("unknown parameter %s", key)
+		}
+	}
+
+	return nil
+}
+
+// Open opens the connection described by the URL. Configuration
+// defaults can be set through functional options (see Parse).
+// Reads on the returned connection return io.EOF when no data has
+// been received within the read timeout.
+func Open(rawURL string, opts ...func(*Config)) (io.ReadWriteCloser, error) {
+
+	c, err := Parse(rawURL, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	switch c.Scheme {
+	case "tcp":
+		return openTCP(c)
+	case "rfc2217":
+		return openRFC2217(c)
+	default:
+		return openSerial(c)
+	}
+}
+
+// String returns the configuration as URL.
+func (c Config) String() string {
+	switch c.Scheme {
+	case "tcp":
+		return "tcp://" + c.Address
+	}
+
+	stop := strconv.Itoa(int(c.StopBits))
+	if c.StopBits == Stop1Half {
+		stop = "1.5"
+	}
+
+	return fmt.Sprintf("%s://%s?baud=%d&data=%d&parity=%c&stop=%s&flow=%s",
+		c.Scheme, c.Address, c.Baudrate, c.DataBits, c.Parity, stop, c.FlowControl)
+}
+
+var errConnClosed = errors.New("connection closed by remote host")
+
+// isHostPort checks if the address is of the form host:port
+func isHostPort(address string) bool {
+	host, p, err := net.SplitHostPort(address)
+	if err != nil || host == "" {
+		return false
+	}
+	_, err = strconv.ParseUint(p, 10, 16)
+	return err == nil
+}
+
+// tcpPort is a raw TCP connection with the read timeout semantics of
+// a serial port.
+type tcpPort struct {
+	net.Conn
+	timeout time.Duration
+}
+
+func openTCP(c Config) (io.ReadWriteCloser, error) {
+	conn, err := net.DialTimeout("tcp", c.Address, time.Second*5)
+	if err != nil {
+		return nil, err
+	}
+	return &tcpPort{conn, c.ReadTimeout}, nil
+}
+
+func (p *tcpPort) Read(b []byte) (int, error) {
+	return readTimeout(p.Conn, b, p.timeout)
+}
+
+// readTimeout reads from conn and returns io.EOF if no data has been
+// received within the timeout.
+func readTimeout(conn net.Conn, b []byte, timeout time.Duration) (int, error) {
+	if timeout > 0 {
+		conn.SetReadDeadline(time.Now().Add(timeout))
+	}
+	n, err := conn.Read(b)
+	if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		return n, io.EOF
+	}
+	// io.EOF is reserved for timeouts; a closed connection is an error
+	if err == io.EOF {
+		return n, errConnClosed
+	}
+	return n, err
+}
@@ -0,0 +1,189 @@
+package port
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+
+	tt := []struct {
+		name   string
+		url    string
+		expURL string
+		expErr bool
+	}{
+		{"legacy device", "/dev/ttyACM0", "serial:///dev/ttyACM0?baud=9600&data=8&parity=N&stop=1&flow=none", false},
+		{"legacy windows", "COM3", "serial://COM3?baud=9600&data=8&parity=N&stop=1&flow=none", false},
+		{"legacy tcp", "127.0.0.1:6001", "tcp://127.0.0.1:6001", false},
+		{"serial", "serial:///dev/ttyUSB0?baud=4800&parity=N&stop=1",
+			"serial:///dev/ttyUSB0?baud=4800&data=8&parity=N&stop=1&flow=none", false},
+		{"serial windows", "serial://COM3?flow=rtscts",
+			"serial://COM3?baud=9600&data=8&parity=N&stop=1&flow=rtscts", false},
+		{"tcp", "tcp://192.168.1.10:4001", "tcp://192.168.1.10:4001", false},
+		{"rfc2217", "rfc2217://moxa:4001?baud=4800&parity=even&data=7&stop=2",
+			"rfc2217://moxa:4001?baud=4800&data=7&parity=E&stop=2&flow=none", false},
+		{"stop 1.5", "serial://COM1?stop=1.5", "serial://COM1?baud=9600&data=8&parity=N&stop=1.5&flow=none", false},
+		{"unknown scheme", "udp://host:4001", "", true},
+		{"tcp without port", "tcp://host", "", true},
+		{"serial without device", "serial://", "", true},
+		{"invalid baudrate", "serial://COM1?baud=fast", "", true},
+		{"invalid parity", "serial://COM1?parity=x", "", true},
+		{"invalid flow", "serial://COM1?flow=dtr", "", true},
+		{"unknown parameter", "serial://COM1?speed=9600", "", true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := Parse(tc.url)
+			if tc.expErr {
+				if err == nil {
+					t.Fatalf("expected error for %s", tc.url)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.String() != tc.expURL {
+				t.Fatalf("expected %s, got %s", tc.expURL, c.String())
+			}
+		})
+	}
+}
+
+func TestParseDefaultBaudrate(t *testing.T) {
+	c, err := Parse("/dev/ttyUSB0", Baudrate(4800))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Baudrate != 4800 {
+		t.Fatalf("expected baudrate 4800, got %d", c.Baudrate)
+	}
+
+	c, err = Parse("serial:///dev/ttyUSB0?baud=1200", Baudrate(4800))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Baudrate != 1200 {
+		t.Fatalf("expected baudrate 1200, got %d", c.Baudrate)
+	}
+}
+
+func TestTCPReadTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		time.Sleep(time.Millisecond * 200)
+		conn.Write([]byte("+0300\r\n"))
+		time.Sleep(time.Millisecond * 200)
+	}()
+
+	p, err := Open("tcp://"+ln.Addr().String(), ReadTimeout(time.Millisecond*50))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	buf := make([]byte, 64)
+	if _, err := p.Read(buf); err != io.EOF {
+		t.Fatalf("expected io.EOF on timeout, got %v", err)
+	}
+
+	time.Sleep(time.Millisecond * 200)
+	n, err := p.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf[:n]) != "+0300\r\n" {
+		t.Fatalf("unexpected data %q", buf[:n])
+	}
+}
+
+func TestRFC2217(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	rxCh := make(chan []byte, 1)
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		// negotiation from the server, data containing an escaped 0xFF
+		// and an unsupported option
+		conn.Write([]byte{
+			telnetIAC, telnetDO, optComPort,
+			telnetIAC, telnetSB, optComPort, 101, 0, 0, 0x12, 0xc0, telnetIAC, telnetSE,
+			'+', '0', telnetIAC, telnetIAC, '\r', '\n',
+			telnetIAC, telnetDO, 24,
+		})
+
+		conn.SetReadDeadline(time.Now().Add(time.Millisecond * 500))
+		rx := []byte{}
+		buf := make([]byte, 256)
+		for {
+			n, err := conn.Read(buf)
+			rx = append(rx, buf[:n]...)
+			if err != nil {
+				break
+			}
+		}
+		rxCh <- rx
+	}()
+
+	p, err := Open("rfc2217://"+ln.Addr().String()+"?baud=4800&parity=E", ReadTimeout(time.Millisecond*100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	buf := make([]byte, 64)
+	n, err := p.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf[:n], []byte{'+', '0', 0xff, '\r', '\n'}) {
+		t.Fatalf("unexpected data %q", buf[:n])
+	}
+
+	// triggers the refusal of the unsupported option
+	p.Read(buf)
+
+	if _, err := p.Write([]byte{'M', 0xff}); err != nil {
+		t.Fatal(err)
+	}
+
+	rx := <-rxCh
+
+	exp := [][]byte{
+		{telnetIAC, telnetWILL, optComPort},
+		{telnetIAC, telnetSB, optComPort, comSetBaudrate, 0, 0, 0x12, 0xc0, telnetIAC, telnetSE},
+		{telnetIAC, telnetSB, optComPort, comSetParity, 3, telnetIAC, telnetSE},
+		{telnetIAC, telnetWONT, 24},
+		{'M', telnetIAC, telnetIAC},
+	}
+	for _, e := range exp {
+		if !bytes.Contains(rx, e) {
+			t.Fatalf("expected %v to be sent to the server; got %v", e, rx)
+		}
+	}
+}
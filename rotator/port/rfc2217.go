@@ -0,0 +1,274 @@
+package port
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// telnet commands & options (RFC 854, RFC 856, RFC 858, RFC 2217)
+const (
+	telnetSE   = 240
+	telnetSB   = 250
+	telnetWILL = 251
+	telnetWONT = 252
+	telnetDO   = 253
+	telnetDONT = 254
+	telnetIAC  = 255
+
+	optBinary  = 0
+	optSGA     = 3
+	optComPort = 44
+
+	comSetBaudrate = 1
+	comSetDataSize = 2
+	comSetParity   = 3
+	comSetStopSize = 4
+	comSetControl  = 5
+)
+
+// states of the telnet stream decoder
+const (
+	stData = iota
+	stIAC
+	stNegotiate
+	stSB
+	stSBIAC
+)
+
+// rfc2217Port is a connection to a networked serial server which
+// supports the telnet COM port control option (RFC 2217). The serial
+// line settings are sent to the server when the connection is opened.
+type rfc2217Port struct {
+	conn    net.Conn
+	wMu     sync.Mutex
+	timeout time.Duration
+	raw     []byte
+	state   int
+	verb    byte
+	enabled map[byte]bool // options we have accepted (local and remote)
+}
+
+func openRFC2217(c Config) (io.ReadWriteCloser, error) {
+	conn, err := net.DialTimeout("tcp", c.Address, time.Second*5)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &rfc2217Port{
+		conn:    conn,
+		timeout: c.ReadTimeout,
+		enabled: map[byte]bool{},
+	}
+
+	if err := p.negotiate(c); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// negotiate requests a binary, full duplex connection and configures
+// the serial line of the server.
+func (p *rfc2217Port) negotiate(c Config) error {
+
+	msg := []byte{
+		telnetIAC, telnetWILL, optBinary,
+		telnetIAC, telnetDO, optBinary,
+		telnetIAC, telnetWILL, optSGA,
+		telnetIAC, telnetDO, optSGA,
+		telnetIAC, telnetWILL, optComPort,
+	}
+	p.enabled[optBinary] = true
+	p.enabled[optSGA] = true
+	p.enabled[optComPort] = true
+
+	baud := make([]byte, 4)
+	binary.BigEndian.PutUint32(baud, uint32(c.Baudrate))
+	msg = append(msg, comPortCmd(comSetBaudrate, baud...)...)
+	msg = append(msg, comPortCmd(comSetDataSize, byte(c.DataBits))...)
+
+	parity := map[Parity]byte{
+		ParityNone:  1,
+		ParityOdd:   2,
+		ParityEven:  3,
+		ParityMark:  4,
+		ParitySpace: 5,
+	}
+	msg = append(msg, comPortCmd(comSetParity, parity[c.Parity])...)
+
+	stop := map[StopBits]byte{
+		Stop1:     1,
+		Stop2:     2,
+		Stop1Half: 3,
+	}
+	msg = append(msg, comPortCmd(comSetStopSize, stop[c.StopBits])...)
+
+	flow := map[FlowControl]byte{
+		FlowNone:    1,
+		FlowXONXOFF: 2,
+		FlowRTSCTS:  3,
+	}
+	msg = append(msg, comPortCmd(comSetControl, flow[c.FlowControl])...)
+
+	return p.writeRaw(msg)
+}
+
+// comPortCmd returns a COM port control subnegotiation. Values of 255
+// are escaped.
+func comPortCmd(cmd byte, values ...byte) []byte {
+	msg := []byte{telnetIAC, telnetSB, optComPort, cmd}
+	for _, v := range values {
+		msg = append(msg, v)
+		if v == telnetIAC {
+			msg = append(msg, telnetIAC)
+		}
+	}
+	return append(msg, telnetIAC, telnetSE)
+}
+
+func (p *rfc2217Port) writeRaw(b []byte) error {
+	p.wMu.Lock()
+	defer p.wMu.Unlock()
+	_, err := p.conn.Write(b)
+	return err
+}
+
+// Write escapes the data and sends it to the serial server.
+func (p *rfc2217Port) Write(b []byte) (int, error) {
+	data := make([]byte, 0, len(b))
+	for _, c := range b {
+		data = append(data, c)
+		if c == telnetIAC {
+			data = append(data, telnetIAC)
+		}
+	}
+	if err := p.writeRaw(data); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// Read returns the data received from the serial line. Telnet commands
+// are handled and removed from the stream.
+func (p *rfc2217Port) Read(b []byte) (int, error) {
+
+	if len(p.raw) < len(b) {
+		p.raw = make([]byte, len(b))
+	}
+
+	for {
+		n, err := readTimeout(p.conn, p.raw[:len(b)], p.timeout)
+		if err != nil {
+			return 0, err
+		}
+
+		data, replies := p.decode(p.raw[:n], b[:0])
+		if len(replies) > 0 {
+			if err := p.writeRaw(replies); err != nil {
+				return 0, err
+			}
+		}
+
+		if len(data) > 0 {
+			return len(data), nil
+		}
+	}
+}
+
+// decode appends the payload of raw to data and returns the replies to
+// the option negotiations of the server.
+func (p *rfc2217Port) decode(raw, data []byte) ([]byte, []byte) {
+
+	replies := []byte{}
+
+	for _, c := range raw {
+		switch p.state {
+		case stData:
+			if c == telnetIAC {
+				p.state = stIAC
+				continue
+			}
+			data = append(data, c)
+
+		case stIAC:
+			switch c {
+			case telnetIAC:
+				data = append(data, c)
+				p.state = stData
+			case telnetWILL, telnetWONT, telnetDO, telnetDONT:
+				p.verb = c
+				p.state = stNegotiate
+			case telnetSB:
+				p.state = stSB
+			default:
+				p.state = stData
+			}
+
+		case stNegotiate:
+			replies = append(replies, p.reply(p.verb, c)...)
+			p.state = stData
+
+		// the responses of the server to our COM port settings
+		// are not evaluated
+		case stSB:
+			if c == telnetIAC {
+				p.state = stSBIAC
+			}
+
+		case stSBIAC:
+			if c == telnetSE {
+				p.state = stData
+			} else {
+				p.state = stSB
+			}
+		}
+	}
+
+	return data, replies
+}
+
+// reply answers an option negotiation of the server. Options which
+// are already in the requested state are not acknowledged again to
+// avoid negotiation loops.
+func (p *rfc2217Port) reply(verb, opt byte) []byte {
+
+	supported := opt == optBinary || opt == optSGA || opt == optComPort
+
+	switch verb {
+	case telnetDO, telnetWILL:
+		accept, refuse := byte(telnetWILL), byte(telnetWONT)
+		if verb == telnetWILL {
+			accept, refuse = telnetDO, telnetDONT
+		}
+		if !supported {
+			return []byte{telnetIAC, refuse, opt}
+		}
+		if p.enabled[opt] {
+			return nil
+		}
+		p.enabled[opt] = true
+		return []byte{telnetIAC, accept, opt}
+
+	case telnetDONT, telnetWONT:
+		if !p.enabled[opt] {
+			return nil
+		}
+		p.enabled[opt] = false
+		ack := byte(telnetWONT)
+		if verb == telnetWONT {
+			ack = telnetDONT
+		}
+		return []byte{telnetIAC, ack, opt}
+	}
+
+	return nil
+}
+
+// Close the connection to the serial server.
+func (p *rfc2217Port) Close() error {
+	return p.conn.Close()
+}
@@ -0,0 +1,34 @@
+package port
+
+import (
+	"io"
+
+	serial "github.com/tarm/serial"
+)
+
+// openSerial opens a local serial port.
+func openSerial(c Config) (io.ReadWriteCloser, error) {
+
+	spConfig := &serial.Config{
+		Name:        c.Address,
+		Baud:        c.Baudrate,
+		ReadTimeout: c.ReadTimeout,
+		Parity:      serial.Parity(c.Parity),
+		Size:        byte(c.DataBits),
+		StopBits:    serial.StopBits(c.StopBits),
+	}
+
+	sp, err := serial.OpenPort(spConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.FlowControl != FlowNone {
+		if err := setFlowControl(c.Address, c.FlowControl); err != nil {
+			sp.Close()
+			return nil, err
+		}
+	}
+
+	return sp, nil
+}
@@ -0,0 +1,210 @@
+// Package probe detects rotator controllers connected to serial ports.
+// Each known protocol's handshake is sent at the configured baudrates
+// until a controller responds.
+package probe
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"time"
+
+	"github.com/dh1tw/remoteRotator/rotator/port"
+)
+
+// ErrNotFound is returned if no controller responded on a port.
+var ErrNotFound = errors.New("no rotator controller found")
+
+// Protocol describes the handshake of a rotator protocol.
+type Protocol struct {
+	Name   string              // name of the protocol
+	Driver string              // rotator type of the driver; empty if there is none
+	Query  []byte              // query sent to the controller
+	Match  func(b []byte) bool // checks if the response belongs to this protocol
+}
+
+var gs232Pattern = regexp.MustCompile(`(\+0\d{3})|((AZ|EL)=\d{3})`)
+var easyCommPattern = regexp.MustCompile(`AZ\s?-?\d+(\.\d+)?`)
+
+// Protocols are the known rotator protocols. They are probed in this
+// order.
+var Protocols = []Protocol{
+	{
+		Name:   "Yaesu GS-232",
+		Driver: "yaesu",
+		Query:  []byte("C2\r"),
+		Match:  gs232Pattern.Match,
+	},
+	{
+		Name:  "SPID Rot2Prog",
+		Query: []byte{'W', 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x1f, 0x20},
+		Match: func(b []byte) bool {
+			return len(b) >= 12 && b[0] == 'W' && b[11] == 0x20
+		},
+	},
+	{
+		Name:  "EasyComm",
+		Query: []byte("AZ EL\n"),
+		Match: easyCommPattern.Match,
+	},
+}
+
+// Result describes a controller found on a port.
+type Result struct {
+	Port     string
+	Baudrate int
+	Protocol Protocol
+	Response string
+}
+
+// Prober probes serial ports for rotator controllers.
+type Prober struct {
+	baudrates []int
+	protocols []Protocol
+	timeout   time.Duration
+}
+
+// New returns a Prober. Configuration settings can be set through
+// functional options.
+// Default settings are:
+// baudrates: 9600, 4800, 1200, 600, 19200, 38400, 115200,
+// protocols: all known Protocols,
+// timeout: 500ms.
+func New(opts ...func(*Prober)) *Prober {
+
+	p := &Prober{
+		baudrates: []int{9600, 4800, 1200, 600, 19200, 38400, 115200},
+		protocols: Protocols,
+		timeout:   time.Millisecond * 500,
+	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	return p
+}
+
+// Baudrates is a functional option to set the baudrates which will be
+// probed.
+func Baudrates(baudrates ...int) func(*Prober) {
+	return func(p *Prober) {
+		p.baudrates = baudrates
+	}
+}
+
+// Timeout is a functional option to set how long to wait for the
+// response to a handshake.
+func Timeout(d time.Duration) func(*Prober) {
+	return func(p *Prober) {
+		p.timeout = d
+	}
+}
+
+// Probe tries the handshakes of all protocols at all baudrates on the
+// port and returns the first controller which responded.
+func (p *Prober) Probe(portname string) (Result, error) {
+
+	for _, baud := range p.baudrates {
+		sp, err := port.Open(portname, port.Baudrate(baud),
+			port.ReadTimeout(time.Millisecond*100))
+		if err != nil {
+			return Result{}, err
+		}
+
+		for _, proto := range p.protocols {
+			resp, ok := p.handshake(sp, proto)
+			if ok {
+				sp.Close()
+				return Result{portname, baud, proto, string(resp)}, nil
+			}
+		}
+		sp.Close()
+	}
+
+	return Result{}, ErrNotFound
+}
+
+// Scan probes all ports and returns the controllers found.
+func (p *Prober) Scan(portnames []string) []Result {
+	results := []Result{}
+	for _, pn := range portnames {
+		res, err := p.Probe(pn)
+		if err != nil {
+			continue
+		}
+		results = append(results, res)
+	}
+	return results
+}
+
+// handshake sends the query of the protocol and waits until the
+// response matches or the timeout expires.
+func (p *Prober) handshake(sp io.ReadWriter, proto Protocol) ([]byte, bool) {
+
+	// discard stale data (e.g. responses to previous handshakes)
+	drain(sp)
+
+	if _, err := sp.Write(proto.Query); err != nil {
+		return nil, false
+	}
+
+	resp := []byte{}
+	buf := make([]byte, 64)
+	deadline := time.Now().Add(p.timeout)
+
+	for time.Now().Before(deadline) {
+		n, err := sp.Read(buf)
+		resp = append(resp, buf[:n]...)
+		if proto.Match(resp) {
+			return resp, true
+		}
+		if err != nil && err != io.EOF {
+			return nil, false
+		}
+	}
+
+	return nil, false
+}
+
+// drain reads until no more data is received.
+func drain(sp io.Reader) {
+	buf := make([]byte, 64)
+	for i := 0; i < 10; i++ {
+		n, err := sp.Read(buf)
+		if err != nil || n == 0 {
+			return
+		}
+	}
+}
+
+// SerialPorts returns the candidates for serial ports on this computer.
+func SerialPorts() []string {
+
+	switch runtime.GOOS {
+	case "windows":
+		ports := []string{}
+		for i := 1; i <= 32; i++ {
+			ports = append(ports, fmt.Sprintf("COM%d", i))
+		}
+		return ports
+	case "darwin":
+		return glob("/dev/cu.usbserial*", "/dev/cu.usbmodem*", "/dev/cu.SLAB*")
+	default:
+		return glob("/dev/ttyUSB*", "/dev/ttyACM*", "/dev/ttyAMA*")
+	}
+}
+
+func glob(patterns ...string) []string {
+	ports := []string{}
+	for _, pattern := range patterns {
+		matches, _ := filepath.Glob(pattern)
+		ports = append(ports, matches...)
+	}
+	sort.Strings(ports)
+	return ports
+}
@@ -0,0 +1,92 @@
+package probe
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// openPty returns the master side of a pseudo terminal and the name of
+// its slave device.
+func openPty(t *testing.T) (*os.File, string) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		t.Skipf("pseudo terminals not available: %v", err)
+	}
+	fd := int(master.Fd())
+	if err := unix.IoctlSetPointerInt(fd, unix.TIOCSPTLCK, 0); err != nil {
+		t.Fatal(err)
+	}
+	n, err := unix.IoctlGetInt(fd, unix.TIOCGPTN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return master, fmt.Sprintf("/dev/pts/%d", n)
+}
+
+// simulate answers the query of a controller on the master side of the pty.
+func simulate(master *os.File, query, response []byte) {
+	buf := make([]byte, 256)
+	rx := []byte{}
+	for {
+		n, err := master.Read(buf)
+		if err != nil {
+			return
+		}
+		rx = append(rx, buf[:n]...)
+		if bytes.Contains(rx, query) {
+			rx = rx[:0]
+			master.Write(response)
+		}
+	}
+}
+
+func TestProbe(t *testing.T) {
+
+	tt := []struct {
+		name     string
+		query    []byte
+		response []byte
+		exp      string
+	}{
+		{"GS-232A", []byte("C2"), []byte("+0123+0045\r\n"), "Yaesu GS-232"},
+		{"GS-232B", []byte("C2"), []byte("AZ=123  EL=045\r\n"), "Yaesu GS-232"},
+		{"SPID", []byte{'W', 0, 0}, []byte{'W', 3, 6, 0, 0, 1, 3, 6, 0, 0, 1, 0x20}, "SPID Rot2Prog"},
+		{"EasyComm", []byte("AZ EL"), []byte("AZ123.0 EL45.0\n"), "EasyComm"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			master, slave := openPty(t)
+			defer master.Close()
+			go simulate(master, tc.query, tc.response)
+
+			p := New(Baudrates(9600), Timeout(time.Millisecond*300))
+			res, err := p.Probe(slave)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Protocol.Name != tc.exp {
+				t.Fatalf("expected %s, got %s", tc.exp, res.Protocol.Name)
+			}
+			if res.Port != slave || res.Baudrate != 9600 {
+				t.Fatalf("unexpected result %+v", res)
+			}
+		})
+	}
+}
+
+func TestProbeNotFound(t *testing.T) {
+	master, slave := openPty(t)
+	defer master.Close()
+	go simulate(master, []byte("nothing"), nil)
+
+	p := New(Baudrates(9600), Timeout(time.Millisecond*200))
+	if _, err := p.Probe(slave); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
@@ -1,6 +1,10 @@
 package proxy
 
-import "github.com/dh1tw/remoteRotator/rotator"
+import (
+	"crypto/tls"
+
+	"github.com/dh1tw/remoteRotator/rotator"
+)
 
 // Host is a functional option to set IP / dns name of the remote Rotators host.
 func Host(host string) func(*Proxy) {
@@ -31,3 +35,21 @@ func EventHandler(h func(rotator.Rotator, rotator.Heading)) func(*Proxy) {
 		r.eventHandler = h
 	}
 }
+
+// TLS is a functional option to connect to the remote rotator through
+// https and wss with the given TLS configuration.
+func TLS(cfg *tls.Config) func(*Proxy) {
+	return func(r *Proxy) {
+		r.tlsConfig = cfg
+	}
+}
+
+// PinnedCertificates is a functional option to only accept the
+// certificates of the remote rotator with the given SHA-256 fingerprints
+// (e.g. self-signed certificates). The pins replace the verification
+// against the certificate authorities. Implies TLS.
+func PinnedCertificates(fingerprints ...string) func(*Proxy) {
+	return func(r *Proxy) {
+		r.pins = append(r.pins, fingerprints...)
+	}
+}
@@ -3,7 +3,9 @@ package proxy
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -34,6 +36,9 @@ type Proxy struct {
 	sync.RWMutex
 	host           string
 	port           int
+	tlsConfig      *tls.Config
+	pins           []string
+	client         *http.Client
 	conn           *websocket.Conn
 	wsTxTimeout    time.Duration
 	wsRxTimeout    time.Duration
@@ -51,6 +56,7 @@ type Proxy struct {
 	azPreset       int
 	elevation      int
 	elPreset       int
+	antennas       []rotator.Antenna
 	closeCh        chan struct{}
 	doneCh         chan struct{}
 }
@@ -67,14 +73,26 @@ func New(opts ...func(*Proxy)) (*Proxy, error) {
 		opt(r)
 	}
 
+	if len(r.pins) > 0 {
+		cfg, err := pinned(r.tlsConfig, r.pins)
+		if err != nil {
+			return nil, err
+		}
+		r.tlsConfig = cfg
+	}
+
+	r.client = &http.Client{
+		Timeout:   3 * time.Second,
+		Transport: &http.Transport{TLSClientConfig: r.tlsConfig},
+	}
+
 	if err := r.getObject(); err != nil {
 		return nil, err
 	}
 
-	wsDialer := &websocket.Dialer{}
+	wsDialer := &websocket.Dialer{TLSClientConfig: r.tlsConfig}
 
-	wsURL := fmt.Sprintf("ws://%s:%d/ws", r.host, r.port)
-	conn, _, err := wsDialer.Dial(wsURL, nil)
+	conn, _, err := wsDialer.Dial(r.url("ws", "/ws"), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -177,10 +195,7 @@ func (r *Proxy) Close() {
 // same parameters in our proxy Object
 func (r *Proxy) getObject() error {
 
-	url := fmt.Sprintf("http://%s:%d/api/rotators", r.host, r.port)
-
-	c := &http.Client{Timeout: 3 * time.Second}
-	resp, err := c.Get(url)
+	resp, err := r.client.Get(r.url("http", "/api/rotators"))
 	if err != nil {
 		return err
 	}
@@ -214,6 +229,7 @@ func (r *Proxy) getObject() error {
 		r.azPreset = pr.Heading.AzPreset
 		r.elevation = pr.Heading.Elevation
 		r.elPreset = pr.Heading.ElPreset
+		r.antennas = pr.Antennas
 	}
 
 	return nil
@@ -255,9 +271,9 @@ func (r *Proxy) SetAzimuth(az int) error {
 		Azimuth: &az,
 	}
 
-	url := fmt.Sprintf("http://%s:%d/api/rotator/%s/azimuth", r.host, r.port, r.name)
+	url := r.url("http", "/api/rotator/"+r.name+"/azimuth")
 
-	return putRequest(url, &azPut)
+	return r.putRequest(url, &azPut)
 }
 
 func (r *Proxy) Elevation() int {
@@ -278,28 +294,28 @@ func (r *Proxy) SetElevation(el int) error {
 		Elevation: &el,
 	}
 
-	url := fmt.Sprintf("http://%s:%d/api/rotator/%s/elevation", r.host, r.port, r.name)
+	url := r.url("http", "/api/rotator/"+r.name+"/elevation")
 
-	return putRequest(url, &elPut)
+	return r.putRequest(url, &elPut)
 }
 
 func (r *Proxy) StopAzimuth() error {
 
-	url := fmt.Sprintf("http://%s:%d/api/rotator/%s/stop_azimuth", r.host, r.port, r.name)
+	url := r.url("http", "/api/rotator/"+r.name+"/stop_azimuth")
 
-	return putRequest(url, struct{}{})
+	return r.putRequest(url, struct{}{})
 }
 
 func (r *Proxy) StopElevation() error {
-	url := fmt.Sprintf("http://%s:%d/api/rotator/%s/stop_elevation", r.host, r.port, r.name)
+	url := r.url("http", "/api/rotator/"+r.name+"/stop_elevation")
 
-	return putRequest(url, struct{}{})
+	return r.putRequest(url, struct{}{})
 }
 
 func (r *Proxy) Stop() error {
-	url := fmt.Sprintf("http://%s:%d/api/rotator/%s/stop", r.host, r.port, r.name)
+	url := r.url("http", "/api/rotator/"+r.name+"/stop")
 
-	return putRequest(url, struct{}{})
+	return r.putRequest(url, struct{}{})
 }
 
 // Serialize the data of the rotator
@@ -328,13 +344,61 @@ func (r *Proxy) serialize() rotator.Object {
 			ElevationMax: r.elevationMax,
 			ElevationMin: r.elevationMin,
 		},
+		Antennas: rotator.Bearings(r.antennas, rotator.Heading{
+			Azimuth:  r.azimuth,
+			AzPreset: r.azPreset,
+		}),
 	}
 
 	return obj
 }
 
+// url returns the url of the path on the remote rotator. The scheme
+// ("http" or "ws") is upgraded to "https" / "wss" if TLS is enabled.
+func (r *Proxy) url(scheme, path string) string {
+	if r.tlsConfig != nil {
+		scheme += "s"
+	}
+	return fmt.Sprintf("%s://%s:%d%s", scheme, r.host, r.port, path)
+}
+
+// pinned returns a copy of the TLS configuration which only accepts
+// certificates with one of the SHA-256 fingerprints.
+func pinned(cfg *tls.Config, fingerprints []string) (*tls.Config, error) {
+
+	pins := make(map[string]bool, len(fingerprints))
+	for _, fp := range fingerprints {
+		pin, err := hub.NormalizeFingerprint(fp)
+		if err != nil {
+			return nil, err
+		}
+		pins[pin] = true
+	}
+
+	if cfg == nil {
+		cfg = &tls.Config{}
+	}
+	cfg = cfg.Clone()
+
+	// the pins replace the verification of the certificate chain, so
+	// that self-signed certificates can be used
+	cfg.InsecureSkipVerify = true
+	cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+		if len(cs.PeerCertificates) == 0 {
+			return errors.New("no certificate presented")
+		}
+		fp := hub.Fingerprint(cs.PeerCertificates[0].Raw)
+		if pin, _ := hub.NormalizeFingerprint(fp); !pins[pin] {
+			return fmt.Errorf("certificate %s is not pinned", fp)
+		}
+		return nil
+	}
+
+	return cfg, nil
+}
+
 // putRequest executes an HTTP put request.
-func putRequest(url string, data interface{}) error {
+func (r *Proxy) putRequest(url string, data interface{}) error {
 
 	b := new(bytes.Buffer)
 	json.NewEncoder(b).Encode(data)
@@ -351,8 +415,7 @@ func putRequest(url string, data interface{}) error {
 
 	req.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := r.client.Do(req)
 	if err != nil {
 		return (err)
 	}
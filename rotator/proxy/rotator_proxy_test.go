@@ -0,0 +1,65 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dh1tw/remoteRotator/hub"
+)
+
+func TestPinned(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+	defer srv.Close()
+
+	fp := hub.Fingerprint(srv.Certificate().Raw)
+	other := hub.Fingerprint([]byte("another certificate"))
+
+	tt := []struct {
+		name  string
+		pins  []string
+		valid bool
+	}{
+		{"pinned", []string{fp}, true},
+		{"pinned lower case", []string{strings.ToLower(strings.ReplaceAll(fp, ":", ""))}, true},
+		{"one of several pins", []string{other, fp}, true},
+		{"not pinned", []string{other}, false},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg, err := pinned(&tls.Config{}, tc.pins)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c := &http.Client{Transport: &http.Transport{TLSClientConfig: cfg}}
+			resp, err := c.Get(srv.URL)
+			if err == nil {
+				resp.Body.Close()
+			}
+			if tc.valid && err != nil {
+				t.Fatal(err)
+			}
+			if !tc.valid && err == nil {
+				t.Fatal("expected certificate to be rejected")
+			}
+		})
+	}
+
+	if _, err := pinned(nil, []string{"invalid"}); err == nil {
+		t.Fatal("expected invalid fingerprint to be rejected")
+	}
+}
+
+func TestURL(t *testing.T) {
+	r := &Proxy{host: "10.0.0.1", port: 7070}
+	if u := r.url("ws", "/ws"); u != "ws://10.0.0.1:7070/ws" {
+		t.Fatalf("unexpected url %s", u)
+	}
+	r.tlsConfig = &tls.Config{}
+	if u := r.url("http", "/api/rotators"); u != "https://10.0.0.1:7070/api/rotators" {
+		t.Fatalf("unexpected url %s", u)
+	}
+}
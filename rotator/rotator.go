@@ -1,5 +1,11 @@
 package rotator
 
+import "errors"
+
+// ErrOutOfRange is returned if a rotator is commanded to a heading
+// beyond its limits.
+var ErrOutOfRange = errors.New("out of range")
+
 // Rotator is the interface which has to be implemented by each Rotator
 type Rotator interface {
 	Name() string
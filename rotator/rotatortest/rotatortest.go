@@ -0,0 +1,122 @@
+// Package rotatortest provides a fake rotator for the tests of the
+// packages which monitor or decorate a rotator.
+package rotatortest
+
+import (
+	"sync"
+
+	"github.com/dh1tw/remoteRotator/rotator"
+)
+
+// Rotator is a fake rotator whose heading is set by the test. Commanded
+// presets are only reached through SetHeading or Step, unless the rotator
+// moves instantly (see Instant).
+type Rotator struct {
+	sync.Mutex
+	obj     rotator.Object
+	instant bool
+	stops   int
+}
+
+// New returns a fake rotator named "fake" with the configuration.
+// Configuration settings can be set through functional options.
+func New(cfg rotator.Config, opts ...func(*Rotator)) *Rotator {
+	r := &Rotator{obj: rotator.Object{Name: "fake", Config: cfg}}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+// Instant is a functional option to let the rotator reach its presets
+// instantly.
+func Instant() func(*Rotator) {
+	return func(r *Rotator) {
+		r.instant = true
+	}
+}
+
+// Heading is a functional option to set the initial heading (and
+// presets) of the rotator.
+func Heading(az, el int) func(*Rotator) {
+	return func(r *Rotator) {
+		r.obj.Heading.Azimuth, r.obj.Heading.AzPreset = az, az
+		r.obj.Heading.Elevation, r.obj.Heading.ElPreset = el, el
+	}
+}
+
+// SetHeading sets the current heading without changing the presets.
+func (r *Rotator) SetHeading(az, el int) {
+	r.Lock()
+	defer r.Unlock()
+	r.obj.Heading.Azimuth = az
+	r.obj.Heading.Elevation = el
+}
+
+// Step turns the azimuth by one degree towards its preset.
+func (r *Rotator) Step() {
+	r.Lock()
+	defer r.Unlock()
+	switch h := &r.obj.Heading; {
+	case h.Azimuth < h.AzPreset:
+		h.Azimuth++
+	case h.Azimuth > h.AzPreset:
+		h.Azimuth--
+	}
+}
+
+// Stops returns how often the rotator has been stopped.
+func (r *Rotator) Stops() int {
+	r.Lock()
+	defer r.Unlock()
+	return r.stops
+}
+
+func (r *Rotator) Name() string         { return r.obj.Name }
+func (r *Rotator) HasAzimuth() bool     { return r.obj.Config.HasAzimuth }
+func (r *Rotator) HasElevation() bool   { return r.obj.Config.HasElevation }
+func (r *Rotator) Azimuth() int         { return r.Serialize().Heading.Azimuth }
+func (r *Rotator) AzPreset() int        { return r.Serialize().Heading.AzPreset }
+func (r *Rotator) Elevation() int       { return r.Serialize().Heading.Elevation }
+func (r *Rotator) ElPreset() int        { return r.Serialize().Heading.ElPreset }
+func (r *Rotator) StopAzimuth() error   { return r.Stop() }
+func (r *Rotator) StopElevation() error { return r.Stop() }
+func (r *Rotator) Close()               {}
+
+func (r *Rotator) SetAzimuth(az int) error {
+	r.Lock()
+	defer r.Unlock()
+	r.obj.Heading.AzPreset = az
+	if r.instant {
+		r.obj.Heading.Azimuth = az
+	}
+	return nil
+}
+
+func (r *Rotator) SetElevation(el int) error {
+	r.Lock()
+	defer r.Unlock()
+	r.obj.Heading.ElPreset = el
+	if r.instant {
+		r.obj.Heading.Elevation = el
+	}
+	return nil
+}
+
+// Stop counts the stops and sets the presets to the current heading.
+func (r *Rotator) Stop() error {
+	r.Lock()
+	defer r.Unlock()
+	r.stops++
+	r.obj.Heading.AzPreset = r.obj.Heading.Azimuth
+	r.obj.Heading.ElPreset = r.obj.Heading.Elevation
+	return nil
+}
+
+func (r *Rotator) Serialize() rotator.Object {
+	r.Lock()
+	defer r.Unlock()
+	return r.obj
+}
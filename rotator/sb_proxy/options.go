@@ -39,3 +39,11 @@ func EventHandler(h func(rotator.Rotator, rotator.Heading)) func(*SbProxy) {
 		r.eventHandler = h
 	}
 }
+
+// Antennas sets the antennas mounted on the mast of the remote rotator.
+// The antennas are announced through the metadata of the service.
+func Antennas(antennas []rotator.Antenna) func(*SbProxy) {
+	return func(r *SbProxy) {
+		r.antennas = antennas
+	}
+}
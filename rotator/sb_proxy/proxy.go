@@ -29,6 +29,7 @@ type SbProxy struct {
 	azPreset       int
 	elevation      int
 	elPreset       int
+	antennas       []rotator.Antenna
 	doneCh         chan struct{}
 	doneOnce       sync.Once
 	subscriber     broker.Subscriber
@@ -219,6 +220,10 @@ func (r *SbProxy) serialize() rotator.Object {
 			ElevationMax: r.elevationMax,
 			ElevationMin: r.elevationMin,
 		},
+		Antennas: rotator.Bearings(r.antennas, rotator.Heading{
+			Azimuth:  r.azimuth,
+			AzPreset: r.azPreset,
+		}),
 	}
 
 	return obj
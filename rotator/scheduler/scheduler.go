@@ -0,0 +1,256 @@
+package scheduler
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/dh1tw/remoteRotator/rotator"
+)
+
+// Scheduler is a command scheduler which can be put in front of any
+// rotator.Rotator. Rapid successive set commands (e.g. while dragging
+// the heading dial in the web interface) are coalesced to the latest
+// target and forwarded to the rotator with a minimum interval between
+// two commands. Stop commands are always forwarded immediately and
+// discard pending set commands.
+// Scheduler implements the rotator.Rotator interface itself.
+type Scheduler struct {
+	rotator.Rotator
+	sync.Mutex
+	cmdMu    sync.Mutex // serializes the commands sent to the rotator
+	interval time.Duration
+	azTarget *int
+	elTarget *int
+	lastCmd  time.Time
+	wakeCh   chan struct{}
+	closeCh  chan struct{}
+	closer   sync.Once
+}
+
+// New returns a Scheduler for the rotator. Configuration settings can
+// be set through functional options.
+// Default settings are:
+// interval: 500ms.
+func New(r rotator.Rotator, opts ...func(*Scheduler)) (*Scheduler, error) {
+
+	s := &Scheduler{
+		Rotator:  r,
+		interval: time.Millisecond * 500,
+		wakeCh:   make(chan struct{}, 1),
+		closeCh:  make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	go s.start()
+
+	return s, nil
+}
+
+// Interval is a functional option to set the minimum interval between
+// two set commands sent to the rotator.
+func Interval(d time.Duration) func(*Scheduler) {
+	return func(s *Scheduler) {
+		s.interval = d
+	}
+}
+
+// start the event loop which forwards the pending commands to the
+// rotator. Since this function contains an endless loop, it should
+// be executed in a go routine.
+func (s *Scheduler) start() {
+	for {
+		select {
+		case <-s.wakeCh:
+		case <-s.closeCh:
+			return
+		}
+
+		s.Lock()
+		wait := s.interval - time.Since(s.lastCmd)
+		s.Unlock()
+
+		if wait > 0 {
+			t := time.NewTimer(wait)
+			select {
+			case <-t.C:
+			case <-s.closeCh:
+				t.Stop()
+				return
+			}
+		}
+
+		s.flush()
+	}
+}
+
+// flush sends the pending set commands to the rotator.
+func (s *Scheduler) flush() {
+	s.cmdMu.Lock()
+	defer s.cmdMu.Unlock()
+
+	s.Lock()
+	az, el := s.azTarget, s.elTarget
+	s.azTarget, s.elTarget = nil, nil
+	if az != nil || el != nil {
+		s.lastCmd = time.Now()
+	}
+	s.Unlock()
+
+	if az != nil {
+		if err := s.Rotator.SetAzimuth(*az); err != nil {
+			log.Printf("unable to set azimuth of %s to %d: %v\n", s.Name(), *az, err)
+		}
+	}
+
+	if el != nil {
+		if err := s.Rotator.SetElevation(*el); err != nil {
+			log.Printf("unable to set elevation of %s to %d: %v\n", s.Name(), *el, err)
+		}
+	}
+}
+
+// wake signals the event loop that a new command is pending.
+func (s *Scheduler) wake() {
+	select {
+	case s.wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+// SetAzimuth schedules the azimuth to which the rotator shall turn to.
+// The command is sent asynchronously; a newer target replaces a target
+// which has not been sent yet. Since the errors of the rotator can't be
+// returned anymore, commands which the rotator can't execute are
+// refused right away.
+func (s *Scheduler) SetAzimuth(az int) error {
+	if !s.Rotator.HasAzimuth() {
+		return fmt.Errorf("rotator does not support azimuth")
+	}
+	// headings beyond 360° turn into the overlap
+	max := s.Rotator.Serialize().Config.AzimuthMax
+	if max < 360 {
+		max = 360
+	}
+	if az < 0 || az > max {
+		return fmt.Errorf("azimuth %d° %w (0° ... %d°)", az, rotator.ErrOutOfRange, max)
+	}
+
+	s.Lock()
+	s.azTarget = &az
+	s.Unlock()
+	s.wake()
+	return nil
+}
+
+// SetElevation schedules the elevation to which the rotator shall turn
+// to. The command is sent asynchronously; a newer target replaces a
+// target which has not been sent yet. Commands which the rotator can't
+// execute are refused right away (see SetAzimuth).
+func (s *Scheduler) SetElevation(el int) error {
+	if !s.Rotator.HasElevation() {
+		return fmt.Errorf("rotator does not support elevation")
+	}
+	cfg := s.Rotator.Serialize().Config
+	if el < cfg.ElevationMin || el > cfg.ElevationMax {
+		return fmt.Errorf("elevation %d° %w (%d° ... %d°)", el, rotator.ErrOutOfRange,
+			cfg.ElevationMin, cfg.ElevationMax)
+	}
+
+	s.Lock()
+	s.elTarget = &el
+	s.Unlock()
+	s.wake()
+	return nil
+}
+
+// AzPreset returns the pending azimuth target, or if there is none,
+// the preset of the rotator.
+func (s *Scheduler) AzPreset() int {
+	s.Lock()
+	defer s.Unlock()
+	if s.azTarget != nil {
+		return *s.azTarget
+	}
+	return s.Rotator.AzPreset()
+}
+
+// ElPreset returns the pending elevation target, or if there is none,
+// the preset of the rotator.
+func (s *Scheduler) ElPreset() int {
+	s.Lock()
+	defer s.Unlock()
+	if s.elTarget != nil {
+		return *s.elTarget
+	}
+	return s.Rotator.ElPreset()
+}
+
+// Stop discards all pending commands and stops the rotator immediately.
+func (s *Scheduler) Stop() error {
+	s.Lock()
+	s.azTarget, s.elTarget = nil, nil
+	s.Unlock()
+
+	s.cmdMu.Lock()
+	defer s.cmdMu.Unlock()
+	return s.Rotator.Stop()
+}
+
+// StopAzimuth discards a pending azimuth command and stops the
+// horizontal movement immediately.
+func (s *Scheduler) StopAzimuth() error {
+	s.Lock()
+	s.azTarget = nil
+	s.Unlock()
+
+	s.cmdMu.Lock()
+	defer s.cmdMu.Unlock()
+	return s.Rotator.StopAzimuth()
+}
+
+// StopElevation discards a pending elevation command and stops the
+// vertical movement immediately.
+func (s *Scheduler) StopElevation() error {
+	s.Lock()
+	s.elTarget = nil
+	s.Unlock()
+
+	s.cmdMu.Lock()
+	defer s.cmdMu.Unlock()
+	return s.Rotator.StopElevation()
+}
+
+// Serialize the data of the rotator. Pending targets are reported
+// as presets.
+func (s *Scheduler) Serialize() rotator.Object {
+	obj := s.Rotator.Serialize()
+
+	s.Lock()
+	defer s.Unlock()
+	if s.azTarget != nil {
+		obj.Heading.AzPreset = *s.azTarget
+	}
+	if s.elTarget != nil {
+		obj.Heading.ElPreset = *s.elTarget
+	}
+
+	return obj
+}
+
+// Unwrap returns the scheduled rotator.
+func (s *Scheduler) Unwrap() rotator.Rotator {
+	return s.Rotator
+}
+
+// Close shuts down the scheduler and the rotator.
+func (s *Scheduler) Close() {
+	s.closer.Do(func() {
+		close(s.closeCh)
+	})
+	s.Rotator.Close()
+}
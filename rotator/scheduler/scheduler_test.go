@@ -0,0 +1,142 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dh1tw/remoteRotator/rotator"
+)
+
+// fakeRotator records the commands it receives
+type fakeRotator struct {
+	rotator.Rotator
+	sync.Mutex
+	cmds []string
+}
+
+func (r *fakeRotator) record(cmd string) error {
+	r.Lock()
+	defer r.Unlock()
+	r.cmds = append(r.cmds, cmd)
+	return nil
+}
+
+func (r *fakeRotator) commands() []string {
+	r.Lock()
+	defer r.Unlock()
+	return append([]string{}, r.cmds...)
+}
+
+func (r *fakeRotator) Name() string              { return "fake" }
+func (r *fakeRotator) SetAzimuth(az int) error   { return r.record(fmt.Sprintf("az %d", az)) }
+func (r *fakeRotator) SetElevation(el int) error { return r.record(fmt.Sprintf("el %d", el)) }
+func (r *fakeRotator) Stop() error               { return r.record("stop") }
+func (r *fakeRotator) StopAzimuth() error        { return r.record("stop az") }
+func (r *fakeRotator) StopElevation() error      { return r.record("stop el") }
+func (r *fakeRotator) AzPreset() int             { return 0 }
+func (r *fakeRotator) HasAzimuth() bool          { return true }
+func (r *fakeRotator) HasElevation() bool        { return true }
+func (r *fakeRotator) Close()                    {}
+
+func (r *fakeRotator) Serialize() rotator.Object {
+	return rotator.Object{Config: rotator.Config{AzimuthMax: 450, ElevationMax: 90}}
+}
+
+func TestCoalesceSetCommands(t *testing.T) {
+	fr := &fakeRotator{}
+
+	s, err := New(fr, Interval(time.Millisecond*200))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// the first command is sent immediately, the following ones
+	// are coalesced into the latest target
+	for az := 10; az <= 50; az += 10 {
+		s.SetAzimuth(az)
+		time.Sleep(time.Millisecond * 20)
+	}
+
+	if s.AzPreset() != 50 {
+		t.Fatalf("expected pending preset 50, got %d", s.AzPreset())
+	}
+
+	time.Sleep(time.Millisecond * 400)
+
+	exp := []string{"az 10", "az 50"}
+	if res := fr.commands(); !reflect.DeepEqual(res, exp) {
+		t.Fatalf("expected commands %v, got %v", exp, res)
+	}
+}
+
+func TestStopDiscardsPendingCommands(t *testing.T) {
+	fr := &fakeRotator{}
+
+	s, err := New(fr, Interval(time.Millisecond*200))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	s.SetAzimuth(10)
+	time.Sleep(time.Millisecond * 20)
+	s.SetAzimuth(20)
+	s.SetElevation(30)
+	if err := s.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(time.Millisecond * 400)
+
+	exp := []string{"az 10", "stop"}
+	if res := fr.commands(); !reflect.DeepEqual(res, exp) {
+		t.Fatalf("expected commands %v, got %v", exp, res)
+	}
+}
+
+func TestRefuseInvalidCommands(t *testing.T) {
+	fr := &fakeRotator{}
+
+	s, err := New(fr, Interval(time.Millisecond*10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	tt := []struct {
+		name string
+		set  func() error
+		err  bool
+	}{
+		{"azimuth", func() error { return s.SetAzimuth(350) }, false},
+		{"azimuth overlap", func() error { return s.SetAzimuth(450) }, false},
+		{"azimuth beyond overlap", func() error { return s.SetAzimuth(451) }, true},
+		{"negative azimuth", func() error { return s.SetAzimuth(-1) }, true},
+		{"elevation", func() error { return s.SetElevation(90) }, false},
+		{"elevation out of range", func() error { return s.SetElevation(91) }, true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.set()
+			if tc.err != (err != nil) {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if err != nil && !errors.Is(err, rotator.ErrOutOfRange) {
+				t.Fatalf("expected ErrOutOfRange, got %v", err)
+			}
+		})
+	}
+
+	time.Sleep(time.Millisecond * 50)
+	for _, cmd := range fr.commands() {
+		if cmd == "az 451" || cmd == "az -1" || cmd == "el 91" {
+			t.Fatalf("refused command %s sent to the rotator", cmd)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package simulator
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// Pty is a pseudo terminal. The simulator is served on the master side;
+// clients connect to the device Name.
+type Pty struct {
+	Master *os.File
+	Name   string
+	slave  *os.File
+}
+
+// OpenPty creates a pseudo terminal in raw mode. The slave side is kept
+// open, so that clients can disconnect and reconnect.
+func OpenPty() (*Pty, error) {
+
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	fd := int(master.Fd())
+	if err := unix.IoctlSetPointerInt(fd, unix.TIOCSPTLCK, 0); err != nil {
+		master.Close()
+		return nil, fmt.Errorf("unable to unlock pty: %v", err)
+	}
+
+	n, err := unix.IoctlGetInt(fd, unix.TIOCGPTN)
+	if err != nil {
+		master.Close()
+		return nil, fmt.Errorf("unable to get pty number: %v", err)
+	}
+	name := fmt.Sprintf("/dev/pts/%d", n)
+
+	slave, err := os.OpenFile(name, os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		master.Close()
+		return nil, err
+	}
+
+	// raw mode: no echo, no line editing, no CR/LF translation
+	t, err := unix.IoctlGetTermios(int(slave.Fd()), unix.TCGETS)
+	if err != nil {
+		master.Close()
+		slave.Close()
+		return nil, err
+	}
+	t.Iflag &^= unix.IGNBRK | unix.BRKINT | unix.PARMRK | unix.ISTRIP |
+		unix.INLCR | unix.IGNCR | unix.ICRNL | unix.IXON
+	t.Oflag &^= unix.OPOST
+	t.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON | unix.ISIG | unix.IEXTEN
+	t.Cflag &^= unix.CSIZE | unix.PARENB
+	t.Cflag |= unix.CS8
+	if err := unix.IoctlSetTermios(int(slave.Fd()), unix.TCSETS, t); err != nil {
+		master.Close()
+		slave.Close()
+		return nil, err
+	}
+
+	return &Pty{Master: master, Name: name, slave: slave}, nil
+}
+
+// Close the pseudo terminal.
+func (p *Pty) Close() error {
+	p.slave.Close()
+	return p.Master.Close()
+}
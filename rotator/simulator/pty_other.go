@@ -0,0 +1,25 @@
+//go:build !linux
+
+package simulator
+
+import (
+	"errors"
+	"os"
+)
+
+// Pty is a pseudo terminal. The simulator is served on the master side;
+// clients connect to the device Name.
+type Pty struct {
+	Master *os.File
+	Name   string
+}
+
+// OpenPty is only supported on Linux; use the TCP listener instead.
+func OpenPty() (*Pty, error) {
+	return nil, errors.New("pseudo terminals are not supported on this platform")
+}
+
+// Close the pseudo terminal.
+func (p *Pty) Close() error {
+	return nil
+}
@@ -0,0 +1,292 @@
+// Package simulator emulates the serial interface of a Yaesu GS-232A/B
+// rotator controller. The motion of the simulated rotator is provided by
+// a rotator.Rotator (typically dummy.Dummy). The simulator can be served
+// on a pseudo terminal or a TCP socket, so that the yaesu driver and
+// third party software can be tested without a rotator attached.
+// Faults like noisy headings, dropped bytes and garbled replies can be
+// injected.
+package simulator
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dh1tw/remoteRotator/rotator"
+	"github.com/dh1tw/remoteRotator/rotator/framing"
+)
+
+// Protocol variants of the GS-232 controller
+const (
+	GS232A = "A"
+	GS232B = "B"
+)
+
+// Simulator emulates a Yaesu GS-232 controller.
+type Simulator struct {
+	sync.Mutex // protects rnd
+	rotator    rotator.Rotator
+	protocol   string
+	noise      int
+	dropRate   float64
+	garbleRate float64
+	rnd        *rand.Rand
+}
+
+// New returns a Simulator for the rotator. Configuration settings can
+// be set through functional options.
+// Default settings are:
+// protocol: GS232A,
+// noise: 0 (deg),
+// dropRate: 0,
+// garbleRate: 0.
+func New(r rotator.Rotator, opts ...func(*Simulator)) *Simulator {
+
+	s := &Simulator{
+		rotator:  r,
+		protocol: GS232A,
+		rnd:      rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// Protocol is a functional option to set the emulated protocol variant
+// (GS232A or GS232B).
+func Protocol(p string) func(*Simulator) {
+	return func(s *Simulator) {
+		s.protocol = strings.ToUpper(p)
+	}
+}
+
+// Noise is a functional option to set the maximum deviation (in degrees)
+// which is randomly added to the reported headings.
+func Noise(deg int) func(*Simulator) {
+	return func(s *Simulator) {
+		s.noise = deg
+	}
+}
+
+// DropRate is a functional option to set the probability (0...1) that a
+// byte of a reply gets lost.
+func DropRate(rate float64) func(*Simulator) {
+	return func(s *Simulator) {
+		s.dropRate = rate
+	}
+}
+
+// GarbleRate is a functional option to set the probability (0...1) that
+// a reply is garbled.
+func GarbleRate(rate float64) func(*Simulator) {
+	return func(s *Simulator) {
+		s.garbleRate = rate
+	}
+}
+
+// Serve processes the commands received on the connection until it
+// returns an error. Read timeouts (io.EOF) are ignored.
+func (s *Simulator) Serve(conn io.ReadWriter) error {
+
+	// a GS-232 controller doesn't receive prompts
+	rd := framing.NewReader(conn, framing.Prompts())
+
+	for {
+		frame, err := rd.ReadFrame()
+		if err == io.EOF {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		reply := s.execute(frame.Data)
+		if _, err := conn.Write(s.distort(reply)); err != nil {
+			return err
+		}
+	}
+}
+
+// ListenTCP accepts connections on the address and serves each of them
+// in a separate go routine. This function blocks until the listener
+// fails.
+func (s *Simulator) ListenTCP(address string) error {
+
+	ln, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	defer ln.Close()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		log.Printf("client connected (%v)\n", conn.RemoteAddr())
+
+		go func() {
+			defer conn.Close()
+			if err := s.Serve(conn); err != nil {
+				log.Printf("client disconnected (%v): %v\n", conn.RemoteAddr(), err)
+			}
+		}()
+	}
+}
+
+// execute a GS-232 command and return the reply of the controller.
+func (s *Simulator) execute(cmd string) string {
+
+	cmd = strings.TrimSpace(cmd)
+	if len(cmd) == 0 {
+		return "?>"
+	}
+
+	r := s.rotator
+	args := strings.TrimSpace(cmd[1:])
+
+	switch strings.ToUpper(cmd[0:1]) {
+	// query azimuth (C) or azimuth + elevation (C2)
+	case "C":
+		if args == "2" {
+			return s.heading(true, true)
+		}
+		return s.heading(true, false)
+	// query elevation
+	case "B":
+		return s.heading(false, true)
+	// turn to azimuth
+	case "M":
+		az, err := strconv.Atoi(args)
+		if err != nil {
+			return "?>"
+		}
+		r.SetAzimuth(az)
+	// turn to azimuth + elevation
+	case "W":
+		fields := strings.Fields(args)
+		if len(fields) != 2 {
+			return "?>"
+		}
+		az, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return "?>"
+		}
+		el, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return "?>"
+		}
+		r.SetAzimuth(az)
+		r.SetElevation(el)
+	// rotate clockwise / counter clockwise
+	case "R":
+		r.SetAzimuth(r.Serialize().Config.AzimuthMax)
+	case "L":
+		r.SetAzimuth(r.Serialize().Config.AzimuthMin)
+	// rotate up / down
+	case "U":
+		r.SetElevation(r.Serialize().Config.ElevationMax)
+	case "D":
+		r.SetElevation(r.Serialize().Config.ElevationMin)
+	// stop
+	case "A":
+		r.StopAzimuth()
+	case "E":
+		r.StopElevation()
+	case "S":
+		r.Stop()
+	default:
+		return "?>"
+	}
+
+	return "\r"
+}
+
+// heading returns the formatted heading of the rotator.
+func (s *Simulator) heading(az, el bool) string {
+
+	obj := s.rotator.Serialize()
+	azimuth := obj.Heading.Azimuth
+	// GS-232 controllers report headings in the overlap as > 360°
+	if obj.Config.AzimuthOverlap {
+		azimuth += 360
+	}
+	azimuth = s.addNoise(azimuth)
+	elevation := s.addNoise(obj.Heading.Elevation)
+
+	if s.protocol == GS232B {
+		switch {
+		case az && el:
+			return fmt.Sprintf("AZ=%.3d  EL=%.3d\r\n", azimuth, elevation)
+		case el:
+			return fmt.Sprintf("EL=%.3d\r\n", elevation)
+		default:
+			return fmt.Sprintf("AZ=%.3d\r\n", azimuth)
+		}
+	}
+
+	switch {
+	case az && el:
+		return fmt.Sprintf("+0%.3d+0%.3d\r\n", azimuth, elevation)
+	case el:
+		return fmt.Sprintf("+0%.3d\r\n", elevation)
+	default:
+		return fmt.Sprintf("+0%.3d\r\n", azimuth)
+	}
+}
+
+// addNoise adds a random deviation to the heading.
+func (s *Simulator) addNoise(value int) int {
+	if s.noise <= 0 {
+		return value
+	}
+
+	s.Lock()
+	value += s.rnd.Intn(2*s.noise+1) - s.noise
+	s.Unlock()
+
+	if value < 0 {
+		value = 0
+	}
+	return value
+}
+
+// distort garbles the reply and drops bytes according to the
+// configured fault rates.
+func (s *Simulator) distort(reply string) []byte {
+	s.Lock()
+	defer s.Unlock()
+
+	data := []byte(reply)
+
+	if s.garbleRate > 0 && s.rnd.Float64() < s.garbleRate {
+		for i := range data {
+			if s.rnd.Intn(2) == 0 {
+				data[i] = byte(0x20 + s.rnd.Intn(0x5f))
+			}
+		}
+	}
+
+	if s.dropRate <= 0 {
+		return data
+	}
+
+	res := make([]byte, 0, len(data))
+	for _, b := range data {
+		if s.rnd.Float64() < s.dropRate {
+			continue
+		}
+		res = append(res, b)
+	}
+
+	return res
+}
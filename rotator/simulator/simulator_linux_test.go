@@ -0,0 +1,51 @@
+package simulator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dh1tw/remoteRotator/rotator"
+	"github.com/dh1tw/remoteRotator/rotator/dummy"
+	"github.com/dh1tw/remoteRotator/rotator/yaesu"
+)
+
+// TestYaesuDriver connects the yaesu driver to the simulator through a
+// pseudo terminal.
+func TestYaesuDriver(t *testing.T) {
+
+	pty, err := OpenPty()
+	if err != nil {
+		t.Skipf("pseudo terminals not available: %v", err)
+	}
+	defer pty.Close()
+
+	d, err := dummy.New(dummy.AzimuthSpeed(50),
+		dummy.EventHandler(func(rotator.Rotator, rotator.Heading) {}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	go New(d, Protocol(GS232B)).Serve(pty.Master)
+
+	y, err := yaesu.New(yaesu.Portname(pty.Name),
+		yaesu.UpdateInterval(time.Millisecond*100),
+		yaesu.ErrorCh(make(chan struct{})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer y.Close()
+
+	if err := y.SetAzimuth(90); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(time.Second * 5)
+	for time.Now().Before(deadline) {
+		if az := y.Azimuth(); az >= 88 && az <= 92 {
+			return
+		}
+		time.Sleep(time.Millisecond * 50)
+	}
+	t.Fatalf("expected azimuth 90, got %d", y.Azimuth())
+}
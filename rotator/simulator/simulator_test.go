@@ -0,0 +1,82 @@
+package simulator
+
+import (
+	"testing"
+
+	"github.com/dh1tw/remoteRotator/rotator"
+)
+
+// fakeRotator reports a fixed heading and records the presets
+type fakeRotator struct {
+	rotator.Rotator
+	azPreset int
+	elPreset int
+	stopped  bool
+}
+
+func (r *fakeRotator) Serialize() rotator.Object {
+	return rotator.Object{Heading: rotator.Heading{Azimuth: 123, Elevation: 45}}
+}
+func (r *fakeRotator) SetAzimuth(az int) error   { r.azPreset = az; return nil }
+func (r *fakeRotator) SetElevation(el int) error { r.elPreset = el; return nil }
+func (r *fakeRotator) Stop() error               { r.stopped = true; return nil }
+
+func TestExecute(t *testing.T) {
+
+	tt := []struct {
+		name     string
+		protocol string
+		cmd      string
+		exp      string
+		azPreset int
+		elPreset int
+	}{
+		{"C GS232A", GS232A, "C", "+0123\r\n", 0, 0},
+		{"C2 GS232A", GS232A, "C2", "+0123+0045\r\n", 0, 0},
+		{"B GS232A", GS232A, "B", "+0045\r\n", 0, 0},
+		{"C GS232B", GS232B, "C", "AZ=123\r\n", 0, 0},
+		{"C2 GS232B", GS232B, "C2", "AZ=123  EL=045\r\n", 0, 0},
+		{"M", GS232A, "M270", "\r", 270, 0},
+		{"W", GS232A, "W180 030", "\r", 180, 30},
+		{"invalid M", GS232A, "Mabc", "?>", 0, 0},
+		{"invalid W", GS232A, "W180", "?>", 0, 0},
+		{"unknown", GS232A, "X", "?>", 0, 0},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			r := &fakeRotator{}
+			s := New(r, Protocol(tc.protocol))
+			if res := s.execute(tc.cmd); res != tc.exp {
+				t.Fatalf("expected %q, got %q", tc.exp, res)
+			}
+			if r.azPreset != tc.azPreset || r.elPreset != tc.elPreset {
+				t.Fatalf("expected presets %d/%d, got %d/%d",
+					tc.azPreset, tc.elPreset, r.azPreset, r.elPreset)
+			}
+		})
+	}
+}
+
+func TestStop(t *testing.T) {
+	r := &fakeRotator{}
+	s := New(r)
+	if res := s.execute("S"); res != "\r" {
+		t.Fatalf("unexpected reply %q", res)
+	}
+	if !r.stopped {
+		t.Fatal("rotator not stopped")
+	}
+}
+
+func TestDistort(t *testing.T) {
+	s := New(&fakeRotator{}, DropRate(1))
+	if res := s.distort("+0123\r\n"); len(res) != 0 {
+		t.Fatalf("expected all bytes to be dropped, got %q", res)
+	}
+
+	s = New(&fakeRotator{})
+	if res := s.distort("+0123\r\n"); string(res) != "+0123\r\n" {
+		t.Fatalf("expected unmodified reply, got %q", res)
+	}
+}
@@ -0,0 +1,126 @@
+package rotator
+
+// Levels of a Status
+const (
+	StatusInfo    = "info"
+	StatusWarning = "warning"
+	StatusFault   = "fault"
+)
+
+// Status is a condition of a rotator raised by one of the layers put in
+// front of the rotator driver (e.g. the safety monitor).
+type Status struct {
+	Source  string `json:"source"`  // layer which raised the status
+	Level   string `json:"level"`   // info, warning or fault
+	Code    string `json:"code"`    // machine readable reason (e.g. "stall")
+	Message string `json:"message"` // human readable description
+	Locked  bool   `json:"locked"`  // moves are refused while locked
+}
+
+// StatusHandler is a callback through which the layers report changes
+// of their status.
+type StatusHandler func(Rotator, Status)
+
+// Wrapper is implemented by layers which are put in front of a rotator
+// and wrap another Rotator.
+type Wrapper interface {
+	Unwrap() Rotator
+}
+
+// Acknowledger is implemented by layers which lock out moves after a
+// fault until an operator acknowledges it.
+type Acknowledger interface {
+	Acknowledge() error
+}
+
+// MoveChecker is implemented by layers which refuse moves in some
+// states (e.g. while locked out after a fault). CanMove returns the
+// error with which a move would currently be refused, so that moves of
+// several rotators can be checked before any of them is commanded.
+type MoveChecker interface {
+	CanMove() error
+}
+
+// Rebaseliner is implemented by layers which track the heading of the
+// rotator. The heading can jump without a movement (e.g. when the
+// calibration changes); Rebaseline makes the layer accept the current
+// heading as its new reference.
+type Rebaseliner interface {
+	Rebaseline()
+}
+
+// Composite is implemented by rotators which command other rotators
+// (e.g. a composite az/el rotator). Frontends bind the members to their
+// clients the same way as the rotator itself, so that the roles and
+// leases of the members apply as well.
+type Composite interface {
+	// WithMembers returns a view of the rotator which commands the
+	// members returned by bind.
+	WithMembers(bind func(Rotator) Rotator) Rotator
+}
+
+// Layers returns the rotator and all rotators wrapped by it, starting
+// with the outermost layer.
+func Layers(r Rotator) []Rotator {
+	layers := []Rotator{}
+	for r != nil {
+		layers = append(layers, r)
+		w, ok := r.(Wrapper)
+		if !ok {
+			break
+		}
+		r = w.Unwrap()
+	}
+	return layers
+}
+
+// As finds the first layer of the rotator which is of type T.
+func As[T any](r Rotator) (T, bool) {
+	for _, l := range Layers(r) {
+		if t, ok := l.(T); ok {
+			return t, true
+		}
+	}
+	var t T
+	return t, false
+}
+
+// Acknowledge acknowledges the faults of all layers of the rotator. It
+// returns false if none of the layers supports acknowledging.
+func Acknowledge(r Rotator) (bool, error) {
+	found := false
+	for _, l := range Layers(r) {
+		a, ok := l.(Acknowledger)
+		if !ok {
+			continue
+		}
+		found = true
+		if err := a.Acknowledge(); err != nil {
+			return found, err
+		}
+	}
+	return found, nil
+}
+
+// Rebaseline rebaselines all layers of the rotator which track its
+// heading.
+func Rebaseline(r Rotator) {
+	for _, l := range Layers(r) {
+		if rb, ok := l.(Rebaseliner); ok {
+			rb.Rebaseline()
+		}
+	}
+}
+
+// CanMove returns the error with which a layer of the rotator would
+// currently refuse a move, or nil if none of the layers refuses moves.
+func CanMove(r Rotator) error {
+	for _, l := range Layers(r) {
+		if mc, ok := l.(MoveChecker); ok {
+			if err := mc.CanMove(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
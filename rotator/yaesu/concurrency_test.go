@@ -148,31 +148,31 @@ func randomAccess(r *Yaesu, timeout time.Duration, c *apiCallCounter,
 		case 7:
 			err := r.SetAzimuth(rand.Intn(450))
 			if err != nil {
-				t.Fatalf("unexpected error %v", err)
+				t.Errorf("unexpected error %v", err)
 			}
 			atomic.AddUint64(&c.setAzimuth, 1)
 		case 8:
 			err := r.SetElevation(rand.Intn(180))
 			if err != nil {
-				t.Fatalf("unexpected error %v", err)
+				t.Errorf("unexpected error %v", err)
 			}
 			atomic.AddUint64(&c.setElevation, 1)
 		case 9:
 			err := r.Stop()
 			if err != nil {
-				t.Fatalf("unexpected error %v", err)
+				t.Errorf("unexpected error %v", err)
 			}
 			atomic.AddUint64(&c.stop, 1)
 		case 10:
 			err := r.StopElevation()
 			if err != nil {
-				t.Fatalf("unexpected error %v", err)
+				t.Errorf("unexpected error %v", err)
 			}
 			atomic.AddUint64(&c.stopElevation, 1)
 		case 11:
 			err := r.StopAzimuth()
 			if err != nil {
-				t.Fatalf("unexpected error %v", err)
+				t.Errorf("unexpected error %v", err)
 			}
 			atomic.AddUint64(&c.stopAzimuth, 1)
 		}
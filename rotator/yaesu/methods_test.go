@@ -365,6 +365,30 @@ func TestParseMsg(t *testing.T) {
 	}
 }
 
+func TestInitialPreset(t *testing.T) {
+
+	yaesu := &Yaesu{
+		headingPatternGS232A: getProtocolRegExp("GS232A", t),
+		headingPatternGS232B: getProtocolRegExp("GS232B", t),
+	}
+	InitialAzPreset(120)(yaesu)
+
+	yaesu.parseMsg("+0030+0010")
+
+	if yaesu.AzPreset() != 120 {
+		t.Fatalf("expected restored azimuth preset 120, got %d", yaesu.AzPreset())
+	}
+	if yaesu.ElPreset() != 10 {
+		t.Fatalf("expected elevation preset initialized with 10, got %d", yaesu.ElPreset())
+	}
+
+	// the restored preset is only used on startup
+	yaesu.parseMsg("+0040+0010")
+	if yaesu.AzPreset() != 120 || yaesu.Azimuth() != 40 {
+		t.Fatalf("unexpected heading %d (preset %d)", yaesu.Azimuth(), yaesu.AzPreset())
+	}
+}
+
 func TestParseGS232A(t *testing.T) {
 
 	tt := []struct {
@@ -454,15 +478,15 @@ func TestRead(t *testing.T) {
 		sp: dp,
 	}
 
-	expValue := "+0300+0150\r\n"
-	dp.rxBuf.WriteString(expValue)
+	expValue := "+0300+0150"
+	dp.rxBuf.WriteString(expValue + "\r\n")
 
 	res, err := yaesu.read()
 	if err != nil {
 		t.Fatalf("read error: %v", err)
 	}
-	if res != expValue {
-		t.Fatalf("expected %s, got %s", expValue, res)
+	if res.Data != expValue {
+		t.Fatalf("expected %s, got %s", expValue, res.Data)
 	}
 
 	// if dp.rxBuf != nil || dp.sendBuf != nil {
@@ -470,6 +494,56 @@ func TestRead(t *testing.T) {
 	// }
 }
 
+func TestNextPollInterval(t *testing.T) {
+
+	slow := time.Second
+	fast := time.Millisecond * 100
+
+	tt := []struct {
+		name         string
+		fastInterval time.Duration
+		azimuth      int
+		azPreset     int
+		hasElevation bool
+		elevation    int
+		elPreset     int
+		lastCmd      time.Duration
+		lastMove     time.Duration
+		expInterval  time.Duration
+	}{
+		{"adaptive polling disabled", 0, 10, 90, false, 0, 0, 0, 0, slow},
+		{"idle", fast, 90, 90, false, 0, 0, time.Minute, time.Minute, slow},
+		{"azimuth moving towards preset", fast, 10, 90, false, 0, 0, time.Minute, time.Minute, fast},
+		{"azimuth within tolerance", fast, 89, 90, false, 0, 0, time.Minute, time.Minute, slow},
+		{"elevation moving towards preset", fast, 90, 90, true, 10, 45, time.Minute, time.Minute, fast},
+		{"elevation not supported", fast, 90, 90, false, 10, 45, time.Minute, time.Minute, slow},
+		{"just after a command", fast, 90, 90, false, 0, 0, time.Second, time.Minute, fast},
+		{"just after a move", fast, 90, 90, false, 0, 0, time.Minute, time.Second, fast},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			yaesu := &Yaesu{
+				pollingInterval:     slow,
+				fastPollingInterval: tc.fastInterval,
+				fastPollingHold:     time.Second * 3,
+				azInitialized:       true,
+				elInitialized:       true,
+				azimuth:             tc.azimuth,
+				azPreset:            tc.azPreset,
+				hasElevation:        tc.hasElevation,
+				elevation:           tc.elevation,
+				elPreset:            tc.elPreset,
+				lastCmdTs:           time.Now().Add(-tc.lastCmd),
+				lastMoveTs:          time.Now().Add(-tc.lastMove),
+			}
+			if res := yaesu.nextPollInterval(); res != tc.expInterval {
+				t.Fatalf("expected polling interval %v, got %v", tc.expInterval, res)
+			}
+		})
+	}
+}
+
 func replaceLineBreaks(input []byte) []byte {
 	s := bytes.Replace(input, []byte("\n"), []byte("\\n"), -1)
 	return bytes.Replace(s, []byte("\r"), []byte("\\r"), -1)
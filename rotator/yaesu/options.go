@@ -35,6 +35,24 @@ func UpdateInterval(d time.Duration) func(*Yaesu) {
 	}
 }
 
+// FastUpdateInterval is a functional option to set the frequency by which
+// the rotator will be queried while it is moving or shortly after a
+// command has been sent. A value of 0 disables the adaptive polling.
+func FastUpdateInterval(d time.Duration) func(*Yaesu) {
+	return func(r *Yaesu) {
+		r.fastPollingInterval = d
+	}
+}
+
+// FastUpdateHold is a functional option to set how long the rotator
+// will be queried with the FastUpdateInterval after a command has been
+// sent or the heading has changed.
+func FastUpdateHold(d time.Duration) func(*Yaesu) {
+	return func(r *Yaesu) {
+		r.fastPollingHold = d
+	}
+}
+
 // EventHandler sets a callback function through which the rotator
 // will report Event
 func EventHandler(h func(rotator.Rotator, rotator.Heading)) func(*Yaesu) {
@@ -44,6 +62,7 @@ func EventHandler(h func(rotator.Rotator, rotator.Heading)) func(*Yaesu) {
 }
 
 // Baudrate is a functional option to set the baurate of the serial port.
+// A baudrate provided in the portname url takes precedence.
 func Baudrate(baudrate int) func(*Yaesu) {
 	return func(r *Yaesu) {
 		r.spBaudrate = baudrate
@@ -51,7 +70,9 @@ func Baudrate(baudrate int) func(*Yaesu) {
 }
 
 // Portname is a functional option to set the portname of the serial port.
-// On Windows this will be "COMx", on Linux & MacOS "/dev/tty/xxx"
+// On Windows this will be "COMx", on Linux & MacOS "/dev/tty/xxx". Instead
+// of a portname, an url like serial:///dev/ttyUSB0?baud=4800&parity=N,
+// tcp://host:port or rfc2217://host:port can be provided.
 func Portname(pn string) func(*Yaesu) {
 	return func(r *Yaesu) {
 		r.spPortName = pn
@@ -79,6 +100,24 @@ func AzimuthStop(stop int) func(*Yaesu) {
 	}
 }
 
+// InitialAzPreset is a functional option to restore the azimuth preset
+// (e.g. after a restart while the rotator was turning). By default the
+// preset is initialized with the first azimuth read from the rotator.
+func InitialAzPreset(az int) func(*Yaesu) {
+	return func(r *Yaesu) {
+		r.initAzPreset = &az
+	}
+}
+
+// InitialElPreset is a functional option to restore the elevation
+// preset. By default the preset is initialized with the first elevation
+// read from the rotator.
+func InitialElPreset(el int) func(*Yaesu) {
+	return func(r *Yaesu) {
+		r.initElPreset = &el
+	}
+}
+
 // ElevationMin is a functional option to set the minimum elevation angle.
 func ElevationMin(min int) func(*Yaesu) {
 	return func(r *Yaesu) {
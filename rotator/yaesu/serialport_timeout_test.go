@@ -44,3 +44,36 @@ func TestSerialPortReadTimeout(t *testing.T) {
 	// workaround - time for teardown needed - otherwise the test crashes on macos
 	dp.Close()
 }
+
+func TestWatchdogMinimumTime(t *testing.T) {
+
+	dp := dummyPort{
+		sendBuf: &bytes.Buffer{},
+		rxBuf:   &bytes.Buffer{},
+	}
+
+	// with fast polling, 5 missed polls take only 100ms; the
+	// connection must survive until the minimum watchdog time
+	yaesu := Yaesu{
+		sp:              &dp,
+		closeCh:         make(chan struct{}),
+		errorCh:         make(chan struct{}),
+		pollingInterval: time.Millisecond * 20,
+		minWatchdog:     time.Millisecond * 500,
+	}
+
+	start := time.Now()
+	go yaesu.start()
+
+	select {
+	case <-yaesu.errorCh:
+		if d := time.Since(start); d < yaesu.minWatchdog {
+			t.Fatalf("watchdog launched after %v, before the minimum watchdog time", d)
+		}
+		yaesu.Close()
+	case <-time.After(time.Second * 2):
+		t.Fatal("watchdog did not launch")
+	}
+
+	dp.Close()
+}
@@ -1,19 +1,16 @@
 package yaesu
 
 import (
-	"bufio"
 	"fmt"
 	"io"
-	"net"
 	"regexp"
 	"strconv"
-	"strings"
 	"sync"
 	"time"
 
-	serial "github.com/tarm/serial"
-
 	"github.com/dh1tw/remoteRotator/rotator"
+	"github.com/dh1tw/remoteRotator/rotator/framing"
+	"github.com/dh1tw/remoteRotator/rotator/port"
 )
 
 // Yaesu is the implementation of the Yaesu GS232A/B rotator protocol
@@ -30,15 +27,23 @@ type Yaesu struct {
 	azPreset             int
 	elevation            int
 	elPreset             int
+	initAzPreset         *int // restored preset, instead of the first reading
+	initElPreset         *int // restored preset, instead of the first reading
 	hasAzimuth           bool
 	hasElevation         bool
 	azInitialized        bool
 	elInitialized        bool
 	pollingInterval      time.Duration
-	pollingTicker        *time.Ticker
+	fastPollingInterval  time.Duration
+	fastPollingHold      time.Duration
+	pollWakeCh           chan struct{}
+	lastCmdTs            time.Time
+	lastMoveTs           time.Time
 	eventHandler         func(rotator.Rotator, rotator.Heading)
 	sp                   io.ReadWriteCloser
 	spRead               sync.Mutex
+	framer               *framing.Reader
+	framerInit           sync.Once
 	spWrite              sync.Mutex
 	spPortName           string
 	spBaudrate           int
@@ -47,7 +52,9 @@ type Yaesu struct {
 	closer               sync.Once
 	headingPatternGS232A *regexp.Regexp
 	headingPatternGS232B *regexp.Regexp
-	watchdogTs           time.Time
+	missedPolls          int
+	lastReplyTs          time.Time
+	minWatchdog          time.Duration
 }
 
 // New creates a new Yaesu object which satisfies implicitly the
@@ -55,8 +62,11 @@ type Yaesu struct {
 // functional options.
 // Default settings are:
 // hasAzimuth: true,
-// portname: /dev/ttyACM0 (or 127.0.0.1:6001),
+// portname: /dev/ttyACM0 (or an url, e.g. tcp://127.0.0.1:6001),
 // pollingInterval: 5sec,
+// fastPollingInterval: 0 (adaptive polling disabled),
+// fastPollingHold: 3sec,
+// watchdog: 5 missed polls, but at least 5sec,
 // baudrate: 9600.
 func New(opts ...func(*Yaesu)) (*Yaesu, error) {
 
@@ -75,12 +85,15 @@ func New(opts ...func(*Yaesu)) (*Yaesu, error) {
 	r := &Yaesu{
 		hasAzimuth:           true,
 		pollingInterval:      time.Second * 5,
+		fastPollingHold:      time.Second * 3,
+		pollWakeCh:           make(chan struct{}, 1),
 		spPortName:           "/dev/ttyACM0",
 		spBaudrate:           9600,
 		headingPatternGS232A: headingPattern232A,
 		headingPatternGS232B: headingPattern232B,
 		azimuthMax:           450,
 		elevationMax:         180,
+		minWatchdog:          time.Second * 5,
 		closeCh:              make(chan struct{}),
 	}
 
@@ -88,27 +101,11 @@ func New(opts ...func(*Yaesu)) (*Yaesu, error) {
 		opt(r)
 	}
 
-	if strings.Contains(r.spPortName, ":") {
-		tcpConn, err := net.Dial("tcp", r.spPortName)
-		if err != nil {
-			return nil, err
-		}
-		r.sp = tcpConn
-	} else {
-		spConfig := &serial.Config{
-			Name:        r.spPortName,
-			Baud:        r.spBaudrate,
-			ReadTimeout: time.Second,
-			Parity:      serial.ParityNone,
-			Size:        8,
-			StopBits:    1,
-		}
-		sp, err := serial.OpenPort(spConfig)
-		if err != nil {
-			return nil, err
-		}
-		r.sp = sp
+	sp, err := port.Open(r.spPortName, port.Baudrate(r.spBaudrate))
+	if err != nil {
+		return nil, err
 	}
+	r.sp = sp
 
 	go r.start()
 
@@ -124,9 +121,6 @@ func (r *Yaesu) Close() {
 	defer r.spWrite.Unlock()
 	defer r.spRead.Unlock()
 
-	if r.pollingTicker != nil {
-		r.pollingTicker.Stop()
-	}
 	// makes sure that the serial port and the event loop just gets closed once
 	r.closer.Do(func() {
 		close(r.closeCh)
@@ -139,15 +133,65 @@ func (r *Yaesu) Close() {
 func (r *Yaesu) resetWatchdog() {
 	r.Lock()
 	defer r.Unlock()
-	r.watchdogTs = time.Now()
+	r.missedPolls = 0
+	r.lastReplyTs = time.Now()
 }
 
-// checkWatchdog compares the watchdog timestamp with the current time
-// and returns true if this value is greater than 5x updateInterval.
+// checkWatchdog returns true if the Yaesu rotator hasn't replied to
+// the last 5 polls. Since the watchdog counts polls, it scales with the
+// (adaptive) polling interval; but with fast polling a short hiccup of
+// the serial line must not cut the connection, so the rotator must also
+// have been silent for the minimum watchdog time.
 func (r *Yaesu) checkWatchdog() bool {
 	r.Lock()
 	defer r.Unlock()
-	return time.Since(r.watchdogTs) > 5*r.pollingInterval
+	return r.missedPolls >= 5 && time.Since(r.lastReplyTs) >= r.minWatchdog
+}
+
+// nextPollInterval returns the interval until the next poll. While the
+// rotator is moving or shortly after a command has been sent, the rotator
+// is polled with the fast polling interval. Otherwise it backs off to
+// the regular polling interval.
+func (r *Yaesu) nextPollInterval() time.Duration {
+	r.RLock()
+	defer r.RUnlock()
+
+	if r.fastPollingInterval <= 0 || r.fastPollingInterval >= r.pollingInterval {
+		return r.pollingInterval
+	}
+
+	if r.azInitialized && abs(r.azimuth-r.azPreset) > 1 {
+		return r.fastPollingInterval
+	}
+
+	if r.hasElevation && r.elInitialized && abs(r.elevation-r.elPreset) > 1 {
+		return r.fastPollingInterval
+	}
+
+	if time.Since(r.lastCmdTs) < r.fastPollingHold ||
+		time.Since(r.lastMoveTs) < r.fastPollingHold {
+		return r.fastPollingInterval
+	}
+
+	return r.pollingInterval
+}
+
+// commandSent must be called (with the lock held) whenever a command
+// has been sent to the rotator. It notifies the polling routine so
+// that it can switch to the fast polling interval.
+func (r *Yaesu) commandSent() {
+	r.lastCmdTs = time.Now()
+	select {
+	case r.pollWakeCh <- struct{}{}:
+	default:
+	}
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
 }
 
 // Start the main event loop for the serial port.
@@ -160,10 +204,7 @@ func (r *Yaesu) checkWatchdog() bool {
 func (r *Yaesu) start() {
 	defer r.Close()
 
-	r.Lock()
-	r.pollingTicker = time.NewTicker(r.pollingInterval)
-	r.watchdogTs = time.Now()
-	r.Unlock()
+	r.resetWatchdog()
 
 	// start async polling
 	go r.poll()
@@ -179,7 +220,7 @@ func (r *Yaesu) start() {
 
 		// this is a blocking function which will run eventually
 		// into a timeout if no data is received
-		msg, err := r.read()
+		frame, err := r.read()
 		if err != nil {
 			// serialport read is expected to timeout after 100ms
 			// to unblock this routine
@@ -192,7 +233,15 @@ func (r *Yaesu) start() {
 			return // exit
 		}
 		r.resetWatchdog()
-		r.parseMsg(msg)
+
+		// the rotator responds with a prompt to commands it doesn't understand
+		if frame.Prompt {
+			if frame.Command != "" {
+				fmt.Printf("%s rejected command %s\n", r.name, frame.Command)
+			}
+			continue
+		}
+		r.parseMsg(frame.Data)
 	}
 }
 
@@ -200,19 +249,41 @@ func (r *Yaesu) start() {
 func (r *Yaesu) poll() {
 	defer r.Close()
 
+	interval := r.nextPollInterval()
+	nextPoll := time.Now().Add(interval)
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+
 	for {
 		select {
-		case <-r.pollingTicker.C:
-			if err := r.query(); err != nil {
-				fmt.Println("serial port write error:", err)
+		case <-timer.C:
+			if r.checkWatchdog() {
+				fmt.Println("communication lost with Yaesu rotator")
 				close(r.errorCh)
 				return
 			}
-			if r.checkWatchdog() {
-				fmt.Println("communication lost with Yaesu rotator")
+			if err := r.query(); err != nil {
+				fmt.Println("serial port write error:", err)
 				close(r.errorCh)
 				return
 			}
+			r.Lock()
+			r.missedPolls++
+			r.Unlock()
+
+			interval = r.nextPollInterval()
+			nextPoll = time.Now().Add(interval)
+			timer.Reset(interval)
+
+		// a command has been sent; poll earlier if the (fast) polling
+		// interval requires it
+		case <-r.pollWakeCh:
+			interval = r.nextPollInterval()
+			if time.Until(nextPoll) > interval {
+				nextPoll = time.Now().Add(interval)
+				timer.Reset(interval)
+			}
+
 		// when closing has been signaled, stop polling and return
 		case <-r.closeCh:
 			return
@@ -220,19 +291,34 @@ func (r *Yaesu) poll() {
 	}
 }
 
-// read from the Yaesu rotator through this wrapper function
-func (r *Yaesu) read() (string, error) {
+// frames returns the framing reader of the serial port. It is created
+// on first use and keeps partially received frames between reads.
+func (r *Yaesu) frames() *framing.Reader {
+	r.framerInit.Do(func() {
+		r.framer = framing.NewReader(r.sp)
+	})
+	return r.framer
+}
+
+// read a frame from the Yaesu rotator through this wrapper function
+func (r *Yaesu) read() (framing.Frame, error) {
 	r.spRead.Lock()
 	defer r.spRead.Unlock()
-	a, b := bufio.NewReader(r.sp).ReadString('\n')
-	return a, b
+	return r.frames().ReadFrame()
 }
 
 // request Azimuth + Elevation from Yaesu rotator
 func (r *Yaesu) query() error {
+	// the response is expected before the query is written, so that a
+	// fast response can't arrive before it is expected; if the write
+	// fails, the expectation expires with the response timeout
+	r.frames().Expect("C2")
+
 	//query azimuth + elevation
-	_, err := r.write([]byte("C2\r\n"))
-	return err
+	if _, err := r.write([]byte("C2\r\n")); err != nil {
+		return err
+	}
+	return nil
 }
 
 // all functions write to the Yaesu rotator / serial port through this wrapper function
@@ -263,14 +349,19 @@ func (r *Yaesu) parseMsg(msg string) {
 	az, ok := res["azimuth"]
 	if ok {
 		// on startup we initialize azPreset with the current azimuth position
+		// unless a preset has been restored
 		if !r.azInitialized {
 			r.azPreset = az
+			if r.initAzPreset != nil {
+				r.azPreset = *r.initAzPreset
+			}
 			r.azInitialized = true
 			gotNewValue = true
 		}
 
 		if r.azimuth != az {
 			r.azimuth = az
+			r.lastMoveTs = time.Now()
 			gotNewValue = true
 		}
 	}
@@ -278,14 +369,19 @@ func (r *Yaesu) parseMsg(msg string) {
 	el, ok := res["elevation"]
 	if ok {
 		// on startup we initialize elPreset with the current elevation position
+		// unless a preset has been restored
 		if !r.elInitialized {
 			r.elPreset = el
+			if r.initElPreset != nil {
+				r.elPreset = *r.initElPreset
+			}
 			r.elInitialized = true
 			gotNewValue = true
 		}
 
 		if r.elevation != el {
 			r.elevation = el
+			r.lastMoveTs = time.Now()
 			gotNewValue = true
 		}
 	}
@@ -408,6 +504,7 @@ func (r *Yaesu) SetAzimuth(az int) error {
 	if _, err := r.write([]byte(fmt.Sprintf("M%.3d\r\n", az))); err != nil {
 		return err
 	}
+	r.commandSent()
 
 	return nil
 }
@@ -439,8 +536,8 @@ func (r *Yaesu) HasElevation() bool {
 // rotator shall turn to. Allowed values are 0 ... 180. Values outside
 // of this range will be clipped.
 func (r *Yaesu) SetElevation(el int) error {
-	r.RLock()
-	defer r.RUnlock()
+	r.Lock()
+	defer r.Unlock()
 
 	if !r.hasElevation {
 		return nil
@@ -459,6 +556,7 @@ func (r *Yaesu) SetElevation(el int) error {
 	if _, err := r.write([]byte(fmt.Sprintf("W%.3d %.3d\r\n", r.azPreset, r.elPreset))); err != nil {
 		return err
 	}
+	r.commandSent()
 
 	return nil
 }
@@ -474,6 +572,7 @@ func (r *Yaesu) Stop() error {
 	if _, err := r.write([]byte("S\r\n")); err != nil {
 		return err
 	}
+	r.commandSent()
 
 	return nil
 }
@@ -488,6 +587,7 @@ func (r *Yaesu) StopAzimuth() error {
 	if _, err := r.write([]byte("A\r\n")); err != nil {
 		return err
 	}
+	r.commandSent()
 
 	return nil
 }
@@ -502,6 +602,7 @@ func (r *Yaesu) StopElevation() error {
 	if _, err := r.write([]byte("E\r\n")); err != nil {
 		return err
 	}
+	r.commandSent()
 
 	return nil
 }
@@ -0,0 +1,75 @@
+package state
+
+import "github.com/dh1tw/remoteRotator/rotator"
+
+// Persist returns a view of the rotator which stores the commanded
+// presets. Stopping an axis clears its preset. If the store is nil, the
+// rotator is returned unchanged.
+func Persist(r rotator.Rotator, s *Store) rotator.Rotator {
+	if s == nil {
+		return r
+	}
+	return &persister{Rotator: r, store: s}
+}
+
+// persister is a layer which stores the commanded presets.
+type persister struct {
+	rotator.Rotator
+	store *Store
+}
+
+func (p *persister) SetAzimuth(az int) error {
+	if err := p.Rotator.SetAzimuth(az); err != nil {
+		return err
+	}
+	p.store.Update(p.Name(), func(r *Rotator) {
+		r.AzPreset = &az
+	})
+	return nil
+}
+
+func (p *persister) SetElevation(el int) error {
+	if err := p.Rotator.SetElevation(el); err != nil {
+		return err
+	}
+	p.store.Update(p.Name(), func(r *Rotator) {
+		r.ElPreset = &el
+	})
+	return nil
+}
+
+func (p *persister) StopAzimuth() error {
+	if err := p.Rotator.StopAzimuth(); err != nil {
+		return err
+	}
+	p.store.Update(p.Name(), func(r *Rotator) {
+		r.AzPreset = nil
+	})
+	return nil
+}
+
+func (p *persister) StopElevation() error {
+	if err := p.Rotator.StopElevation(); err != nil {
+		return err
+	}
+	p.store.Update(p.Name(), func(r *Rotator) {
+		r.ElPreset = nil
+	})
+	return nil
+}
+
+func (p *persister) Stop() error {
+	if err := p.Rotator.Stop(); err != nil {
+		return err
+	}
+	p.store.Update(p.Name(), func(r *Rotator) {
+		r.AzPreset = nil
+		r.ElPreset = nil
+	})
+	return nil
+}
+
+// Unwrap returns the persisted rotator.
+func (p *persister) Unwrap() rotator.Rotator {
+	return p.Rotator
+}
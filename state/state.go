@@ -0,0 +1,207 @@
+// Package state persists the state of the rotators which must survive a
+// restart of the server (e.g. the last commanded preset, the lease,
+// unacknowledged faults and calibration offsets) in a small JSON file.
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/dh1tw/remoteRotator/rotator"
+)
+
+// Rotator is the persisted state of a rotator.
+type Rotator struct {
+	AzPreset *int            `json:"az_preset,omitempty"` // nil: no pending preset
+	ElPreset *int            `json:"el_preset,omitempty"` // nil: no pending preset
+	Lease    *Lease          `json:"lease,omitempty"`
+	Fault    *rotator.Status `json:"fault,omitempty"`   // unacknowledged fault
+	Offsets  *Offsets        `json:"offsets,omitempty"` // calibration changed at runtime
+}
+
+// Offsets are the calibration offsets (in degrees) of a rotator.
+type Offsets struct {
+	Offset   int `json:"offset"`
+	Mounting int `json:"mounting"`
+}
+
+// Lease is the exclusive control of a rotator by a client.
+type Lease struct {
+	Owner    string    `json:"owner"`
+	Operator string    `json:"operator,omitempty"`
+	Expires  time.Time `json:"expires"`
+}
+
+// Store keeps the state of the rotators in memory and writes it to a
+// file in the background after a change. Changes within the delay are
+// written at once, which saves the SD cards of single board computers
+// while a rotator is tracking. The file is replaced atomically, so that
+// it doesn't get corrupted by a power failure.
+type Store struct {
+	sync.Mutex
+	saveMu   sync.Mutex // serializes the writes of the file
+	path     string
+	delay    time.Duration
+	dirty    bool               // the file is not up to date
+	pending  *time.Timer        // scheduled write of the file
+	rotators map[string]Rotator // key: rotator name
+}
+
+// Open reads the state from the file. If the file doesn't exist yet, it
+// is created with the first change. Configuration settings can be set
+// through functional options.
+// Default settings are:
+// delay: 2s.
+func Open(path string, opts ...func(*Store)) (*Store, error) {
+
+	s := &Store{
+		path:     path,
+		delay:    time.Second * 2,
+		rotators: make(map[string]Rotator),
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read state file: %v", err)
+	}
+
+	if err := json.Unmarshal(data, &s.rotators); err != nil {
+		return nil, fmt.Errorf("invalid state file %s: %v", path, err)
+	}
+
+	return s, nil
+}
+
+// Delay is a functional option to set the time after a change at which
+// the state is written to the file. A delay <= 0 writes every change
+// immediately.
+func Delay(d time.Duration) func(*Store) {
+	return func(s *Store) {
+		s.delay = d
+	}
+}
+
+// Get returns the state of a rotator.
+func (s *Store) Get(rName string) Rotator {
+	if s == nil {
+		return Rotator{}
+	}
+	s.Lock()
+	defer s.Unlock()
+	return s.rotators[rName]
+}
+
+// All returns the state of all rotators.
+func (s *Store) All() map[string]Rotator {
+	all := make(map[string]Rotator)
+	if s == nil {
+		return all
+	}
+	s.Lock()
+	defer s.Unlock()
+	for name, r := range s.rotators {
+		all[name] = r
+	}
+	return all
+}
+
+// Update modifies the state of a rotator and schedules writing it to
+// the file. The fields of the state must be replaced, not modified in
+// place. Errors are logged.
+func (s *Store) Update(rName string, f func(*Rotator)) {
+	if s == nil {
+		return
+	}
+
+	s.Lock()
+	r := s.rotators[rName]
+	f(&r)
+	if r == (Rotator{}) {
+		delete(s.rotators, rName)
+	} else {
+		s.rotators[rName] = r
+	}
+	s.dirty = true
+
+	if s.delay <= 0 {
+		s.Unlock()
+		s.Flush()
+		return
+	}
+	if s.pending == nil {
+		s.pending = time.AfterFunc(s.delay, s.Flush)
+	}
+	s.Unlock()
+}
+
+// Flush writes the pending changes to the file. Errors are logged.
+func (s *Store) Flush() {
+	if s == nil {
+		return
+	}
+
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+
+	s.Lock()
+	if s.pending != nil {
+		s.pending.Stop()
+		s.pending = nil
+	}
+	if !s.dirty {
+		s.Unlock()
+		return
+	}
+	s.dirty = false
+	data, err := json.MarshalIndent(s.rotators, "", "  ")
+	s.Unlock()
+
+	if err == nil {
+		err = s.save(data)
+	}
+	if err != nil {
+		log.Printf("unable to save state: %v\n", err)
+	}
+}
+
+// Close writes the pending changes to the file.
+func (s *Store) Close() {
+	s.Flush()
+}
+
+// save writes the state to a temporary file which then replaces the
+// state file.
+func (s *Store) save(data []byte) error {
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.path)
+}
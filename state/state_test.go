@@ -0,0 +1,153 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dh1tw/remoteRotator/rotator"
+	"github.com/dh1tw/remoteRotator/rotator/dummy"
+)
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := s.Get("rot"); r != (Rotator{}) {
+		t.Fatalf("expected empty state, got %+v", r)
+	}
+
+	az := 120
+	expires := time.Now().Add(time.Hour).Round(time.Second)
+	s.Update("rot", func(r *Rotator) {
+		r.AzPreset = &az
+		r.Lease = &Lease{Owner: "alice", Expires: expires}
+		r.Fault = &rotator.Status{Source: "monitor", Code: "stall", Locked: true}
+	})
+	s.Update("other", func(r *Rotator) {
+		r.AzPreset = &az
+	})
+	s.Update("other", func(r *Rotator) {
+		r.AzPreset = nil
+	})
+
+	// restart
+	s.Close()
+	s, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := s.Get("rot")
+	if r.AzPreset == nil || *r.AzPreset != 120 || r.ElPreset != nil {
+		t.Fatalf("unexpected presets %+v", r)
+	}
+	if r.Lease == nil || r.Lease.Owner != "alice" || !r.Lease.Expires.Equal(expires) {
+		t.Fatalf("unexpected lease %+v", r.Lease)
+	}
+	if r.Fault == nil || r.Fault.Code != "stall" {
+		t.Fatalf("unexpected fault %+v", r.Fault)
+	}
+	if all := s.All(); len(all) != 1 {
+		t.Fatalf("expected empty state to be removed, got %v", all)
+	}
+
+	if err := os.WriteFile(path, []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(path); err == nil {
+		t.Fatal("expected invalid state file to be rejected")
+	}
+}
+
+func TestDelayedSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	s, err := Open(path, Delay(time.Millisecond*100))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// rapid changes are written at once after the delay
+	for az := 0; az < 10; az++ {
+		s.Update("rot", func(r *Rotator) {
+			r.AzPreset = &az
+		})
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected state not to be written yet, got %v", err)
+	}
+
+	time.Sleep(time.Millisecond * 300)
+	s2, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := s2.Get("rot"); r.AzPreset == nil || *r.AzPreset != 9 {
+		t.Fatalf("unexpected state %+v", r)
+	}
+
+	// pending changes are written on close
+	s.Update("rot", func(r *Rotator) {
+		r.AzPreset = nil
+	})
+	s.Close()
+	s2, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := s2.Get("rot"); r != (Rotator{}) {
+		t.Fatalf("expected empty state, got %+v", r)
+	}
+}
+
+func TestNilStore(t *testing.T) {
+	var s *Store
+	s.Update("rot", func(r *Rotator) {})
+	s.Close()
+	if r := s.Get("rot"); r != (Rotator{}) {
+		t.Fatalf("expected empty state, got %+v", r)
+	}
+
+	d, _ := dummy.New()
+	defer d.Close()
+	if r := Persist(d, nil); r != rotator.Rotator(d) {
+		t.Fatal("expected rotator to be returned unchanged")
+	}
+}
+
+func TestPersist(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, _ := dummy.New(dummy.Name("rot"), dummy.HasElevation(true))
+	defer d.Close()
+	r := Persist(d, s)
+
+	r.SetAzimuth(90)
+	r.SetElevation(30)
+	if st := s.Get("rot"); st.AzPreset == nil || *st.AzPreset != 90 ||
+		st.ElPreset == nil || *st.ElPreset != 30 {
+		t.Fatalf("unexpected state %+v", st)
+	}
+
+	r.StopAzimuth()
+	if st := s.Get("rot"); st.AzPreset != nil || st.ElPreset == nil {
+		t.Fatalf("expected azimuth preset to be cleared, got %+v", st)
+	}
+
+	r.Stop()
+	if st := s.Get("rot"); st != (Rotator{}) {
+		t.Fatalf("expected presets to be cleared, got %+v", st)
+	}
+
+	if l := rotator.Layers(r); len(l) != 2 || l[1] != rotator.Rotator(d) {
+		t.Fatalf("unexpected layers %v", l)
+	}
+}
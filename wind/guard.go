@@ -0,0 +1,145 @@
+package wind
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Guard evaluates the readings of a weather station and parks the
+// rotators while the wind speed exceeds the threshold. The rotators are
+// released once the wind speed has stayed below the release speed for
+// the hold time.
+type Guard struct {
+	sync.Mutex
+	source     Source
+	parkers    []*Parker
+	threshold  float64
+	release    float64
+	holdTime   time.Duration
+	parked     bool
+	calmSince  time.Time
+	last       Reading
+	hasReading bool
+}
+
+// NewGuard returns a Guard which parks the rotators when the wind speed
+// reported by the source exceeds the threshold (in the unit of the
+// weather station). Configuration settings can be set through
+// functional options.
+// Default settings are:
+// release: 80% of the threshold,
+// holdTime: 10min.
+func NewGuard(src Source, threshold float64, opts ...func(*Guard)) (*Guard, error) {
+
+	g := &Guard{
+		source:    src,
+		threshold: threshold,
+		release:   threshold * 0.8,
+		holdTime:  time.Minute * 10,
+	}
+
+	for _, opt := range opts {
+		opt(g)
+	}
+
+	if g.threshold <= 0 {
+		return nil, fmt.Errorf("invalid wind speed threshold %v", g.threshold)
+	}
+	if g.release > g.threshold {
+		return nil, fmt.Errorf("release speed (%v) must not exceed the threshold (%v)",
+			g.release, g.threshold)
+	}
+
+	return g, nil
+}
+
+// ReleaseSpeed is a functional option to set the wind speed below which
+// the rotators are released again.
+func ReleaseSpeed(speed float64) func(*Guard) {
+	return func(g *Guard) {
+		g.release = speed
+	}
+}
+
+// HoldTime is a functional option to set the time the wind speed has to
+// stay below the release speed before the rotators are released.
+func HoldTime(d time.Duration) func(*Guard) {
+	return func(g *Guard) {
+		g.holdTime = d
+	}
+}
+
+// Add adds a rotator which is parked by the Guard.
+func (g *Guard) Add(p *Parker) {
+	g.Lock()
+	defer g.Unlock()
+	g.parkers = append(g.parkers, p)
+}
+
+// Start starts reading the wind speed from the source.
+func (g *Guard) Start() error {
+	return g.source.Start(g.handle)
+}
+
+// Close stops reading the wind speed. Parked rotators remain parked.
+func (g *Guard) Close() {
+	g.source.Close()
+}
+
+// Last returns the latest reading of the weather station.
+func (g *Guard) Last() (Reading, bool) {
+	g.Lock()
+	defer g.Unlock()
+	return g.last, g.hasReading
+}
+
+// handle evaluates a reading of the weather station.
+func (g *Guard) handle(rd Reading) {
+	g.Lock()
+
+	g.last = rd
+	g.hasReading = true
+
+	var park, release bool
+	var reason string
+
+	switch {
+	case rd.Speed >= g.threshold:
+		g.calmSince = time.Time{}
+		g.parked = true
+		park = true
+		reason = fmt.Sprintf("wind speed %.1f exceeds %.1f", rd.Speed, g.threshold)
+	case g.parked && rd.Speed <= g.release:
+		if g.calmSince.IsZero() {
+			g.calmSince = rd.Time
+		}
+		if rd.Time.Sub(g.calmSince) >= g.holdTime {
+			g.parked = false
+			release = true
+			reason = fmt.Sprintf("wind speed below %.1f for %v", g.release, g.holdTime)
+		} else {
+			park = true
+		}
+	case g.parked:
+		g.calmSince = time.Time{}
+		park = true
+	}
+
+	if park && reason == "" {
+		reason = fmt.Sprintf("wind speed %.1f exceeds %.1f", rd.Speed, g.threshold)
+	}
+
+	parkers := make([]*Parker, len(g.parkers))
+	copy(parkers, g.parkers)
+	g.Unlock()
+
+	for _, p := range parkers {
+		switch {
+		case park:
+			p.park(rd, reason)
+		case release:
+			p.release(reason)
+		}
+	}
+}
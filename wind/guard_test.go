@@ -0,0 +1,164 @@
+package wind
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dh1tw/remoteRotator/rotator"
+	"github.com/dh1tw/remoteRotator/rotator/rotatortest"
+)
+
+// newFakeRotator returns an az/el rotator at the azimuth which reaches
+// its presets instantly.
+func newFakeRotator(az int) *rotatortest.Rotator {
+	return rotatortest.New(
+		rotator.Config{HasAzimuth: true, HasElevation: true, AzimuthMax: 360, ElevationMax: 180},
+		rotatortest.Instant(), rotatortest.Heading(az, 0))
+}
+
+// fakeSource delivers the readings pushed by the test
+type fakeSource struct {
+	handler func(Reading)
+}
+
+func (s *fakeSource) Start(h func(Reading)) error {
+	s.handler = h
+	return nil
+}
+
+func (s *fakeSource) Close() {}
+
+func reading(speed, dir float64, t time.Time) Reading {
+	return Reading{Speed: speed, Direction: &dir, Time: t}
+}
+
+func TestGuard(t *testing.T) {
+	f := newFakeRotator(10)
+	statuses := []rotator.Status{}
+	p, _ := NewParker(f, ParkAzimuth(180), ParkElevation(90),
+		StatusHandler(func(r rotator.Rotator, s rotator.Status) {
+			statuses = append(statuses, s)
+		}))
+
+	src := &fakeSource{}
+	g, err := NewGuard(src, 20, ReleaseSpeed(15), HoldTime(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.Add(p)
+	if err := g.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	src.handler(reading(10, 0, now))
+	if p.Parked() {
+		t.Fatal("rotator must not be parked")
+	}
+
+	src.handler(reading(25, 0, now))
+	if !p.Parked() || f.Azimuth() != 180 || f.Elevation() != 90 {
+		t.Fatalf("expected rotator to be parked at 180/90, got %d/%d", f.Azimuth(), f.Elevation())
+	}
+	if len(statuses) != 1 || statuses[0].Code != Parked || !statuses[0].Locked {
+		t.Fatalf("expected parked status, got %v", statuses)
+	}
+	if err := p.SetAzimuth(20); err != ErrParked {
+		t.Fatalf("expected ErrParked, got %v", err)
+	}
+	if len(p.Serialize().Status) != 1 {
+		t.Fatal("expected parked status in serialized rotator")
+	}
+
+	// the rotator is turned back into the park heading after a stop
+	f.SetAzimuth(100)
+	src.handler(reading(18, 0, now.Add(time.Second)))
+	if f.Azimuth() != 180 {
+		t.Fatalf("expected rotator to be turned back to 180, got %d", f.Azimuth())
+	}
+
+	// hold time
+	src.handler(reading(10, 0, now.Add(time.Second*2)))
+	src.handler(reading(10, 0, now.Add(time.Second*30)))
+	if !p.Parked() {
+		t.Fatal("expected rotator to remain parked during the hold time")
+	}
+	src.handler(reading(10, 0, now.Add(time.Second*62)))
+	if p.Parked() {
+		t.Fatal("expected rotator to be released")
+	}
+	if statuses[len(statuses)-1].Code != Released {
+		t.Fatalf("expected released status, got %v", statuses)
+	}
+	if err := p.SetAzimuth(20); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParkBroadside(t *testing.T) {
+
+	tt := []struct {
+		name    string
+		current int
+		dir     float64
+		expAz   int
+	}{
+		{"broadside", 100, 0, 90},
+		{"opposite side closer", 250, 0, 270},
+		{"wrap", 10, 300, 30},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			f := newFakeRotator(tc.current)
+			p, _ := NewParker(f, Offset(90))
+
+			p.park(reading(30, tc.dir, time.Now()), "test")
+			if f.Azimuth() != tc.expAz {
+				t.Fatalf("expected azimuth %d, got %d", tc.expAz, f.Azimuth())
+			}
+		})
+	}
+}
+
+func TestFeeds(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "weewx.json")
+	if err := os.WriteFile(path, []byte(`{"current": {"windSpeed": "21.0"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data": {"conditions": [{"wind_speed_last": 7}]}}`))
+	}))
+	defer srv.Close()
+
+	tt := []struct {
+		name     string
+		feed     *Feed
+		expSpeed float64
+	}{
+		{"file", NewFileFeed(path, Interval(time.Hour)), 21},
+		{"http", NewHTTPFeed(srv.URL, Interval(time.Hour)), 7},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			defer tc.feed.Close()
+			var rd Reading
+			if err := tc.feed.Start(func(r Reading) { rd = r }); err != nil {
+				t.Fatal(err)
+			}
+			if rd.Speed != tc.expSpeed {
+				t.Fatalf("expected speed %v, got %v", tc.expSpeed, rd.Speed)
+			}
+		})
+	}
+
+	if err := NewFileFeed(filepath.Join(t.TempDir(), "missing")).Start(func(Reading) {}); err == nil {
+		t.Fatal("expected error for missing file")
+	}
+}
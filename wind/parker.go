@@ -0,0 +1,268 @@
+package wind
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/dh1tw/remoteRotator/rotator"
+)
+
+// Status codes raised by the parker
+const (
+	Parked   = "wind_parked"
+	Released = "wind_released"
+)
+
+// source is the name under which the parker reports its status
+const source = "wind"
+
+// ErrParked is returned for moves while the rotator is parked.
+var ErrParked = errors.New("rotator parked due to high wind")
+
+// Parker wraps a rotator.Rotator and turns it into its wind-park heading
+// when instructed by the Guard. While parked, all moves are refused;
+// stop commands are always forwarded. Parker implements the
+// rotator.Rotator interface itself.
+type Parker struct {
+	rotator.Rotator
+	sync.Mutex
+	statusHandler rotator.StatusHandler
+	azimuth       *int
+	elevation     *int
+	offset        *int
+	tolerance     int
+	parked        bool
+	azTarget      *int
+	status        *rotator.Status
+}
+
+// NewParker returns a Parker for the rotator. Configuration settings can
+// be set through functional options. If neither a park azimuth nor an
+// offset is set, the rotator keeps its azimuth while parked.
+// Default settings are:
+// tolerance: 15. (deg)
+func NewParker(r rotator.Rotator, opts ...func(*Parker)) (*Parker, error) {
+
+	p := &Parker{
+		Rotator:   r,
+		tolerance: 15,
+	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	return p, nil
+}
+
+// StatusHandler is a functional option to set the callback which is
+// executed when the rotator is parked or released.
+func StatusHandler(h rotator.StatusHandler) func(*Parker) {
+	return func(p *Parker) {
+		p.statusHandler = h
+	}
+}
+
+// ParkAzimuth is a functional option to set the fixed wind-park azimuth.
+// If an offset is set as well, the fixed azimuth is only used when the
+// weather station doesn't report the wind direction.
+func ParkAzimuth(az int) func(*Parker) {
+	return func(p *Parker) {
+		p.azimuth = &az
+	}
+}
+
+// ParkElevation is a functional option to set the wind-park elevation.
+func ParkElevation(el int) func(*Parker) {
+	return func(p *Parker) {
+		p.elevation = &el
+	}
+}
+
+// Offset is a functional option to park the rotator relative to the
+// wind direction (e.g. 90 for broadside to the wind). Antennas are
+// assumed to be symmetric, so the rotator takes the closer one of the
+// headings offset and offset + 180°.
+func Offset(deg int) func(*Parker) {
+	return func(p *Parker) {
+		p.offset = &deg
+	}
+}
+
+// Tolerance is a functional option to set the change of the wind
+// direction (in degrees) after which a parked rotator is re-aligned.
+func Tolerance(deg int) func(*Parker) {
+	return func(p *Parker) {
+		p.tolerance = deg
+	}
+}
+
+// park turns the rotator into its wind-park heading and locks out all
+// other moves. It is called for every reading while the wind is too
+// strong, so that the rotator follows the wind direction and is turned
+// back into the park heading if it has been stopped.
+func (p *Parker) park(rd Reading, reason string) {
+
+	current := p.Rotator.Serialize().Heading
+
+	p.Lock()
+	first := !p.parked
+	p.parked = true
+
+	az := p.parkAzimuth(rd, current.Azimuth)
+	send := first
+	if az != nil {
+		if p.azTarget == nil || rotator.AzDiff(*az, *p.azTarget) > p.tolerance {
+			p.azTarget = az
+			send = true
+		}
+		// e.g. the rotator has been stopped on the way
+		if rotator.AzDiff(current.AzPreset, *p.azTarget) > 2 {
+			send = true
+		}
+	}
+	az = p.azTarget
+
+	if first {
+		p.status = &rotator.Status{
+			Source:  source,
+			Level:   rotator.StatusWarning,
+			Code:    Parked,
+			Message: reason,
+			Locked:  true,
+		}
+	}
+	status := p.status
+	p.Unlock()
+
+	if first {
+		log.Printf("%s: %s; parking rotator\n", p.Name(), reason)
+		p.emit(*status)
+	}
+
+	if !send {
+		return
+	}
+
+	if az != nil && p.Rotator.HasAzimuth() {
+		if err := p.Rotator.SetAzimuth(*az); err != nil {
+			log.Printf("unable to park %s at azimuth %d: %v\n", p.Name(), *az, err)
+		}
+	}
+	if p.elevation != nil && p.Rotator.HasElevation() {
+		if err := p.Rotator.SetElevation(*p.elevation); err != nil {
+			log.Printf("unable to park %s at elevation %d: %v\n", p.Name(), *p.elevation, err)
+		}
+	}
+}
+
+// parkAzimuth returns the wind-park azimuth for the reading. Must be
+// called with the lock held.
+func (p *Parker) parkAzimuth(rd Reading, current int) *int {
+
+	if p.offset == nil || rd.Direction == nil {
+		return p.azimuth
+	}
+
+	az := rotator.NormAzimuth(int(*rd.Direction) + *p.offset)
+	opposite := rotator.NormAzimuth(az + 180)
+	if rotator.AzDiff(opposite, current) < rotator.AzDiff(az, current) {
+		az = opposite
+	}
+
+	return &az
+}
+
+// release unlocks the rotator after the wind has calmed down.
+func (p *Parker) release(reason string) {
+	p.Lock()
+	if !p.parked {
+		p.Unlock()
+		return
+	}
+	p.parked = false
+	p.azTarget = nil
+	p.status = nil
+	p.Unlock()
+
+	log.Printf("%s: %s; releasing rotator\n", p.Name(), reason)
+
+	p.emit(rotator.Status{
+		Source:  source,
+		Level:   rotator.StatusInfo,
+		Code:    Released,
+		Message: reason,
+	})
+}
+
+// emit reports a status through the status handler.
+func (p *Parker) emit(s rotator.Status) {
+	if p.statusHandler != nil {
+		p.statusHandler(p, s)
+	}
+}
+
+// Parked returns true if the rotator is parked.
+func (p *Parker) Parked() bool {
+	p.Lock()
+	defer p.Unlock()
+	return p.parked
+}
+
+// CanMove returns ErrParked while the rotator is parked.
+func (p *Parker) CanMove() error {
+	if p.Parked() {
+		return ErrParked
+	}
+	return nil
+}
+
+// SetAzimuth forwards the azimuth to the rotator unless it is parked.
+func (p *Parker) SetAzimuth(az int) error {
+	if p.Parked() {
+		return ErrParked
+	}
+	return p.Rotator.SetAzimuth(az)
+}
+
+// SetElevation forwards the elevation to the rotator unless it is
+// parked.
+func (p *Parker) SetElevation(el int) error {
+	if p.Parked() {
+		return ErrParked
+	}
+	return p.Rotator.SetElevation(el)
+}
+
+// Serialize the data of the rotator. The wind-park status is added to
+// the status of the rotator.
+func (p *Parker) Serialize() rotator.Object {
+	obj := p.Rotator.Serialize()
+
+	p.Lock()
+	defer p.Unlock()
+	if p.status != nil {
+		obj.Status = append(obj.Status, *p.status)
+	}
+
+	return obj
+}
+
+// Unwrap returns the parked rotator.
+func (p *Parker) Unwrap() rotator.Rotator {
+	return p.Rotator
+}
+
+// String returns a short description of the configured park heading.
+func (p *Parker) String() string {
+	switch {
+	case p.offset != nil:
+		return fmt.Sprintf("%d° to the wind direction", *p.offset)
+	case p.azimuth != nil:
+		return fmt.Sprintf("azimuth %d°", *p.azimuth)
+	default:
+		return "current azimuth"
+	}
+}
@@ -0,0 +1,147 @@
+// Package wind provides a safety subsystem which parks the rotators when
+// the wind speed reported by a local weather station exceeds a threshold.
+// The wind speed can be read from a JSON file (e.g. written by WeeWX or a
+// Davis WeatherLink), polled from an HTTP endpoint or received on an MQTT
+// topic. While the wind is too strong, the rotators are turned into their
+// wind-park heading and all other moves are refused.
+package wind
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Reading is a wind measurement of the weather station. The speed is in
+// the unit of the weather station; the direction (if available) is the
+// direction from which the wind blows (in degrees).
+type Reading struct {
+	Speed     float64
+	Direction *float64
+	Time      time.Time
+}
+
+// default keys under which weather stations report the wind speed and
+// direction (WeeWX, Davis WeatherLink Live, ...), in order of priority
+var (
+	speedKeys     = []string{"windSpeed", "wind_speed", "wind_speed_last", "windspeed"}
+	directionKeys = []string{"windDir", "wind_dir", "wind_dir_last", "winddir"}
+)
+
+// Parse extracts a Reading from a JSON document. The keys can either be
+// a plain key, which is searched in the whole document, or a dot
+// separated path (e.g. "current.windSpeed.value"). If a key is empty, the
+// keys commonly used by weather stations are tried. A document which
+// only contains a number is interpreted as the wind speed.
+func Parse(data []byte, speedKey, directionKey string) (Reading, error) {
+
+	rd := Reading{Time: time.Now()}
+
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		// e.g. a plain (non JSON) MQTT payload
+		speed, ok := toFloat(strings.TrimSpace(string(data)))
+		if !ok {
+			return rd, fmt.Errorf("invalid wind data: %v", err)
+		}
+		rd.Speed = speed
+		return rd, nil
+	}
+
+	if speed, ok := toFloat(doc); ok {
+		rd.Speed = speed
+		return rd, nil
+	}
+
+	speed, ok := lookup(doc, speedKey, speedKeys)
+	if !ok {
+		return rd, fmt.Errorf("wind speed not found")
+	}
+	rd.Speed = speed
+
+	if dir, ok := lookup(doc, directionKey, directionKeys); ok {
+		rd.Direction = &dir
+	}
+
+	return rd, nil
+}
+
+// lookup returns the numeric value stored under the key (or one of the
+// default keys if the key is empty).
+func lookup(doc interface{}, key string, defaults []string) (float64, bool) {
+
+	if strings.Contains(key, ".") {
+		v := doc
+		for _, k := range strings.Split(key, ".") {
+			m, ok := v.(map[string]interface{})
+			if !ok {
+				return 0, false
+			}
+			if v, ok = m[k]; !ok {
+				return 0, false
+			}
+		}
+		return toFloat(v)
+	}
+
+	keys := defaults
+	if key != "" {
+		keys = []string{key}
+	}
+
+	for _, k := range keys {
+		if v, ok := search(doc, k); ok {
+			return v, true
+		}
+	}
+
+	return 0, false
+}
+
+// search looks recursively for the first numeric value stored under
+// the key.
+func search(v interface{}, key string) (float64, bool) {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		if val, ok := t[key]; ok {
+			if f, ok := toFloat(val); ok {
+				return f, true
+			}
+		}
+		for _, val := range t {
+			if f, ok := search(val, key); ok {
+				return f, true
+			}
+		}
+	case []interface{}:
+		for _, val := range t {
+			if f, ok := search(val, key); ok {
+				return f, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// toFloat converts a JSON value into a number. Numbers might be encoded
+// as strings with a unit (e.g. "12.5 km/h") or as object with a "value".
+func toFloat(v interface{}) (float64, bool) {
+	switch t := v.(type) {
+	case float64:
+		return t, true
+	case string:
+		fields := strings.Fields(t)
+		if len(fields) == 0 {
+			return 0, false
+		}
+		f, err := strconv.ParseFloat(fields[0], 64)
+		return f, err == nil
+	case map[string]interface{}:
+		if val, ok := t["value"]; ok {
+			return toFloat(val)
+		}
+	}
+	return 0, false
+}
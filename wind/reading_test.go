@@ -0,0 +1,50 @@
+package wind
+
+import "testing"
+
+func TestParse(t *testing.T) {
+
+	tt := []struct {
+		name         string
+		data         string
+		speedKey     string
+		directionKey string
+		expSpeed     float64
+		expDir       float64 // -1 = no direction
+		expErr       bool
+	}{
+		{"weewx", `{"current": {"windSpeed": "12.5 km/h", "windDir": "270"}}`, "", "", 12.5, 270, false},
+		{"davis weatherlink live", `{"data": {"conditions": [{"lsid": 1, "wind_speed_last": 8.2, "wind_dir_last": 90}]}}`, "", "", 8.2, 90, false},
+		{"value object", `{"windSpeed": {"value": 3, "units": "m/s"}}`, "", "", 3, -1, false},
+		{"path", `{"current": {"windGust": {"value": 20}, "windSpeed": {"value": 10}}}`, "current.windGust.value", "", 20, -1, false},
+		{"custom key", `{"gust": 17}`, "gust", "", 17, -1, false},
+		{"plain number", `14.2`, "", "", 14.2, -1, false},
+		{"plain number with unit", `14.2 km/h`, "", "", 14.2, -1, false},
+		{"missing speed", `{"temperature": 12}`, "", "", 0, -1, true},
+		{"invalid", `{"windSpeed": `, "", "", 0, -1, true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			rd, err := Parse([]byte(tc.data), tc.speedKey, tc.directionKey)
+			if tc.expErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rd.Speed != tc.expSpeed {
+				t.Fatalf("expected speed %v, got %v", tc.expSpeed, rd.Speed)
+			}
+			if tc.expDir < 0 && rd.Direction != nil {
+				t.Fatalf("expected no direction, got %v", *rd.Direction)
+			}
+			if tc.expDir >= 0 && (rd.Direction == nil || *rd.Direction != tc.expDir) {
+				t.Fatalf("expected direction %v, got %v", tc.expDir, rd.Direction)
+			}
+		})
+	}
+}
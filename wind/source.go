@@ -0,0 +1,234 @@
+package wind
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// Source delivers the readings of a weather station.
+type Source interface {
+	// Start delivers the readings to the handler until the source
+	// is closed.
+	Start(handler func(Reading)) error
+	Close()
+}
+
+// Feed is a Source reading the wind speed from a JSON file, an HTTP
+// endpoint or an MQTT topic.
+type Feed struct {
+	address      string
+	topic        string
+	fetch        func() ([]byte, error) // file and http feeds
+	interval     time.Duration
+	speedKey     string
+	directionKey string
+	username     string
+	password     string
+	client       mqtt.Client
+	closeCh      chan struct{}
+	closer       sync.Once
+}
+
+// NewFileFeed returns a Feed which periodically reads the wind speed
+// from a JSON file (e.g. written by WeeWX).
+// Default settings are:
+// interval: 10s.
+func NewFileFeed(path string, opts ...func(*Feed)) *Feed {
+	f := newFeed(path, opts...)
+	f.fetch = func() ([]byte, error) {
+		return os.ReadFile(path)
+	}
+	return f
+}
+
+// NewHTTPFeed returns a Feed which periodically polls the wind speed
+// from an HTTP endpoint returning a JSON document (e.g. a Davis
+// WeatherLink Live).
+// Default settings are:
+// interval: 10s.
+func NewHTTPFeed(url string, opts ...func(*Feed)) *Feed {
+	f := newFeed(url, opts...)
+	client := &http.Client{Timeout: time.Second * 5}
+
+	f.fetch = func() ([]byte, error) {
+		resp, err := client.Get(url)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("unexpected status %s", resp.Status)
+		}
+		return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	}
+	return f
+}
+
+// NewMQTTFeed returns a Feed which subscribes to an MQTT topic on the
+// broker (e.g. tcp://127.0.0.1:1883). The payload can either be a JSON
+// document or a plain number (wind speed).
+func NewMQTTFeed(broker, topic string, opts ...func(*Feed)) *Feed {
+	f := newFeed(broker, opts...)
+	f.topic = topic
+	return f
+}
+
+func newFeed(address string, opts ...func(*Feed)) *Feed {
+	f := &Feed{
+		address:  address,
+		interval: time.Second * 10,
+		closeCh:  make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(f)
+	}
+
+	return f
+}
+
+// Interval is a functional option to set the interval in which a file
+// or HTTP feed is polled.
+func Interval(d time.Duration) func(*Feed) {
+	return func(f *Feed) {
+		f.interval = d
+	}
+}
+
+// SpeedKey is a functional option to set the key (or dot separated
+// path) of the wind speed in the JSON document.
+func SpeedKey(key string) func(*Feed) {
+	return func(f *Feed) {
+		f.speedKey = key
+	}
+}
+
+// DirectionKey is a functional option to set the key (or dot separated
+// path) of the wind direction in the JSON document.
+func DirectionKey(key string) func(*Feed) {
+	return func(f *Feed) {
+		f.directionKey = key
+	}
+}
+
+// Username is a functional option to set the username for the MQTT
+// broker.
+func Username(username string) func(*Feed) {
+	return func(f *Feed) {
+		f.username = username
+	}
+}
+
+// Password is a functional option to set the password for the MQTT
+// broker.
+func Password(password string) func(*Feed) {
+	return func(f *Feed) {
+		f.password = password
+	}
+}
+
+// Start delivers the readings of the feed to the handler in the
+// background. File and HTTP feeds are read once before Start returns,
+// so that invalid feeds are reported early.
+func (f *Feed) Start(handler func(Reading)) error {
+	if f.fetch == nil {
+		return f.subscribe(handler)
+	}
+	return f.poll(handler)
+}
+
+func (f *Feed) poll(handler func(Reading)) error {
+
+	if f.interval <= 0 {
+		return fmt.Errorf("invalid polling interval %v", f.interval)
+	}
+
+	rd, err := f.read()
+	if err != nil {
+		return err
+	}
+	handler(rd)
+
+	go func() {
+		ticker := time.NewTicker(f.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				rd, err := f.read()
+				if err != nil {
+					log.Printf("unable to read wind speed from %s: %v\n", f.address, err)
+					continue
+				}
+				handler(rd)
+			case <-f.closeCh:
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (f *Feed) read() (Reading, error) {
+	data, err := f.fetch()
+	if err != nil {
+		return Reading{}, err
+	}
+	return Parse(data, f.speedKey, f.directionKey)
+}
+
+func (f *Feed) subscribe(handler func(Reading)) error {
+
+	msgHandler := func(c mqtt.Client, msg mqtt.Message) {
+		rd, err := Parse(msg.Payload(), f.speedKey, f.directionKey)
+		if err != nil {
+			log.Printf("unable to read wind speed from %s: %v\n", msg.Topic(), err)
+			return
+		}
+		handler(rd)
+	}
+
+	opts := mqtt.NewClientOptions().
+		AddBroker(f.address).
+		SetUsername(f.username).
+		SetPassword(f.password).
+		SetAutoReconnect(true).
+		SetConnectTimeout(time.Second * 10).
+		// (re-)subscribe whenever the connection has been established
+		SetOnConnectHandler(func(c mqtt.Client) {
+			t := c.Subscribe(f.topic, 0, msgHandler)
+			if t.Wait() && t.Error() != nil {
+				log.Printf("unable to subscribe to %s: %v\n", f.topic, t.Error())
+			}
+		}).
+		SetConnectionLostHandler(func(c mqtt.Client, err error) {
+			log.Printf("connection to mqtt broker %s lost: %v\n", f.address, err)
+		})
+
+	f.client = mqtt.NewClient(opts)
+	t := f.client.Connect()
+	if t.Wait() && t.Error() != nil {
+		return fmt.Errorf("unable to connect to mqtt broker %s: %v", f.address, t.Error())
+	}
+
+	return nil
+}
+
+// Close stops the feed.
+func (f *Feed) Close() {
+	f.closer.Do(func() {
+		close(f.closeCh)
+		if f.client != nil {
+			f.client.Disconnect(250)
+		}
+	})
+}